@@ -2,12 +2,16 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
+	"errors"
+	"flag"
 	"fmt"
 	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
+	"text/tabwriter"
 	"time"
 
 	"github.com/redis/go-redis/v9"
@@ -16,11 +20,18 @@ import (
 	"github.com/timkrebs/image-processor/internal/config"
 	"github.com/timkrebs/image-processor/internal/database"
 	"github.com/timkrebs/image-processor/internal/metrics"
+	"github.com/timkrebs/image-processor/internal/migrate"
+	"github.com/timkrebs/image-processor/internal/models"
 	"github.com/timkrebs/image-processor/internal/queue"
 	"github.com/timkrebs/image-processor/internal/storage"
 )
 
 func main() {
+	migrateAction := flag.String("migrate", "", "run database migrations and exit: up, down, status or force")
+	migrateVersion := flag.Int("migrate-version", -1,
+		"target version for -migrate (up: latest, down: previous; required for force)")
+	flag.Parse()
+
 	// Setup logger
 	logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
 		Level: slog.LevelInfo,
@@ -47,6 +58,29 @@ func main() {
 	}()
 	logger.Info("connected to database")
 
+	migrator, err := migrate.New(db.DB, logger)
+	if err != nil {
+		logger.Error("failed to load migrations", "error", err)
+		os.Exit(1)
+	}
+	if *migrateAction != "" {
+		if err := runMigrations(context.Background(), migrator, *migrateAction, *migrateVersion); err != nil {
+			logger.Error("migration failed", "action", *migrateAction, "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Refuse to serve against a schema older than this binary expects
+	if err := migrator.Check(context.Background()); err != nil {
+		if errors.Is(err, migrate.ErrSchemaOutdated) {
+			logger.Error("database schema is outdated, run the api with -migrate=up", "error", err)
+		} else {
+			logger.Error("failed to check database schema", "error", err)
+		}
+		os.Exit(1)
+	}
+
 	// Create job repository
 	jobRepo := database.NewJobRepository(db)
 
@@ -74,47 +108,94 @@ func main() {
 	// Create queue producer
 	producer := queue.NewProducer(redisClient, cfg.QueueStreamName)
 
-	// Connect to MinIO
-	storageClient, err := storage.New(storage.Config{
-		Endpoint:  cfg.MinIOEndpoint,
-		AccessKey: cfg.MinIOAccessKey,
-		SecretKey: cfg.MinIOSecretKey,
-		Bucket:    cfg.MinIOBucket,
-		UseSSL:    cfg.MinIOUseSSL,
-	})
-	if err != nil {
-		logger.Error("failed to create storage client", "error", err)
-		os.Exit(1)
-	}
-
-	// Ensure bucket exists
-	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
-	if err := storageClient.EnsureBucket(ctx); err != nil {
-		cancel()
-		logger.Error("failed to ensure bucket", "error", err)
-		os.Exit(1)
-	}
-	cancel()
-	logger.Info("connected to minio", "bucket", cfg.MinIOBucket)
-
 	// Initialize metrics
 	httpMetrics := metrics.NewHTTPMetrics("image_processor_api")
 	jobMetrics := metrics.NewJobMetrics("image_processor_api")
 	storageMetrics := metrics.NewStorageMetrics("image_processor_api")
 	dbMetrics := metrics.NewDatabaseMetrics("image_processor_api")
+	queueMetrics := metrics.NewQueueMetrics("image_processor_api")
 
-	// Inject metrics into storage client
-	storageClient.SetMetrics(storageMetrics)
+	// Open the storage backend
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+	storageClient, err := storage.Open(ctx, storage.Options{
+		Type:      cfg.StorageBackend,
+		LocalPath: cfg.StorageLocalPath,
+		Metrics:   storageMetrics,
+		MinIO: storage.Config{
+			Endpoint:            cfg.MinIOEndpoint,
+			AccessKey:           cfg.MinIOAccessKey,
+			SecretKey:           cfg.MinIOSecretKey,
+			Bucket:              cfg.MinIOBucket,
+			UseSSL:              cfg.MinIOUseSSL,
+			Encryption:          cfg.StorageEncryption,
+			EncryptionKeys:      cfg.StorageEncryptionKeys,
+			ProcessedBucket:     cfg.MinIOProcessedBucket,
+			OriginalsPrefix:     cfg.StorageOriginalsPrefix,
+			ProcessedPrefix:     cfg.StorageProcessedPrefix,
+			OriginalsExpiryDays: cfg.StorageOriginalsExpiryDays,
+			ProcessedExpiryDays: cfg.StorageProcessedExpiryDays,
+		},
+	})
+	cancel()
+	if err != nil {
+		logger.Error("failed to open storage", "backend", cfg.StorageBackend, "error", err)
+		os.Exit(1)
+	}
+	logger.Info("storage ready", "backend", cfg.StorageBackend)
 
 	// Inject metrics into database
 	db.SetMetrics(dbMetrics)
 
+	// Inject metrics into queue producer
+	producer.SetMetrics(queueMetrics)
+
 	// Create session store with 24 hour TTL
 	sessionStore := api.NewSessionStore(24 * time.Hour)
+	sessionStore.SetUsers(db)
 
 	// Create handlers
 	handlers := api.NewHandlers(jobRepo, storageClient, producer, db, cfg.QueueConsumerGroup, logger)
 	handlers.SetMetrics(jobMetrics)
+	handlers.SetScheduler(queue.NewScheduler(redisClient, producer, cfg.QueueStreamName))
+	if cfg.RateLimitPerMinute > 0 {
+		handlers.SetRateLimiter(api.NewRateLimiter(redisClient, "ratelimit:jobs:", cfg.RateLimitPerMinute, cfg.RateLimitBurst))
+	}
+	if err := handlers.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		logger.Error("invalid trusted proxies", "error", err)
+		os.Exit(1)
+	}
+	handlers.SetUploadLimits(cfg.MaxUploadSize, cfg.UploadURLExpiry, cfg.ResumableUploadTTL)
+	if err := handlers.SetImageDelivery(cfg.ImageDelivery, cfg.ImageURLExpiry, cfg.ImageCacheMaxAge); err != nil {
+		logger.Error("invalid image delivery config", "error", err)
+		os.Exit(1)
+	}
+	if cfg.TransformSigningKey != "" {
+		handlers.SetTransforms([]byte(cfg.TransformSigningKey), cfg.TransformConcurrency, cfg.TransformMaxDimension)
+	}
+	handlers.SetCompareConcurrency(cfg.CompareConcurrency)
+	handlers.SetBatchLimits(cfg.BatchMaxFiles, cfg.BatchMaxUploadSize)
+	handlers.SetOriginalDeletion(cfg.AllowDeleteOriginal)
+	handlers.SetQuotas(models.QuotaLimits{
+		JobsPerDay:  cfg.QuotaJobsPerDay,
+		BytesStored: cfg.QuotaBytesStored,
+		ActiveJobs:  cfg.QuotaActiveJobs,
+	})
+
+	if cfg.AllowAnonymous {
+		secret := []byte(cfg.AnonymousCookieSecret)
+		if len(secret) == 0 {
+			// Identities only survive until restart and are not shared between replicas
+			secret = make([]byte, 32)
+			if _, err := rand.Read(secret); err != nil {
+				logger.Error("failed to generate anonymous cookie secret", "error", err)
+				os.Exit(1)
+			}
+			logger.Warn("ANONYMOUS_COOKIE_SECRET not set, using a random per-process secret")
+		}
+		handlers.SetAnonymousIdentity(api.NewAnonymousIdentity(secret, cfg.AnonymousCookieTTL))
+	} else {
+		logger.Info("anonymous job submission disabled")
+	}
 
 	// Create router
 	router := api.NewRouter(handlers, httpMetrics, cfg.MaxUploadSize, db, sessionStore, logger)
@@ -152,3 +233,43 @@ func main() {
 
 	logger.Info("server stopped")
 }
+
+// runMigrations executes a -migrate action against the database
+func runMigrations(ctx context.Context, migrator *migrate.Migrator, action string, version int) error {
+	switch action {
+	case "up":
+		applied, err := migrator.Up(ctx, version)
+		if err != nil {
+			return err
+		}
+		slog.Info("migrations applied", "count", applied)
+	case "down":
+		rolledBack, err := migrator.Down(ctx, version)
+		if err != nil {
+			return err
+		}
+		slog.Info("migrations rolled back", "count", rolledBack)
+	case "force":
+		if version < 0 {
+			return errors.New("-migrate=force requires -migrate-version")
+		}
+		if err := migrator.Force(ctx, version); err != nil {
+			return err
+		}
+		slog.Info("schema version forced", "version", version)
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		_, _ = fmt.Fprintln(tw, "VERSION\tNAME\tAPPLIED")
+		for _, status := range statuses {
+			_, _ = fmt.Fprintf(tw, "%03d\t%s\t%t\n", status.Version, status.Name, status.Applied)
+		}
+		return tw.Flush()
+	default:
+		return fmt.Errorf("unknown -migrate action %q (want up, down, status or force)", action)
+	}
+	return nil
+}
@@ -0,0 +1,361 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/timkrebs/image-processor/internal/api"
+	"github.com/timkrebs/image-processor/internal/cleanup"
+	"github.com/timkrebs/image-processor/internal/database"
+	"github.com/timkrebs/image-processor/internal/models"
+	"github.com/timkrebs/image-processor/internal/queue"
+)
+
+// terminalStatuses are the statuses of jobs no worker will touch again
+var terminalStatuses = []models.JobStatus{
+	models.JobStatusCompleted,
+	models.JobStatusFailed,
+	models.JobStatusCancelled,
+}
+
+func (a *app) jobs(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%w: missing jobs subcommand", errUsage)
+	}
+
+	switch args[0] {
+	case "list":
+		return a.jobsList(ctx, args[1:])
+	case "get":
+		return a.jobsGet(ctx, args[1:])
+	case "retry":
+		return a.jobsRetry(ctx, args[1:])
+	case "cancel":
+		return a.jobsCancel(ctx, args[1:])
+	case "purge":
+		return a.jobsPurge(ctx, args[1:])
+	default:
+		return fmt.Errorf("%w: unknown jobs subcommand %q", errUsage, args[0])
+	}
+}
+
+func (a *app) jobsList(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("jobs list", flag.ContinueOnError)
+	statuses := fs.String("status", "", "comma separated statuses to list")
+	user := fs.String("user", "", "only list jobs of this user ID")
+	limit := fs.Int("limit", 50, "maximum number of jobs")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+
+	filter, err := jobFilter(*statuses, *user, *limit)
+	if err != nil {
+		return err
+	}
+
+	db, err := a.db()
+	if err != nil {
+		return err
+	}
+	jobs, err := database.NewJobRepository(db).ListJobs(ctx, filter)
+	if err != nil {
+		return err
+	}
+
+	return a.printJobs(jobs)
+}
+
+func (a *app) jobsGet(ctx context.Context, args []string) error {
+	id, err := jobIDArg(args)
+	if err != nil {
+		return err
+	}
+
+	db, err := a.db()
+	if err != nil {
+		return err
+	}
+	job, err := database.NewJobRepository(db).GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	return a.print(job, func(w io.Writer) {
+		row(w, "ID:", job.ID)
+		row(w, "Status:", job.Status)
+		row(w, "Priority:", job.Priority)
+		row(w, "User:", job.UserID)
+		row(w, "Name:", job.OriginalName)
+		row(w, "Content type:", job.ContentType)
+		row(w, "Size:", job.FileSize)
+		row(w, "Original:", job.OriginalKey)
+		if job.OriginalDeletedAt != nil {
+			row(w, "Original deleted:", formatTime(job.OriginalDeletedAt))
+		}
+		if job.ProcessedKey != "" {
+			row(w, "Processed:", job.ProcessedKey)
+		}
+		if job.OutputSize != nil {
+			row(w, "Output size:", *job.OutputSize)
+		}
+		if job.CompressionRatio != nil {
+			row(w, "Compression:", fmt.Sprintf("%.2fx", *job.CompressionRatio))
+		}
+		if quality := job.Quality(); quality != nil {
+			row(w, "Quality:", fmt.Sprintf("SSIM %.4f, PSNR %.2f dB", quality.SSIM, quality.PSNR))
+		}
+		if job.Analysis != nil {
+			if job.Analysis.PHash != "" {
+				row(w, "pHash:", job.Analysis.PHash)
+			}
+			if job.Analysis.DHash != "" {
+				row(w, "dHash:", job.Analysis.DHash)
+			}
+			if job.Analysis.AverageColor != "" {
+				row(w, "Average color:", job.Analysis.AverageColor)
+			}
+			if job.Analysis.BlurHash != "" {
+				row(w, "Blurhash:", job.Analysis.BlurHash)
+			}
+		}
+		if job.BatchID != nil {
+			row(w, "Batch:", *job.BatchID)
+		}
+		if job.ParentJobID != nil {
+			row(w, "Parent:", *job.ParentJobID)
+		}
+		if job.PresetID != nil && job.PresetVersion != nil {
+			row(w, "Preset:", fmt.Sprintf("%s (v%d)", *job.PresetID, *job.PresetVersion))
+		}
+		if job.WorkerID != "" {
+			row(w, "Worker:", job.WorkerID)
+		}
+		if job.Error != "" {
+			row(w, "Error:", job.Error)
+		}
+		row(w, "Created:", formatTime(&job.CreatedAt))
+		row(w, "Run at:", formatTime(job.RunAt))
+		row(w, "Started:", formatTime(job.StartedAt))
+		row(w, "Completed:", formatTime(job.CompletedAt))
+		row(w, "Delete at:", formatTime(job.DeleteAt))
+		for i, op := range job.Operations {
+			row(w, fmt.Sprintf("Operation %d:", i+1), op.Operation)
+		}
+	})
+}
+
+// jobsRetry runs a failed or canceled job again as a new child job of its
+// owner, the way the API's retry endpoint does
+func (a *app) jobsRetry(ctx context.Context, args []string) error {
+	id, err := jobIDArg(args)
+	if err != nil {
+		return err
+	}
+
+	db, err := a.db()
+	if err != nil {
+		return err
+	}
+	store, err := a.backend(ctx)
+	if err != nil {
+		return err
+	}
+	producer, err := a.producer(ctx)
+	if err != nil {
+		return err
+	}
+	repo := database.NewJobRepository(db)
+
+	parent, err := repo.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	handlers := api.NewHandlers(repo, store, producer, db, a.cfg.QueueConsumerGroup, a.logger)
+	handlers.SetOriginalDeletion(a.cfg.AllowDeleteOriginal)
+	handlers.SetQuotas(models.QuotaLimits{
+		JobsPerDay:  a.cfg.QuotaJobsPerDay,
+		BytesStored: a.cfg.QuotaBytesStored,
+		ActiveJobs:  a.cfg.QuotaActiveJobs,
+	})
+
+	job, err := handlers.Retry(ctx, parent)
+	if err != nil {
+		return fmt.Errorf("job %s can't be retried: %w", id, err)
+	}
+
+	return a.print(map[string]any{"job_id": job.ID, "parent_job_id": id, "status": job.Status}, func(w io.Writer) {
+		row(w, "Job", job.ID, job.Status)
+		row(w, "Parent:", id)
+	})
+}
+
+// requeue resets a job in one of the given statuses and publishes it to the queue
+func (a *app) requeue(ctx context.Context, repo *database.JobRepository, id uuid.UUID, from ...models.JobStatus) error {
+	client, err := a.redisClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	job, err := repo.RequeueJob(ctx, id, from...)
+	if err != nil {
+		return err
+	}
+
+	msg := &models.JobMessage{JobID: job.ID, Priority: job.Priority, Operations: job.Operations}
+	if err := queue.NewProducer(client, a.cfg.QueueStreamName).Enqueue(ctx, msg); err != nil {
+		if failErr := repo.FailJob(ctx, id, "failed to requeue job: "+err.Error()); failErr != nil {
+			a.logger.Error("failed to mark job as failed", "job_id", id, "error", failErr)
+		}
+		return err
+	}
+	return nil
+}
+
+func (a *app) jobsCancel(ctx context.Context, args []string) error {
+	id, err := jobIDArg(args)
+	if err != nil {
+		return err
+	}
+
+	db, err := a.db()
+	if err != nil {
+		return err
+	}
+	if err := database.NewJobRepository(db).CancelJob(ctx, id); err != nil {
+		return err
+	}
+
+	// Drop the job from the scheduler so it is never dispatched
+	client, err := a.redisClient(ctx)
+	if err != nil {
+		return err
+	}
+	producer := queue.NewProducer(client, a.cfg.QueueStreamName)
+	if _, err := queue.NewScheduler(client, producer, a.cfg.QueueStreamName).Cancel(ctx, id); err != nil {
+		return fmt.Errorf("job canceled, but not removed from the scheduler: %w", err)
+	}
+
+	return a.print(map[string]any{"job_id": id, "status": models.JobStatusCancelled}, func(w io.Writer) {
+		row(w, "Job", id, "canceled")
+	})
+}
+
+// jobsPurge deletes finished jobs and their files ahead of their retention
+func (a *app) jobsPurge(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("jobs purge", flag.ContinueOnError)
+	statuses := fs.String("status", "completed,failed,canceled", "comma separated statuses to purge")
+	olderThan := fs.Duration("older-than", 30*24*time.Hour, "only purge jobs created longer ago")
+	user := fs.String("user", "", "only purge jobs of this user ID")
+	limit := fs.Int("limit", 1000, "maximum number of jobs to purge")
+	dryRun := fs.Bool("dry-run", false, "list the jobs that would be purged")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+
+	filter, err := jobFilter(*statuses, *user, *limit)
+	if err != nil {
+		return err
+	}
+	for _, status := range filter.Statuses {
+		if !slices.Contains(terminalStatuses, status) {
+			return fmt.Errorf("%w: only completed, failed and canceled jobs can be purged", errUsage)
+		}
+	}
+	if len(filter.Statuses) == 0 {
+		filter.Statuses = terminalStatuses
+	}
+	filter.CreatedBefore = time.Now().Add(-*olderThan)
+
+	db, err := a.db()
+	if err != nil {
+		return err
+	}
+	repo := database.NewJobRepository(db)
+	jobs, err := repo.ListJobs(ctx, filter)
+	if err != nil {
+		return err
+	}
+	if *dryRun {
+		return a.printJobs(jobs)
+	}
+
+	store, err := a.backend(ctx)
+	if err != nil {
+		return err
+	}
+	purged, failed := cleanup.NewWorker(repo, store, cleanup.Config{}, a.logger).Purge(ctx, jobs)
+
+	err = a.print(map[string]int{"purged": purged, "failed": failed}, func(w io.Writer) {
+		row(w, "Purged:", purged)
+		row(w, "Failed:", failed)
+	})
+	if err == nil && failed > 0 {
+		err = fmt.Errorf("%d jobs could not be purged", failed)
+	}
+	return err
+}
+
+func (a *app) printJobs(jobs []*models.Job) error {
+	if jobs == nil {
+		jobs = []*models.Job{}
+	}
+	return a.print(jobs, func(w io.Writer) {
+		row(w, "ID", "STATUS", "PRIORITY", "USER", "NAME", "CREATED")
+		for _, job := range jobs {
+			row(w, job.ID, job.Status, job.Priority, job.UserID, job.OriginalName, formatTime(&job.CreatedAt))
+		}
+	})
+}
+
+// jobFilter builds a filter from the common job selection flags
+func jobFilter(statuses, user string, limit int) (database.JobFilter, error) {
+	filter := database.JobFilter{Limit: limit}
+
+	for _, s := range strings.Split(statuses, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		status := models.JobStatus(s)
+		if !status.IsValid() {
+			return filter, fmt.Errorf("%w: unknown job status %q", errUsage, s)
+		}
+		filter.Statuses = append(filter.Statuses, status)
+	}
+
+	if user != "" {
+		id, err := uuid.Parse(user)
+		if err != nil {
+			return filter, fmt.Errorf("%w: invalid user ID %q", errUsage, user)
+		}
+		filter.UserID = &id
+	}
+
+	return filter, nil
+}
+
+// jobIDArg parses the single job ID argument of a command
+func jobIDArg(args []string) (uuid.UUID, error) {
+	if len(args) != 1 {
+		return uuid.Nil, fmt.Errorf("%w: expected a job ID", errUsage)
+	}
+	id, err := uuid.Parse(args[0])
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("%w: invalid job ID %q", errUsage, args[0])
+	}
+	return id, nil
+}
+
+// isGone reports whether err means the job no longer exists
+func isGone(err error) bool {
+	return errors.Is(err, database.ErrNotFound)
+}
@@ -0,0 +1,261 @@
+// Command imgctl operates an image-processor deployment: it inspects and repairs
+// jobs, manages users, the queue and storage, and applies database migrations.
+// It reads the same environment variables as the api and worker.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/timkrebs/image-processor/internal/config"
+	"github.com/timkrebs/image-processor/internal/database"
+	"github.com/timkrebs/image-processor/internal/storage"
+)
+
+const usage = `Usage: imgctl [-o table|json] <command> [flags] [args]
+
+Jobs:
+  jobs list [-status s,...] [-user id] [-limit n]
+  jobs get <id>
+  jobs retry <id>
+  jobs cancel <id>
+  jobs purge [-status s,...] [-older-than d] [-user id] [-limit n] [-dry-run]
+
+Users:
+  users list [-limit n] [-offset n]
+  users create -email e -username u [-password p]
+  users disable <id|email>
+  users enable <id|email>
+  users reset-password [-password p] <id|email>
+
+Queue:
+  queue stats
+  queue trim
+  queue drain
+  queue dlq list [-limit n]
+  queue dlq replay [-limit n] [-job id]
+
+Storage:
+  storage reconcile [-prefix p] [-grace d] [-delete]
+  storage rekey [-prefix p]
+
+Migrations:
+  migrate up [-version n]
+  migrate down [-version n]
+  migrate status
+  migrate force -version n
+`
+
+// errUsage is returned for invalid command lines; the usage is printed for it
+var errUsage = errors.New("invalid usage")
+
+func main() {
+	flags := flag.NewFlagSet("imgctl", flag.ContinueOnError)
+	output := flags.String("o", "table", "output format: table or json")
+	flags.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	if err := flags.Parse(os.Args[1:]); err != nil {
+		os.Exit(2)
+	}
+	if *output != "table" && *output != "json" {
+		fmt.Fprintf(os.Stderr, "imgctl: unknown output format %q\n", *output)
+		os.Exit(2)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "imgctl: failed to load config:", err)
+		os.Exit(1)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	a := &app{
+		cfg:    cfg,
+		out:    os.Stdout,
+		json:   *output == "json",
+		logger: slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelWarn})),
+	}
+	err = a.run(ctx, flags.Args())
+	a.close()
+	stop()
+
+	if errors.Is(err, errUsage) {
+		fmt.Fprintln(os.Stderr, "imgctl:", err)
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "imgctl:", err)
+		os.Exit(1)
+	}
+}
+
+// app holds the configuration and the connections opened by a command
+type app struct {
+	cfg    *config.Config
+	out    io.Writer
+	json   bool
+	logger *slog.Logger
+
+	database *database.DB
+	redis    *redis.Client
+	store    storage.Backend
+}
+
+// run dispatches a command line to its command group
+func (a *app) run(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%w: missing command", errUsage)
+	}
+
+	switch args[0] {
+	case "jobs":
+		return a.jobs(ctx, args[1:])
+	case "users":
+		return a.users(ctx, args[1:])
+	case "queue":
+		return a.queue(ctx, args[1:])
+	case "storage":
+		return a.storage(ctx, args[1:])
+	case "migrate":
+		return a.migrate(ctx, args[1:])
+	case "help":
+		fmt.Fprint(a.out, usage)
+		return nil
+	default:
+		return fmt.Errorf("%w: unknown command %q", errUsage, args[0])
+	}
+}
+
+// db connects to the database on first use
+func (a *app) db() (*database.DB, error) {
+	if a.database == nil {
+		db, err := database.New(a.cfg.DatabaseURL, 2)
+		if err != nil {
+			return nil, err
+		}
+		a.database = db
+	}
+	return a.database, nil
+}
+
+// redisClient connects to Redis on first use
+func (a *app) redisClient(ctx context.Context) (*redis.Client, error) {
+	if a.redis == nil {
+		client := redis.NewClient(&redis.Options{
+			Addr:     a.cfg.RedisAddr,
+			Password: a.cfg.RedisPassword,
+			DB:       a.cfg.RedisDB,
+		})
+		if err := client.Ping(ctx).Err(); err != nil {
+			_ = client.Close()
+			return nil, fmt.Errorf("failed to connect to redis: %w", err)
+		}
+		a.redis = client
+	}
+	return a.redis, nil
+}
+
+// backend opens the configured storage backend on first use
+func (a *app) backend(ctx context.Context) (storage.Backend, error) {
+	if a.store == nil {
+		ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		defer cancel()
+
+		store, err := storage.Open(ctx, storage.Options{
+			Type:      a.cfg.StorageBackend,
+			LocalPath: a.cfg.StorageLocalPath,
+			MinIO: storage.Config{
+				Endpoint:            a.cfg.MinIOEndpoint,
+				AccessKey:           a.cfg.MinIOAccessKey,
+				SecretKey:           a.cfg.MinIOSecretKey,
+				Bucket:              a.cfg.MinIOBucket,
+				UseSSL:              a.cfg.MinIOUseSSL,
+				Encryption:          a.cfg.StorageEncryption,
+				EncryptionKeys:      a.cfg.StorageEncryptionKeys,
+				ProcessedBucket:     a.cfg.MinIOProcessedBucket,
+				OriginalsPrefix:     a.cfg.StorageOriginalsPrefix,
+				ProcessedPrefix:     a.cfg.StorageProcessedPrefix,
+				OriginalsExpiryDays: a.cfg.StorageOriginalsExpiryDays,
+				ProcessedExpiryDays: a.cfg.StorageProcessedExpiryDays,
+			},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to open storage: %w", err)
+		}
+		a.store = store
+	}
+	return a.store, nil
+}
+
+// close releases the connections opened by the command
+func (a *app) close() {
+	if a.database != nil {
+		_ = a.database.Close()
+	}
+	if a.redis != nil {
+		_ = a.redis.Close()
+	}
+}
+
+// print writes v as indented JSON, or renders it with table as aligned columns
+func (a *app) print(v any, table func(w io.Writer)) error {
+	if a.json {
+		enc := json.NewEncoder(a.out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	}
+
+	tw := tabwriter.NewWriter(a.out, 0, 4, 2, ' ', 0)
+	table(tw)
+	return tw.Flush()
+}
+
+// row writes one tab separated table row
+func row(w io.Writer, cols ...any) {
+	for i, col := range cols {
+		if i > 0 {
+			_, _ = fmt.Fprint(w, "\t")
+		}
+		_, _ = fmt.Fprint(w, col)
+	}
+	_, _ = fmt.Fprintln(w)
+}
+
+// parseArgs parses flags that may appear before, between or after the
+// positional arguments, and returns the positional arguments
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	fs.SetOutput(io.Discard)
+
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", errUsage, fs.Name(), err)
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// formatTime renders an optional timestamp for tables
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return t.Local().Format(time.DateTime)
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/timkrebs/image-processor/internal/migrate"
+)
+
+func (a *app) migrate(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%w: missing migrate subcommand", errUsage)
+	}
+
+	fs := flag.NewFlagSet("migrate "+args[0], flag.ContinueOnError)
+	version := fs.Int("version", -1, "target version (up: latest, down: previous)")
+	if _, err := parseArgs(fs, args[1:]); err != nil {
+		return err
+	}
+
+	db, err := a.db()
+	if err != nil {
+		return err
+	}
+	migrator, err := migrate.New(db.DB, a.logger)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up(ctx, *version)
+		if err != nil {
+			return err
+		}
+		return a.printVersion(ctx, migrator, "applied", applied, "Applied:")
+	case "down":
+		rolledBack, err := migrator.Down(ctx, *version)
+		if err != nil {
+			return err
+		}
+		return a.printVersion(ctx, migrator, "rolled_back", rolledBack, "Rolled back:")
+	case "force":
+		if *version < 0 {
+			return fmt.Errorf("%w: migrate force requires -version", errUsage)
+		}
+		if err := migrator.Force(ctx, *version); err != nil {
+			return err
+		}
+		return a.printVersion(ctx, migrator, "forced", 1, "")
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		return a.print(statuses, func(w io.Writer) {
+			row(w, "VERSION", "NAME", "APPLIED")
+			for _, status := range statuses {
+				row(w, fmt.Sprintf("%03d", status.Version), status.Name, status.Applied)
+			}
+		})
+	default:
+		return fmt.Errorf("%w: unknown migrate subcommand %q", errUsage, args[0])
+	}
+}
+
+// printVersion reports the outcome of a migration and the resulting schema version
+func (a *app) printVersion(ctx context.Context, migrator *migrate.Migrator, action string, count int, label string) error {
+	version, dirty, err := migrator.Version(ctx)
+	if err != nil {
+		return err
+	}
+	return a.print(map[string]any{action: count, "version": version, "dirty": dirty}, func(w io.Writer) {
+		row(w, "Version:", version)
+		if label != "" {
+			row(w, label, count)
+		}
+	})
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/google/uuid"
+
+	"github.com/timkrebs/image-processor/internal/database"
+	"github.com/timkrebs/image-processor/internal/models"
+	"github.com/timkrebs/image-processor/internal/queue"
+)
+
+// drainConsumer is the consumer name imgctl reads with when draining the queue
+const drainConsumer = "imgctl-drain"
+
+func (a *app) queue(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%w: missing queue subcommand", errUsage)
+	}
+
+	switch args[0] {
+	case "stats":
+		return a.queueStats(ctx)
+	case "trim":
+		return a.queueTrim(ctx)
+	case "drain":
+		return a.queueDrain(ctx)
+	case "dlq":
+		if len(args) < 2 {
+			return fmt.Errorf("%w: missing dlq subcommand", errUsage)
+		}
+		switch args[1] {
+		case "list":
+			return a.dlqList(ctx, args[2:])
+		case "replay":
+			return a.dlqReplay(ctx, args[2:])
+		}
+		return fmt.Errorf("%w: unknown dlq subcommand %q", errUsage, args[1])
+	default:
+		return fmt.Errorf("%w: unknown queue subcommand %q", errUsage, args[0])
+	}
+}
+
+func (a *app) producer(ctx context.Context) (*queue.Producer, error) {
+	client, err := a.redisClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return queue.NewProducer(client, a.cfg.QueueStreamName), nil
+}
+
+func (a *app) queueStats(ctx context.Context) error {
+	client, err := a.redisClient(ctx)
+	if err != nil {
+		return err
+	}
+	producer := queue.NewProducer(client, a.cfg.QueueStreamName)
+
+	stats, err := producer.GetStats(ctx, a.cfg.QueueConsumerGroup)
+	if err != nil {
+		return err
+	}
+	stats.ScheduledJobs, err = queue.NewScheduler(client, producer, a.cfg.QueueStreamName).Count(ctx)
+	if err != nil {
+		return err
+	}
+
+	return a.print(stats, func(w io.Writer) {
+		row(w, "PRIORITY", "STREAM", "LENGTH", "PENDING")
+		for _, priority := range models.Priorities {
+			if ps := stats.Priorities[priority]; ps != nil {
+				row(w, priority, ps.Stream, ps.StreamLength, ps.PendingMessages)
+			}
+		}
+		row(w)
+		row(w, "Consumers:", stats.ConsumerCount)
+		row(w, "Scheduled:", stats.ScheduledJobs)
+		row(w, "Dead letters:", stats.DeadLetters)
+	})
+}
+
+// queueTrim drops messages every consumer group has acknowledged
+func (a *app) queueTrim(ctx context.Context) error {
+	producer, err := a.producer(ctx)
+	if err != nil {
+		return err
+	}
+	trimmed, err := producer.Trim(ctx)
+	if err != nil {
+		return err
+	}
+
+	return a.print(map[string]int64{"trimmed": trimmed}, func(w io.Writer) {
+		row(w, "Trimmed:", trimmed)
+	})
+}
+
+// queueDrain moves undelivered messages to the dead-letter stream, from where
+// they can be replayed once the workers are healthy again
+func (a *app) queueDrain(ctx context.Context) error {
+	client, err := a.redisClient(ctx)
+	if err != nil {
+		return err
+	}
+	consumer := queue.NewConsumer(client, queue.ConsumerConfig{
+		StreamName:    a.cfg.QueueStreamName,
+		ConsumerGroup: a.cfg.QueueConsumerGroup,
+		ConsumerName:  drainConsumer,
+	}, a.logger)
+	if err := consumer.EnsureGroup(ctx); err != nil {
+		return err
+	}
+
+	drained, err := consumer.Drain(ctx, "drained by imgctl")
+	if err != nil {
+		return err
+	}
+
+	return a.print(map[string]int{"drained": drained}, func(w io.Writer) {
+		row(w, "Drained:", drained)
+	})
+}
+
+func (a *app) dlqList(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("queue dlq list", flag.ContinueOnError)
+	limit := fs.Int64("limit", 100, "maximum number of messages")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+
+	producer, err := a.producer(ctx)
+	if err != nil {
+		return err
+	}
+	letters, err := producer.DeadLetters(ctx, *limit)
+	if err != nil {
+		return err
+	}
+
+	return a.print(letters, func(w io.Writer) {
+		row(w, "ID", "JOB", "STREAM", "FAILED", "REASON")
+		for _, letter := range letters {
+			job := "-"
+			if letter.Job != nil {
+				job = letter.Job.JobID.String()
+			}
+			row(w, letter.ID, job, letter.Stream, formatTime(&letter.FailedAt), letter.Reason)
+		}
+	})
+}
+
+// replayResult is what dlq replay did with one dead letter
+type replayResult struct {
+	ID     string     `json:"id"`
+	JobID  *uuid.UUID `json:"job_id,omitempty"`
+	Action string     `json:"action"`
+	Reason string     `json:"reason,omitempty"`
+}
+
+// dlqReplay requeues the jobs of dead-lettered messages. Messages of jobs that
+// finished, were canceled or no longer exist are dropped; messages that can't be
+// replayed stay on the dead-letter stream.
+func (a *app) dlqReplay(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("queue dlq replay", flag.ContinueOnError)
+	limit := fs.Int64("limit", 100, "maximum number of messages")
+	jobFlag := fs.String("job", "", "only replay messages of this job ID")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+	var only uuid.UUID
+	if *jobFlag != "" {
+		id, err := uuid.Parse(*jobFlag)
+		if err != nil {
+			return fmt.Errorf("%w: invalid job ID %q", errUsage, *jobFlag)
+		}
+		only = id
+	}
+
+	producer, err := a.producer(ctx)
+	if err != nil {
+		return err
+	}
+	db, err := a.db()
+	if err != nil {
+		return err
+	}
+	repo := database.NewJobRepository(db)
+
+	letters, err := producer.DeadLetters(ctx, *limit)
+	if err != nil {
+		return err
+	}
+
+	results := []replayResult{}
+	for _, letter := range letters {
+		if letter.Job == nil {
+			results = append(results, replayResult{ID: letter.ID, Action: "kept", Reason: "malformed message"})
+			continue
+		}
+		if only != uuid.Nil && letter.Job.JobID != only {
+			continue
+		}
+
+		result := replayResult{ID: letter.ID, JobID: &letter.Job.JobID, Action: "replayed"}
+		err := a.requeue(ctx, repo, letter.Job.JobID,
+			models.JobStatusPending, models.JobStatusQueued, models.JobStatusProcessing, models.JobStatusFailed)
+		switch {
+		case isGone(err):
+			result.Action, result.Reason = "dropped", "job no longer exists"
+		case errors.Is(err, database.ErrJobNotRequeueable):
+			job, getErr := repo.GetByID(ctx, letter.Job.JobID)
+			if getErr == nil && job.OriginalDeletedAt != nil {
+				result.Action, result.Reason = "kept", "original was deleted"
+			} else if getErr == nil {
+				result.Action, result.Reason = "dropped", "job is "+string(job.Status)
+			} else {
+				result.Action, result.Reason = "kept", getErr.Error()
+			}
+		case err != nil:
+			result.Action, result.Reason = "kept", err.Error()
+		}
+
+		if result.Action != "kept" {
+			if err := producer.RemoveDeadLetter(ctx, letter.ID); err != nil {
+				return err
+			}
+		}
+		results = append(results, result)
+	}
+
+	return a.print(results, func(w io.Writer) {
+		row(w, "ID", "JOB", "ACTION", "REASON")
+		for _, r := range results {
+			job := "-"
+			if r.JobID != nil {
+				job = r.JobID.String()
+			}
+			row(w, r.ID, job, r.Action, r.Reason)
+		}
+	})
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/timkrebs/image-processor/internal/cleanup"
+	"github.com/timkrebs/image-processor/internal/database"
+	"github.com/timkrebs/image-processor/internal/storage"
+)
+
+func (a *app) storage(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%w: missing storage subcommand", errUsage)
+	}
+
+	switch args[0] {
+	case "reconcile":
+		return a.storageReconcile(ctx, args[1:])
+	case "rekey":
+		return a.storageRekey(ctx, args[1:])
+	default:
+		return fmt.Errorf("%w: unknown storage subcommand %q", errUsage, args[0])
+	}
+}
+
+// storageReconcile reports objects no job, upload or reference points to, and
+// objects the database needs that are missing from storage
+func (a *app) storageReconcile(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("storage reconcile", flag.ContinueOnError)
+	prefix := fs.String("prefix", "", "only compare keys with this prefix")
+	grace := fs.Duration("grace", time.Hour, "ignore objects modified more recently")
+	deleteOrphans := fs.Bool("delete", false, "delete orphaned objects")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+
+	db, err := a.db()
+	if err != nil {
+		return err
+	}
+	store, err := a.backend(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Load the references first: objects written while listing fall within the grace period
+	refs, err := database.NewJobRepository(db).ListObjectReferences(ctx)
+	if err != nil {
+		return err
+	}
+	report, err := cleanup.Reconcile(ctx, store, refs, cleanup.ReconcileOptions{
+		Prefix: *prefix,
+		Grace:  *grace,
+		Delete: *deleteOrphans,
+	})
+	if err != nil {
+		return err
+	}
+
+	return a.print(report, func(w io.Writer) {
+		row(w, "Scanned:", report.Scanned)
+		row(w, "Orphaned:", fmt.Sprintf("%d (%d bytes)", len(report.Orphaned), report.OrphanedBytes))
+		row(w, "Deleted:", report.Deleted)
+		row(w, "Missing:", len(report.Missing))
+		for _, key := range report.Orphaned {
+			row(w, "orphaned", key)
+		}
+		for _, key := range report.Missing {
+			row(w, "missing", key)
+		}
+	})
+}
+
+// storageRekey rewrites objects still encrypted with a retired key
+func (a *app) storageRekey(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("storage rekey", flag.ContinueOnError)
+	prefix := fs.String("prefix", "", "only rewrite keys with this prefix")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+
+	store, err := a.backend(ctx)
+	if err != nil {
+		return err
+	}
+	minio, ok := store.(*storage.Storage)
+	if !ok {
+		return errors.New("rekey requires the minio storage backend")
+	}
+
+	rewritten, err := minio.Rekey(ctx, *prefix)
+	if err != nil {
+		return err
+	}
+
+	return a.print(map[string]int{"rewritten": rewritten}, func(w io.Writer) {
+		row(w, "Rewritten:", rewritten)
+	})
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/google/uuid"
+
+	"github.com/timkrebs/image-processor/internal/database"
+	"github.com/timkrebs/image-processor/internal/models"
+)
+
+func (a *app) users(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%w: missing users subcommand", errUsage)
+	}
+
+	switch args[0] {
+	case "list":
+		return a.usersList(ctx, args[1:])
+	case "create":
+		return a.usersCreate(ctx, args[1:])
+	case "disable":
+		return a.usersSetDisabled(ctx, args[1:], true)
+	case "enable":
+		return a.usersSetDisabled(ctx, args[1:], false)
+	case "reset-password":
+		return a.usersResetPassword(ctx, args[1:])
+	default:
+		return fmt.Errorf("%w: unknown users subcommand %q", errUsage, args[0])
+	}
+}
+
+func (a *app) usersList(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("users list", flag.ContinueOnError)
+	limit := fs.Int("limit", 50, "maximum number of users")
+	offset := fs.Int("offset", 0, "number of users to skip")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+
+	db, err := a.db()
+	if err != nil {
+		return err
+	}
+	users, err := db.ListUsers(ctx, *limit, *offset)
+	if err != nil {
+		return err
+	}
+	if users == nil {
+		users = []*models.User{}
+	}
+
+	return a.print(users, func(w io.Writer) {
+		row(w, "ID", "EMAIL", "USERNAME", "CREATED", "LAST LOGIN", "DISABLED")
+		for _, user := range users {
+			row(w, user.ID, user.Email, user.Username, formatTime(&user.CreatedAt), formatTime(user.LastLoginAt), formatTime(user.DisabledAt))
+		}
+	})
+}
+
+func (a *app) usersCreate(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("users create", flag.ContinueOnError)
+	email := fs.String("email", "", "email address")
+	username := fs.String("username", "", "username")
+	password := fs.String("password", "", "password (generated if empty)")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+
+	req := &models.CreateUserRequest{Email: *email, Username: *username, Password: *password}
+	generated := req.Password == ""
+	if generated {
+		req.Password = generatePassword()
+	}
+	if err := req.Validate(); err != nil {
+		return err
+	}
+
+	db, err := a.db()
+	if err != nil {
+		return err
+	}
+	user, err := db.CreateUser(ctx, req)
+	if err != nil {
+		return err
+	}
+
+	return a.printCredentials(user, req.Password, generated)
+}
+
+func (a *app) usersSetDisabled(ctx context.Context, args []string, disabled bool) error {
+	if len(args) != 1 {
+		return fmt.Errorf("%w: expected a user ID or email", errUsage)
+	}
+
+	db, err := a.db()
+	if err != nil {
+		return err
+	}
+	user, err := findUser(ctx, db, args[0])
+	if err != nil {
+		return err
+	}
+	if err := db.SetUserDisabled(ctx, user.ID, disabled); err != nil {
+		return err
+	}
+
+	state := "enabled"
+	if disabled {
+		state = "disabled"
+	}
+	return a.print(map[string]any{"user_id": user.ID, "email": user.Email, "status": state}, func(w io.Writer) {
+		row(w, "User", user.Email, state)
+	})
+}
+
+func (a *app) usersResetPassword(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("users reset-password", flag.ContinueOnError)
+	password := fs.String("password", "", "new password (generated if empty)")
+	args, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 1 {
+		return fmt.Errorf("%w: expected a user ID or email", errUsage)
+	}
+
+	newPassword := *password
+	generated := newPassword == ""
+	if generated {
+		newPassword = generatePassword()
+	}
+	if err := models.ValidatePassword(newPassword); err != nil {
+		return err
+	}
+
+	db, err := a.db()
+	if err != nil {
+		return err
+	}
+	user, err := findUser(ctx, db, args[0])
+	if err != nil {
+		return err
+	}
+	hash, err := models.HashPassword(newPassword)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+	if err := db.UpdatePassword(ctx, user.ID, hash); err != nil {
+		return err
+	}
+
+	return a.printCredentials(user, newPassword, generated)
+}
+
+// printCredentials shows a user, and the password only if imgctl generated it
+func (a *app) printCredentials(user *models.User, password string, generated bool) error {
+	out := map[string]any{"user": user}
+	if generated {
+		out["password"] = password
+	}
+	return a.print(out, func(w io.Writer) {
+		row(w, "ID:", user.ID)
+		row(w, "Email:", user.Email)
+		row(w, "Username:", user.Username)
+		if generated {
+			row(w, "Password:", password)
+		}
+	})
+}
+
+// findUser looks a user up by ID or email address
+func findUser(ctx context.Context, db *database.DB, ref string) (*models.User, error) {
+	if id, err := uuid.Parse(ref); err == nil {
+		return db.GetUserByID(ctx, id)
+	}
+	return db.GetUserByEmail(ctx, ref)
+}
+
+// generatePassword returns a random password for accounts created by an operator
+func generatePassword() string {
+	b := make([]byte, 18)
+	_, _ = rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
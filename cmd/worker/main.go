@@ -3,7 +3,9 @@ package main
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
 	"os"
@@ -13,25 +15,31 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/redis/go-redis/v9"
 
 	"github.com/timkrebs/image-processor/internal/cleanup"
 	"github.com/timkrebs/image-processor/internal/config"
 	"github.com/timkrebs/image-processor/internal/database"
+	"github.com/timkrebs/image-processor/internal/metrics"
+	"github.com/timkrebs/image-processor/internal/migrate"
 	"github.com/timkrebs/image-processor/internal/models"
 	"github.com/timkrebs/image-processor/internal/processor"
 	"github.com/timkrebs/image-processor/internal/queue"
+	"github.com/timkrebs/image-processor/internal/scheduler"
 	"github.com/timkrebs/image-processor/internal/storage"
 )
 
 // Worker processes image jobs from the queue
 type Worker struct {
-	id        string
-	jobRepo   *database.JobRepository
-	storage   *storage.Storage
-	consumer  *queue.Consumer
-	processor *processor.Processor
-	logger    *slog.Logger
+	id             string
+	jobRepo        *database.JobRepository
+	storage        storage.Backend
+	consumer       *queue.Consumer
+	processor      *processor.Processor
+	metrics        *metrics.JobMetrics
+	logger         *slog.Logger
+	measureQuality bool
 }
 
 func main() {
@@ -65,6 +73,17 @@ func main() {
 	}()
 	logger.Info("connected to database")
 
+	// Refuse to process jobs against a schema older than this binary expects
+	migrator, err := migrate.New(db.DB, logger)
+	if err != nil {
+		logger.Error("failed to load migrations", "error", err)
+		os.Exit(1)
+	}
+	if err := migrator.Check(context.Background()); err != nil {
+		logger.Error("database schema check failed, run the api with -migrate=up", "error", err)
+		os.Exit(1)
+	}
+
 	// Create job repository
 	jobRepo := database.NewJobRepository(db)
 
@@ -95,6 +114,11 @@ func main() {
 		ConsumerGroup: cfg.QueueConsumerGroup,
 		ConsumerName:  workerID,
 		PollTimeout:   cfg.WorkerPollTimeout,
+		Weights: map[models.JobPriority]int{
+			models.PriorityInteractive: cfg.QueueWeightInteractive,
+			models.PriorityDefault:     cfg.QueueWeightDefault,
+			models.PriorityBulk:        cfg.QueueWeightBulk,
+		},
 	}, logger)
 
 	// Ensure consumer group exists
@@ -106,31 +130,46 @@ func main() {
 	}
 	cancel()
 
-	// Connect to MinIO
-	storageClient, err := storage.New(storage.Config{
-		Endpoint:  cfg.MinIOEndpoint,
-		AccessKey: cfg.MinIOAccessKey,
-		SecretKey: cfg.MinIOSecretKey,
-		Bucket:    cfg.MinIOBucket,
-		UseSSL:    cfg.MinIOUseSSL,
+	// Open the storage backend
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+	storageClient, err := storage.Open(ctx, storage.Options{
+		Type:      cfg.StorageBackend,
+		LocalPath: cfg.StorageLocalPath,
+		MinIO: storage.Config{
+			Endpoint:            cfg.MinIOEndpoint,
+			AccessKey:           cfg.MinIOAccessKey,
+			SecretKey:           cfg.MinIOSecretKey,
+			Bucket:              cfg.MinIOBucket,
+			UseSSL:              cfg.MinIOUseSSL,
+			Encryption:          cfg.StorageEncryption,
+			EncryptionKeys:      cfg.StorageEncryptionKeys,
+			ProcessedBucket:     cfg.MinIOProcessedBucket,
+			OriginalsPrefix:     cfg.StorageOriginalsPrefix,
+			ProcessedPrefix:     cfg.StorageProcessedPrefix,
+			OriginalsExpiryDays: cfg.StorageOriginalsExpiryDays,
+			ProcessedExpiryDays: cfg.StorageProcessedExpiryDays,
+		},
 	})
+	cancel()
 	if err != nil {
-		logger.Error("failed to create storage client", "error", err)
+		logger.Error("failed to open storage", "backend", cfg.StorageBackend, "error", err)
 		os.Exit(1)
 	}
-	logger.Info("connected to minio", "bucket", cfg.MinIOBucket)
+	logger.Info("storage ready", "backend", cfg.StorageBackend)
 
 	// Create image processor
 	imageProcessor := processor.New()
 
 	// Create worker
 	worker := &Worker{
-		id:        workerID,
-		jobRepo:   jobRepo,
-		storage:   storageClient,
-		consumer:  consumer,
-		processor: imageProcessor,
-		logger:    logger,
+		id:             workerID,
+		jobRepo:        jobRepo,
+		storage:        storageClient,
+		consumer:       consumer,
+		processor:      imageProcessor,
+		metrics:        metrics.NewJobMetrics("image_processor_worker"),
+		logger:         logger,
+		measureQuality: cfg.WorkerMeasureQuality,
 	}
 
 	// Create cleanup worker
@@ -139,6 +178,13 @@ func main() {
 		BatchSize: 100,
 	}, logger.With("component", "cleanup"))
 
+	// Create scheduler worker to dispatch due scheduled jobs
+	producer := queue.NewProducer(redisClient, cfg.QueueStreamName)
+	schedulerWorker := scheduler.NewWorker(jobRepo, queue.NewScheduler(redisClient, producer, cfg.QueueStreamName), scheduler.Config{
+		Interval:  cfg.SchedulerInterval,
+		BatchSize: 100,
+	}, logger.With("component", "scheduler"))
+
 	// Start health check server
 	go startHealthServer(cfg.HTTPPort, logger)
 
@@ -160,6 +206,13 @@ func main() {
 		cleanupWorker.Start(ctx)
 	}()
 
+	// Start scheduler worker in background
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		schedulerWorker.Start(ctx)
+	}()
+
 	// Start worker goroutines
 	for i := 0; i < cfg.WorkerConcurrency; i++ {
 		wg.Add(1)
@@ -203,13 +256,17 @@ func (w *Worker) run(ctx context.Context, workerNum int) {
 				continue
 			}
 
-			// Process the job
+			// Process the job, keeping failed messages on the dead-letter stream for replay
 			if err := w.processJob(ctx, msg); err != nil {
 				logger.Error("failed to process job", "job_id", msg.Job.JobID, "error", err)
+				if err := w.consumer.DeadLetter(ctx, msg, err.Error()); err != nil {
+					logger.Error("failed to dead-letter message", "error", err)
+				}
+				continue
 			}
 
 			// Acknowledge the message
-			if err := w.consumer.Acknowledge(ctx, msg.ID); err != nil {
+			if err := w.consumer.Acknowledge(ctx, msg); err != nil {
 				logger.Error("failed to acknowledge message", "error", err)
 			}
 		}
@@ -218,7 +275,7 @@ func (w *Worker) run(ctx context.Context, workerNum int) {
 
 func (w *Worker) processJob(ctx context.Context, msg *queue.Message) error {
 	jobID := msg.Job.JobID
-	logger := w.logger.With("job_id", jobID)
+	logger := w.logger.With("job_id", jobID, "priority", msg.Priority)
 
 	logger.Info("starting job processing")
 
@@ -244,6 +301,19 @@ func (w *Worker) processJob(ctx context.Context, msg *queue.Message) error {
 		return fmt.Errorf("failed to start processing: %w", err)
 	}
 
+	// Identical work done before only needs its result shared
+	if job.OriginalSHA256 != "" {
+		reused, err := w.reuseResult(ctx, job)
+		if err != nil {
+			logger.Warn("failed to reuse identical result", "error", err)
+		}
+		if reused {
+			logger.Info("job completed with identical result")
+			w.deleteOriginal(ctx, job)
+			return nil
+		}
+	}
+
 	// Download original image
 	logger.Info("downloading original image", "key", job.OriginalKey)
 	reader, err := w.storage.Download(ctx, job.OriginalKey)
@@ -259,9 +329,31 @@ func (w *Worker) processJob(ctx context.Context, msg *queue.Message) error {
 		}
 	}()
 
+	// Measuring quality needs the original a second time
+	var source io.Reader = reader
+	var original []byte
+	if w.measureQuality {
+		original, err = io.ReadAll(reader)
+		if err != nil {
+			if failErr := w.jobRepo.FailJob(ctx, jobID, "failed to download image: "+err.Error()); failErr != nil {
+				logger.Error("failed to mark job as failed", "error", failErr)
+			}
+			return fmt.Errorf("failed to download image: %w", err)
+		}
+		source = bytes.NewReader(original)
+	}
+
+	fonts, err := w.loadFonts(ctx, job, msg.Job.Operations)
+	if err != nil {
+		if failErr := w.jobRepo.FailJob(ctx, jobID, "failed to load font: "+err.Error()); failErr != nil {
+			logger.Error("failed to mark job as failed", "error", failErr)
+		}
+		return fmt.Errorf("failed to load font: %w", err)
+	}
+
 	// Process the image
 	logger.Info("processing image", "operations", len(msg.Job.Operations))
-	result, err := w.processor.Process(reader, job.ContentType, msg.Job.Operations)
+	result, err := w.processor.WithFonts(fonts).Process(source, job.ContentType, msg.Job.Operations)
 	if err != nil {
 		if failErr := w.jobRepo.FailJob(ctx, jobID, "failed to process image: "+err.Error()); failErr != nil {
 			logger.Error("failed to mark job as failed", "error", failErr)
@@ -269,6 +361,16 @@ func (w *Worker) processJob(ctx context.Context, msg *queue.Message) error {
 		return fmt.Errorf("failed to process image: %w", err)
 	}
 
+	// Analysis operations are results in their own right, so losing them fails the job
+	if result.Analysis != nil {
+		if err := w.jobRepo.RecordAnalysis(ctx, jobID, result.Analysis); err != nil {
+			if failErr := w.jobRepo.FailJob(ctx, jobID, "failed to record analysis: "+err.Error()); failErr != nil {
+				logger.Error("failed to mark job as failed", "error", failErr)
+			}
+			return fmt.Errorf("failed to record analysis: %w", err)
+		}
+	}
+
 	// Generate processed key with user isolation
 	processedKey := fmt.Sprintf("users/%s/processed/%s/%s", job.UserID.String(), jobID.String(), job.OriginalName)
 
@@ -281,15 +383,138 @@ func (w *Worker) processJob(ctx context.Context, msg *queue.Message) error {
 		return fmt.Errorf("failed to upload processed image: %w", err)
 	}
 
-	// Mark job as completed - set 1 hour retention for cleanup
-	if err := w.jobRepo.CompleteJob(ctx, jobID, processedKey, 1); err != nil {
+	w.recordOutput(ctx, job, result, original)
+
+	// Mark job as completed - set retention for cleanup
+	if err := w.jobRepo.CompleteJob(ctx, jobID, processedKey, models.JobRetentionHours); err != nil {
 		return fmt.Errorf("failed to complete job: %w", err)
 	}
 
 	logger.Info("job completed successfully")
+	w.deleteOriginal(ctx, job)
 	return nil
 }
 
+// loadFonts downloads the uploaded fonts a job's text operations use, keyed by
+// ID. Jobs can only use their owner's fonts.
+func (w *Worker) loadFonts(ctx context.Context, job *models.Job, operations []models.Operation) (map[string][]byte, error) {
+	ids := models.TextFontIDs(operations)
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	fonts := make(map[string][]byte, len(ids))
+	for _, id := range ids {
+		font, err := w.jobRepo.GetFont(ctx, id)
+		if errors.Is(err, database.ErrFontNotFound) || (err == nil && font.UserID != job.UserID) {
+			return nil, fmt.Errorf("font %s not found", id)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		reader, err := w.storage.Download(ctx, font.StorageKey)
+		if err != nil {
+			return nil, err
+		}
+		data, err := io.ReadAll(io.LimitReader(reader, models.MaxFontSize))
+		reader.Close()
+		if err != nil {
+			return nil, err
+		}
+		fonts[id.String()] = data
+	}
+	return fonts, nil
+}
+
+// recordOutput records the size of a job's output and, with quality measurement
+// enabled, how closely it matches the original. Failures are only logged.
+func (w *Worker) recordOutput(ctx context.Context, job *models.Job, result *processor.ProcessResult, original []byte) {
+	job.SetOutputSize(int64(len(result.Data)))
+
+	var quality *models.OutputQuality
+	if original != nil {
+		var err error
+		quality, err = w.processor.MeasureQuality(bytes.NewReader(original), bytes.NewReader(result.Data))
+		if err != nil {
+			w.logger.Warn("failed to measure output quality", "job_id", job.ID, "error", err)
+		} else {
+			job.SSIM, job.PSNR = &quality.SSIM, &quality.PSNR
+		}
+	}
+
+	if err := w.jobRepo.RecordOutput(ctx, job.ID, *job.OutputSize, quality); err != nil {
+		w.logger.Warn("failed to record output", "job_id", job.ID, "error", err)
+		return
+	}
+	w.metrics.ObserveOutput(job, result.ContentType)
+}
+
+// deleteOriginal drops a completed job's original right away if its submitter asked
+// for that. Failures are only logged: the cleanup worker deletes the original later.
+func (w *Worker) deleteOriginal(ctx context.Context, job *models.Job) {
+	if !job.DeleteOriginal {
+		return
+	}
+
+	err := w.jobRepo.ReleaseObjectRef(ctx, job.OriginalKey, job.ID, func() error {
+		return w.storage.Delete(ctx, job.OriginalKey)
+	})
+	if err == nil {
+		err = w.jobRepo.MarkOriginalDeleted(ctx, job.ID)
+	}
+	if err != nil {
+		w.logger.Warn("failed to delete original after processing", "job_id", job.ID, "key", job.OriginalKey, "error", err)
+		return
+	}
+
+	w.logger.Info("deleted original after processing", "job_id", job.ID, "key", job.OriginalKey)
+}
+
+// reuseResult completes job with the output of a completed job that had the same
+// original and operations, if there is one
+func (w *Worker) reuseResult(ctx context.Context, job *models.Job) (bool, error) {
+	source, err := w.jobRepo.FindCompletedResult(ctx, job)
+	if errors.Is(err, database.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	// Both jobs now share the output, which is deleted with the last of them
+	if err := w.jobRepo.AcquireObjectRefs(ctx, source.ProcessedKey, source.ID, job.ID); err != nil {
+		return false, err
+	}
+
+	exists, err := w.storage.Exists(ctx, source.ProcessedKey)
+	if err != nil || !exists {
+		releaseErr := w.jobRepo.ReleaseObjectRef(ctx, source.ProcessedKey, job.ID, func() error { return nil })
+		return false, errors.Join(err, releaseErr)
+	}
+
+	if source.Analysis != nil {
+		if err := w.jobRepo.RecordAnalysis(ctx, job.ID, source.Analysis); err != nil {
+			releaseErr := w.jobRepo.ReleaseObjectRef(ctx, source.ProcessedKey, job.ID, func() error { return nil })
+			return false, errors.Join(err, releaseErr)
+		}
+	}
+
+	// The shared output measures the same for both jobs
+	if source.OutputSize != nil {
+		if err := w.jobRepo.RecordOutput(ctx, job.ID, *source.OutputSize, source.Quality()); err != nil {
+			w.logger.Warn("failed to record output", "job_id", job.ID, "error", err)
+		}
+	}
+
+	if err := w.jobRepo.CompleteJob(ctx, job.ID, source.ProcessedKey, models.JobRetentionHours); err != nil {
+		return false, fmt.Errorf("failed to complete job: %w", err)
+	}
+
+	w.logger.Info("reused result of identical job", "job_id", job.ID, "source_job_id", source.ID)
+	return true, nil
+}
+
 func startHealthServer(port int, logger *slog.Logger) {
 	mux := http.NewServeMux()
 
@@ -299,6 +524,8 @@ func startHealthServer(port int, logger *slog.Logger) {
 		w.Write([]byte(`{"status":"healthy"}`))
 	})
 
+	mux.Handle("/metrics", promhttp.Handler())
+
 	mux.HandleFunc("/ready", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusOK)
@@ -0,0 +1,72 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/timkrebs/image-processor/internal/models"
+)
+
+// maxSimilarJobs bounds the near-duplicates returned for one job
+const maxSimilarJobs = 100
+
+// SimilarJobs handles GET /api/v1/jobs/{id}/similar
+// Lists the caller's other jobs whose image hash is within ?max_distance bits
+// (default 10) of the job's. ?hash picks phash (the default) or dhash, which the
+// jobs must have computed with the operation of that name.
+func (h *Handlers) SimilarJobs(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	kind, err := models.ParseHashKind(query.Get("hash"))
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	maxDistance := models.DefaultSimilarDistance
+	if value := query.Get("max_distance"); value != "" {
+		maxDistance, err = strconv.Atoi(value)
+		if err != nil || maxDistance < 0 || maxDistance > 64 {
+			h.writeError(w, http.StatusBadRequest, "max_distance must be between 0 and 64")
+			return
+		}
+	}
+
+	limit, _ := strconv.Atoi(query.Get("limit"))
+	if limit < 1 || limit > maxSimilarJobs {
+		limit = 20
+	}
+
+	job := h.loadOwnedJob(w, r)
+	if job == nil {
+		return
+	}
+	value := job.Analysis.Hash(kind)
+	if value == "" {
+		h.writeError(w, http.StatusConflict, "job has no "+kind+", submit it with the "+kind+" operation")
+		return
+	}
+	hash, err := models.ParseImageHash(value)
+	if err != nil {
+		h.logger.Error("invalid stored image hash", "job_id", job.ID, "error", err)
+		h.writeError(w, http.StatusInternalServerError, "failed to find similar jobs")
+		return
+	}
+
+	matches, err := h.jobRepo.FindSimilar(r.Context(), requestOwner(r), job.ID, kind, hash, maxDistance, limit)
+	if err != nil {
+		h.logger.Error("failed to find similar jobs", "job_id", job.ID, "error", err)
+		h.writeError(w, http.StatusInternalServerError, "failed to find similar jobs")
+		return
+	}
+	if matches == nil {
+		matches = []*models.SimilarJob{}
+	}
+
+	h.writeJSON(w, http.StatusOK, models.SimilarJobsResponse{
+		JobID:       job.ID,
+		Hash:        kind,
+		Value:       value,
+		MaxDistance: maxDistance,
+		Matches:     matches,
+	})
+}
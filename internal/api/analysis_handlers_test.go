@@ -0,0 +1,49 @@
+package api
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/timkrebs/image-processor/internal/models"
+)
+
+func TestHandlers_SimilarJobs_BadRequest(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	h := &Handlers{logger: logger}
+
+	r := chi.NewRouter()
+	r.Get("/api/v1/jobs/{id}/similar", h.SimilarJobs)
+
+	id := "00000000-0000-0000-0000-000000000001"
+	paths := []string{
+		"/api/v1/jobs/not-a-uuid/similar",
+		"/api/v1/jobs/" + id + "/similar?hash=ahash",
+		"/api/v1/jobs/" + id + "/similar?max_distance=65",
+		"/api/v1/jobs/" + id + "/similar?max_distance=-1",
+		"/api/v1/jobs/" + id + "/similar?max_distance=near",
+	}
+
+	for _, path := range paths {
+		t.Run(path, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			r.ServeHTTP(recorder, httptest.NewRequest("GET", path, http.NoBody))
+
+			if recorder.Code != http.StatusBadRequest {
+				t.Errorf("Status = %d, want %d", recorder.Code, http.StatusBadRequest)
+			}
+		})
+	}
+}
+
+func TestIsValidOperation_Analysis(t *testing.T) {
+	for _, op := range []string{"phash", "dhash", "palette", "average_color", "blurhash", "histogram"} {
+		if !isValidOperation(models.OperationType(op)) {
+			t.Errorf("isValidOperation(%q) = false, want true", op)
+		}
+	}
+}
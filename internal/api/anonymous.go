@@ -0,0 +1,104 @@
+package api
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// anonymousCookieName holds the signed identity of an anonymous client
+const anonymousCookieName = "anon_id"
+
+const anonymousContextKey contextKey = "anonymous_id"
+
+// AnonymousIdentity issues and verifies signed cookies that give each
+// anonymous client its own ephemeral identity
+type AnonymousIdentity struct {
+	secret []byte
+	ttl    time.Duration
+}
+
+// NewAnonymousIdentity creates an identity signer. All API replicas must share the secret.
+func NewAnonymousIdentity(secret []byte, ttl time.Duration) *AnonymousIdentity {
+	return &AnonymousIdentity{
+		secret: secret,
+		ttl:    ttl,
+	}
+}
+
+// sign returns the cookie value for an anonymous ID
+func (a *AnonymousIdentity) sign(id uuid.UUID) string {
+	return id.String() + "." + base64.RawURLEncoding.EncodeToString(a.mac(id))
+}
+
+// verify parses a cookie value, returning false if it was not signed with our secret
+func (a *AnonymousIdentity) verify(value string) (uuid.UUID, bool) {
+	idPart, sigPart, found := strings.Cut(value, ".")
+	if !found {
+		return uuid.Nil, false
+	}
+
+	id, err := uuid.Parse(idPart)
+	if err != nil {
+		return uuid.Nil, false
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(sigPart)
+	if err != nil || !hmac.Equal(sig, a.mac(id)) {
+		return uuid.Nil, false
+	}
+
+	return id, true
+}
+
+func (a *AnonymousIdentity) mac(id uuid.UUID) []byte {
+	h := hmac.New(sha256.New, a.secret)
+	h.Write(id[:])
+	return h.Sum(nil)
+}
+
+// Middleware attaches the anonymous identity to requests without a session,
+// issuing a new signed cookie when the client has none or it fails verification
+func (a *AnonymousIdentity) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if session, ok := GetSession(r.Context()); ok && session != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		var id uuid.UUID
+		verified := false
+		if cookie, err := r.Cookie(anonymousCookieName); err == nil {
+			id, verified = a.verify(cookie.Value)
+		}
+		if !verified {
+			id = uuid.New()
+		}
+
+		// Refresh the cookie so active clients keep their identity
+		http.SetCookie(w, &http.Cookie{
+			Name:     anonymousCookieName,
+			Value:    a.sign(id),
+			Path:     "/",
+			HttpOnly: true,
+			Secure:   r.TLS != nil,
+			SameSite: http.SameSiteLaxMode,
+			MaxAge:   int(a.ttl.Seconds()),
+		})
+
+		ctx := context.WithValue(r.Context(), anonymousContextKey, id)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// GetAnonymousID retrieves the anonymous identity from context
+func GetAnonymousID(ctx context.Context) (uuid.UUID, bool) {
+	id, ok := ctx.Value(anonymousContextKey).(uuid.UUID)
+	return id, ok
+}
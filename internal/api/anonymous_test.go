@@ -0,0 +1,115 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestAnonymousIdentity_SignVerify(t *testing.T) {
+	identity := NewAnonymousIdentity([]byte("test-secret"), time.Hour)
+	id := uuid.New()
+
+	got, ok := identity.verify(identity.sign(id))
+	if !ok || got != id {
+		t.Errorf("verify(sign(id)) = %v, %v; want %v, true", got, ok, id)
+	}
+
+	other := NewAnonymousIdentity([]byte("other-secret"), time.Hour)
+	tests := []struct {
+		name  string
+		value string
+	}{
+		{"foreign secret", other.sign(id)},
+		{"swapped id", uuid.New().String() + identity.sign(id)[36:]},
+		{"unsigned", id.String()},
+		{"garbage", "not-a-cookie"},
+		{"empty", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, ok := identity.verify(tt.value); ok {
+				t.Errorf("verify(%q) = true, want false", tt.value)
+			}
+		})
+	}
+}
+
+func TestAnonymousIdentity_Middleware(t *testing.T) {
+	identity := NewAnonymousIdentity([]byte("test-secret"), time.Hour)
+
+	var seen uuid.UUID
+	var seenOK bool
+	handler := identity.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen, seenOK = GetAnonymousID(r.Context())
+	}))
+
+	// New clients get a fresh identity cookie
+	req := httptest.NewRequest("GET", "/api/v1/jobs", nil)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	cookies := rr.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != anonymousCookieName {
+		t.Fatalf("cookies = %v, want %s", cookies, anonymousCookieName)
+	}
+	if !seenOK || seen == uuid.Nil {
+		t.Fatal("anonymous ID not set in context")
+	}
+	first := seen
+
+	// Returning clients keep their identity
+	req = httptest.NewRequest("GET", "/api/v1/jobs", nil)
+	req.AddCookie(cookies[0])
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if seen != first {
+		t.Errorf("anonymous ID = %v, want %v", seen, first)
+	}
+
+	// Forged cookies are replaced
+	req = httptest.NewRequest("GET", "/api/v1/jobs", nil)
+	req.AddCookie(&http.Cookie{Name: anonymousCookieName, Value: first.String() + ".forged"})
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if seen == first {
+		t.Error("forged cookie was accepted")
+	}
+
+	// Authenticated requests are left alone
+	seenOK = false
+	req = httptest.NewRequest("GET", "/api/v1/jobs", nil)
+	req = req.WithContext(context.WithValue(req.Context(), sessionContextKey, &Session{UserID: uuid.New()}))
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if seenOK {
+		t.Error("anonymous ID set for authenticated request")
+	}
+	if len(rr.Result().Cookies()) != 0 {
+		t.Error("cookie issued for authenticated request")
+	}
+}
+
+func TestRequestOwner(t *testing.T) {
+	userID := uuid.New()
+	anonID := uuid.New()
+
+	req := httptest.NewRequest("GET", "/api/v1/jobs", nil)
+	if owner := requestOwner(req); owner.UserID != systemUserID || owner.AnonymousID != nil {
+		t.Errorf("requestOwner() = %+v, want system user", owner)
+	}
+
+	anonReq := req.WithContext(context.WithValue(req.Context(), anonymousContextKey, anonID))
+	owner := requestOwner(anonReq)
+	if owner.UserID != systemUserID || owner.AnonymousID == nil || *owner.AnonymousID != anonID {
+		t.Errorf("requestOwner() = %+v, want system user with anonymous ID %v", owner, anonID)
+	}
+
+	userReq := anonReq.WithContext(context.WithValue(anonReq.Context(), sessionContextKey, &Session{UserID: userID}))
+	if owner := requestOwner(userReq); owner.UserID != userID || owner.AnonymousID != nil {
+		t.Errorf("requestOwner() = %+v, want user %v", owner, userID)
+	}
+}
@@ -125,6 +125,11 @@ func (h *AuthHandlers) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if user.DisabledAt != nil {
+		h.writeError(w, http.StatusForbidden, models.ErrUserDisabled.Error())
+		return
+	}
+
 	h.logger.Info("user logged in", "user_id", user.ID, "email", user.Email)
 
 	// Create session
@@ -0,0 +1,339 @@
+package api
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"github.com/timkrebs/image-processor/internal/database"
+	"github.com/timkrebs/image-processor/internal/models"
+)
+
+// Default batch limits, overridable with SetBatchLimits
+const (
+	defaultBatchMaxFiles      = 500
+	defaultBatchMaxUploadSize = 500 << 20 // 500MB
+)
+
+// batchFile is one image of a batch upload, from a multipart part or a zip entry
+type batchFile struct {
+	open        func() (io.ReadCloser, error)
+	name        string
+	contentType string
+	size        int64
+}
+
+// SetBatchLimits sets the maximum number of files and total upload size of a batch
+func (h *Handlers) SetBatchLimits(maxFiles int, maxUploadSize int64) {
+	h.batchMaxFiles = maxFiles
+	h.batchMaxUploadSize = maxUploadSize
+}
+
+// CreateBatch handles POST /api/v1/batches
+func (h *Handlers) CreateBatch(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	owner := requestOwner(r)
+
+	// Parse multipart form, spilling large uploads to disk
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		h.writeError(w, http.StatusBadRequest, "failed to parse form: "+err.Error())
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	files, closeArchives, err := h.collectBatchFiles(r.MultipartForm)
+	defer closeArchives()
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	opts, err := parseJobOptions(r, time.Now())
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !h.checkJobOptions(w, opts) || !h.resolvePreset(w, r, &opts) {
+		return
+	}
+
+	var totalSize int64
+	for _, f := range files {
+		totalSize += f.size
+	}
+
+	// Enforce quotas for the whole batch before accepting any upload
+	if !h.checkQuota(w, r, owner, len(files), totalSize) {
+		return
+	}
+
+	batch := models.NewBatch(opts.operations, opts.priority)
+	batch.UserID = owner.UserID
+	batch.AnonymousID = owner.AnonymousID
+
+	if err := h.jobRepo.CreateBatch(ctx, batch); err != nil {
+		h.logger.Error("failed to create batch", "error", err)
+		h.writeError(w, http.StatusInternalServerError, "failed to create batch")
+		return
+	}
+
+	batch.Items = make([]*models.BatchItem, 0, len(files))
+	for _, f := range files {
+		batch.Items = append(batch.Items, h.createBatchJob(r, owner, opts, batch.ID, f))
+	}
+	batch.Summarize()
+
+	h.logger.Info("batch created", "batch_id", batch.ID, "files", len(files), "failed", batch.StatusCount[models.JobStatusFailed])
+	h.writeJSON(w, http.StatusCreated, batch)
+}
+
+// createBatchJob creates the job for one batch file, reporting failures on the item
+func (h *Handlers) createBatchJob(r *http.Request, owner models.JobOwner, opts jobOptions, batchID uuid.UUID, f batchFile) *models.BatchItem {
+	reader, err := f.open()
+	if err != nil {
+		h.logger.Error("failed to open batch file", "batch_id", batchID, "name", f.name, "error", err)
+		return &models.BatchItem{Name: f.name, Status: models.JobStatusFailed, Error: "failed to read file"}
+	}
+	defer reader.Close()
+
+	job, err := h.createJob(r.Context(), owner, opts, &batchID, f.name, f.contentType, reader, f.size)
+	if err != nil {
+		h.logger.Error("failed to create batch job", "batch_id", batchID, "name", f.name, "error", err)
+		if job == nil {
+			return &models.BatchItem{Name: f.name, Status: models.JobStatusFailed, Error: jobErrorMessage(err)}
+		}
+		item := models.BatchItemFromJob(job)
+		item.Error = jobErrorMessage(err)
+		return item
+	}
+
+	return models.BatchItemFromJob(job)
+}
+
+// collectBatchFiles validates the "images" files and the images inside any "archive" zip files.
+// The returned function closes the opened archives and must always be called.
+func (h *Handlers) collectBatchFiles(form *multipart.Form) ([]batchFile, func(), error) {
+	var files []batchFile
+	var archives []multipart.File
+	closeArchives := func() {
+		for _, a := range archives {
+			a.Close()
+		}
+	}
+
+	for _, header := range form.File["images"] {
+		contentType := header.Header.Get("Content-Type")
+		if !isValidImageType(contentType) {
+			contentType = detectContentType(header.Filename)
+			if !isValidImageType(contentType) {
+				return nil, closeArchives, fmt.Errorf("invalid image type for %s, must be JPEG, PNG, or GIF", header.Filename)
+			}
+		}
+		files = append(files, batchFile{
+			open:        func() (io.ReadCloser, error) { return header.Open() },
+			name:        header.Filename,
+			contentType: contentType,
+			size:        header.Size,
+		})
+	}
+
+	for _, header := range form.File["archive"] {
+		archive, err := header.Open()
+		if err != nil {
+			return nil, closeArchives, fmt.Errorf("failed to open archive %s", header.Filename)
+		}
+		archives = append(archives, archive)
+
+		zr, err := zip.NewReader(archive, header.Size)
+		if err != nil {
+			return nil, closeArchives, fmt.Errorf("invalid zip archive %s", header.Filename)
+		}
+		files = append(files, zipImages(zr)...)
+	}
+
+	if len(files) == 0 {
+		return nil, closeArchives, errors.New("at least one image is required")
+	}
+
+	maxFiles, maxUploadSize := h.batchLimits()
+	maxFileSize := h.fileSizeLimit()
+	if len(files) > maxFiles {
+		return nil, closeArchives, fmt.Errorf("too many files: %d, maximum is %d", len(files), maxFiles)
+	}
+
+	var total int64
+	for _, f := range files {
+		if f.size > maxFileSize {
+			return nil, closeArchives, fmt.Errorf("file %s exceeds maximum size of %d bytes", f.name, maxFileSize)
+		}
+		total += f.size
+	}
+	if total > maxUploadSize {
+		return nil, closeArchives, fmt.Errorf("batch exceeds maximum size of %d bytes", maxUploadSize)
+	}
+
+	return files, closeArchives, nil
+}
+
+// zipImages returns the images in a zip archive, skipping directories,
+// hidden files and anything that is not a supported image type.
+// Entry sizes can be trusted because archive/zip fails reads that exceed them.
+func zipImages(zr *zip.Reader) []batchFile {
+	var files []batchFile
+	for _, entry := range zr.File {
+		name := path.Base(entry.Name)
+		if entry.FileInfo().IsDir() || strings.HasPrefix(entry.Name, "__MACOSX/") || strings.HasPrefix(name, ".") {
+			continue
+		}
+
+		contentType := detectContentType(name)
+		if !isValidImageType(contentType) {
+			continue
+		}
+
+		files = append(files, batchFile{
+			open:        entry.Open,
+			name:        name,
+			contentType: contentType,
+			size:        int64(entry.UncompressedSize64),
+		})
+	}
+	return files
+}
+
+// batchLimits returns the configured batch limits, falling back to the defaults
+func (h *Handlers) batchLimits() (maxFiles int, maxUploadSize int64) {
+	maxFiles, maxUploadSize = h.batchMaxFiles, h.batchMaxUploadSize
+	if maxFiles < 1 {
+		maxFiles = defaultBatchMaxFiles
+	}
+	if maxUploadSize < 1 {
+		maxUploadSize = defaultBatchMaxUploadSize
+	}
+	return maxFiles, maxUploadSize
+}
+
+// loadBatch fetches a batch owned by the requester together with its jobs.
+// It writes the error response and returns nil on failure.
+func (h *Handlers) loadBatch(w http.ResponseWriter, r *http.Request) (*models.Batch, []*models.Job) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "invalid batch ID")
+		return nil, nil
+	}
+
+	batch, err := h.jobRepo.GetBatch(r.Context(), id)
+	if errors.Is(err, database.ErrBatchNotFound) || (err == nil && !requestOwner(r).Owns(batch.UserID, batch.AnonymousID)) {
+		h.writeError(w, http.StatusNotFound, "batch not found")
+		return nil, nil
+	}
+	if err != nil {
+		h.logger.Error("failed to get batch", "error", err)
+		h.writeError(w, http.StatusInternalServerError, "failed to get batch")
+		return nil, nil
+	}
+
+	jobs, err := h.jobRepo.ListBatchJobs(r.Context(), id)
+	if err != nil {
+		h.logger.Error("failed to list batch jobs", "error", err)
+		h.writeError(w, http.StatusInternalServerError, "failed to get batch")
+		return nil, nil
+	}
+
+	batch.Items = make([]*models.BatchItem, 0, len(jobs))
+	for _, job := range jobs {
+		batch.Items = append(batch.Items, models.BatchItemFromJob(job))
+	}
+	batch.Summarize()
+
+	return batch, jobs
+}
+
+// GetBatch handles GET /api/v1/batches/{id}
+func (h *Handlers) GetBatch(w http.ResponseWriter, r *http.Request) {
+	batch, _ := h.loadBatch(w, r)
+	if batch == nil {
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, batch)
+}
+
+// DownloadBatch handles GET /api/v1/batches/{id}/download
+// Streams a zip archive of all completed outputs in the batch.
+func (h *Handlers) DownloadBatch(w http.ResponseWriter, r *http.Request) {
+	batch, jobs := h.loadBatch(w, r)
+	if batch == nil {
+		return
+	}
+
+	if batch.StatusCount[models.JobStatusCompleted] == 0 {
+		h.writeError(w, http.StatusConflict, "batch has no completed outputs")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="batch-%s.zip"`, batch.ID))
+
+	zw := zip.NewWriter(w)
+	names := make(map[string]int)
+	for _, job := range jobs {
+		if job.Status != models.JobStatusCompleted || job.ProcessedKey == "" {
+			continue
+		}
+
+		if err := h.writeBatchOutput(r, zw, uniqueName(names, job.OriginalName), job); err != nil {
+			// Headers are already sent, so the client sees a truncated archive
+			h.logger.Error("failed to stream batch output", "batch_id", batch.ID, "job_id", job.ID, "error", err)
+			return
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		h.logger.Error("failed to finish batch archive", "batch_id", batch.ID, "error", err)
+	}
+}
+
+// writeBatchOutput copies a job's processed image into the archive
+func (h *Handlers) writeBatchOutput(r *http.Request, zw *zip.Writer, name string, job *models.Job) error {
+	reader, err := h.storage.Download(r.Context(), job.ProcessedKey)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	// Images are already compressed, so store them as-is
+	entry, err := zw.CreateHeader(&zip.FileHeader{
+		Name:     name,
+		Method:   zip.Store,
+		Modified: job.UpdatedAt,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(entry, reader)
+	return err
+}
+
+// uniqueName returns name, or name with a numeric suffix if it was already used
+func uniqueName(used map[string]int, name string) string {
+	n := used[name]
+	used[name] = n + 1
+	if n == 0 {
+		return name
+	}
+
+	ext := path.Ext(name)
+	candidate := fmt.Sprintf("%s-%d%s", strings.TrimSuffix(name, ext), n, ext)
+	return uniqueName(used, candidate)
+}
@@ -0,0 +1,142 @@
+package api
+
+import (
+	"archive/zip"
+	"bytes"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestUniqueName(t *testing.T) {
+	used := make(map[string]int)
+	names := []string{"a.jpg", "a.jpg", "b.png", "a.jpg", "a-1.jpg"}
+	want := []string{"a.jpg", "a-1.jpg", "b.png", "a-2.jpg", "a-1-1.jpg"}
+
+	for i, name := range names {
+		if got := uniqueName(used, name); got != want[i] {
+			t.Errorf("uniqueName(%q) = %q, want %q", name, got, want[i])
+		}
+	}
+}
+
+// newBatchForm builds a parsed multipart form with the given image and archive parts
+func newBatchForm(t *testing.T, images map[string][]byte, archives map[string][]byte) *multipart.Form {
+	t.Helper()
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	for name, data := range images {
+		part, _ := writer.CreateFormFile("images", name)
+		part.Write(data)
+	}
+	for name, data := range archives {
+		part, _ := writer.CreateFormFile("archive", name)
+		part.Write(data)
+	}
+	writer.Close()
+
+	req := httptest.NewRequest("POST", "/api/v1/batches", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	if err := req.ParseMultipartForm(1 << 20); err != nil {
+		t.Fatalf("ParseMultipartForm() error = %v", err)
+	}
+	return req.MultipartForm
+}
+
+func newZip(t *testing.T, entries map[string][]byte) []byte {
+	t.Helper()
+
+	buf := &bytes.Buffer{}
+	zw := zip.NewWriter(buf)
+	for name, data := range entries {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatalf("zip Create() error = %v", err)
+		}
+		w.Write(data)
+	}
+	zw.Close()
+	return buf.Bytes()
+}
+
+func TestHandlers_CollectBatchFiles(t *testing.T) {
+	h := &Handlers{}
+
+	archive := newZip(t, map[string][]byte{
+		"photos/a.jpg":          []byte("jpeg"),
+		"photos/b.png":          []byte("png"),
+		"photos/notes.txt":      []byte("text"),
+		"__MACOSX/photos/a.jpg": []byte("junk"),
+		"photos/.hidden.jpg":    []byte("junk"),
+	})
+	form := newBatchForm(t, map[string][]byte{"c.gif": []byte("gif")}, map[string][]byte{"catalog.zip": archive})
+
+	files, closeArchives, err := h.collectBatchFiles(form)
+	defer closeArchives()
+	if err != nil {
+		t.Fatalf("collectBatchFiles() error = %v", err)
+	}
+
+	got := make(map[string]string)
+	for _, f := range files {
+		got[f.name] = f.contentType
+	}
+	want := map[string]string{"a.jpg": "image/jpeg", "b.png": "image/png", "c.gif": "image/gif"}
+	if len(got) != len(want) {
+		t.Fatalf("files = %v, want %v", got, want)
+	}
+	for name, contentType := range want {
+		if got[name] != contentType {
+			t.Errorf("file %s content type = %q, want %q", name, got[name], contentType)
+		}
+	}
+}
+
+func TestHandlers_CollectBatchFiles_Errors(t *testing.T) {
+	tests := []struct {
+		name     string
+		h        *Handlers
+		images   map[string][]byte
+		archives map[string][]byte
+	}{
+		{"no files", &Handlers{}, nil, nil},
+		{"invalid image type", &Handlers{}, map[string][]byte{"doc.pdf": []byte("pdf")}, nil},
+		{"invalid archive", &Handlers{}, nil, map[string][]byte{"bad.zip": []byte("not a zip")}},
+		{"too many files", &Handlers{batchMaxFiles: 1}, map[string][]byte{"a.jpg": []byte("a"), "b.jpg": []byte("b")}, nil},
+		{"file too large", &Handlers{maxFileSize: 2}, map[string][]byte{"a.jpg": []byte("abc")}, nil},
+		{"batch too large", &Handlers{batchMaxUploadSize: 3}, map[string][]byte{"a.jpg": []byte("ab"), "b.jpg": []byte("ab")}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := newBatchForm(t, tt.images, tt.archives)
+			_, closeArchives, err := tt.h.collectBatchFiles(form)
+			closeArchives()
+			if err == nil {
+				t.Error("collectBatchFiles() error = nil, want error")
+			}
+		})
+	}
+}
+
+func TestHandlers_GetBatch_InvalidID(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	h := &Handlers{logger: logger}
+
+	req := httptest.NewRequest("GET", "/api/v1/batches/not-a-uuid", http.NoBody)
+	recorder := httptest.NewRecorder()
+
+	r := chi.NewRouter()
+	r.Get("/api/v1/batches/{id}", h.GetBatch)
+	r.ServeHTTP(recorder, req)
+
+	if recorder.Code != http.StatusBadRequest {
+		t.Errorf("Status = %d, want %d", recorder.Code, http.StatusBadRequest)
+	}
+}
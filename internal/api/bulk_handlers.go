@@ -0,0 +1,226 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/google/uuid"
+
+	"github.com/timkrebs/image-processor/internal/cleanup"
+	"github.com/timkrebs/image-processor/internal/database"
+	"github.com/timkrebs/image-processor/internal/models"
+)
+
+// maxBulkJobs caps the number of jobs one bulk request may act on
+const maxBulkJobs = 100
+
+// bulkFilterParams are the job list parameters a bulk filter may use. Anything
+// else, such as a misspelt name, is rejected rather than ignored, since it
+// would widen the selection.
+var bulkFilterParams = map[string]bool{
+	"status":         true,
+	"operation":      true,
+	"content_type":   true,
+	"name_prefix":    true,
+	"name":           true,
+	"created_after":  true,
+	"created_before": true,
+	"min_size":       true,
+	"max_size":       true,
+}
+
+// BulkJobs handles POST /api/v1/jobs/bulk. It cancels, deletes or retries the
+// requester's jobs listed by ID or matched by a filter, and reports the outcome
+// per job. Jobs the action doesn't apply to are skipped.
+func (h *Handlers) BulkJobs(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req models.BulkJobRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if err := req.Validate(); err != nil {
+		h.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if len(req.IDs) > maxBulkJobs {
+		h.writeError(w, http.StatusBadRequest, fmt.Sprintf("at most %d jobs can be changed at once", maxBulkJobs))
+		return
+	}
+
+	filter := database.JobFilter{IDs: req.IDs}
+	if len(req.Filter) > 0 {
+		query := url.Values{}
+		for name, value := range req.Filter {
+			if !bulkFilterParams[name] {
+				h.writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid filter: unknown parameter %q", name))
+				return
+			}
+			query.Set(name, value)
+		}
+		var err error
+		if filter, _, err = parseJobFilter(query); err != nil {
+			h.writeError(w, http.StatusBadRequest, "invalid filter: "+err.Error())
+			return
+		}
+		if filter.IsEmpty() {
+			h.writeError(w, http.StatusBadRequest, "invalid filter: it must restrict the jobs selected")
+			return
+		}
+	}
+
+	owner := requestOwner(r)
+	jobs, more, err := h.jobRepo.ListAfter(ctx, owner, filter, nil, maxBulkJobs)
+	if err != nil {
+		h.logger.Error("failed to select jobs", "error", err)
+		h.writeError(w, http.StatusInternalServerError, "failed to select jobs")
+		return
+	}
+	if more {
+		h.writeError(w, http.StatusBadRequest, fmt.Sprintf("the filter matches more than %d jobs", maxBulkJobs))
+		return
+	}
+
+	response := &models.BulkJobResponse{Action: req.Action, Items: []*models.BulkJobItem{}}
+	switch req.Action {
+	case models.BulkActionCancel:
+		h.bulkCancel(ctx, owner, jobs, response)
+	case models.BulkActionDelete:
+		h.bulkDelete(ctx, jobs, response)
+	case models.BulkActionRetry:
+		if !h.bulkRetry(w, r, owner, jobs, response) {
+			return
+		}
+	}
+
+	// Listed IDs that aren't the requester's jobs
+	seen := make(map[uuid.UUID]bool, len(jobs))
+	for _, job := range jobs {
+		seen[job.ID] = true
+	}
+	for _, id := range req.IDs {
+		if !seen[id] {
+			seen[id] = true
+			response.Add(&models.BulkJobItem{ID: id, Result: models.BulkResultNotFound, Error: "job not found"})
+		}
+	}
+
+	h.logger.Info("bulk job action", "action", req.Action, "succeeded", response.Succeeded,
+		"skipped", response.Skipped, "failed", response.Failed)
+	h.writeJSON(w, http.StatusOK, response)
+}
+
+// bulkCancel cancels the jobs that haven't started processing in one statement
+func (h *Handlers) bulkCancel(ctx context.Context, owner models.JobOwner, jobs []*models.Job, response *models.BulkJobResponse) {
+	var ids []uuid.UUID
+	for _, job := range jobs {
+		if job.Status == models.JobStatusPending || job.Status == models.JobStatusScheduled || job.Status == models.JobStatusQueued {
+			ids = append(ids, job.ID)
+		}
+	}
+
+	canceled := make(map[uuid.UUID]bool)
+	var cancelErr error
+	if len(ids) > 0 {
+		var result []uuid.UUID
+		result, cancelErr = h.jobRepo.CancelJobs(ctx, owner, ids)
+		if cancelErr != nil {
+			h.logger.Error("failed to cancel jobs", "error", cancelErr)
+		}
+		for _, id := range result {
+			canceled[id] = true
+		}
+	}
+
+	for _, job := range jobs {
+		item := &models.BulkJobItem{ID: job.ID, Result: models.BulkResultSucceeded}
+		switch {
+		case canceled[job.ID]:
+			// Drop the job from the scheduler so it is never dispatched
+			if job.Status == models.JobStatusScheduled && h.scheduler != nil {
+				if _, err := h.scheduler.Cancel(ctx, job.ID); err != nil {
+					h.logger.Error("failed to remove job from scheduler", "job_id", job.ID, "error", err)
+				}
+			}
+		case cancelErr != nil:
+			item.Result, item.Error = models.BulkResultFailed, "failed to cancel job"
+		default:
+			item.Result, item.Error = models.BulkResultSkipped, fmt.Sprintf("%s jobs can't be canceled", job.Status)
+		}
+		response.Add(item)
+	}
+}
+
+// bulkDelete removes finished jobs and their files through the cleanup worker,
+// as if their retention had expired
+func (h *Handlers) bulkDelete(ctx context.Context, jobs []*models.Job, response *models.BulkJobResponse) {
+	var deletable []*models.Job
+	for _, job := range jobs {
+		if job.Status.IsTerminal() {
+			deletable = append(deletable, job)
+		}
+	}
+
+	failed := cleanup.NewWorker(h.jobRepo, h.storage, cleanup.Config{}, h.logger).PurgeJobs(ctx, deletable)
+
+	for _, job := range jobs {
+		item := &models.BulkJobItem{ID: job.ID, Result: models.BulkResultSucceeded}
+		switch {
+		case !job.Status.IsTerminal():
+			item.Result, item.Error = models.BulkResultSkipped, fmt.Sprintf("%s jobs must be canceled before deleting them", job.Status)
+		case failed[job.ID] != nil:
+			item.Result, item.Error = models.BulkResultFailed, "failed to delete job"
+		}
+		response.Add(item)
+	}
+}
+
+// bulkRetry runs failed and canceled jobs again as new jobs. The quota must
+// allow all of them, otherwise the error response is written and false returned.
+func (h *Handlers) bulkRetry(w http.ResponseWriter, r *http.Request, owner models.JobOwner, jobs []*models.Job, response *models.BulkJobResponse) bool {
+	var count int
+	var size int64
+	for _, job := range jobs {
+		if canRetry(job) == nil {
+			count++
+			size += job.FileSize
+		}
+	}
+	if count > 0 && !h.checkQuota(w, r, owner, count, size) {
+		return false
+	}
+
+	for _, job := range jobs {
+		item := &models.BulkJobItem{ID: job.ID, Result: models.BulkResultSucceeded}
+		if err := canRetry(job); err != nil {
+			item.Result, item.Error = models.BulkResultSkipped, err.Error()
+			response.Add(item)
+			continue
+		}
+
+		child, err := h.createJobFromOriginal(r.Context(), owner, h.retryOptions(job), job)
+		switch {
+		case child != nil:
+			item.NewJobID = &child.ID
+			if err != nil {
+				// The job exists but couldn't be queued; it stays pending
+				item.Result, item.Error = models.BulkResultFailed, jobErrorMessage(err)
+			}
+		case errors.Is(err, errOriginalGone):
+			item.Result, item.Error = models.BulkResultFailed, err.Error()
+		case errors.As(err, new(*models.QuotaExceededError)):
+			// A concurrent submission used up the quota checked above
+			item.Result, item.Error = models.BulkResultFailed, jobErrorMessage(err)
+		case err != nil:
+			h.logger.Error("failed to retry job", "job_id", job.ID, "error", err)
+			item.Result, item.Error = models.BulkResultFailed, "failed to retry job"
+		}
+		response.Add(item)
+	}
+	return true
+}
@@ -0,0 +1,50 @@
+package api
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestHandlers_BulkJobs_InvalidRequest(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	h := &Handlers{logger: logger}
+
+	ids := make([]string, maxBulkJobs+1)
+	for i := range ids {
+		ids[i] = `"` + uuid.NewString() + `"`
+	}
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"malformed JSON", `{"action":`},
+		{"unknown action", `{"action": "archive", "ids": ["` + uuid.NewString() + `"]}`},
+		{"no selection", `{"action": "cancel"}`},
+		{"invalid ID", `{"action": "cancel", "ids": ["not-a-uuid"]}`},
+		{"too many IDs", `{"action": "delete", "ids": [` + strings.Join(ids, ",") + `]}`},
+		{"invalid filter", `{"action": "delete", "filter": {"status": "done"}}`},
+		{"misspelt filter", `{"action": "delete", "filter": {"stauts": "failed"}}`},
+		{"sort only", `{"action": "delete", "filter": {"sort": "file_size"}}`},
+		{"empty filter values", `{"action": "delete", "filter": {"status": "", "min_size": "0"}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/api/v1/jobs/bulk", strings.NewReader(tt.body))
+			recorder := httptest.NewRecorder()
+
+			h.BulkJobs(recorder, req)
+
+			if recorder.Code != http.StatusBadRequest {
+				t.Errorf("Status = %d, want %d", recorder.Code, http.StatusBadRequest)
+			}
+		})
+	}
+}
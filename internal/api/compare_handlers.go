@@ -0,0 +1,127 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/timkrebs/image-processor/internal/models"
+	"github.com/timkrebs/image-processor/internal/processor"
+	"github.com/timkrebs/image-processor/internal/storage"
+)
+
+const defaultCompareConcurrency = 2
+
+// errCompareBusy is returned when no comparison slot frees up in time
+var errCompareBusy = errors.New("too many comparisons in progress")
+
+// SetCompareConcurrency limits how many comparisons are rendered at a time
+func (h *Handlers) SetCompareConcurrency(concurrency int) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	h.compareSlots = make(chan struct{}, concurrency)
+}
+
+// compareKey is where the comparison of a job in mode is cached
+func compareKey(job *models.Job, mode string) string {
+	return job.DerivedPrefix() + "compare-" + mode + ".png"
+}
+
+// CompareJob handles GET /api/v1/jobs/{id}/compare
+// Serves a PNG comparing a completed job's output with its original:
+// ?mode=side-by-side (the default) or ?mode=diff for the amplified difference.
+// It is rendered on first use and cached with the job's derived images.
+func (h *Handlers) CompareJob(w http.ResponseWriter, r *http.Request) {
+	mode := r.URL.Query().Get("mode")
+	switch mode {
+	case "":
+		mode = processor.CompareSideBySide
+	case processor.CompareSideBySide, processor.CompareDiff:
+	default:
+		h.writeError(w, http.StatusBadRequest, "mode must be "+processor.CompareSideBySide+" or "+processor.CompareDiff)
+		return
+	}
+
+	job := h.loadOwnedJob(w, r)
+	if job == nil {
+		return
+	}
+	if job.Status != models.JobStatusCompleted || job.ProcessedKey == "" {
+		h.writeError(w, http.StatusConflict, "job has no output to compare")
+		return
+	}
+
+	ctx := r.Context()
+	key := compareKey(job, mode)
+	exists, err := h.storage.Exists(ctx, key)
+	if err != nil {
+		h.logger.Error("failed to check comparison", "key", key, "error", err)
+		h.writeError(w, http.StatusInternalServerError, "failed to compare images")
+		return
+	}
+
+	if !exists {
+		// Comparisons rendered before the original was deleted are still served
+		if job.OriginalDeletedAt != nil {
+			h.writeError(w, http.StatusGone, "original was deleted after processing")
+			return
+		}
+
+		err, shared := h.compareFlights.Do(key, func() error {
+			return h.renderComparison(ctx, job, mode, key)
+		})
+		if errors.Is(err, errCompareBusy) {
+			w.Header().Set("Retry-After", "1")
+			h.writeError(w, http.StatusServiceUnavailable, err.Error())
+			return
+		}
+		if err != nil {
+			h.logger.Error("failed to compare images", "job_id", job.ID, "error", err)
+			h.writeError(w, http.StatusInternalServerError, "failed to compare images")
+			return
+		}
+		if !shared {
+			h.logger.Info("comparison rendered", "job_id", job.ID, "key", key)
+		}
+	}
+
+	// A completed job's images never change
+	h.serveImage(w, r, key, "image/png", storage.PresignOptions{
+		CacheControl: h.imageCacheControl(true),
+	})
+}
+
+// renderComparison compares the job's output with its original and stores
+// the PNG at key. Like renderVariant it runs detached from the request.
+func (h *Handlers) renderComparison(ctx context.Context, job *models.Job, mode, key string) error {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), transformTimeout)
+	defer cancel()
+
+	select {
+	case h.compareSlots <- struct{}{}:
+		defer func() { <-h.compareSlots }()
+	case <-ctx.Done():
+		return errCompareBusy
+	}
+
+	original, err := h.storage.Download(ctx, job.OriginalKey)
+	if err != nil {
+		return err
+	}
+	defer original.Close()
+
+	output, err := h.storage.Download(ctx, job.ProcessedKey)
+	if err != nil {
+		return err
+	}
+	defer output.Close()
+
+	result, err := h.processor.Compare(original, output, mode)
+	if err != nil {
+		return err
+	}
+
+	return h.storage.Upload(ctx, key, bytes.NewReader(result.Data), int64(len(result.Data)), result.ContentType)
+}
@@ -0,0 +1,80 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/png"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"github.com/timkrebs/image-processor/internal/models"
+	"github.com/timkrebs/image-processor/internal/processor"
+	"github.com/timkrebs/image-processor/internal/storage"
+)
+
+func TestHandlers_CompareJob_BadRequest(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	h := &Handlers{logger: logger}
+
+	r := chi.NewRouter()
+	r.Get("/api/v1/jobs/{id}/compare", h.CompareJob)
+
+	paths := []string{
+		"/api/v1/jobs/not-a-uuid/compare",
+		"/api/v1/jobs/not-a-uuid/compare?mode=diff",
+		"/api/v1/jobs/00000000-0000-0000-0000-000000000001/compare?mode=overlay",
+	}
+
+	for _, path := range paths {
+		t.Run(path, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			r.ServeHTTP(recorder, httptest.NewRequest("GET", path, http.NoBody))
+
+			if recorder.Code != http.StatusBadRequest {
+				t.Errorf("Status = %d, want %d", recorder.Code, http.StatusBadRequest)
+			}
+		})
+	}
+}
+
+func TestHandlers_renderComparison(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemory()
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	h := &Handlers{logger: logger, storage: store, processor: processor.New()}
+	h.SetCompareConcurrency(1)
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewNRGBA(image.Rect(0, 0, 20, 10))); err != nil {
+		t.Fatal(err)
+	}
+	job := &models.Job{ID: uuid.New(), UserID: uuid.New(), OriginalKey: "original.png", ProcessedKey: "processed.png"}
+	for _, key := range []string{job.OriginalKey, job.ProcessedKey} {
+		if err := store.Upload(ctx, key, bytes.NewReader(buf.Bytes()), int64(buf.Len()), "image/png"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	key := compareKey(job, processor.CompareDiff)
+	if err := h.renderComparison(ctx, job, processor.CompareDiff, key); err != nil {
+		t.Fatalf("renderComparison() error = %v", err)
+	}
+
+	info, err := store.Stat(ctx, key)
+	if err != nil {
+		t.Fatalf("comparison not cached: %v", err)
+	}
+	if info.ContentType != "image/png" {
+		t.Errorf("ContentType = %s, want image/png", info.ContentType)
+	}
+	if len(h.compareSlots) != 0 {
+		t.Errorf("%d comparison slots still taken", len(h.compareSlots))
+	}
+}
@@ -0,0 +1,43 @@
+package api
+
+import "sync"
+
+// flightGroup collapses concurrent calls for the same key into one execution,
+// so a burst of requests for a missing variant renders it only once
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*flightCall
+}
+
+type flightCall struct {
+	done chan struct{}
+	err  error
+}
+
+// Do runs fn unless a call for key is already in flight, in which case it waits
+// for that call and returns its error. shared reports whether the result was reused.
+func (g *flightGroup) Do(key string, fn func() error) (err error, shared bool) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*flightCall)
+	}
+	if call, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		<-call.done
+		return call.err, true
+	}
+
+	call := &flightCall{done: make(chan struct{})}
+	g.calls[key] = call
+	g.mu.Unlock()
+
+	defer func() {
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+		close(call.done)
+	}()
+
+	call.err = fn()
+	return call.err, false
+}
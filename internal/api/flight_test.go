@@ -0,0 +1,56 @@
+package api
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestFlightGroup_Do(t *testing.T) {
+	var g flightGroup
+	var calls atomic.Int32
+	release := make(chan struct{})
+	errRender := errors.New("render failed")
+
+	var wg sync.WaitGroup
+	var shared atomic.Int32
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err, wasShared := g.Do("key", func() error {
+				calls.Add(1)
+				<-release
+				return errRender
+			})
+			if !errors.Is(err, errRender) {
+				t.Errorf("Do() error = %v, want %v", err, errRender)
+			}
+			if wasShared {
+				shared.Add(1)
+			}
+		}()
+	}
+
+	// Hold the flight open until it has started, so late callers can join it
+	for {
+		g.mu.Lock()
+		started := g.calls["key"] != nil
+		g.mu.Unlock()
+		if started && calls.Load() == 1 {
+			break
+		}
+	}
+	close(release)
+	wg.Wait()
+
+	if calls.Load() < 1 || int(calls.Load())+int(shared.Load()) != 10 {
+		t.Errorf("calls = %d, shared = %d, want every caller to run or share", calls.Load(), shared.Load())
+	}
+
+	// Once finished, the key runs again
+	if err, wasShared := g.Do("key", func() error { return nil }); err != nil || wasShared {
+		t.Errorf("Do() = %v, %v, want a fresh call", err, wasShared)
+	}
+}
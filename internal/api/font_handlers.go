@@ -0,0 +1,169 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"github.com/timkrebs/image-processor/internal/database"
+	"github.com/timkrebs/image-processor/internal/models"
+	"github.com/timkrebs/image-processor/internal/processor"
+)
+
+// fontName picks the name of an uploaded font: the one requested, else the
+// font's family name, else its file name without extension
+func fontName(requested, family, filename string) (string, error) {
+	if requested != "" {
+		if err := models.ValidateFontName(requested); err != nil {
+			return "", err
+		}
+		return requested, nil
+	}
+	for _, name := range []string{family, strings.TrimSuffix(filename, filepath.Ext(filename))} {
+		if models.ValidateFontName(name) == nil {
+			return name, nil
+		}
+	}
+	return "", models.ErrInvalidFontName
+}
+
+// ListFonts handles GET /api/v1/fonts. It lists the embedded fonts, and the
+// requester's uploaded fonts unless they are anonymous.
+func (h *Handlers) ListFonts(w http.ResponseWriter, r *http.Request) {
+	resp := models.FontListResponse{Builtin: processor.BuiltinFonts(), Fonts: []*models.Font{}}
+
+	if owner := requestOwner(r); owner.AnonymousID == nil {
+		fonts, err := h.jobRepo.ListFonts(r.Context(), owner.UserID)
+		if err != nil {
+			h.logger.Error("failed to list fonts", "error", err)
+			h.writeError(w, http.StatusInternalServerError, "failed to list fonts")
+			return
+		}
+		resp.Fonts = fonts
+	}
+
+	h.writeJSON(w, http.StatusOK, resp)
+}
+
+// CreateFont handles POST /api/v1/fonts. It takes a TrueType or OpenType file
+// as the font form field, and an optional name.
+func (h *Handlers) CreateFont(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if err := r.ParseMultipartForm(models.MaxFontSize); err != nil {
+		h.writeError(w, http.StatusBadRequest, "failed to parse form: "+err.Error())
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	file, header, err := r.FormFile("font")
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "font file is required")
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, models.MaxFontSize+1))
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "failed to read font file")
+		return
+	}
+	if len(data) > models.MaxFontSize {
+		h.writeError(w, http.StatusRequestEntityTooLarge, models.ErrFontTooLarge.Error())
+		return
+	}
+
+	family, err := processor.ParseFont(data)
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "font must be a TrueType or OpenType file")
+		return
+	}
+	name, err := fontName(r.FormValue("name"), family, header.Filename)
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	userID := requestOwner(r).UserID
+	font := &models.Font{
+		ID:     uuid.New(),
+		UserID: userID,
+		Name:   name,
+		Size:   int64(len(data)),
+	}
+	font.StorageKey = models.FontKey(userID, font.ID)
+
+	if err := h.storage.Upload(ctx, font.StorageKey, bytes.NewReader(data), font.Size, http.DetectContentType(data)); err != nil {
+		h.logger.Error("failed to upload font", "error", err)
+		h.writeError(w, http.StatusInternalServerError, "failed to store font")
+		return
+	}
+
+	err = h.jobRepo.CreateFont(ctx, font)
+	if err != nil {
+		// The file is of no use without its row
+		if deleteErr := h.storage.Delete(ctx, font.StorageKey); deleteErr != nil {
+			h.logger.Warn("failed to delete unrecorded font", "key", font.StorageKey, "error", deleteErr)
+		}
+	}
+	if errors.Is(err, database.ErrFontExists) {
+		h.writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		h.logger.Error("failed to create font", "error", err)
+		h.writeError(w, http.StatusInternalServerError, "failed to create font")
+		return
+	}
+
+	h.logger.Info("font created", "font_id", font.ID, "name", font.Name, "size", font.Size)
+	h.writeJSON(w, http.StatusCreated, font)
+}
+
+// DeleteFont handles DELETE /api/v1/fonts/{id}. Jobs still using the font
+// fail when they are processed.
+func (h *Handlers) DeleteFont(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "invalid font ID")
+		return
+	}
+
+	font, err := h.jobRepo.GetFont(ctx, id)
+	if errors.Is(err, database.ErrFontNotFound) || (err == nil && font.UserID != requestOwner(r).UserID) {
+		h.writeError(w, http.StatusNotFound, "font not found")
+		return
+	}
+	if err != nil {
+		h.logger.Error("failed to get font", "error", err)
+		h.writeError(w, http.StatusInternalServerError, "failed to get font")
+		return
+	}
+
+	err = h.jobRepo.DeleteFont(ctx, font.ID, font.UserID)
+	if errors.Is(err, database.ErrFontNotFound) {
+		h.writeError(w, http.StatusNotFound, "font not found")
+		return
+	}
+	if err != nil {
+		h.logger.Error("failed to delete font", "font_id", font.ID, "error", err)
+		h.writeError(w, http.StatusInternalServerError, "failed to delete font")
+		return
+	}
+
+	// Reconciliation removes the file if this fails
+	if err := h.storage.Delete(ctx, font.StorageKey); err != nil {
+		h.logger.Warn("failed to delete font file", "font_id", font.ID, "key", font.StorageKey, "error", err)
+	}
+
+	h.logger.Info("font deleted", "font_id", font.ID)
+	h.writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+}
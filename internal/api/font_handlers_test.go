@@ -0,0 +1,90 @@
+package api
+
+import (
+	"bytes"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/timkrebs/image-processor/internal/models"
+)
+
+func TestFontName(t *testing.T) {
+	tests := []struct {
+		requested string
+		family    string
+		filename  string
+		want      string
+		wantErr   bool
+	}{
+		{"Brand", "Go", "go.ttf", "Brand", false},
+		{"", "Go Mono", "go.ttf", "Go Mono", false},
+		{"", "", "brand-sans.ttf", "brand-sans", false},
+		{"", "Schrift/Kursiv", "kursiv.otf", "kursiv", false},
+		{"bad/name", "Go", "go.ttf", "", true},
+		{"", "", "/.ttf", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := fontName(tt.requested, tt.family, tt.filename)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("fontName(%q, %q, %q) error = %v, wantErr %v", tt.requested, tt.family, tt.filename, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("fontName(%q, %q, %q) = %q, want %q", tt.requested, tt.family, tt.filename, got, tt.want)
+		}
+	}
+}
+
+func TestHandlers_Fonts_InvalidRequest(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	h := &Handlers{logger: logger}
+
+	r := chi.NewRouter()
+	r.Post("/api/v1/fonts", h.CreateFont)
+	r.Delete("/api/v1/fonts/{id}", h.DeleteFont)
+
+	upload := func(field, filename string, data []byte, name string) *http.Request {
+		body := &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+		if field != "" {
+			part, _ := writer.CreateFormFile(field, filename)
+			part.Write(data)
+		}
+		if name != "" {
+			writer.WriteField("name", name)
+		}
+		writer.Close()
+		req := httptest.NewRequest("POST", "/api/v1/fonts", body)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		return req
+	}
+
+	requests := []struct {
+		name   string
+		req    *http.Request
+		status int
+	}{
+		{"no file", upload("", "", nil, "Brand"), http.StatusBadRequest},
+		{"not a font", upload("font", "brand.ttf", []byte("not a font"), ""), http.StatusBadRequest},
+		{"too large", upload("font", "brand.ttf", make([]byte, models.MaxFontSize+1), ""), http.StatusRequestEntityTooLarge},
+		{"invalid ID", httptest.NewRequest("DELETE", "/api/v1/fonts/not-a-uuid", nil), http.StatusBadRequest},
+	}
+
+	for _, tt := range requests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			r.ServeHTTP(recorder, tt.req)
+
+			if recorder.Code != tt.status {
+				t.Errorf("Status = %d, want %d", recorder.Code, tt.status)
+			}
+		})
+	}
+}
@@ -2,21 +2,27 @@ package api
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
+	"net"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
 	"time"
 
-	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
 
 	"github.com/timkrebs/image-processor/internal/database"
 	"github.com/timkrebs/image-processor/internal/metrics"
 	"github.com/timkrebs/image-processor/internal/models"
+	"github.com/timkrebs/image-processor/internal/processor"
 	"github.com/timkrebs/image-processor/internal/queue"
 	"github.com/timkrebs/image-processor/internal/storage"
 )
@@ -24,31 +30,58 @@ import (
 // Handlers holds all HTTP handlers
 type Handlers struct {
 	jobRepo    *database.JobRepository
-	storage    *storage.Storage
+	storage    storage.Backend
+	multipart  storage.MultipartBackend
 	producer   *queue.Producer
+	scheduler  *queue.Scheduler
+	limiter    *RateLimiter
+	proxies    []*net.IPNet
+	anonymous  *AnonymousIdentity
 	logger     *slog.Logger
 	db         *database.DB
 	jobMetrics *metrics.JobMetrics
+	processor  *processor.Processor
 	groupName  string
+	quotas     models.QuotaLimits
+
+	batchMaxFiles      int
+	batchMaxUploadSize int64
+	maxFileSize        int64
+	uploadURLExpiry    time.Duration
+	resumableTTL       time.Duration
+	imageDelivery      string
+	imageURLExpiry     time.Duration
+	imageCacheMaxAge   time.Duration
+	transforms         *imageTransformer
+	compareSlots       chan struct{}
+	compareFlights     flightGroup
+	originalDeletion   bool
 }
 
 // NewHandlers creates a new handlers instance
 func NewHandlers(
 	jobRepo *database.JobRepository,
-	storage *storage.Storage,
+	store storage.Backend,
 	producer *queue.Producer,
 	db *database.DB,
 	groupName string,
 	logger *slog.Logger,
 ) *Handlers {
-	return &Handlers{
+	h := &Handlers{
 		jobRepo:   jobRepo,
-		storage:   storage,
+		storage:   store,
 		producer:  producer,
 		db:        db,
+		processor: processor.New(),
 		groupName: groupName,
 		logger:    logger,
 	}
+	h.SetCompareConcurrency(defaultCompareConcurrency)
+	// Resumable uploads need a backend that can assemble parts
+	if multipart, ok := store.(storage.MultipartBackend); ok {
+		h.multipart = multipart
+	}
+	return h
 }
 
 // SetMetrics injects metrics collectors into handlers
@@ -56,6 +89,44 @@ func (h *Handlers) SetMetrics(jobMetrics *metrics.JobMetrics) {
 	h.jobMetrics = jobMetrics
 }
 
+// SetScheduler enables scheduled and delayed job submission
+func (h *Handlers) SetScheduler(scheduler *queue.Scheduler) {
+	h.scheduler = scheduler
+}
+
+// SetQuotas sets the default per-user quota limits applied to job submission
+func (h *Handlers) SetQuotas(defaults models.QuotaLimits) {
+	h.quotas = defaults
+}
+
+// SetAnonymousIdentity enables anonymous job submission using signed identity cookies.
+// Without it all job endpoints require authentication.
+func (h *Handlers) SetAnonymousIdentity(identity *AnonymousIdentity) {
+	h.anonymous = identity
+}
+
+// SetOriginalDeletion lets submitters ask for their originals to be deleted
+// as soon as processing succeeds, with delete_original=true
+func (h *Handlers) SetOriginalDeletion(allow bool) {
+	h.originalDeletion = allow
+}
+
+// SetRateLimiter records the job submission rate limiter for usage reporting
+func (h *Handlers) SetRateLimiter(limiter *RateLimiter) {
+	h.limiter = limiter
+}
+
+// SetTrustedProxies sets the proxies, as CIDRs or IPs, whose forwarded
+// client addresses are believed. Without any the connection address is used.
+func (h *Handlers) SetTrustedProxies(proxies []string) error {
+	nets, err := ParseTrustedProxies(proxies)
+	if err != nil {
+		return err
+	}
+	h.proxies = nets
+	return nil
+}
+
 // writeJSON writes a JSON response
 func (h *Handlers) writeJSON(w http.ResponseWriter, status int, data interface{}) {
 	w.Header().Set("Content-Type", "application/json")
@@ -74,22 +145,20 @@ func (h *Handlers) writeError(w http.ResponseWriter, status int, message string)
 func (h *Handlers) CreateJob(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
-	// Get user session (optional for backward compatibility)
-	session, ok := GetSession(ctx)
-	var userID uuid.UUID
-	if ok && session != nil {
-		userID = session.UserID
-	} else {
-		// Use system user for unauthenticated requests
-		userID = uuid.MustParse("00000000-0000-0000-0000-000000000000")
-	}
+	owner := requestOwner(r)
 
-	// Parse multipart form
-	if err := r.ParseMultipartForm(50 << 20); err != nil { // 50MB max
+	// Parse form; requests referencing an upload may be URL-encoded
+	if err := r.ParseMultipartForm(50 << 20); err != nil && !errors.Is(err, http.ErrNotMultipart) { // 50MB max
 		h.writeError(w, http.StatusBadRequest, "failed to parse form: "+err.Error())
 		return
 	}
 
+	// Create from a direct-to-storage upload instead of a file
+	if uploadID := r.FormValue("upload_id"); uploadID != "" {
+		h.createJobFromUpload(w, r, owner, uploadID)
+		return
+	}
+
 	// Get the uploaded file
 	file, header, err := r.FormFile("image")
 	if err != nil {
@@ -109,129 +178,338 @@ func (h *Handlers) CreateJob(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	opts, err := parseJobOptions(r, time.Now())
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !h.checkJobOptions(w, opts) || !h.resolvePreset(w, r, &opts) {
+		return
+	}
+
+	// Enforce quotas before accepting the upload
+	if !h.checkQuota(w, r, owner, 1, header.Size) {
+		return
+	}
+
+	job, err := h.createJob(ctx, owner, opts, nil, header.Filename, contentType, file, header.Size)
+	if err != nil {
+		h.logger.Error("failed to create job", "error", err)
+		h.writeJobError(w, owner, err)
+		return
+	}
+
+	h.logger.Info("job created", "job_id", job.ID, "priority", job.Priority, "status", job.Status, "operations", len(job.Operations))
+	h.writeJSON(w, http.StatusCreated, job)
+}
+
+// jobOptions holds the processing options shared by single and batch submissions
+type jobOptions struct {
+	runAt          time.Time
+	priority       models.JobPriority
+	operations     []models.Operation
+	deleteOriginal bool
+	parentJobID    *uuid.UUID
+	preset         *presetRef
+	presetID       *uuid.UUID
+	presetVersion  *int
+}
+
+// parseJobOptions parses and validates the operations or preset, priority, schedule and
+// delete_original form fields. Presets are resolved afterwards by resolvePreset.
+func parseJobOptions(r *http.Request, now time.Time) (jobOptions, error) {
+	var opts jobOptions
+
 	// Parse operations
-	operationsJSON := r.FormValue("operations")
-	var operations []models.Operation
-	if operationsJSON != "" {
-		if err := json.Unmarshal([]byte(operationsJSON), &operations); err != nil {
-			h.writeError(w, http.StatusBadRequest, "invalid operations JSON: "+err.Error())
-			return
+	if operationsJSON := r.FormValue("operations"); operationsJSON != "" {
+		if err := json.Unmarshal([]byte(operationsJSON), &opts.operations); err != nil {
+			return opts, fmt.Errorf("invalid operations JSON: %w", err)
 		}
 	}
 
+	// Parse the preset to take operations from instead
+	preset, err := parsePresetRef(r)
+	if err != nil {
+		return opts, err
+	}
+	if preset != nil && len(opts.operations) > 0 {
+		return opts, fmt.Errorf("operations and preset are mutually exclusive")
+	}
+	opts.preset = preset
+
 	// Validate operations
-	for _, op := range operations {
+	for _, op := range opts.operations {
 		if !isValidOperation(op.Operation) {
-			h.writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid operation: %s", op.Operation))
-			return
+			return opts, fmt.Errorf("invalid operation: %s", op.Operation)
+		}
+	}
+
+	// Parse priority
+	priority, err := models.ParsePriority(r.FormValue("priority"))
+	if err != nil {
+		return opts, err
+	}
+	opts.priority = priority
+
+	// Parse optional schedule
+	opts.runAt, err = parseRunAt(r.FormValue("run_at"), r.FormValue("delay"), now)
+	if err != nil {
+		return opts, err
+	}
+
+	// Parse optional deletion of the original after processing
+	if value := r.FormValue("delete_original"); value != "" {
+		opts.deleteOriginal, err = strconv.ParseBool(value)
+		if err != nil {
+			return opts, fmt.Errorf("invalid delete_original: %s", value)
 		}
 	}
 
 	// Default operation if none provided
-	if len(operations) == 0 {
-		operations = []models.Operation{
+	if len(opts.operations) == 0 && opts.preset == nil {
+		opts.operations = []models.Operation{
 			{Operation: models.OperationThumbnail, Parameters: map[string]interface{}{"size": 150}},
 		}
 	}
 
-	// Generate storage key with user isolation
+	return opts, nil
+}
+
+// checkJobOptions rejects options this deployment can't honor, writing the error response
+func (h *Handlers) checkJobOptions(w http.ResponseWriter, opts jobOptions) bool {
+	if !opts.runAt.IsZero() && h.scheduler == nil {
+		h.writeError(w, http.StatusServiceUnavailable, "job scheduling is not available")
+		return false
+	}
+	if opts.deleteOriginal && !h.originalDeletion {
+		h.writeError(w, http.StatusBadRequest, "deleting originals after processing is not enabled")
+		return false
+	}
+	return true
+}
+
+// jobError records which step of job creation failed, for the client-facing message
+type jobError struct {
+	err     error
+	message string
+}
+
+func (e *jobError) Error() string {
+	return e.message + ": " + e.err.Error()
+}
+
+func (e *jobError) Unwrap() error {
+	return e.err
+}
+
+// jobErrorMessage returns the client-facing message for a createJob error
+func jobErrorMessage(err error) string {
+	var jobErr *jobError
+	if errors.As(err, &jobErr) {
+		return jobErr.message
+	}
+	return "failed to create job"
+}
+
+// createJob uploads an original, persists its job and submits it for processing
+func (h *Handlers) createJob(
+	ctx context.Context,
+	owner models.JobOwner,
+	opts jobOptions,
+	batchID *uuid.UUID,
+	filename, contentType string,
+	file io.Reader,
+	size int64,
+) (*models.Job, error) {
+	// Stage the upload under a unique key, hashing it on the way
 	id := uuid.New()
-	originalKey := fmt.Sprintf("users/%s/original/%s/%s", userID.String(), id.String(), header.Filename)
+	stagingKey := fmt.Sprintf("users/%s/staging/%s", owner.UserID.String(), id.String())
+	hasher := sha256.New()
 
-	// Upload to storage
-	if err := h.storage.Upload(ctx, originalKey, file, header.Size, contentType); err != nil {
-		h.logger.Error("failed to upload file", "error", err)
-		h.writeError(w, http.StatusInternalServerError, "failed to upload file")
-		return
+	if err := h.storage.Upload(ctx, stagingKey, io.TeeReader(file, hasher), size, contentType); err != nil {
+		return nil, &jobError{err: err, message: "failed to upload file"}
 	}
+	digest := hex.EncodeToString(hasher.Sum(nil))
 
-	// Create job
-	job := models.NewJob(originalKey, header.Filename, contentType, header.Size, operations)
-	job.ID = id
-	job.UserID = userID
+	originalKey, err := h.storeOriginal(ctx, id, owner, stagingKey, digest)
+	if err != nil {
+		return nil, &jobError{err: err, message: "failed to upload file"}
+	}
 
-	if err := h.jobRepo.Create(ctx, job); err != nil {
-		h.logger.Error("failed to create job", "error", err)
-		h.writeError(w, http.StatusInternalServerError, "failed to create job")
-		return
+	job, err := h.persistJob(ctx, id, owner, opts, batchID, originalKey, digest, filename, contentType, size)
+	if job == nil {
+		h.releaseOriginal(ctx, id, originalKey)
 	}
+	return job, err
+}
 
-	// Update status to queued
-	if err := h.jobRepo.UpdateStatus(ctx, job.ID, models.JobStatusQueued); err != nil {
-		h.logger.Error("failed to update job status", "error", err)
+// storeOriginal moves a staged upload to its content-addressed key and records the
+// job's reference to it, so each user stores a file once however often it is uploaded
+func (h *Handlers) storeOriginal(ctx context.Context, jobID uuid.UUID, owner models.JobOwner, stagingKey, digest string) (string, error) {
+	key := fmt.Sprintf("users/%s/blobs/sha256/%s", owner.UserID.String(), digest)
+
+	defer func() {
+		if err := h.storage.Delete(ctx, stagingKey); err != nil {
+			h.logger.Error("failed to delete staged upload", "key", stagingKey, "error", err)
+		}
+	}()
+
+	if err := h.jobRepo.AcquireObjectRefs(ctx, key, jobID); err != nil {
+		return "", err
 	}
-	job.Status = models.JobStatusQueued
 
-	// Enqueue the job
+	// Copy only if this is the first reference or the last one was released meanwhile
+	exists, err := h.storage.Exists(ctx, key)
+	if err == nil && !exists {
+		err = h.storage.Copy(ctx, stagingKey, key)
+	}
+	if err != nil {
+		h.releaseOriginal(ctx, jobID, key)
+		return "", err
+	}
+
+	if exists {
+		h.logger.Info("reusing stored original", "job_id", jobID, "sha256", digest)
+	}
+	return key, nil
+}
+
+// releaseOriginal drops a job's reference to its original, deleting it if unused
+func (h *Handlers) releaseOriginal(ctx context.Context, jobID uuid.UUID, key string) {
+	err := h.jobRepo.ReleaseObjectRef(ctx, key, jobID, func() error {
+		return h.storage.Delete(ctx, key)
+	})
+	if err != nil {
+		h.logger.Error("failed to release original", "key", key, "error", err)
+	}
+}
+
+// persistJob creates the job for an original already in storage and submits it for processing
+func (h *Handlers) persistJob(
+	ctx context.Context,
+	id uuid.UUID,
+	owner models.JobOwner,
+	opts jobOptions,
+	batchID *uuid.UUID,
+	originalKey, originalSHA256, filename, contentType string,
+	size int64,
+) (*models.Job, error) {
+	job := models.NewJob(originalKey, filename, contentType, size, opts.operations)
+	job.ID = id
+	job.OriginalSHA256 = originalSHA256
+	job.UserID = owner.UserID
+	job.AnonymousID = owner.AnonymousID
+	job.BatchID = batchID
+	job.Priority = opts.priority
+	job.DeleteOriginal = opts.deleteOriginal
+	job.ParentJobID = opts.parentJobID
+	job.PresetID = opts.presetID
+	job.PresetVersion = opts.presetVersion
+	if !opts.runAt.IsZero() {
+		runAt := opts.runAt
+		job.RunAt = &runAt
+	}
+
+	if err := h.createJobWithinQuota(ctx, job); err != nil {
+		var exceeded *models.QuotaExceededError
+		if errors.As(err, &exceeded) {
+			return nil, &jobError{err: err, message: exceeded.Error()}
+		}
+		return nil, &jobError{err: err, message: "failed to create job"}
+	}
+
+	if err := h.submitJob(ctx, job); err != nil {
+		return job, &jobError{err: err, message: "failed to queue job"}
+	}
+
+	return job, nil
+}
+
+// submitJob hands a persisted job to the queue, or to the scheduler if it should run later.
+// On failure the job is reset to pending.
+func (h *Handlers) submitJob(ctx context.Context, job *models.Job) error {
 	msg := &models.JobMessage{
 		JobID:      job.ID,
-		Operations: operations,
+		Priority:   job.Priority,
+		Operations: job.Operations,
+	}
+
+	status := models.JobStatusQueued
+	if job.RunAt != nil && job.RunAt.After(time.Now()) {
+		status = models.JobStatusScheduled
+	}
+
+	// Update status before publishing so a fast worker never sees a pending job
+	if err := h.jobRepo.UpdateStatus(ctx, job.ID, status); err != nil {
+		h.logger.Error("failed to update job status", "error", err)
 	}
-	if err := h.producer.Enqueue(ctx, msg); err != nil {
-		h.logger.Error("failed to enqueue job", "error", err)
+	job.Status = status
+
+	var err error
+	if status == models.JobStatusScheduled {
+		err = h.scheduler.Schedule(ctx, msg, *job.RunAt)
+	} else {
+		err = h.producer.Enqueue(ctx, msg)
+	}
+	if err != nil {
 		// Update status back to pending on queue failure
 		if updateErr := h.jobRepo.UpdateStatus(ctx, job.ID, models.JobStatusPending); updateErr != nil {
 			h.logger.Error("failed to update job status", "error", updateErr)
 		}
-		h.writeError(w, http.StatusInternalServerError, "failed to queue job")
-		return
+		job.Status = models.JobStatusPending
+		return fmt.Errorf("failed to enqueue job: %w", err)
 	}
 
-	h.logger.Info("job created", "job_id", job.ID, "operations", len(operations))
-	h.writeJSON(w, http.StatusCreated, job)
+	return nil
 }
 
 // GetJob handles GET /api/v1/jobs/{id}
 func (h *Handlers) GetJob(w http.ResponseWriter, r *http.Request) {
-	idStr := chi.URLParam(r, "id")
-	id, err := uuid.Parse(idStr)
-	if err != nil {
-		h.writeError(w, http.StatusBadRequest, "invalid job ID")
-		return
-	}
-
-	job, err := h.jobRepo.GetByID(r.Context(), id)
-	if err != nil {
-		h.logger.Error("failed to get job", "error", err)
-		h.writeError(w, http.StatusInternalServerError, "failed to get job")
-		return
-	}
-
+	job := h.loadOwnedJob(w, r)
 	if job == nil {
-		h.writeError(w, http.StatusNotFound, "job not found")
 		return
 	}
 
 	h.writeJSON(w, http.StatusOK, job)
 }
 
-// ListJobs handles GET /api/v1/jobs
+// ListJobs handles GET /api/v1/jobs. Jobs are paged by page number, or with
+// opaque cursors when the cursor parameter is present (empty for the first page).
 func (h *Handlers) ListJobs(w http.ResponseWriter, r *http.Request) {
-	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	query := r.URL.Query()
+	page, _ := strconv.Atoi(query.Get("page"))
 	if page < 1 {
 		page = 1
 	}
-	pageSize, _ := strconv.Atoi(r.URL.Query().Get("page_size"))
+	pageSize, _ := strconv.Atoi(query.Get("page_size"))
 	if pageSize < 1 || pageSize > 100 {
 		pageSize = 20
 	}
 
-	// Get user session for filtering
-	session, ok := GetSession(r.Context())
-	var userID uuid.UUID
-	if ok && session != nil {
-		userID = session.UserID
-	} else {
-		// Use system user for unauthenticated requests
-		userID = uuid.MustParse("00000000-0000-0000-0000-000000000000")
+	filter, includeOperations, err := parseJobFilter(query)
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if query.Has("cursor") {
+		if query.Has("page") {
+			h.writeError(w, http.StatusBadRequest, "page and cursor are mutually exclusive")
+			return
+		}
+		h.listJobsByCursor(w, r, filter, includeOperations, pageSize)
+		return
 	}
 
-	jobs, total, err := h.jobRepo.List(r.Context(), userID, page, pageSize)
+	jobs, total, err := h.jobRepo.List(r.Context(), requestOwner(r), filter, page, pageSize)
 	if err != nil {
 		h.logger.Error("failed to list jobs", "error", err)
 		h.writeError(w, http.StatusInternalServerError, "failed to list jobs")
 		return
 	}
+	if !includeOperations {
+		omitOperations(jobs)
+	}
 
 	totalPages := (total + pageSize - 1) / pageSize
 
@@ -246,67 +524,105 @@ func (h *Handlers) ListJobs(w http.ResponseWriter, r *http.Request) {
 	h.writeJSON(w, http.StatusOK, response)
 }
 
-// CancelJob handles DELETE /api/v1/jobs/{id}
-func (h *Handlers) CancelJob(w http.ResponseWriter, r *http.Request) {
-	idStr := chi.URLParam(r, "id")
-	id, err := uuid.Parse(idStr)
-	if err != nil {
-		h.writeError(w, http.StatusBadRequest, "invalid job ID")
-		return
-	}
+// listJobsByCursor serves a page of jobs following or preceding a cursor. It
+// skips the count of the page mode; estimate_total=true adds the planner's guess.
+func (h *Handlers) listJobsByCursor(w http.ResponseWriter, r *http.Request, filter database.JobFilter, includeOperations bool, pageSize int) {
+	query := r.URL.Query()
 
-	if err := h.jobRepo.CancelJob(r.Context(), id); err != nil {
-		h.logger.Error("failed to cancel job", "error", err)
-		h.writeError(w, http.StatusBadRequest, err.Error())
+	if filter.Sort != "" && filter.Sort != "created_at" {
+		h.writeError(w, http.StatusBadRequest, "cursor pagination only supports sort=created_at")
 		return
 	}
+	var cursor *database.JobCursor
+	if token := query.Get("cursor"); token != "" {
+		c, err := decodeJobCursor(token)
+		if err != nil {
+			h.writeError(w, http.StatusBadRequest, "invalid cursor")
+			return
+		}
+		cursor = &c
+	}
+	estimate := false
+	if v := query.Get("estimate_total"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			h.writeError(w, http.StatusBadRequest, "invalid estimate_total, must be true or false")
+			return
+		}
+		estimate = b
+	}
 
-	h.writeJSON(w, http.StatusOK, map[string]string{"status": "canceled"})
-}
-
-// GetImage handles GET /api/v1/images/{id}
-func (h *Handlers) GetImage(w http.ResponseWriter, r *http.Request) {
-	idStr := chi.URLParam(r, "id")
-	id, err := uuid.Parse(idStr)
+	owner := requestOwner(r)
+	jobs, hasMore, err := h.jobRepo.ListAfter(r.Context(), owner, filter, cursor, pageSize)
 	if err != nil {
-		h.writeError(w, http.StatusBadRequest, "invalid image ID")
+		h.logger.Error("failed to list jobs", "error", err)
+		h.writeError(w, http.StatusInternalServerError, "failed to list jobs")
 		return
 	}
+	if jobs == nil {
+		jobs = []*models.Job{}
+	}
+	if !includeOperations {
+		omitOperations(jobs)
+	}
 
-	job, err := h.jobRepo.GetByID(r.Context(), id)
-	if err != nil {
-		h.logger.Error("failed to get job", "error", err)
-		h.writeError(w, http.StatusInternalServerError, "failed to get job")
-		return
+	response := models.JobCursorResponse{Jobs: jobs, PageSize: pageSize}
+	if len(jobs) > 0 {
+		// A page reached by walking backward always has jobs after it, and one
+		// reached by walking forward from a cursor always has jobs before it
+		backward := cursor != nil && cursor.Backward
+		if hasMore || backward {
+			last := jobs[len(jobs)-1]
+			response.NextCursor = encodeJobCursor(database.JobCursor{CreatedAt: last.CreatedAt, ID: last.ID})
+		}
+		if (hasMore && backward) || (cursor != nil && !backward) {
+			first := jobs[0]
+			response.PrevCursor = encodeJobCursor(database.JobCursor{CreatedAt: first.CreatedAt, ID: first.ID, Backward: true})
+		}
 	}
 
-	if job == nil {
-		h.writeError(w, http.StatusNotFound, "image not found")
-		return
+	if estimate {
+		total, err := h.jobRepo.EstimateCount(r.Context(), owner, filter)
+		if err != nil {
+			h.logger.Warn("failed to estimate job count", "error", err)
+		} else {
+			response.EstimatedTotal = &total
+		}
 	}
 
-	// Determine which image to serve (processed or original)
-	imageKey := job.ProcessedKey
-	if imageKey == "" || r.URL.Query().Get("original") == "true" {
-		imageKey = job.OriginalKey
+	h.writeJSON(w, http.StatusOK, response)
+}
+
+// omitOperations drops the operations of listed jobs; they can be large and
+// are only listed with include=operations
+func omitOperations(jobs []*models.Job) {
+	for _, job := range jobs {
+		job.Operations = nil
 	}
+}
 
-	// Download from storage
-	reader, err := h.storage.Download(r.Context(), imageKey)
-	if err != nil {
-		h.logger.Error("failed to download image", "error", err)
-		h.writeError(w, http.StatusInternalServerError, "failed to download image")
+// CancelJob handles DELETE /api/v1/jobs/{id}
+func (h *Handlers) CancelJob(w http.ResponseWriter, r *http.Request) {
+	job := h.loadOwnedJob(w, r)
+	if job == nil {
 		return
 	}
-	defer reader.Close()
+	id := job.ID
 
-	// Set content type
-	w.Header().Set("Content-Type", job.ContentType)
+	if err := h.jobRepo.CancelJob(r.Context(), id); err != nil {
+		h.logger.Error("failed to cancel job", "error", err)
+		h.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
 
-	// Stream the image
-	if _, err := io.Copy(w, reader); err != nil {
-		h.logger.Error("failed to stream image", "error", err)
+	// Drop the job from the scheduler so it is never dispatched
+	if h.scheduler != nil {
+		if _, err := h.scheduler.Cancel(r.Context(), id); err != nil {
+			h.logger.Error("failed to remove job from scheduler", "job_id", id, "error", err)
+		}
 	}
+
+	h.writeJSON(w, http.StatusOK, map[string]string{"status": "canceled"})
 }
 
 // GetQueueStats handles GET /api/v1/stats/queue
@@ -318,30 +634,25 @@ func (h *Handlers) GetQueueStats(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if h.scheduler != nil {
+		scheduled, err := h.scheduler.Count(r.Context())
+		if err != nil {
+			h.logger.Error("failed to count scheduled jobs", "error", err)
+		}
+		stats.ScheduledJobs = scheduled
+	}
+
 	h.writeJSON(w, http.StatusOK, stats)
 }
 
 // StreamJobStatus handles GET /api/v1/jobs/{id}/stream
 // Streams job status updates using Server-Sent Events (SSE)
 func (h *Handlers) StreamJobStatus(w http.ResponseWriter, r *http.Request) {
-	idStr := chi.URLParam(r, "id")
-	id, err := uuid.Parse(idStr)
-	if err != nil {
-		h.writeError(w, http.StatusBadRequest, "invalid job ID")
-		return
-	}
-
-	// Check if job exists
-	job, err := h.jobRepo.GetByID(r.Context(), id)
-	if err != nil {
-		h.logger.Error("failed to get job", "error", err)
-		h.writeError(w, http.StatusInternalServerError, "failed to get job")
-		return
-	}
+	job := h.loadOwnedJob(w, r)
 	if job == nil {
-		h.writeError(w, http.StatusNotFound, "job not found")
 		return
 	}
+	id := job.ID
 
 	// Set SSE headers
 	w.Header().Set("Content-Type", "text/event-stream")
@@ -463,6 +774,179 @@ func (h *Handlers) Health(w http.ResponseWriter, r *http.Request) {
 
 // Helper functions
 
+// systemUserID owns jobs submitted without a session
+var systemUserID = uuid.MustParse("00000000-0000-0000-0000-000000000000")
+
+// requestOwner returns the session user, or the system user scoped to the
+// client's anonymous identity for unauthenticated requests
+func requestOwner(r *http.Request) models.JobOwner {
+	if session, ok := GetSession(r.Context()); ok && session != nil {
+		return models.JobOwner{UserID: session.UserID}
+	}
+	owner := models.JobOwner{UserID: systemUserID}
+	if id, ok := GetAnonymousID(r.Context()); ok {
+		owner.AnonymousID = &id
+	}
+	return owner
+}
+
+// maxScheduleHorizon bounds how far in the future a job may be scheduled
+const maxScheduleHorizon = 30 * 24 * time.Hour
+
+// parseRunAt resolves the run_at (RFC 3339) or delay (Go duration) form values
+// into an absolute run time. It returns the zero time when neither is set.
+func parseRunAt(runAtValue, delayValue string, now time.Time) (time.Time, error) {
+	if runAtValue != "" && delayValue != "" {
+		return time.Time{}, fmt.Errorf("run_at and delay are mutually exclusive")
+	}
+
+	var runAt time.Time
+	switch {
+	case runAtValue != "":
+		t, err := time.Parse(time.RFC3339, runAtValue)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid run_at, must be RFC 3339: %w", err)
+		}
+		runAt = t
+	case delayValue != "":
+		d, err := time.ParseDuration(delayValue)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid delay, must be a duration like 90s or 2h: %w", err)
+		}
+		if d < 0 {
+			return time.Time{}, fmt.Errorf("delay must not be negative")
+		}
+		runAt = now.Add(d)
+	}
+
+	if runAt.Sub(now) > maxScheduleHorizon {
+		return time.Time{}, fmt.Errorf("jobs cannot be scheduled more than %s ahead", maxScheduleHorizon)
+	}
+
+	return runAt, nil
+}
+
+// parseJobFilter reads the search, filter and sort parameters of the job list,
+// and whether operations were requested with include=operations
+func parseJobFilter(query url.Values) (database.JobFilter, bool, error) {
+	var filter database.JobFilter
+
+	for _, s := range splitList(query["status"]) {
+		status := models.JobStatus(s)
+		if !status.IsValid() {
+			return filter, false, fmt.Errorf("invalid status %q", s)
+		}
+		filter.Statuses = append(filter.Statuses, status)
+	}
+	for _, s := range splitList(query["operation"]) {
+		op := models.OperationType(s)
+		if !isValidOperation(op) {
+			return filter, false, fmt.Errorf("invalid operation %q", s)
+		}
+		filter.Operations = append(filter.Operations, op)
+	}
+	filter.ContentTypes = splitList(query["content_type"])
+	filter.NamePrefix = query.Get("name_prefix")
+	filter.NameSearch = query.Get("name")
+
+	for param, t := range map[string]*time.Time{
+		"created_after":  &filter.CreatedAfter,
+		"created_before": &filter.CreatedBefore,
+	} {
+		if v := query.Get(param); v != "" {
+			parsed, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return filter, false, fmt.Errorf("invalid %s, must be RFC 3339", param)
+			}
+			*t = parsed
+		}
+	}
+	for param, size := range map[string]*int64{
+		"min_size": &filter.MinSize,
+		"max_size": &filter.MaxSize,
+	} {
+		if v := query.Get(param); v != "" {
+			parsed, err := strconv.ParseInt(v, 10, 64)
+			if err != nil || parsed < 0 {
+				return filter, false, fmt.Errorf("invalid %s, must be a number of bytes", param)
+			}
+			*size = parsed
+		}
+	}
+	if filter.MaxSize > 0 && filter.MinSize > filter.MaxSize {
+		return filter, false, fmt.Errorf("min_size must not exceed max_size")
+	}
+
+	if sort := query.Get("sort"); sort != "" {
+		if !database.ValidJobSort(sort) {
+			return filter, false, fmt.Errorf("invalid sort field %q", sort)
+		}
+		filter.Sort = sort
+	}
+	switch order := query.Get("order"); order {
+	case "", "desc":
+	case "asc":
+		filter.Ascending = true
+	default:
+		return filter, false, fmt.Errorf("invalid order %q, must be asc or desc", order)
+	}
+
+	includeOperations := false
+	for _, include := range splitList(query["include"]) {
+		if include != "operations" {
+			return filter, false, fmt.Errorf("invalid include %q", include)
+		}
+		includeOperations = true
+	}
+
+	return filter, includeOperations, nil
+}
+
+// encodeJobCursor turns a list position into an opaque cursor token
+func encodeJobCursor(c database.JobCursor) string {
+	direction := "n"
+	if c.Backward {
+		direction = "p"
+	}
+	raw := direction + "|" + c.CreatedAt.UTC().Format(time.RFC3339Nano) + "|" + c.ID.String()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// decodeJobCursor parses a token made by encodeJobCursor
+func decodeJobCursor(token string) (database.JobCursor, error) {
+	var c database.JobCursor
+
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return c, err
+	}
+	parts := strings.Split(string(raw), "|")
+	if len(parts) != 3 || (parts[0] != "n" && parts[0] != "p") {
+		return c, errors.New("malformed cursor")
+	}
+	c.Backward = parts[0] == "p"
+	if c.CreatedAt, err = time.Parse(time.RFC3339Nano, parts[1]); err != nil {
+		return c, err
+	}
+	if c.ID, err = uuid.Parse(parts[2]); err != nil {
+		return c, err
+	}
+	return c, nil
+}
+
+// splitList flattens repeated and comma separated query values
+func splitList(values []string) []string {
+	var result []string
+	for _, value := range values {
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				result = append(result, item)
+			}
+		}
+	}
+	return result
+}
+
 func isValidImageType(contentType string) bool {
 	validTypes := []string{
 		"image/jpeg",
@@ -506,7 +990,16 @@ func isValidOperation(op models.OperationType) bool {
 		models.OperationContrast,
 		models.OperationSaturation,
 		models.OperationWatermark,
-	}
+		models.OperationText,
+		models.OperationColorProfile,
+		models.OperationPad,
+		models.OperationBorder,
+		models.OperationRoundCorners,
+		models.OperationBackground,
+		models.OperationFlatten,
+		models.OperationTrim,
+	}
+	validOps = append(validOps, models.AnalysisOperations...)
 	for _, valid := range validOps {
 		if op == valid {
 			return true
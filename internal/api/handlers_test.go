@@ -2,17 +2,23 @@ package api
 
 import (
 	"bytes"
+	"encoding/base64"
 	"encoding/json"
 	"log/slog"
 	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
 
+	"github.com/timkrebs/image-processor/internal/database"
 	"github.com/timkrebs/image-processor/internal/models"
 )
 
@@ -113,6 +119,113 @@ func TestIsValidOperation(t *testing.T) {
 	}
 }
 
+func TestParseRunAt(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		runAt   string
+		delay   string
+		want    time.Time
+		wantErr bool
+	}{
+		{"unset", "", "", time.Time{}, false},
+		{"run_at", "2025-01-02T03:00:00Z", "", time.Date(2025, 1, 2, 3, 0, 0, 0, time.UTC), false},
+		{"delay", "", "90m", now.Add(90 * time.Minute), false},
+		{"zero delay", "", "0s", now, false},
+		{"both set", "2025-01-02T03:00:00Z", "1h", time.Time{}, true},
+		{"invalid run_at", "tomorrow", "", time.Time{}, true},
+		{"invalid delay", "", "soon", time.Time{}, true},
+		{"negative delay", "", "-5m", time.Time{}, true},
+		{"beyond horizon", "", "800h", time.Time{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseRunAt(tt.runAt, tt.delay, now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseRunAt() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("parseRunAt() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseJobFilter(t *testing.T) {
+	tests := []struct {
+		name        string
+		query       string
+		want        database.JobFilter
+		wantInclude bool
+		wantErr     bool
+	}{
+		{"empty", "", database.JobFilter{}, false, false},
+		{
+			"statuses",
+			"status=failed,canceled&status=completed",
+			database.JobFilter{Statuses: []models.JobStatus{models.JobStatusFailed, models.JobStatusCancelled, models.JobStatusCompleted}},
+			false, false,
+		},
+		{
+			"operations",
+			"operation=resize,blur",
+			database.JobFilter{Operations: []models.OperationType{models.OperationResize, models.OperationBlur}},
+			false, false,
+		},
+		{
+			"names and content type",
+			"name_prefix=IMG_&name=holiday&content_type=image/png",
+			database.JobFilter{NamePrefix: "IMG_", NameSearch: "holiday", ContentTypes: []string{"image/png"}},
+			false, false,
+		},
+		{
+			"date range",
+			"created_after=2025-01-01T00:00:00Z&created_before=2025-02-01T00:00:00Z",
+			database.JobFilter{
+				CreatedAfter:  time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+				CreatedBefore: time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC),
+			},
+			false, false,
+		},
+		{"size range", "min_size=1024&max_size=2048", database.JobFilter{MinSize: 1024, MaxSize: 2048}, false, false},
+		{"sort", "sort=file_size&order=asc", database.JobFilter{Sort: "file_size", Ascending: true}, false, false},
+		{"include operations", "include=operations", database.JobFilter{}, true, false},
+		{"invalid status", "status=done", database.JobFilter{}, false, true},
+		{"invalid operation", "operation=explode", database.JobFilter{}, false, true},
+		{"invalid date", "created_after=yesterday", database.JobFilter{}, false, true},
+		{"negative size", "min_size=-1", database.JobFilter{}, false, true},
+		{"inverted size range", "min_size=10&max_size=5", database.JobFilter{}, false, true},
+		{"invalid sort", "sort=id", database.JobFilter{}, false, true},
+		{"invalid order", "order=sideways", database.JobFilter{}, false, true},
+		{"invalid include", "include=everything", database.JobFilter{}, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, include, err := parseJobFilter(query)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseJobFilter() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseJobFilter() = %+v, want %+v", got, tt.want)
+			}
+			if include != tt.wantInclude {
+				t.Errorf("parseJobFilter() include = %v, want %v", include, tt.wantInclude)
+			}
+		})
+	}
+}
+
 func TestHandlers_WriteJSON(t *testing.T) {
 	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
 	h := &Handlers{logger: logger}
@@ -467,3 +580,107 @@ func TestOperationsValidation(t *testing.T) {
 		})
 	}
 }
+
+func TestHandlers_CreateJob_DeleteOriginal(t *testing.T) {
+	tests := []struct {
+		name      string
+		value     string
+		wantError string
+	}{
+		{"invalid value", "maybe", "invalid delete_original"},
+		{"not enabled", "true", "not enabled"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+			h := &Handlers{logger: logger}
+
+			body := &bytes.Buffer{}
+			writer := multipart.NewWriter(body)
+			part, _ := writer.CreateFormFile("image", "test.jpg")
+			part.Write([]byte("fake image data"))
+			writer.WriteField("delete_original", tt.value)
+			writer.Close()
+
+			req := httptest.NewRequest("POST", "/api/v1/jobs", body)
+			req.Header.Set("Content-Type", writer.FormDataContentType())
+			recorder := httptest.NewRecorder()
+
+			h.CreateJob(recorder, req)
+
+			if recorder.Code != http.StatusBadRequest {
+				t.Errorf("Status = %d, want %d", recorder.Code, http.StatusBadRequest)
+			}
+
+			var result map[string]string
+			if err := json.NewDecoder(recorder.Body).Decode(&result); err != nil {
+				t.Logf("Failed to decode response: %v", err)
+			}
+			if !strings.Contains(result["error"], tt.wantError) {
+				t.Errorf("Error = %q, want to contain %q", result["error"], tt.wantError)
+			}
+		})
+	}
+}
+
+func TestJobCursor_RoundTrip(t *testing.T) {
+	cursors := []database.JobCursor{
+		{CreatedAt: time.Date(2025, 3, 4, 5, 6, 7, 123456000, time.UTC), ID: uuid.New()},
+		{CreatedAt: time.Date(2025, 3, 4, 5, 6, 7, 0, time.UTC), ID: uuid.New(), Backward: true},
+	}
+
+	for _, want := range cursors {
+		got, err := decodeJobCursor(encodeJobCursor(want))
+		if err != nil {
+			t.Fatalf("decodeJobCursor() error = %v", err)
+		}
+		if !got.CreatedAt.Equal(want.CreatedAt) || got.ID != want.ID || got.Backward != want.Backward {
+			t.Errorf("decodeJobCursor() = %+v, want %+v", got, want)
+		}
+	}
+}
+
+func TestDecodeJobCursor_Invalid(t *testing.T) {
+	tokens := []string{
+		"not base64!",
+		base64.RawURLEncoding.EncodeToString([]byte("garbage")),
+		base64.RawURLEncoding.EncodeToString([]byte("x|2025-01-01T00:00:00Z|" + uuid.NewString())),
+		base64.RawURLEncoding.EncodeToString([]byte("n|yesterday|" + uuid.NewString())),
+		base64.RawURLEncoding.EncodeToString([]byte("n|2025-01-01T00:00:00Z|not-a-uuid")),
+	}
+
+	for _, token := range tokens {
+		if _, err := decodeJobCursor(token); err == nil {
+			t.Errorf("decodeJobCursor(%q) succeeded, want error", token)
+		}
+	}
+}
+
+func TestHandlers_ListJobs_CursorValidation(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	h := &Handlers{logger: logger}
+
+	tests := []struct {
+		name  string
+		query string
+	}{
+		{"page and cursor", "?page=2&cursor="},
+		{"invalid cursor", "?cursor=bogus"},
+		{"unsupported sort", "?cursor=&sort=file_size"},
+		{"invalid estimate_total", "?cursor=&estimate_total=maybe"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/v1/jobs"+tt.query, http.NoBody)
+			w := httptest.NewRecorder()
+
+			h.ListJobs(w, req)
+
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want %d", w.Code, http.StatusBadRequest)
+			}
+		})
+	}
+}
@@ -0,0 +1,191 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"github.com/timkrebs/image-processor/internal/database"
+	"github.com/timkrebs/image-processor/internal/models"
+	"github.com/timkrebs/image-processor/internal/storage"
+)
+
+// Image delivery modes
+const (
+	// ImageDeliveryProxy streams images through the API
+	ImageDeliveryProxy = "proxy"
+	// ImageDeliveryRedirect redirects clients to a presigned storage URL
+	ImageDeliveryRedirect = "redirect"
+)
+
+const (
+	defaultImageURLExpiry   = 15 * time.Minute
+	defaultImageCacheMaxAge = time.Hour
+)
+
+// SetImageDelivery sets how images are served, how long presigned image URLs are
+// valid in redirect mode, and how long clients may cache finished images
+func (h *Handlers) SetImageDelivery(mode string, urlExpiry, cacheMaxAge time.Duration) error {
+	switch mode {
+	case ImageDeliveryProxy, ImageDeliveryRedirect:
+	default:
+		return fmt.Errorf("invalid image delivery mode %q, must be %q or %q", mode, ImageDeliveryProxy, ImageDeliveryRedirect)
+	}
+
+	h.imageDelivery = mode
+	h.imageURLExpiry = urlExpiry
+	h.imageCacheMaxAge = cacheMaxAge
+	return nil
+}
+
+// imageURLValidity returns the expiry of presigned image URLs
+func (h *Handlers) imageURLValidity() time.Duration {
+	if h.imageURLExpiry <= 0 {
+		return defaultImageURLExpiry
+	}
+	return h.imageURLExpiry
+}
+
+// imageCacheControl returns the Cache-Control value for an image.
+// Only images that will not change may be cached; until a job finishes its
+// original stands in for the output, so those responses must be revalidated.
+func (h *Handlers) imageCacheControl(final bool) string {
+	if !final {
+		return "no-cache"
+	}
+
+	maxAge := h.imageCacheMaxAge
+	if maxAge <= 0 {
+		maxAge = defaultImageCacheMaxAge
+	}
+	return fmt.Sprintf("private, max-age=%d", int(maxAge.Seconds()))
+}
+
+// GetImage handles GET /api/v1/images/{id}
+// Serves the processed image, or the original with ?original=true or while the job
+// has no output. ?download=true asks browsers to save it under its original filename.
+func (h *Handlers) GetImage(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "invalid image ID")
+		return
+	}
+
+	job, err := h.jobRepo.GetByID(r.Context(), id)
+	if errors.Is(err, database.ErrNotFound) || (err == nil && !requestOwner(r).Owns(job.UserID, job.AnonymousID)) {
+		h.writeError(w, http.StatusNotFound, "image not found")
+		return
+	}
+	if err != nil {
+		h.logger.Error("failed to get job", "error", err)
+		h.writeError(w, http.StatusInternalServerError, "failed to get job")
+		return
+	}
+
+	imageKey, final := imageKeyFor(job, r.URL.Query().Get("original") == "true")
+	if imageKey == job.OriginalKey && job.OriginalDeletedAt != nil {
+		h.writeError(w, http.StatusGone, "original was deleted after processing")
+		return
+	}
+	cacheControl := h.imageCacheControl(final)
+	disposition := imageDisposition(job.OriginalName, r.URL.Query().Get("download") == "true")
+
+	h.serveImage(w, r, imageKey, job.ContentType, storage.PresignOptions{
+		CacheControl:       cacheControl,
+		ContentDisposition: disposition,
+	})
+}
+
+// serveImage sends the object at key according to the delivery mode.
+// contentType is used when storage has none recorded.
+func (h *Handlers) serveImage(w http.ResponseWriter, r *http.Request, key, contentType string, headers storage.PresignOptions) {
+	ctx := r.Context()
+
+	if h.imageDelivery == ImageDeliveryRedirect {
+		url, err := h.storage.Presign(ctx, http.MethodGet, key, h.imageURLValidity(), headers)
+		switch {
+		case err == nil:
+			// The redirect must not outlive the URL it points to
+			w.Header().Set("Cache-Control", "no-store")
+			http.Redirect(w, r, url, http.StatusFound)
+			return
+		case errors.Is(err, storage.ErrNotSupported):
+			// Backends clients cannot reach are always proxied
+		default:
+			h.logger.Error("failed to presign image URL", "error", err)
+			h.writeError(w, http.StatusInternalServerError, "failed to get image")
+			return
+		}
+	}
+
+	info, err := h.storage.Stat(ctx, key)
+	if storage.IsNotFound(err) {
+		h.writeError(w, http.StatusNotFound, "image not found")
+		return
+	}
+	if err != nil {
+		h.logger.Error("failed to stat image", "error", err)
+		h.writeError(w, http.StatusInternalServerError, "failed to download image")
+		return
+	}
+
+	reader, err := h.storage.Download(ctx, key)
+	if err != nil {
+		h.logger.Error("failed to download image", "error", err)
+		h.writeError(w, http.StatusInternalServerError, "failed to download image")
+		return
+	}
+	defer reader.Close()
+
+	if info.ContentType != "" {
+		contentType = info.ContentType
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", headers.CacheControl)
+	if headers.ContentDisposition != "" {
+		w.Header().Set("Content-Disposition", headers.ContentDisposition)
+	}
+	if info.ETag != "" {
+		w.Header().Set("ETag", strconv.Quote(info.ETag))
+	}
+
+	// ServeContent answers conditional and Range requests and sets Content-Length and Last-Modified
+	http.ServeContent(w, r, "", info.LastModified, reader)
+}
+
+// imageKeyFor returns the storage key of a job's image and whether that image is final
+func imageKeyFor(job *models.Job, original bool) (string, bool) {
+	if original {
+		return job.OriginalKey, true
+	}
+	if job.ProcessedKey != "" {
+		return job.ProcessedKey, true
+	}
+	// Jobs that ended without output keep serving their original
+	return job.OriginalKey, job.Status.IsTerminal()
+}
+
+// imageDisposition builds the Content-Disposition of an image named filename
+func imageDisposition(filename string, download bool) string {
+	disposition := "inline"
+	if download {
+		disposition = "attachment"
+	}
+	if filename == "" {
+		return disposition
+	}
+
+	// FormatMediaType falls back to RFC 2231 encoding for non-ASCII names
+	if formatted := mime.FormatMediaType(disposition, map[string]string{"filename": filename}); formatted != "" {
+		return formatted
+	}
+	return disposition
+}
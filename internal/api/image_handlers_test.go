@@ -0,0 +1,132 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/timkrebs/image-processor/internal/models"
+	"github.com/timkrebs/image-processor/internal/storage"
+)
+
+func TestHandlers_SetImageDelivery(t *testing.T) {
+	h := &Handlers{}
+
+	if err := h.SetImageDelivery("cdn", 0, 0); err == nil {
+		t.Error("SetImageDelivery() should reject unknown modes")
+	}
+	if err := h.SetImageDelivery(ImageDeliveryRedirect, 5*time.Minute, 0); err != nil {
+		t.Fatalf("SetImageDelivery() error = %v", err)
+	}
+	if h.imageDelivery != ImageDeliveryRedirect {
+		t.Errorf("imageDelivery = %q, want %q", h.imageDelivery, ImageDeliveryRedirect)
+	}
+	if got := h.imageURLValidity(); got != 5*time.Minute {
+		t.Errorf("imageURLValidity() = %v, want 5m", got)
+	}
+}
+
+func TestHandlers_ImageCacheControl(t *testing.T) {
+	h := &Handlers{}
+
+	if got := h.imageCacheControl(false); got != "no-cache" {
+		t.Errorf("imageCacheControl(false) = %q, want no-cache", got)
+	}
+	if got := h.imageCacheControl(true); got != "private, max-age=3600" {
+		t.Errorf("imageCacheControl(true) = %q, want default max-age", got)
+	}
+
+	h.imageCacheMaxAge = 10 * time.Minute
+	if got := h.imageCacheControl(true); got != "private, max-age=600" {
+		t.Errorf("imageCacheControl(true) = %q, want max-age=600", got)
+	}
+}
+
+func TestImageKeyFor(t *testing.T) {
+	tests := []struct {
+		name      string
+		job       models.Job
+		original  bool
+		wantKey   string
+		wantFinal bool
+	}{
+		{"processed", models.Job{OriginalKey: "o", ProcessedKey: "p", Status: models.JobStatusCompleted}, false, "p", true},
+		{"original requested", models.Job{OriginalKey: "o", ProcessedKey: "p"}, true, "o", true},
+		{"still processing", models.Job{OriginalKey: "o", Status: models.JobStatusProcessing}, false, "o", false},
+		{"failed", models.Job{OriginalKey: "o", Status: models.JobStatusFailed}, false, "o", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, final := imageKeyFor(&tt.job, tt.original)
+			if key != tt.wantKey || final != tt.wantFinal {
+				t.Errorf("imageKeyFor() = %q, %v, want %q, %v", key, final, tt.wantKey, tt.wantFinal)
+			}
+		})
+	}
+}
+
+func TestImageDisposition(t *testing.T) {
+	if got := imageDisposition("cat.jpg", false); got != "inline; filename=cat.jpg" {
+		t.Errorf("imageDisposition() = %q", got)
+	}
+	if got := imageDisposition("my cat.jpg", true); got != `attachment; filename="my cat.jpg"` {
+		t.Errorf("imageDisposition() = %q", got)
+	}
+	if got := imageDisposition("kätzchen.jpg", true); !strings.HasPrefix(got, "attachment; filename*=utf-8''") {
+		t.Errorf("imageDisposition() = %q, want RFC 2231 encoded filename", got)
+	}
+	if got := imageDisposition("", true); got != "attachment" {
+		t.Errorf("imageDisposition() = %q, want attachment", got)
+	}
+}
+
+func TestHandlers_ServeImage(t *testing.T) {
+	store := storage.NewMemory()
+	if err := store.Upload(context.Background(), "img.png", strings.NewReader("0123456789"), 10, "image/png"); err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+
+	// Redirect mode falls back to proxying for backends without presigned URLs
+	h := NewHandlers(nil, store, nil, nil, "", slog.New(slog.DiscardHandler))
+	if err := h.SetImageDelivery(ImageDeliveryRedirect, 0, 0); err != nil {
+		t.Fatalf("SetImageDelivery() error = %v", err)
+	}
+	headers := storage.PresignOptions{CacheControl: "private, max-age=60"}
+
+	rec := httptest.NewRecorder()
+	h.serveImage(rec, httptest.NewRequest(http.MethodGet, "/", nil), "img.png", "", headers)
+	if rec.Code != http.StatusOK || rec.Body.String() != "0123456789" {
+		t.Fatalf("GET = %d %q, want 200 with the object", rec.Code, rec.Body.String())
+	}
+	if got := rec.Header().Get("Content-Type"); got != "image/png" {
+		t.Errorf("Content-Type = %q, want image/png", got)
+	}
+	etag := rec.Header().Get("ETag")
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("If-None-Match", etag)
+	rec = httptest.NewRecorder()
+	h.serveImage(rec, req, "img.png", "", headers)
+	if rec.Code != http.StatusNotModified {
+		t.Errorf("conditional GET = %d, want 304", rec.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Range", "bytes=2-4")
+	rec = httptest.NewRecorder()
+	h.serveImage(rec, req, "img.png", "", headers)
+	if rec.Code != http.StatusPartialContent || rec.Body.String() != "234" {
+		t.Errorf("range GET = %d %q, want 206 %q", rec.Code, rec.Body.String(), "234")
+	}
+
+	rec = httptest.NewRecorder()
+	h.serveImage(rec, httptest.NewRequest(http.MethodGet, "/", nil), "missing.png", "", headers)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("missing image = %d, want 404", rec.Code)
+	}
+}
@@ -0,0 +1,202 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"github.com/timkrebs/image-processor/internal/database"
+	"github.com/timkrebs/image-processor/internal/models"
+)
+
+// errOriginalGone is returned when a job's original no longer exists, so no
+// new job can be created from it
+var errOriginalGone = errors.New("original no longer exists")
+
+// Reasons canRetry rejects a job for
+var (
+	errNotRetryable    = errors.New("only failed or canceled jobs can be retried")
+	errOriginalDeleted = errors.New("original was deleted after processing")
+)
+
+// loadOwnedJob loads the job named by the id URL parameter if the requester
+// owns it, writing the error response otherwise
+func (h *Handlers) loadOwnedJob(w http.ResponseWriter, r *http.Request) *models.Job {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "invalid job ID")
+		return nil
+	}
+
+	job, err := h.jobRepo.GetByID(r.Context(), id)
+	if errors.Is(err, database.ErrNotFound) || (err == nil && !requestOwner(r).Owns(job.UserID, job.AnonymousID)) {
+		h.writeError(w, http.StatusNotFound, "job not found")
+		return nil
+	}
+	if err != nil {
+		h.logger.Error("failed to get job", "error", err)
+		h.writeError(w, http.StatusInternalServerError, "failed to get job")
+		return nil
+	}
+	return job
+}
+
+// RetryJob handles POST /api/v1/jobs/{id}/retry. It runs a failed or canceled
+// job again as a new job with the same original and operations; the failed job
+// is kept for its history.
+func (h *Handlers) RetryJob(w http.ResponseWriter, r *http.Request) {
+	parent := h.loadOwnedJob(w, r)
+	if parent == nil {
+		return
+	}
+	switch err := canRetry(parent); {
+	case errors.Is(err, errOriginalDeleted):
+		h.writeError(w, http.StatusGone, err.Error())
+		return
+	case err != nil:
+		h.writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+
+	h.createChildJob(w, r, parent, h.retryOptions(parent))
+}
+
+// Retry runs a failed or canceled job again as a child job of its owner, as
+// RetryJob does. It is how admin tools retry jobs, so they keep the lineage,
+// quotas and retryable statuses of the API.
+func (h *Handlers) Retry(ctx context.Context, parent *models.Job) (*models.Job, error) {
+	if err := canRetry(parent); err != nil {
+		return nil, err
+	}
+	owner := models.JobOwner{UserID: parent.UserID, AnonymousID: parent.AnonymousID}
+	return h.createJobFromOriginal(ctx, owner, h.retryOptions(parent), parent)
+}
+
+// retryOptions returns the options to run a job again with
+func (h *Handlers) retryOptions(job *models.Job) jobOptions {
+	return jobOptions{
+		priority:       job.Priority,
+		operations:     job.Operations,
+		deleteOriginal: job.DeleteOriginal && h.originalDeletion,
+		presetID:       job.PresetID,
+		presetVersion:  job.PresetVersion,
+	}
+}
+
+// canRetry returns why a job can't be retried, or nil if it can
+func canRetry(job *models.Job) error {
+	if job.Status != models.JobStatusFailed && job.Status != models.JobStatusCancelled {
+		return errNotRetryable
+	}
+	if job.OriginalDeletedAt != nil {
+		return errOriginalDeleted
+	}
+	return nil
+}
+
+// CloneJob handles POST /api/v1/jobs/{id}/clone. It processes the original of
+// an existing job again with new operations, without uploading it again.
+func (h *Handlers) CloneJob(w http.ResponseWriter, r *http.Request) {
+	parent := h.loadOwnedJob(w, r)
+	if parent == nil {
+		return
+	}
+
+	if err := r.ParseMultipartForm(1 << 20); err != nil && !errors.Is(err, http.ErrNotMultipart) {
+		h.writeError(w, http.StatusBadRequest, "failed to parse form: "+err.Error())
+		return
+	}
+	opts, err := parseJobOptions(r, time.Now())
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if r.FormValue("operations") == "" && opts.preset == nil {
+		h.writeError(w, http.StatusBadRequest, "operations or a preset are required")
+		return
+	}
+	if !h.checkJobOptions(w, opts) || !h.resolvePreset(w, r, &opts) {
+		return
+	}
+
+	h.createChildJob(w, r, parent, opts)
+}
+
+// createChildJob creates and submits a job for the original of parent, writing the response
+func (h *Handlers) createChildJob(w http.ResponseWriter, r *http.Request, parent *models.Job, opts jobOptions) {
+	ctx := r.Context()
+	owner := requestOwner(r)
+
+	if parent.OriginalDeletedAt != nil {
+		h.writeError(w, http.StatusGone, "original was deleted after processing")
+		return
+	}
+	if !h.checkQuota(w, r, owner, 1, parent.FileSize) {
+		return
+	}
+
+	job, err := h.createJobFromOriginal(ctx, owner, opts, parent)
+	if errors.Is(err, errOriginalGone) {
+		h.writeError(w, http.StatusGone, "original no longer exists")
+		return
+	}
+	if err != nil {
+		h.logger.Error("failed to create job from original", "parent_job_id", parent.ID, "error", err)
+		h.writeJobError(w, owner, err)
+		return
+	}
+
+	h.logger.Info("job created from original", "job_id", job.ID, "parent_job_id", parent.ID, "status", job.Status)
+	h.writeJSON(w, http.StatusCreated, job)
+}
+
+// createJobFromOriginal persists and submits a child job of source that shares its original
+func (h *Handlers) createJobFromOriginal(ctx context.Context, owner models.JobOwner, opts jobOptions, source *models.Job) (*models.Job, error) {
+	id := uuid.New()
+	key := source.OriginalKey
+	opts.parentJobID = &source.ID
+
+	// Both jobs reference the original from now on, so it outlives whichever is cleaned up first
+	if err := h.jobRepo.AcquireObjectRefs(ctx, key, source.ID, id); err != nil {
+		return nil, err
+	}
+	exists, err := h.storage.Exists(ctx, key)
+	if err == nil && !exists {
+		err = errOriginalGone
+	}
+	if err != nil {
+		h.releaseOriginal(ctx, id, key)
+		return nil, err
+	}
+
+	job, err := h.persistJob(ctx, id, owner, opts, nil, key, source.OriginalSHA256, source.OriginalName, source.ContentType, source.FileSize)
+	if job == nil {
+		h.releaseOriginal(ctx, id, key)
+	}
+	return job, err
+}
+
+// JobHistory handles GET /api/v1/jobs/{id}/history. It lists the jobs retried or
+// cloned from the same upload as the job, oldest first.
+func (h *Handlers) JobHistory(w http.ResponseWriter, r *http.Request) {
+	job := h.loadOwnedJob(w, r)
+	if job == nil {
+		return
+	}
+
+	jobs, err := h.jobRepo.ListLineage(r.Context(), requestOwner(r), job.ID)
+	if err != nil {
+		h.logger.Error("failed to list job history", "job_id", job.ID, "error", err)
+		h.writeError(w, http.StatusInternalServerError, "failed to list job history")
+		return
+	}
+	if jobs == nil {
+		jobs = []*models.Job{}
+	}
+
+	h.writeJSON(w, http.StatusOK, map[string][]*models.Job{"jobs": jobs})
+}
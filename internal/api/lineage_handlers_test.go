@@ -0,0 +1,78 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/timkrebs/image-processor/internal/models"
+)
+
+func TestHandlers_Lineage_InvalidID(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	h := &Handlers{logger: logger}
+
+	r := chi.NewRouter()
+	r.Post("/api/v1/jobs/{id}/retry", h.RetryJob)
+	r.Post("/api/v1/jobs/{id}/clone", h.CloneJob)
+	r.Get("/api/v1/jobs/{id}/history", h.JobHistory)
+
+	requests := []struct {
+		method string
+		path   string
+	}{
+		{"POST", "/api/v1/jobs/not-a-uuid/retry"},
+		{"POST", "/api/v1/jobs/not-a-uuid/clone"},
+		{"GET", "/api/v1/jobs/not-a-uuid/history"},
+	}
+
+	for _, req := range requests {
+		t.Run(req.method+" "+req.path, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			r.ServeHTTP(recorder, httptest.NewRequest(req.method, req.path, http.NoBody))
+
+			if recorder.Code != http.StatusBadRequest {
+				t.Errorf("Status = %d, want %d", recorder.Code, http.StatusBadRequest)
+			}
+		})
+	}
+}
+
+func TestCanRetry(t *testing.T) {
+	deletedAt := time.Now()
+	tests := []struct {
+		name string
+		job  *models.Job
+		want error
+	}{
+		{"failed", &models.Job{Status: models.JobStatusFailed}, nil},
+		{"canceled", &models.Job{Status: models.JobStatusCancelled}, nil},
+		{"completed", &models.Job{Status: models.JobStatusCompleted}, errNotRetryable},
+		{"original deleted", &models.Job{Status: models.JobStatusFailed, OriginalDeletedAt: &deletedAt}, errOriginalDeleted},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := canRetry(tt.job); !errors.Is(got, tt.want) {
+				t.Errorf("canRetry() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandlers_Retry_NotRetryable(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	h := &Handlers{logger: logger}
+
+	job, err := h.Retry(context.Background(), &models.Job{Status: models.JobStatusProcessing})
+	if job != nil || !errors.Is(err, errNotRetryable) {
+		t.Errorf("Retry() = %v, %v, want nil, %v", job, err, errNotRetryable)
+	}
+}
@@ -4,15 +4,20 @@ import (
 	"context"
 	"crypto/rand"
 	"encoding/base64"
+	"errors"
+	"fmt"
 	"log/slog"
+	"net"
 	"net/http"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/google/uuid"
 
+	"github.com/timkrebs/image-processor/internal/database"
 	"github.com/timkrebs/image-processor/internal/metrics"
 	"github.com/timkrebs/image-processor/internal/models"
 )
@@ -58,10 +63,17 @@ func MaxUploadSize(maxSize int64) func(next http.Handler) http.Handler {
 func CORS(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Accept, Authorization, Content-Type, X-Request-ID")
-
-		if r.Method == "OPTIONS" {
+		w.Header().Set("Access-Control-Allow-Methods", "GET, HEAD, POST, PUT, PATCH, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Accept, Authorization, Content-Type, X-API-Key, X-Request-ID, "+
+			"If-None-Match, If-Modified-Since, If-Range, Range, "+
+			"Tus-Resumable, Upload-Length, Upload-Metadata, Upload-Offset, Upload-Checksum")
+		w.Header().Set("Access-Control-Expose-Headers", "Retry-After, X-RateLimit-Limit, X-RateLimit-Remaining, X-RateLimit-Reset, "+
+			"ETag, Content-Disposition, Content-Range, Accept-Ranges, "+
+			"Location, Tus-Resumable, Tus-Version, Tus-Extension, Tus-Max-Size, Tus-Checksum-Algorithm, "+
+			"Upload-Offset, Upload-Length, Upload-Expires")
+
+		// Only answer preflights here; plain OPTIONS requests (tus discovery) reach their route
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
 			w.WriteHeader(http.StatusOK)
 			return
 		}
@@ -70,6 +82,88 @@ func CORS(next http.Handler) http.Handler {
 	})
 }
 
+// ParseTrustedProxies parses proxy addresses given as CIDRs or bare IPs
+func ParseTrustedProxies(proxies []string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, proxy := range proxies {
+		proxy = strings.TrimSpace(proxy)
+		if proxy == "" {
+			continue
+		}
+		if !strings.Contains(proxy, "/") {
+			ip := net.ParseIP(proxy)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q", proxy)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", proxy, err)
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets, nil
+}
+
+// RealIP returns a middleware that sets r.RemoteAddr to the client IP
+// reported by X-Forwarded-For or X-Real-IP, but only when the connection
+// comes from a trusted proxy. Anyone else could pick a new address per
+// request, so their headers are ignored and the connection address is kept.
+// X-Forwarded-For is read right to left, skipping trusted proxies, since
+// only the entries they appended can be believed.
+func RealIP(trusted []*net.IPNet) func(next http.Handler) http.Handler {
+	isTrusted := func(ip net.IP) bool {
+		for _, ipNet := range trusted {
+			if ipNet.Contains(ip) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			host, _, err := net.SplitHostPort(r.RemoteAddr)
+			if err != nil {
+				host = r.RemoteAddr
+			}
+			peer := net.ParseIP(host)
+			if peer == nil || !isTrusted(peer) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			var client net.IP
+			if forwarded := r.Header.Values("X-Forwarded-For"); len(forwarded) > 0 {
+				hops := strings.Split(strings.Join(forwarded, ","), ",")
+				for i := len(hops) - 1; i >= 0; i-- {
+					ip := net.ParseIP(strings.TrimSpace(hops[i]))
+					if ip == nil {
+						break
+					}
+					client = ip
+					if !isTrusted(ip) {
+						break
+					}
+				}
+			} else if ip := net.ParseIP(strings.TrimSpace(r.Header.Get("X-Real-IP"))); ip != nil {
+				client = ip
+			}
+
+			if client != nil {
+				r.RemoteAddr = client.String()
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 // MetricsMiddleware instruments HTTP requests with Prometheus metrics
 func MetricsMiddleware(m *metrics.HTTPMetrics) func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
@@ -97,11 +191,19 @@ type Session struct {
 	Email     string
 	Username  string
 	ExpiresAt time.Time
+	// Epoch is the user's session epoch at login; the session is revoked once it changes
+	Epoch int
+}
+
+// SessionUsers looks up the users sessions belong to
+type SessionUsers interface {
+	GetUserByID(ctx context.Context, userID uuid.UUID) (*models.User, error)
 }
 
 // SessionStore manages user sessions
 type SessionStore struct {
 	sessions map[string]*Session
+	users    SessionUsers
 	mu       sync.RWMutex
 	ttl      time.Duration
 }
@@ -133,6 +235,7 @@ func (s *SessionStore) Create(user *models.User) (string, error) {
 		Email:     user.Email,
 		Username:  user.Username,
 		ExpiresAt: time.Now().Add(s.ttl),
+		Epoch:     user.SessionEpoch,
 	}
 
 	s.mu.Lock()
@@ -160,6 +263,33 @@ func (s *SessionStore) Get(sessionID string) (*Session, bool) {
 	return session, true
 }
 
+// SetUsers makes Resolve check sessions against their users. Sessions live in
+// this process's memory, so this is how changes made elsewhere, such as by
+// imgctl, reach them.
+func (s *SessionStore) SetUsers(users SessionUsers) {
+	s.users = users
+}
+
+// Resolve returns the session with the given ID if its user may still use it.
+// Sessions of users who were disabled, or whose sessions were revoked by a
+// password reset, since they logged in are deleted.
+func (s *SessionStore) Resolve(ctx context.Context, sessionID string) (*Session, bool, error) {
+	session, exists := s.Get(sessionID)
+	if !exists || s.users == nil {
+		return session, exists, nil
+	}
+
+	user, err := s.users.GetUserByID(ctx, session.UserID)
+	if errors.Is(err, database.ErrUserNotFound) || (err == nil && (user.DisabledAt != nil || user.SessionEpoch != session.Epoch)) {
+		s.Delete(sessionID)
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to verify session: %w", err)
+	}
+	return session, true, nil
+}
+
 // Delete removes a session
 func (s *SessionStore) Delete(sessionID string) {
 	s.mu.Lock()
@@ -202,7 +332,11 @@ func AuthRequired(store *SessionStore) func(next http.Handler) http.Handler {
 				return
 			}
 
-			session, exists := store.Get(cookie.Value)
+			session, exists, err := store.Resolve(r.Context(), cookie.Value)
+			if err != nil {
+				http.Error(w, `{"error":"failed to verify session"}`, http.StatusInternalServerError)
+				return
+			}
 			if !exists {
 				http.Error(w, `{"error":"session expired"}`, http.StatusUnauthorized)
 				return
@@ -221,7 +355,12 @@ func OptionalAuth(store *SessionStore) func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			cookie, err := r.Cookie("session_id")
 			if err == nil {
-				if session, exists := store.Get(cookie.Value); exists {
+				session, exists, err := store.Resolve(r.Context(), cookie.Value)
+				if err != nil {
+					http.Error(w, `{"error":"failed to verify session"}`, http.StatusInternalServerError)
+					return
+				}
+				if exists {
 					ctx := context.WithValue(r.Context(), sessionContextKey, session)
 					r = r.WithContext(ctx)
 				}
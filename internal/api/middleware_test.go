@@ -0,0 +1,152 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/timkrebs/image-processor/internal/database"
+	"github.com/timkrebs/image-processor/internal/models"
+)
+
+// fakeSessionUsers serves users from a map
+type fakeSessionUsers map[uuid.UUID]*models.User
+
+func (f fakeSessionUsers) GetUserByID(_ context.Context, userID uuid.UUID) (*models.User, error) {
+	if user, ok := f[userID]; ok {
+		return user, nil
+	}
+	return nil, database.ErrUserNotFound
+}
+
+func TestSessionStore_Resolve(t *testing.T) {
+	disabledAt := time.Now()
+	tests := []struct {
+		name   string
+		update func(user *models.User, users fakeSessionUsers)
+		want   bool
+	}{
+		{"active", func(*models.User, fakeSessionUsers) {}, true},
+		{"disabled", func(user *models.User, _ fakeSessionUsers) { user.DisabledAt = &disabledAt }, false},
+		{"password reset", func(user *models.User, _ fakeSessionUsers) { user.SessionEpoch++ }, false},
+		{"deleted", func(user *models.User, users fakeSessionUsers) { delete(users, user.ID) }, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := &models.User{ID: uuid.New(), Email: "user@example.com", SessionEpoch: 3}
+			users := fakeSessionUsers{user.ID: user}
+			store := NewSessionStore(time.Hour)
+			store.SetUsers(users)
+
+			sessionID, err := store.Create(user)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.update(user, users)
+
+			_, ok, err := store.Resolve(context.Background(), sessionID)
+			if err != nil {
+				t.Fatalf("Resolve() error = %v", err)
+			}
+			if ok != tt.want {
+				t.Errorf("Resolve() ok = %v, want %v", ok, tt.want)
+			}
+			if _, exists := store.Get(sessionID); exists != tt.want {
+				t.Errorf("session kept = %v, want %v", exists, tt.want)
+			}
+		})
+	}
+}
+
+// failingSessionUsers fails every lookup
+type failingSessionUsers struct{}
+
+func (failingSessionUsers) GetUserByID(context.Context, uuid.UUID) (*models.User, error) {
+	return nil, errors.New("connection refused")
+}
+
+func TestAuthRequired_RevokedSession(t *testing.T) {
+	user := &models.User{ID: uuid.New()}
+	users := fakeSessionUsers{user.ID: user}
+	store := NewSessionStore(time.Hour)
+	store.SetUsers(users)
+	sessionID, _ := store.Create(user)
+
+	handler := AuthRequired(store)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	serve := func() int {
+		req := httptest.NewRequest("GET", "/api/v1/auth/me", http.NoBody)
+		req.AddCookie(&http.Cookie{Name: "session_id", Value: sessionID})
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, req)
+		return recorder.Code
+	}
+
+	if got := serve(); got != http.StatusNoContent {
+		t.Fatalf("Status = %d, want %d", got, http.StatusNoContent)
+	}
+	store.SetUsers(failingSessionUsers{})
+	if got := serve(); got != http.StatusInternalServerError {
+		t.Errorf("Status with failing lookup = %d, want %d", got, http.StatusInternalServerError)
+	}
+	store.SetUsers(users)
+	user.SessionEpoch++
+	if got := serve(); got != http.StatusUnauthorized {
+		t.Errorf("Status after revocation = %d, want %d", got, http.StatusUnauthorized)
+	}
+}
+
+func TestRealIP(t *testing.T) {
+	trusted, err := ParseTrustedProxies([]string{"10.0.0.0/8", "192.168.1.5"})
+	if err != nil {
+		t.Fatalf("ParseTrustedProxies() error = %v", err)
+	}
+	if _, err := ParseTrustedProxies([]string{"proxy.local"}); err == nil {
+		t.Error("ParseTrustedProxies() accepted a hostname")
+	}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  string
+		realIP     string
+		want       string
+	}{
+		{"untrusted peer ignores forwarded for", "203.0.113.7:5000", "198.51.100.1", "", "203.0.113.7:5000"},
+		{"untrusted peer ignores real ip", "203.0.113.7:5000", "", "198.51.100.1", "203.0.113.7:5000"},
+		{"trusted peer", "10.1.2.3:5000", "198.51.100.1", "", "198.51.100.1"},
+		{"trusted peer real ip", "192.168.1.5:5000", "", "198.51.100.1", "198.51.100.1"},
+		{"spoofed entries before the proxy", "10.1.2.3:5000", "1.2.3.4, 198.51.100.1", "", "198.51.100.1"},
+		{"chained trusted proxies", "10.1.2.3:5000", "1.2.3.4, 198.51.100.1, 10.9.9.9", "", "198.51.100.1"},
+		{"trusted peer without headers", "10.1.2.3:5000", "", "", "10.1.2.3:5000"},
+		{"garbage header", "10.1.2.3:5000", "not-an-ip", "", "10.1.2.3:5000"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			handler := RealIP(trusted)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = r.RemoteAddr
+			}))
+			req := httptest.NewRequest("GET", "/", http.NoBody)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.forwarded != "" {
+				req.Header.Set("X-Forwarded-For", tt.forwarded)
+			}
+			if tt.realIP != "" {
+				req.Header.Set("X-Real-IP", tt.realIP)
+			}
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+			if got != tt.want {
+				t.Errorf("RemoteAddr = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,302 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"github.com/timkrebs/image-processor/internal/database"
+	"github.com/timkrebs/image-processor/internal/models"
+)
+
+// presetRef names the preset a job is created from, as given in the form.
+// resolvePreset turns it into the job's operations.
+type presetRef struct {
+	overrides map[string]map[string]interface{}
+	id        *uuid.UUID
+	name      string
+	version   int
+}
+
+// parsePresetRef parses the preset, preset_id, preset_version and overrides
+// form fields. It returns nil if no preset is named.
+func parsePresetRef(r *http.Request) (*presetRef, error) {
+	name, idValue := r.FormValue("preset"), r.FormValue("preset_id")
+	versionValue, overridesValue := r.FormValue("preset_version"), r.FormValue("overrides")
+
+	if name == "" && idValue == "" {
+		if versionValue != "" || overridesValue != "" {
+			return nil, fmt.Errorf("preset_version and overrides require preset or preset_id")
+		}
+		return nil, nil
+	}
+	if name != "" && idValue != "" {
+		return nil, fmt.Errorf("preset and preset_id are mutually exclusive")
+	}
+
+	ref := &presetRef{name: name}
+	if idValue != "" {
+		id, err := uuid.Parse(idValue)
+		if err != nil {
+			return nil, fmt.Errorf("invalid preset_id: %s", idValue)
+		}
+		ref.id = &id
+	}
+	if versionValue != "" {
+		version, err := strconv.Atoi(versionValue)
+		if err != nil || version < 1 {
+			return nil, fmt.Errorf("invalid preset_version: %s", versionValue)
+		}
+		ref.version = version
+	}
+	if overridesValue != "" {
+		if err := json.Unmarshal([]byte(overridesValue), &ref.overrides); err != nil {
+			return nil, fmt.Errorf("invalid overrides JSON: %w", err)
+		}
+	}
+	return ref, nil
+}
+
+// resolvePreset sets the operations of a job created from a preset, writing
+// the error response if the preset can't be used
+func (h *Handlers) resolvePreset(w http.ResponseWriter, r *http.Request, opts *jobOptions) bool {
+	ref := opts.preset
+	if ref == nil {
+		return true
+	}
+	ctx := r.Context()
+	userID := requestOwner(r).UserID
+
+	var preset *models.Preset
+	var err error
+	if ref.id != nil {
+		preset, err = h.jobRepo.GetPreset(ctx, *ref.id)
+		if err == nil && !preset.VisibleTo(userID) {
+			err = database.ErrPresetNotFound
+		}
+	} else {
+		preset, err = h.jobRepo.FindPreset(ctx, userID, ref.name)
+	}
+	if errors.Is(err, database.ErrPresetNotFound) {
+		h.writeError(w, http.StatusBadRequest, "preset not found")
+		return false
+	}
+	if err != nil {
+		h.logger.Error("failed to get preset", "error", err)
+		h.writeError(w, http.StatusInternalServerError, "failed to get preset")
+		return false
+	}
+
+	operations, version := preset.Operations, preset.Version
+	if ref.version != 0 && ref.version != preset.Version {
+		pinned, err := h.jobRepo.GetPresetVersion(ctx, preset.ID, ref.version)
+		if errors.Is(err, database.ErrPresetNotFound) {
+			h.writeError(w, http.StatusBadRequest, fmt.Sprintf("preset has no version %d", ref.version))
+			return false
+		}
+		if err != nil {
+			h.logger.Error("failed to get preset version", "preset_id", preset.ID, "error", err)
+			h.writeError(w, http.StatusInternalServerError, "failed to get preset")
+			return false
+		}
+		operations, version = pinned.Operations, pinned.Version
+	}
+
+	operations, err = models.ApplyOverrides(operations, ref.overrides)
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, err.Error())
+		return false
+	}
+
+	opts.operations = operations
+	opts.presetID = &preset.ID
+	opts.presetVersion = &version
+	return true
+}
+
+// loadVisiblePreset loads the preset named by the id URL parameter if the
+// requester may see it, writing the error response otherwise
+func (h *Handlers) loadVisiblePreset(w http.ResponseWriter, r *http.Request) *models.Preset {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "invalid preset ID")
+		return nil
+	}
+
+	preset, err := h.jobRepo.GetPreset(r.Context(), id)
+	if errors.Is(err, database.ErrPresetNotFound) || (err == nil && !preset.VisibleTo(requestOwner(r).UserID)) {
+		h.writeError(w, http.StatusNotFound, "preset not found")
+		return nil
+	}
+	if err != nil {
+		h.logger.Error("failed to get preset", "error", err)
+		h.writeError(w, http.StatusInternalServerError, "failed to get preset")
+		return nil
+	}
+	return preset
+}
+
+// loadOwnedPreset loads the preset named by the id URL parameter for changing
+// it, which only its owner may do
+func (h *Handlers) loadOwnedPreset(w http.ResponseWriter, r *http.Request) *models.Preset {
+	preset := h.loadVisiblePreset(w, r)
+	if preset == nil {
+		return nil
+	}
+	if preset.UserID != requestOwner(r).UserID {
+		h.writeError(w, http.StatusForbidden, "only the owner can change a shared preset")
+		return nil
+	}
+	return preset
+}
+
+// decodePresetRequest decodes and validates a preset request body, writing the error response
+func (h *Handlers) decodePresetRequest(w http.ResponseWriter, r *http.Request) (*models.PresetRequest, bool) {
+	var req models.PresetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.writeError(w, http.StatusBadRequest, "invalid request body")
+		return nil, false
+	}
+	if err := req.Validate(); err != nil {
+		h.writeError(w, http.StatusBadRequest, err.Error())
+		return nil, false
+	}
+	for _, op := range req.Operations {
+		if !isValidOperation(op.Operation) {
+			h.writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid operation: %s", op.Operation))
+			return nil, false
+		}
+	}
+	return &req, true
+}
+
+// ListPresets handles GET /api/v1/presets. It lists the requester's presets
+// and all shared presets.
+func (h *Handlers) ListPresets(w http.ResponseWriter, r *http.Request) {
+	presets, err := h.jobRepo.ListPresets(r.Context(), requestOwner(r).UserID)
+	if err != nil {
+		h.logger.Error("failed to list presets", "error", err)
+		h.writeError(w, http.StatusInternalServerError, "failed to list presets")
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, map[string][]*models.Preset{"presets": presets})
+}
+
+// CreatePreset handles POST /api/v1/presets
+func (h *Handlers) CreatePreset(w http.ResponseWriter, r *http.Request) {
+	req, ok := h.decodePresetRequest(w, r)
+	if !ok {
+		return
+	}
+
+	preset := &models.Preset{
+		ID:          uuid.New(),
+		UserID:      requestOwner(r).UserID,
+		Name:        req.Name,
+		Description: req.Description,
+		Operations:  req.Operations,
+		Shared:      req.Shared,
+	}
+	err := h.jobRepo.CreatePreset(r.Context(), preset)
+	if errors.Is(err, database.ErrPresetExists) {
+		h.writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		h.logger.Error("failed to create preset", "error", err)
+		h.writeError(w, http.StatusInternalServerError, "failed to create preset")
+		return
+	}
+
+	h.logger.Info("preset created", "preset_id", preset.ID, "name", preset.Name, "shared", preset.Shared)
+	h.writeJSON(w, http.StatusCreated, preset)
+}
+
+// GetPreset handles GET /api/v1/presets/{id}
+func (h *Handlers) GetPreset(w http.ResponseWriter, r *http.Request) {
+	preset := h.loadVisiblePreset(w, r)
+	if preset == nil {
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, preset)
+}
+
+// UpdatePreset handles PUT /api/v1/presets/{id}. It replaces the preset, and
+// creates a new version if the operations changed.
+func (h *Handlers) UpdatePreset(w http.ResponseWriter, r *http.Request) {
+	preset := h.loadOwnedPreset(w, r)
+	if preset == nil {
+		return
+	}
+	req, ok := h.decodePresetRequest(w, r)
+	if !ok {
+		return
+	}
+
+	preset.Name = req.Name
+	preset.Description = req.Description
+	preset.Operations = req.Operations
+	preset.Shared = req.Shared
+	err := h.jobRepo.UpdatePreset(r.Context(), preset, req.Version)
+	switch {
+	case errors.Is(err, database.ErrPresetNotFound):
+		h.writeError(w, http.StatusNotFound, "preset not found")
+		return
+	case errors.Is(err, database.ErrPresetExists), errors.Is(err, database.ErrPresetVersionConflict):
+		h.writeError(w, http.StatusConflict, err.Error())
+		return
+	case err != nil:
+		h.logger.Error("failed to update preset", "preset_id", preset.ID, "error", err)
+		h.writeError(w, http.StatusInternalServerError, "failed to update preset")
+		return
+	}
+
+	h.logger.Info("preset updated", "preset_id", preset.ID, "version", preset.Version)
+	h.writeJSON(w, http.StatusOK, preset)
+}
+
+// DeletePreset handles DELETE /api/v1/presets/{id}
+func (h *Handlers) DeletePreset(w http.ResponseWriter, r *http.Request) {
+	preset := h.loadOwnedPreset(w, r)
+	if preset == nil {
+		return
+	}
+
+	err := h.jobRepo.DeletePreset(r.Context(), preset.ID, preset.UserID)
+	if errors.Is(err, database.ErrPresetNotFound) {
+		h.writeError(w, http.StatusNotFound, "preset not found")
+		return
+	}
+	if err != nil {
+		h.logger.Error("failed to delete preset", "preset_id", preset.ID, "error", err)
+		h.writeError(w, http.StatusInternalServerError, "failed to delete preset")
+		return
+	}
+
+	h.logger.Info("preset deleted", "preset_id", preset.ID)
+	h.writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+}
+
+// ListPresetVersions handles GET /api/v1/presets/{id}/versions, newest first
+func (h *Handlers) ListPresetVersions(w http.ResponseWriter, r *http.Request) {
+	preset := h.loadVisiblePreset(w, r)
+	if preset == nil {
+		return
+	}
+
+	versions, err := h.jobRepo.ListPresetVersions(r.Context(), preset.ID)
+	if err != nil {
+		h.logger.Error("failed to list preset versions", "preset_id", preset.ID, "error", err)
+		h.writeError(w, http.StatusInternalServerError, "failed to list preset versions")
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, map[string][]*models.PresetVersion{"versions": versions})
+}
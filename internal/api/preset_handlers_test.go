@@ -0,0 +1,119 @@
+package api
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+func TestParsePresetRef(t *testing.T) {
+	id := uuid.New()
+
+	tests := []struct {
+		name        string
+		form        url.Values
+		wantPreset  bool
+		wantVersion int
+		wantErr     bool
+	}{
+		{"none", url.Values{}, false, 0, false},
+		{"by name", url.Values{"preset": {"thumbs"}}, true, 0, false},
+		{"by ID and version", url.Values{"preset_id": {id.String()}, "preset_version": {"3"}}, true, 3, false},
+		{"with overrides", url.Values{"preset": {"thumbs"}, "overrides": {`{"resize": {"width": 300}}`}}, true, 0, false},
+		{"name and ID", url.Values{"preset": {"thumbs"}, "preset_id": {id.String()}}, false, 0, true},
+		{"invalid ID", url.Values{"preset_id": {"not-a-uuid"}}, false, 0, true},
+		{"invalid version", url.Values{"preset": {"thumbs"}, "preset_version": {"0"}}, false, 0, true},
+		{"invalid overrides", url.Values{"preset": {"thumbs"}, "overrides": {`{"resize": 300}`}}, false, 0, true},
+		{"version without preset", url.Values{"preset_version": {"1"}}, false, 0, true},
+		{"overrides without preset", url.Values{"overrides": {`{}`}}, false, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/api/v1/jobs", strings.NewReader(tt.form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+			ref, err := parsePresetRef(req)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parsePresetRef() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if (ref != nil) != tt.wantPreset {
+				t.Fatalf("parsePresetRef() = %v, want preset %v", ref, tt.wantPreset)
+			}
+			if ref != nil && ref.version != tt.wantVersion {
+				t.Errorf("version = %d, want %d", ref.version, tt.wantVersion)
+			}
+		})
+	}
+}
+
+func TestParseJobOptions_Preset(t *testing.T) {
+	form := url.Values{"preset": {"thumbs"}, "operations": {`[{"operation": "grayscale"}]`}}
+	req := httptest.NewRequest("POST", "/api/v1/jobs", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	if _, err := parseJobOptions(req, time.Now()); err == nil {
+		t.Error("parseJobOptions() expected an error for operations and preset")
+	}
+
+	// A preset replaces the default operation
+	form = url.Values{"preset": {"thumbs"}, "operations": {"[]"}}
+	req = httptest.NewRequest("POST", "/api/v1/jobs", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	opts, err := parseJobOptions(req, time.Now())
+	if err != nil {
+		t.Fatalf("parseJobOptions() error = %v", err)
+	}
+	if opts.preset == nil || len(opts.operations) != 0 {
+		t.Errorf("parseJobOptions() = %+v, want the preset and no operations", opts)
+	}
+}
+
+func TestHandlers_Presets_InvalidRequest(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	h := &Handlers{logger: logger}
+
+	r := chi.NewRouter()
+	r.Post("/api/v1/presets", h.CreatePreset)
+	r.Get("/api/v1/presets/{id}", h.GetPreset)
+	r.Put("/api/v1/presets/{id}", h.UpdatePreset)
+	r.Delete("/api/v1/presets/{id}", h.DeletePreset)
+	r.Get("/api/v1/presets/{id}/versions", h.ListPresetVersions)
+
+	requests := []struct {
+		method string
+		path   string
+		body   string
+	}{
+		{"POST", "/api/v1/presets", `{"name":`},
+		{"POST", "/api/v1/presets", `{"name": "thumbs", "operations": []}`},
+		{"POST", "/api/v1/presets", `{"name": "thumbs", "operations": [{"operation": "explode"}]}`},
+		{"GET", "/api/v1/presets/not-a-uuid", ""},
+		{"PUT", "/api/v1/presets/not-a-uuid", ""},
+		{"DELETE", "/api/v1/presets/not-a-uuid", ""},
+		{"GET", "/api/v1/presets/not-a-uuid/versions", ""},
+	}
+
+	for _, req := range requests {
+		t.Run(req.method+" "+req.path+" "+req.body, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			r.ServeHTTP(recorder, httptest.NewRequest(req.method, req.path, strings.NewReader(req.body)))
+
+			if recorder.Code != http.StatusBadRequest {
+				t.Errorf("Status = %d, want %d", recorder.Code, http.StatusBadRequest)
+			}
+		})
+	}
+}
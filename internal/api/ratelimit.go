@@ -0,0 +1,173 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// tokenBucketScript atomically refills and takes one token from a bucket.
+// KEYS[1] bucket key, ARGV[1] refill rate per second, ARGV[2] burst, ARGV[3] now in ms.
+// Returns {allowed, remaining tokens as string}.
+var tokenBucketScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local bucket = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(bucket[1])
+local ts = tonumber(bucket[2])
+if tokens == nil then
+	tokens = burst
+	ts = now
+end
+tokens = math.min(burst, tokens + math.max(0, now - ts) / 1000 * rate)
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+redis.call('HSET', KEYS[1], 'tokens', tokens, 'ts', now)
+redis.call('PEXPIRE', KEYS[1], math.ceil(burst / rate * 1000) + 1000)
+return {allowed, tostring(tokens)}
+`)
+
+// RateLimiter is a Redis-backed token bucket shared by all API replicas
+type RateLimiter struct {
+	client *redis.Client
+	prefix string
+	rate   float64
+	burst  int
+}
+
+// RateLimitResult describes the outcome of a rate limit check
+type RateLimitResult struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	RetryAfter time.Duration
+	ResetAfter time.Duration
+}
+
+// NewRateLimiter creates a token bucket limiter that refills perMinute tokens a minute up to burst
+func NewRateLimiter(client *redis.Client, prefix string, perMinute, burst int) *RateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &RateLimiter{
+		client: client,
+		prefix: prefix,
+		rate:   float64(perMinute) / 60,
+		burst:  burst,
+	}
+}
+
+// RequestsPerMinute returns the sustained request rate
+func (l *RateLimiter) RequestsPerMinute() int {
+	return int(math.Round(l.rate * 60))
+}
+
+// Burst returns the bucket size
+func (l *RateLimiter) Burst() int {
+	return l.burst
+}
+
+// Allow takes a token from the bucket identified by key
+func (l *RateLimiter) Allow(ctx context.Context, key string) (*RateLimitResult, error) {
+	res, err := tokenBucketScript.Run(ctx, l.client, []string{l.prefix + key},
+		l.rate, l.burst, time.Now().UnixMilli()).Slice()
+	if err != nil {
+		return nil, fmt.Errorf("failed to run rate limit script: %w", err)
+	}
+	if len(res) != 2 {
+		return nil, fmt.Errorf("unexpected rate limit script result: %v", res)
+	}
+
+	allowed, _ := res[0].(int64)
+	tokensStr, _ := res[1].(string)
+	tokens, err := strconv.ParseFloat(tokensStr, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid rate limit token count: %w", err)
+	}
+
+	return l.result(allowed == 1, tokens), nil
+}
+
+// result derives the response headers' values from the remaining tokens
+func (l *RateLimiter) result(allowed bool, tokens float64) *RateLimitResult {
+	result := &RateLimitResult{
+		Allowed:    allowed,
+		Limit:      l.burst,
+		Remaining:  int(math.Floor(tokens)),
+		ResetAfter: secondsToDuration((float64(l.burst) - tokens) / l.rate),
+	}
+	if !allowed {
+		result.RetryAfter = secondsToDuration((1 - tokens) / l.rate)
+	}
+	return result
+}
+
+func secondsToDuration(s float64) time.Duration {
+	if s <= 0 || math.IsInf(s, 0) || math.IsNaN(s) {
+		return 0
+	}
+	return time.Duration(s * float64(time.Second))
+}
+
+// RateLimit middleware limits requests per caller using the given limiter.
+// Redis failures are logged and let the request through.
+func RateLimit(limiter *RateLimiter, logger *slog.Logger) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			result, err := limiter.Allow(r.Context(), rateLimitKey(r))
+			if err != nil {
+				logger.Error("rate limit check failed", "error", err)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			setRateLimitHeaders(w, result)
+			if !result.Allowed {
+				w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(result.RetryAfter)))
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusTooManyRequests)
+				w.Write([]byte(`{"error":"rate limit exceeded"}`))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// setRateLimitHeaders writes the X-RateLimit-* headers
+func setRateLimitHeaders(w http.ResponseWriter, result *RateLimitResult) {
+	w.Header().Set("X-RateLimit-Limit", strconv.Itoa(result.Limit))
+	w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	w.Header().Set("X-RateLimit-Reset", strconv.Itoa(ceilSeconds(result.ResetAfter)))
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// rateLimitKey identifies the caller by session user, else by client IP.
+// Anything else the caller sends, such as a header or a fresh anonymous
+// cookie, could be changed on every request to get a new bucket.
+func rateLimitKey(r *http.Request) string {
+	if session, ok := GetSession(r.Context()); ok && session != nil {
+		return "user:" + session.UserID.String()
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
@@ -0,0 +1,70 @@
+package api
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestNewRateLimiter(t *testing.T) {
+	limiter := NewRateLimiter(nil, "ratelimit:", 120, 0)
+
+	if got := limiter.RequestsPerMinute(); got != 120 {
+		t.Errorf("RequestsPerMinute() = %d, want 120", got)
+	}
+	if got := limiter.Burst(); got != 1 {
+		t.Errorf("Burst() = %d, want 1 for non-positive burst", got)
+	}
+}
+
+func TestRateLimiter_Result(t *testing.T) {
+	// 60/min refills one token per second
+	limiter := NewRateLimiter(nil, "ratelimit:", 60, 10)
+
+	allowed := limiter.result(true, 7.5)
+	if !allowed.Allowed || allowed.Limit != 10 || allowed.Remaining != 7 {
+		t.Errorf("result(true, 7.5) = %+v", allowed)
+	}
+	if allowed.ResetAfter != 2500*time.Millisecond {
+		t.Errorf("ResetAfter = %v, want 2.5s", allowed.ResetAfter)
+	}
+	if allowed.RetryAfter != 0 {
+		t.Errorf("RetryAfter = %v, want 0 when allowed", allowed.RetryAfter)
+	}
+
+	denied := limiter.result(false, 0.25)
+	if denied.Allowed || denied.Remaining != 0 {
+		t.Errorf("result(false, 0.25) = %+v", denied)
+	}
+	if denied.RetryAfter != 750*time.Millisecond {
+		t.Errorf("RetryAfter = %v, want 750ms", denied.RetryAfter)
+	}
+	if got := ceilSeconds(denied.RetryAfter); got != 1 {
+		t.Errorf("ceilSeconds(RetryAfter) = %d, want 1", got)
+	}
+}
+
+func TestRateLimitKey(t *testing.T) {
+	userID := uuid.New()
+
+	req := httptest.NewRequest("POST", "/api/v1/jobs", nil)
+	req.RemoteAddr = "10.0.0.1:5000"
+	if got := rateLimitKey(req); got != "ip:10.0.0.1" {
+		t.Errorf("rateLimitKey() = %q, want ip:10.0.0.1", got)
+	}
+
+	// Unverified API keys must not get their own bucket
+	req.Header.Set("X-API-Key", "secret-key")
+	if got := rateLimitKey(req); got != "ip:10.0.0.1" {
+		t.Errorf("rateLimitKey() with X-API-Key = %q, want ip:10.0.0.1", got)
+	}
+
+	ctx := context.WithValue(req.Context(), sessionContextKey, &Session{UserID: userID})
+	req = req.WithContext(ctx)
+	if got := rateLimitKey(req); got != "user:"+userID.String() {
+		t.Errorf("rateLimitKey() = %q, want user:%s", got, userID)
+	}
+}
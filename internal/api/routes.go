@@ -2,6 +2,7 @@ package api
 
 import (
 	"log/slog"
+	"net/http"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
@@ -20,44 +21,124 @@ func NewRouter(handlers *Handlers, httpMetrics *metrics.HTTPMetrics, maxUploadSi
 
 	// Global middleware
 	r.Use(middleware.RequestID)
-	r.Use(middleware.RealIP)
+	r.Use(RealIP(handlers.proxies))
 	r.Use(StructuredLogger(logger))
 	r.Use(middleware.Recoverer)
 	r.Use(CORS)
-	r.Use(MaxUploadSize(maxUploadSize))
 	r.Use(MetricsMiddleware(httpMetrics))
 	r.Use(OptionalAuth(sessionStore))
 
 	// Metrics endpoint
 	r.Handle("/metrics", promhttp.Handler())
 
+	// Job endpoints are open to anonymous clients, each with its own
+	// signed identity, unless anonymous submission is disabled
+	jobAuth := AuthRequired(sessionStore)
+	if handlers.anonymous != nil {
+		jobAuth = handlers.anonymous.Middleware
+	}
+
+	// Job submissions are rate limited when a limiter is configured
+	submitLimit := func(next http.Handler) http.Handler { return next }
+	if handlers.limiter != nil {
+		submitLimit = RateLimit(handlers.limiter, logger)
+	}
+
 	// API routes
 	r.Route("/api/v1", func(r chi.Router) {
-		// Health check
-		r.Get("/health", handlers.Health)
-
-		// Authentication
-		r.Route("/auth", func(r chi.Router) {
-			r.Post("/register", authHandlers.Register)
-			r.Post("/login", authHandlers.Login)
-			r.Post("/logout", authHandlers.Logout)
-			r.With(AuthRequired(sessionStore)).Get("/me", authHandlers.GetCurrentUser)
-		})
+		r.Group(func(r chi.Router) {
+			r.Use(MaxUploadSize(maxUploadSize))
 
-		// Jobs
-		r.Route("/jobs", func(r chi.Router) {
-			r.Post("/", handlers.CreateJob)
-			r.Get("/", handlers.ListJobs)
-			r.Get("/{id}", handlers.GetJob)
-			r.Get("/{id}/stream", handlers.StreamJobStatus)
-			r.Delete("/{id}", handlers.CancelJob)
-		})
+			// Health check
+			r.Get("/health", handlers.Health)
+
+			// Authentication
+			r.Route("/auth", func(r chi.Router) {
+				r.Post("/register", authHandlers.Register)
+				r.Post("/login", authHandlers.Login)
+				r.Post("/logout", authHandlers.Logout)
+				r.With(AuthRequired(sessionStore)).Get("/me", authHandlers.GetCurrentUser)
+			})
+
+			// Jobs
+			r.With(jobAuth).Route("/jobs", func(r chi.Router) {
+				r.With(submitLimit).Post("/", handlers.CreateJob)
+				r.Get("/", handlers.ListJobs)
+				r.With(submitLimit).Post("/bulk", handlers.BulkJobs)
+				r.Get("/{id}", handlers.GetJob)
+				r.Get("/{id}/stream", handlers.StreamJobStatus)
+				r.Get("/{id}/history", handlers.JobHistory)
+				r.Get("/{id}/compare", handlers.CompareJob)
+				r.Get("/{id}/similar", handlers.SimilarJobs)
+				r.With(submitLimit).Post("/{id}/retry", handlers.RetryJob)
+				r.With(submitLimit).Post("/{id}/clone", handlers.CloneJob)
+				r.Delete("/{id}", handlers.CancelJob)
+			})
+
+			// Saved operation pipelines; anyone may use shared presets, only users save them
+			r.Route("/presets", func(r chi.Router) {
+				r.With(jobAuth).Get("/", handlers.ListPresets)
+				r.With(jobAuth).Get("/{id}", handlers.GetPreset)
+				r.With(jobAuth).Get("/{id}/versions", handlers.ListPresetVersions)
+				r.Group(func(r chi.Router) {
+					r.Use(AuthRequired(sessionStore))
+					r.Post("/", handlers.CreatePreset)
+					r.Put("/{id}", handlers.UpdatePreset)
+					r.Delete("/{id}", handlers.DeletePreset)
+				})
+			})
 
-		// Images
-		r.Get("/images/{id}", handlers.GetImage)
+			// Fonts for text operations; anyone may list the embedded ones, only users upload
+			r.Route("/fonts", func(r chi.Router) {
+				r.With(jobAuth).Get("/", handlers.ListFonts)
+				r.Group(func(r chi.Router) {
+					r.Use(AuthRequired(sessionStore))
+					r.Post("/", handlers.CreateFont)
+					r.Delete("/{id}", handlers.DeleteFont)
+				})
+			})
 
-		// Stats
-		r.Get("/stats/queue", handlers.GetQueueStats)
+			// Direct-to-storage uploads
+			r.With(jobAuth).Route("/uploads", func(r chi.Router) {
+				r.With(submitLimit).Post("/", handlers.CreateUpload)
+
+				// Resumable uploads (tus protocol)
+				if handlers.multipart != nil {
+					r.Route("/resumable", func(r chi.Router) {
+						r.Use(handlers.tusProtocol)
+						r.Options("/", handlers.TusOptions)
+						r.With(submitLimit).Post("/", handlers.CreateResumableUpload)
+						r.Head("/{id}", handlers.HeadResumableUpload)
+						r.Patch("/{id}", handlers.PatchResumableUpload)
+						r.Delete("/{id}", handlers.DeleteResumableUpload)
+					})
+				}
+			})
+
+			// Quota usage for the current caller
+			r.With(jobAuth).Get("/me/usage", handlers.GetUsage)
+
+			// Images
+			r.With(jobAuth).Get("/images/{id}", handlers.GetImage)
+			r.With(jobAuth).Head("/images/{id}", handlers.GetImage)
+			if handlers.transforms != nil {
+				// Transformation URLs are authorized by their signature, so they can be embedded anywhere
+				r.Get("/images/{id}/transform", handlers.TransformImage)
+				r.Head("/images/{id}/transform", handlers.TransformImage)
+				r.With(jobAuth).Get("/images/{id}/transform-url", handlers.SignTransform)
+			}
+
+			// Stats
+			r.Get("/stats/queue", handlers.GetQueueStats)
+		})
+
+		// Batches carry many files, so they get their own upload limit
+		_, batchMaxUploadSize := handlers.batchLimits()
+		r.With(jobAuth).Route("/batches", func(r chi.Router) {
+			r.With(MaxUploadSize(batchMaxUploadSize), submitLimit).Post("/", handlers.CreateBatch)
+			r.Get("/{id}", handlers.GetBatch)
+			r.Get("/{id}/download", handlers.DownloadBatch)
+		})
 	})
 
 	return r
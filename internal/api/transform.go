@@ -0,0 +1,324 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"github.com/timkrebs/image-processor/internal/database"
+	"github.com/timkrebs/image-processor/internal/models"
+	"github.com/timkrebs/image-processor/internal/processor"
+	"github.com/timkrebs/image-processor/internal/storage"
+)
+
+const (
+	defaultTransformMaxDimension = 4096
+	// transformTimeout bounds rendering a variant, independent of the requests waiting for it
+	transformTimeout = 30 * time.Second
+)
+
+// errTransformBusy is returned when no render slot frees up in time
+var errTransformBusy = errors.New("too many transformations in progress")
+
+// imageTransform is an on-the-fly transformation of a job's original image
+type imageTransform struct {
+	Fit    string
+	Format string // output content type; empty keeps the original's format
+	Width  int
+	Height int
+}
+
+// transformFormats maps the fmt parameter to output content types
+var transformFormats = map[string]string{
+	"jpeg": "image/jpeg",
+	"jpg":  "image/jpeg",
+	"png":  "image/png",
+	"gif":  "image/gif",
+}
+
+// parseImageTransform reads w, h, fit and fmt from a query string
+func parseImageTransform(query url.Values, maxDimension int) (imageTransform, error) {
+	t := imageTransform{Fit: processor.FitContain}
+
+	for name, dst := range map[string]*int{"w": &t.Width, "h": &t.Height} {
+		value := query.Get(name)
+		if value == "" {
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 || n > maxDimension {
+			return t, fmt.Errorf("%s must be between 0 and %d", name, maxDimension)
+		}
+		*dst = n
+	}
+
+	if fit := query.Get("fit"); fit != "" {
+		switch fit {
+		case processor.FitContain, processor.FitCover, processor.FitFill:
+			t.Fit = fit
+		default:
+			return t, fmt.Errorf("fit must be %s, %s or %s", processor.FitContain, processor.FitCover, processor.FitFill)
+		}
+	}
+
+	if format := strings.ToLower(query.Get("fmt")); format != "" {
+		contentType, ok := transformFormats[format]
+		if format == "webp" {
+			// Only a WebP decoder is available in pure Go
+			return t, fmt.Errorf("webp output is not supported, fmt must be jpeg, png or gif")
+		}
+		if !ok {
+			return t, fmt.Errorf("unsupported format: %s, fmt must be jpeg, png or gif", format)
+		}
+		t.Format = contentType
+	}
+
+	return t, nil
+}
+
+// canonical returns the normalized parameters, which are what is signed and cached
+func (t imageTransform) canonical() string {
+	return fmt.Sprintf("w=%d&h=%d&fit=%s&fmt=%s", t.Width, t.Height, t.Fit, strings.TrimPrefix(t.Format, "image/"))
+}
+
+// query returns the URL query requesting this transformation
+func (t imageTransform) query() url.Values {
+	query := url.Values{}
+	if t.Width > 0 {
+		query.Set("w", strconv.Itoa(t.Width))
+	}
+	if t.Height > 0 {
+		query.Set("h", strconv.Itoa(t.Height))
+	}
+	query.Set("fit", t.Fit)
+	if t.Format != "" {
+		query.Set("fmt", strings.TrimPrefix(t.Format, "image/"))
+	}
+	return query
+}
+
+// operations returns the processor pipeline producing this transformation
+func (t imageTransform) operations() []models.Operation {
+	if t.Width == 0 && t.Height == 0 {
+		return nil
+	}
+	return []models.Operation{{
+		Operation: models.OperationResize,
+		Parameters: map[string]interface{}{
+			"width":  t.Width,
+			"height": t.Height,
+			"fit":    t.Fit,
+		},
+	}}
+}
+
+// outputType returns the content type the transformation of an original produces
+func (t imageTransform) outputType(originalType string) string {
+	if t.Format != "" {
+		return t.Format
+	}
+	if strings.Contains(originalType, "png") {
+		return "image/png"
+	}
+	return "image/jpeg"
+}
+
+// key returns the deterministic storage key of the job's variant
+func (t imageTransform) key(job *models.Job) string {
+	sum := sha256.Sum256([]byte(t.canonical()))
+	ext := strings.TrimPrefix(t.outputType(job.ContentType), "image/")
+	return job.DerivedPrefix() + hex.EncodeToString(sum[:16]) + "." + ext
+}
+
+// imageTransformer renders variants, limiting how many run at once and
+// rendering each missing variant only once however many requests ask for it
+type imageTransformer struct {
+	signingKey   []byte
+	slots        chan struct{}
+	processor    *processor.Processor
+	flights      flightGroup
+	maxDimension int
+}
+
+// SetTransforms enables transformation URLs signed with signingKey, rendering at most
+// concurrency variants at a time and no larger than maxDimension pixels per side
+func (h *Handlers) SetTransforms(signingKey []byte, concurrency, maxDimension int) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	if maxDimension < 1 {
+		maxDimension = defaultTransformMaxDimension
+	}
+
+	h.transforms = &imageTransformer{
+		signingKey:   signingKey,
+		slots:        make(chan struct{}, concurrency),
+		processor:    processor.New(),
+		maxDimension: maxDimension,
+	}
+}
+
+// sign returns the URL-safe HMAC-SHA256 of an image ID and transformation
+func (t *imageTransformer) sign(id uuid.UUID, transform imageTransform) string {
+	mac := hmac.New(sha256.New, t.signingKey)
+	mac.Write([]byte(id.String() + "?" + transform.canonical()))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// verify checks a signature produced by sign
+func (t *imageTransformer) verify(id uuid.UUID, transform imageTransform, signature string) bool {
+	sig, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	expected, _ := base64.RawURLEncoding.DecodeString(t.sign(id, transform))
+	return hmac.Equal(sig, expected)
+}
+
+// TransformImage handles GET /api/v1/images/{id}/transform
+// Serves a resized/converted variant of the job's original, rendering and caching it on first use.
+func (h *Handlers) TransformImage(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "invalid image ID")
+		return
+	}
+
+	transform, err := parseImageTransform(r.URL.Query(), h.transforms.maxDimension)
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if !h.transforms.verify(id, transform, r.URL.Query().Get("sig")) {
+		h.writeError(w, http.StatusForbidden, "invalid signature")
+		return
+	}
+
+	job, err := h.jobRepo.GetByID(ctx, id)
+	if errors.Is(err, database.ErrNotFound) {
+		h.writeError(w, http.StatusNotFound, "image not found")
+		return
+	}
+	if err != nil {
+		h.logger.Error("failed to get job", "error", err)
+		h.writeError(w, http.StatusInternalServerError, "failed to get job")
+		return
+	}
+
+	key := transform.key(job)
+	exists, err := h.storage.Exists(ctx, key)
+	if err != nil {
+		h.logger.Error("failed to check variant", "key", key, "error", err)
+		h.writeError(w, http.StatusInternalServerError, "failed to transform image")
+		return
+	}
+
+	if !exists {
+		// Variants rendered before the original was deleted are still served
+		if job.OriginalDeletedAt != nil {
+			h.writeError(w, http.StatusGone, "original was deleted after processing")
+			return
+		}
+
+		err, shared := h.transforms.flights.Do(key, func() error {
+			return h.renderVariant(ctx, job, transform, key)
+		})
+		if errors.Is(err, errTransformBusy) {
+			w.Header().Set("Retry-After", "1")
+			h.writeError(w, http.StatusServiceUnavailable, err.Error())
+			return
+		}
+		if err != nil {
+			h.logger.Error("failed to transform image", "job_id", job.ID, "error", err)
+			h.writeError(w, http.StatusInternalServerError, "failed to transform image")
+			return
+		}
+		if !shared {
+			h.logger.Info("image variant rendered", "job_id", job.ID, "key", key)
+		}
+	}
+
+	// The original never changes, so neither do its variants
+	h.serveImage(w, r, key, transform.outputType(job.ContentType), storage.PresignOptions{
+		CacheControl:       h.imageCacheControl(true),
+		ContentDisposition: imageDisposition(job.OriginalName, r.URL.Query().Get("download") == "true"),
+	})
+}
+
+// renderVariant processes the job's original and stores the result at key.
+// It runs detached from the request so that requests sharing the render are
+// not failed when the one that started it goes away.
+func (h *Handlers) renderVariant(ctx context.Context, job *models.Job, transform imageTransform, key string) error {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), transformTimeout)
+	defer cancel()
+
+	select {
+	case h.transforms.slots <- struct{}{}:
+		defer func() { <-h.transforms.slots }()
+	case <-ctx.Done():
+		return errTransformBusy
+	}
+
+	reader, err := h.storage.Download(ctx, job.OriginalKey)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	result, err := h.transforms.processor.ProcessAs(reader, job.ContentType, transform.operations(), transform.outputType(job.ContentType))
+	if err != nil {
+		return err
+	}
+
+	return h.storage.Upload(ctx, key, bytes.NewReader(result.Data), int64(len(result.Data)), result.ContentType)
+}
+
+// SignTransform handles GET /api/v1/images/{id}/transform-url
+// Returns a signed transformation URL for an image the requester owns.
+func (h *Handlers) SignTransform(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "invalid image ID")
+		return
+	}
+
+	transform, err := parseImageTransform(r.URL.Query(), h.transforms.maxDimension)
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	job, err := h.jobRepo.GetByID(r.Context(), id)
+	if errors.Is(err, database.ErrNotFound) || (err == nil && !requestOwner(r).Owns(job.UserID, job.AnonymousID)) {
+		h.writeError(w, http.StatusNotFound, "image not found")
+		return
+	}
+	if err != nil {
+		h.logger.Error("failed to get job", "error", err)
+		h.writeError(w, http.StatusInternalServerError, "failed to get job")
+		return
+	}
+
+	query := transform.query()
+	query.Set("sig", h.transforms.sign(id, transform))
+
+	h.writeJSON(w, http.StatusOK, map[string]string{
+		"url": "/api/v1/images/" + id.String() + "/transform?" + query.Encode(),
+	})
+}
@@ -0,0 +1,110 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"github.com/timkrebs/image-processor/internal/models"
+)
+
+func TestParseImageTransform(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		want    imageTransform
+		wantErr bool
+	}{
+		{"defaults", "", imageTransform{Fit: "contain"}, false},
+		{"full", "w=400&h=300&fit=cover&fmt=png", imageTransform{Width: 400, Height: 300, Fit: "cover", Format: "image/png"}, false},
+		{"jpg alias", "w=10&fmt=JPG", imageTransform{Width: 10, Fit: "contain", Format: "image/jpeg"}, false},
+		{"too large", "w=5000", imageTransform{}, true},
+		{"negative", "h=-1", imageTransform{}, true},
+		{"not a number", "w=big", imageTransform{}, true},
+		{"unknown fit", "fit=squash", imageTransform{}, true},
+		{"unsupported format", "fmt=tiff", imageTransform{}, true},
+		{"webp", "fmt=webp", imageTransform{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, _ := url.ParseQuery(tt.query)
+			got, err := parseImageTransform(query, 4096)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseImageTransform() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("parseImageTransform() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestImageTransform_Key(t *testing.T) {
+	job := &models.Job{ID: uuid.New(), UserID: uuid.New(), ContentType: "image/png"}
+	a := imageTransform{Width: 400, Fit: "contain"}
+	b := imageTransform{Width: 401, Fit: "contain"}
+
+	if a.key(job) != a.key(job) {
+		t.Error("key() should be deterministic")
+	}
+	if a.key(job) == b.key(job) {
+		t.Error("different transformations should have different keys")
+	}
+	if !strings.HasPrefix(a.key(job), job.DerivedPrefix()) || !strings.HasSuffix(a.key(job), ".png") {
+		t.Errorf("key() = %s, want PNG under %s", a.key(job), job.DerivedPrefix())
+	}
+
+	// Parsing the generated query yields the same transformation
+	parsed, err := parseImageTransform(imageTransform{Width: 400, Height: 300, Fit: "cover", Format: "image/gif"}.query(), 4096)
+	if err != nil || parsed.key(job) != (imageTransform{Width: 400, Height: 300, Fit: "cover", Format: "image/gif"}).key(job) {
+		t.Errorf("query() round trip = %+v, %v", parsed, err)
+	}
+}
+
+func TestImageTransformer_Sign(t *testing.T) {
+	h := &Handlers{}
+	h.SetTransforms([]byte("secret"), 1, 0)
+	id := uuid.New()
+	transform := imageTransform{Width: 400, Fit: "contain"}
+
+	sig := h.transforms.sign(id, transform)
+	if !h.transforms.verify(id, transform, sig) {
+		t.Error("verify() should accept its own signature")
+	}
+	if h.transforms.verify(id, imageTransform{Width: 4000, Fit: "contain"}, sig) {
+		t.Error("verify() should reject a signature for other parameters")
+	}
+	if h.transforms.verify(uuid.New(), transform, sig) {
+		t.Error("verify() should reject a signature for another image")
+	}
+	if h.transforms.verify(id, transform, "") {
+		t.Error("verify() should reject a missing signature")
+	}
+}
+
+func TestHandlers_TransformImage_InvalidSignature(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	h := &Handlers{logger: logger}
+	h.SetTransforms([]byte("secret"), 1, 0)
+
+	req := httptest.NewRequest("GET", "/api/v1/images/x/transform?w=100&sig=forged", nil)
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("id", uuid.New().String())
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+	recorder := httptest.NewRecorder()
+
+	h.TransformImage(recorder, req)
+
+	if recorder.Code != http.StatusForbidden {
+		t.Errorf("Status = %d, want %d", recorder.Code, http.StatusForbidden)
+	}
+}
@@ -0,0 +1,509 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"  //nolint:gosec // tus checksum algorithm negotiated by the client
+	"crypto/sha1" //nolint:gosec // tus checksum algorithm negotiated by the client
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"github.com/timkrebs/image-processor/internal/database"
+	"github.com/timkrebs/image-processor/internal/models"
+	"github.com/timkrebs/image-processor/internal/storage"
+)
+
+// Resumable uploads implement the tus 1.0 protocol (https://tus.io/protocols/resumable-upload)
+// with the creation, checksum, expiration and termination extensions, on top of
+// storage multipart uploads.
+const (
+	tusVersion            = "1.0.0"
+	tusExtensions         = "creation,checksum,expiration,termination"
+	tusChecksumAlgorithms = "sha1,md5,sha256"
+	tusContentType        = "application/offset+octet-stream"
+
+	// statusChecksumMismatch is the tus checksum extension's "460 Checksum Mismatch"
+	statusChecksumMismatch = 460
+
+	defaultResumableUploadTTL = 24 * time.Hour
+	uploadLockLease           = 5 * time.Minute
+)
+
+// resumableUploadTTL returns how long an idle resumable upload is kept
+func (h *Handlers) resumableUploadTTL() time.Duration {
+	if h.resumableTTL <= 0 {
+		return defaultResumableUploadTTL
+	}
+	return h.resumableTTL
+}
+
+// tusProtocol checks the client's protocol version and tags responses with ours
+func (h *Handlers) tusProtocol(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Tus-Resumable", tusVersion)
+
+		if r.Method != http.MethodOptions && r.Header.Get("Tus-Resumable") != tusVersion {
+			w.Header().Set("Tus-Version", tusVersion)
+			h.writeError(w, http.StatusPreconditionFailed, "unsupported tus version")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// TusOptions handles OPTIONS /api/v1/uploads/resumable
+func (h *Handlers) TusOptions(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Tus-Version", tusVersion)
+	w.Header().Set("Tus-Extension", tusExtensions)
+	w.Header().Set("Tus-Max-Size", strconv.FormatInt(h.fileSizeLimit(), 10))
+	w.Header().Set("Tus-Checksum-Algorithm", tusChecksumAlgorithms)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// CreateResumableUpload handles POST /api/v1/uploads/resumable
+func (h *Handlers) CreateResumableUpload(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	length, err := strconv.ParseInt(r.Header.Get("Upload-Length"), 10, 64)
+	if err != nil || length < 1 {
+		h.writeError(w, http.StatusBadRequest, "Upload-Length must be a positive integer")
+		return
+	}
+	if length > h.fileSizeLimit() {
+		h.writeError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("upload exceeds maximum size of %d bytes", h.fileSizeLimit()))
+		return
+	}
+
+	metadata, err := parseUploadMetadata(r.Header.Get("Upload-Metadata"))
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	filename := path.Base(strings.ReplaceAll(metadata["filename"], "\\", "/"))
+	if metadata["filename"] == "" || filename == "." || filename == "/" {
+		h.writeError(w, http.StatusBadRequest, "filename metadata is required")
+		return
+	}
+
+	contentType := metadata["filetype"]
+	if contentType == "" {
+		contentType = detectContentType(filename)
+	}
+	if !isValidImageType(contentType) {
+		h.writeError(w, http.StatusBadRequest, "invalid image type, must be JPEG, PNG, or GIF")
+		return
+	}
+
+	owner := requestOwner(r)
+	now := time.Now()
+	upload := &models.Upload{
+		ID:          uuid.New(),
+		UserID:      owner.UserID,
+		AnonymousID: owner.AnonymousID,
+		Filename:    filename,
+		ContentType: contentType,
+		Size:        length,
+		Status:      models.UploadStatusPending,
+		ExpiresAt:   now.Add(h.resumableUploadTTL()),
+		CreatedAt:   now,
+	}
+	upload.ObjectKey = fmt.Sprintf("users/%s/original/%s/%s", owner.UserID.String(), upload.ID.String(), filename)
+
+	upload.MultipartID, err = h.multipart.NewMultipartUpload(ctx, upload.ObjectKey, contentType)
+	if err != nil {
+		h.logger.Error("failed to start multipart upload", "error", err)
+		h.writeError(w, http.StatusInternalServerError, "failed to create upload")
+		return
+	}
+
+	if err := h.jobRepo.CreateUpload(ctx, upload); err != nil {
+		h.logger.Error("failed to create upload", "error", err)
+		if abortErr := h.multipart.AbortMultipartUpload(ctx, upload.ObjectKey, upload.MultipartID); abortErr != nil {
+			h.logger.Error("failed to abort multipart upload", "error", abortErr)
+		}
+		h.writeError(w, http.StatusInternalServerError, "failed to create upload")
+		return
+	}
+
+	h.logger.Info("resumable upload created", "upload_id", upload.ID, "size", upload.Size)
+	w.Header().Set("Location", "/api/v1/uploads/resumable/"+upload.ID.String())
+	w.Header().Set("Upload-Expires", upload.ExpiresAt.UTC().Format(http.TimeFormat))
+	w.WriteHeader(http.StatusCreated)
+}
+
+// HeadResumableUpload handles HEAD /api/v1/uploads/resumable/{id}
+func (h *Handlers) HeadResumableUpload(w http.ResponseWriter, r *http.Request) {
+	upload := h.loadResumableUpload(w, r)
+	if upload == nil {
+		return
+	}
+
+	offset, _, err := h.resumableOffset(r.Context(), upload)
+	if err != nil {
+		h.logger.Error("failed to get upload offset", "upload_id", upload.ID, "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Upload-Offset", strconv.FormatInt(offset, 10))
+	w.Header().Set("Upload-Length", strconv.FormatInt(upload.Size, 10))
+	w.Header().Set("Upload-Expires", upload.ExpiresAt.UTC().Format(http.TimeFormat))
+	w.WriteHeader(http.StatusOK)
+}
+
+// PatchResumableUpload handles PATCH /api/v1/uploads/resumable/{id}
+// Appends a chunk at the client's offset, verifying its checksum if one was sent.
+func (h *Handlers) PatchResumableUpload(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if r.Header.Get("Content-Type") != tusContentType {
+		h.writeError(w, http.StatusUnsupportedMediaType, "Content-Type must be "+tusContentType)
+		return
+	}
+
+	clientOffset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil || clientOffset < 0 {
+		h.writeError(w, http.StatusBadRequest, "Upload-Offset must be a non-negative integer")
+		return
+	}
+
+	hasher, expectedSum, err := parseUploadChecksum(r.Header.Get("Upload-Checksum"))
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	upload := h.loadResumableUpload(w, r)
+	if upload == nil {
+		return
+	}
+
+	// Chunks of one upload must never be written concurrently
+	locked, err := h.jobRepo.LockUpload(ctx, upload.ID, uploadLockLease)
+	if err != nil {
+		h.logger.Error("failed to lock upload", "upload_id", upload.ID, "error", err)
+		h.writeError(w, http.StatusInternalServerError, "failed to write chunk")
+		return
+	}
+	if !locked {
+		h.writeError(w, http.StatusLocked, "upload is being written by another request")
+		return
+	}
+	expiresAt := time.Now().Add(h.resumableUploadTTL())
+	defer func() {
+		// Use a fresh context so the lease is released even if the client went away
+		if err := h.jobRepo.UnlockUpload(context.WithoutCancel(ctx), upload.ID, expiresAt); err != nil {
+			h.logger.Error("failed to unlock upload", "upload_id", upload.ID, "error", err)
+		}
+	}()
+
+	offset, parts, err := h.resumableOffset(ctx, upload)
+	if err != nil {
+		h.logger.Error("failed to get upload offset", "upload_id", upload.ID, "error", err)
+		h.writeError(w, http.StatusInternalServerError, "failed to write chunk")
+		return
+	}
+	if clientOffset != offset {
+		w.Header().Set("Upload-Offset", strconv.FormatInt(offset, 10))
+		h.writeError(w, http.StatusConflict, "Upload-Offset does not match current offset")
+		return
+	}
+	if offset == upload.Size {
+		h.writeError(w, http.StatusForbidden, "upload already completed")
+		return
+	}
+
+	// Buffer the chunk so nothing is committed before its checksum is verified
+	chunk, err := os.CreateTemp("", "upload-chunk-*")
+	if err != nil {
+		h.logger.Error("failed to create chunk buffer", "error", err)
+		h.writeError(w, http.StatusInternalServerError, "failed to write chunk")
+		return
+	}
+	defer os.Remove(chunk.Name())
+	defer chunk.Close()
+
+	remaining := upload.Size - offset
+	var sink io.Writer = chunk
+	if hasher != nil {
+		sink = io.MultiWriter(chunk, hasher)
+	}
+	n, readErr := io.Copy(sink, io.LimitReader(r.Body, remaining+1))
+	if n > remaining {
+		h.writeError(w, http.StatusRequestEntityTooLarge, "chunk exceeds Upload-Length")
+		return
+	}
+	if readErr != nil && hasher != nil {
+		// A partial chunk cannot match the checksum, so discard it
+		h.writeError(w, http.StatusBadRequest, "failed to read chunk")
+		return
+	}
+	if hasher != nil && !bytes.Equal(hasher.Sum(nil), expectedSum) {
+		h.writeError(w, statusChecksumMismatch, "checksum mismatch")
+		return
+	}
+	// Without a checksum, keep whatever arrived so an interrupted client can resume from there
+
+	if _, err := chunk.Seek(0, io.SeekStart); err != nil {
+		h.logger.Error("failed to rewind chunk buffer", "error", err)
+		h.writeError(w, http.StatusInternalServerError, "failed to write chunk")
+		return
+	}
+
+	if err := h.writeResumableChunk(ctx, upload, parts, offset, chunk, n); err != nil {
+		h.logger.Error("failed to write chunk", "upload_id", upload.ID, "error", err)
+		h.writeError(w, http.StatusInternalServerError, "failed to write chunk")
+		return
+	}
+
+	newOffset := offset + n
+	if newOffset == upload.Size {
+		h.logger.Info("resumable upload completed", "upload_id", upload.ID, "size", upload.Size)
+	}
+
+	w.Header().Set("Upload-Offset", strconv.FormatInt(newOffset, 10))
+	w.Header().Set("Upload-Expires", expiresAt.UTC().Format(http.TimeFormat))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DeleteResumableUpload handles DELETE /api/v1/uploads/resumable/{id}
+func (h *Handlers) DeleteResumableUpload(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	upload := h.loadResumableUpload(w, r)
+	if upload == nil {
+		return
+	}
+	if upload.Status != models.UploadStatusPending {
+		h.writeError(w, http.StatusConflict, database.ErrUploadClaimed.Error())
+		return
+	}
+
+	locked, err := h.jobRepo.LockUpload(ctx, upload.ID, uploadLockLease)
+	if err != nil {
+		h.logger.Error("failed to lock upload", "upload_id", upload.ID, "error", err)
+		h.writeError(w, http.StatusInternalServerError, "failed to delete upload")
+		return
+	}
+	if !locked {
+		h.writeError(w, http.StatusLocked, "upload is being written by another request")
+		return
+	}
+
+	if err := h.discardUpload(ctx, upload); err != nil {
+		h.logger.Error("failed to delete upload", "upload_id", upload.ID, "error", err)
+		h.writeError(w, http.StatusInternalServerError, "failed to delete upload")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// discardUpload removes everything a pending resumable upload stored, then its record
+func (h *Handlers) discardUpload(ctx context.Context, upload *models.Upload) error {
+	if err := h.multipart.AbortMultipartUpload(ctx, upload.ObjectKey, upload.MultipartID); err != nil {
+		return err
+	}
+	if err := h.storage.Delete(ctx, upload.IncompleteKey()); err != nil {
+		return err
+	}
+	if err := h.storage.Delete(ctx, upload.ObjectKey); err != nil {
+		return err
+	}
+	return h.jobRepo.DeleteUpload(ctx, upload.ID)
+}
+
+// loadResumableUpload fetches a live resumable upload owned by the requester.
+// It writes the error response and returns nil on failure.
+func (h *Handlers) loadResumableUpload(w http.ResponseWriter, r *http.Request) *models.Upload {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		h.writeError(w, http.StatusNotFound, "upload not found")
+		return nil
+	}
+
+	upload, err := h.jobRepo.GetUpload(r.Context(), id)
+	if errors.Is(err, database.ErrUploadNotFound) ||
+		(err == nil && (upload.MultipartID == "" || !requestOwner(r).Owns(upload.UserID, upload.AnonymousID))) {
+		h.writeError(w, http.StatusNotFound, "upload not found")
+		return nil
+	}
+	if err != nil {
+		h.logger.Error("failed to get upload", "error", err)
+		h.writeError(w, http.StatusInternalServerError, "failed to get upload")
+		return nil
+	}
+	if time.Now().After(upload.ExpiresAt) {
+		h.writeError(w, http.StatusGone, "upload expired")
+		return nil
+	}
+
+	return upload
+}
+
+// resumableOffset derives how many bytes of an upload are safely stored from
+// the storage state itself: the uploaded parts plus the buffered tail
+func (h *Handlers) resumableOffset(ctx context.Context, upload *models.Upload) (int64, []storage.Part, error) {
+	if upload.CompletedAt != nil {
+		return upload.Size, nil, nil
+	}
+
+	parts, err := h.multipart.ListParts(ctx, upload.ObjectKey, upload.MultipartID)
+	if storage.IsNoSuchUpload(err) {
+		// Completed in storage but not yet recorded
+		if _, statErr := h.storage.Stat(ctx, upload.ObjectKey); statErr != nil {
+			return 0, nil, fmt.Errorf("multipart upload missing: %w", statErr)
+		}
+		if err := h.jobRepo.CompleteUpload(ctx, upload.ID); err != nil {
+			return 0, nil, err
+		}
+		return upload.Size, nil, nil
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+
+	var offset int64
+	for _, p := range parts {
+		offset += p.Size
+	}
+
+	info, err := h.storage.Stat(ctx, upload.IncompleteKey())
+	if err != nil && !storage.IsNotFound(err) {
+		return 0, nil, err
+	}
+	if err == nil {
+		offset += info.Size
+	}
+
+	return offset, parts, nil
+}
+
+// writeResumableChunk appends size bytes from chunk to an upload at offset.
+// Full parts go straight to the multipart upload; a tail too small for a part is
+// buffered in the incomplete object until more data arrives or the upload ends.
+// Each step only ever loses bytes on failure, never double counts them, so the
+// derived offset is always safe to resume from.
+func (h *Handlers) writeResumableChunk(ctx context.Context, upload *models.Upload, parts []storage.Part, offset int64, chunk io.Reader, size int64) error {
+	var tail []byte
+	var partBytes int64
+	for _, p := range parts {
+		partBytes += p.Size
+	}
+	if offset > partBytes {
+		reader, err := h.storage.Download(ctx, upload.IncompleteKey())
+		if err != nil {
+			return err
+		}
+		tail, err = io.ReadAll(reader)
+		reader.Close()
+		if err != nil {
+			return fmt.Errorf("failed to read incomplete part: %w", err)
+		}
+		if err := h.storage.Delete(ctx, upload.IncompleteKey()); err != nil {
+			return err
+		}
+	}
+
+	data := io.MultiReader(bytes.NewReader(tail), chunk)
+	pending := int64(len(tail)) + size
+	final := offset+size == upload.Size
+
+	nextPart := 1
+	if len(parts) > 0 {
+		nextPart = parts[len(parts)-1].Number + 1
+	}
+
+	for pending >= storage.MinPartSize || (final && pending > 0) {
+		partSize := min(pending, int64(storage.MinPartSize))
+		if err := h.multipart.PutPart(ctx, upload.ObjectKey, upload.MultipartID, nextPart, io.LimitReader(data, partSize), partSize); err != nil {
+			return err
+		}
+		nextPart++
+		pending -= partSize
+	}
+
+	if !final {
+		if pending > 0 {
+			return h.storage.Upload(ctx, upload.IncompleteKey(), data, pending, "application/octet-stream")
+		}
+		return nil
+	}
+
+	allParts, err := h.multipart.ListParts(ctx, upload.ObjectKey, upload.MultipartID)
+	if err != nil {
+		return err
+	}
+	if err := h.multipart.CompleteMultipartUpload(ctx, upload.ObjectKey, upload.MultipartID, allParts); err != nil {
+		return err
+	}
+	return h.jobRepo.CompleteUpload(ctx, upload.ID)
+}
+
+// parseUploadMetadata decodes the tus Upload-Metadata header:
+// comma-separated pairs of a key and an optional base64 value
+func parseUploadMetadata(header string) (map[string]string, error) {
+	metadata := make(map[string]string)
+	if strings.TrimSpace(header) == "" {
+		return metadata, nil
+	}
+
+	for _, pair := range strings.Split(header, ",") {
+		key, encoded, _ := strings.Cut(strings.TrimSpace(pair), " ")
+		if key == "" {
+			return nil, errors.New("invalid Upload-Metadata")
+		}
+		value, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+		if err != nil {
+			return nil, fmt.Errorf("invalid Upload-Metadata value for %s", key)
+		}
+		metadata[key] = string(value)
+	}
+
+	return metadata, nil
+}
+
+// parseUploadChecksum parses the tus Upload-Checksum header ("<algorithm> <base64 digest>").
+// It returns a nil hash when the header is absent.
+func parseUploadChecksum(header string) (hash.Hash, []byte, error) {
+	if header == "" {
+		return nil, nil, nil
+	}
+
+	algorithm, encoded, found := strings.Cut(header, " ")
+	if !found {
+		return nil, nil, errors.New("invalid Upload-Checksum")
+	}
+
+	sum, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, nil, errors.New("invalid Upload-Checksum digest")
+	}
+
+	switch algorithm {
+	case "sha1":
+		return sha1.New(), sum, nil //nolint:gosec // integrity check, not security
+	case "md5":
+		return md5.New(), sum, nil //nolint:gosec // integrity check, not security
+	case "sha256":
+		return sha256.New(), sum, nil
+	default:
+		return nil, nil, fmt.Errorf("unsupported checksum algorithm: %s", algorithm)
+	}
+}
@@ -0,0 +1,170 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestParseUploadMetadata(t *testing.T) {
+	header := "filename " + base64.StdEncoding.EncodeToString([]byte("cat.jpg")) +
+		",filetype " + base64.StdEncoding.EncodeToString([]byte("image/jpeg")) + ",is_confidential"
+
+	metadata, err := parseUploadMetadata(header)
+	if err != nil {
+		t.Fatalf("parseUploadMetadata() error = %v", err)
+	}
+	if metadata["filename"] != "cat.jpg" {
+		t.Errorf("filename = %q, want cat.jpg", metadata["filename"])
+	}
+	if metadata["filetype"] != "image/jpeg" {
+		t.Errorf("filetype = %q, want image/jpeg", metadata["filetype"])
+	}
+	if value, ok := metadata["is_confidential"]; !ok || value != "" {
+		t.Errorf("is_confidential = %q, %v, want empty value", value, ok)
+	}
+
+	if metadata, err := parseUploadMetadata(""); err != nil || len(metadata) != 0 {
+		t.Errorf("parseUploadMetadata(\"\") = %v, %v, want empty map", metadata, err)
+	}
+	if _, err := parseUploadMetadata("filename !!!"); err == nil {
+		t.Error("parseUploadMetadata() should reject invalid base64")
+	}
+}
+
+func TestParseUploadChecksum(t *testing.T) {
+	sum := sha256.Sum256([]byte("chunk"))
+	hasher, expected, err := parseUploadChecksum("sha256 " + base64.StdEncoding.EncodeToString(sum[:]))
+	if err != nil {
+		t.Fatalf("parseUploadChecksum() error = %v", err)
+	}
+	hasher.Write([]byte("chunk"))
+	if string(hasher.Sum(nil)) != string(expected) {
+		t.Error("digest of matching data should equal the expected checksum")
+	}
+
+	if hasher, _, err := parseUploadChecksum(""); hasher != nil || err != nil {
+		t.Errorf("parseUploadChecksum(\"\") = %v, %v, want nil hash", hasher, err)
+	}
+	for _, header := range []string{"sha256", "crc32 AAAA", "md5 !!!"} {
+		if _, _, err := parseUploadChecksum(header); err == nil {
+			t.Errorf("parseUploadChecksum(%q) should fail", header)
+		}
+	}
+}
+
+func TestHandlers_TusProtocol(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	h := &Handlers{logger: logger}
+	h.SetUploadLimits(1024, 0, 0)
+	handler := h.tusProtocol(http.HandlerFunc(h.TusOptions))
+
+	t.Run("options advertises capabilities", func(t *testing.T) {
+		req := httptest.NewRequest("OPTIONS", "/api/v1/uploads/resumable", nil)
+		recorder := httptest.NewRecorder()
+
+		handler.ServeHTTP(recorder, req)
+
+		if recorder.Code != http.StatusNoContent {
+			t.Errorf("Status = %d, want %d", recorder.Code, http.StatusNoContent)
+		}
+		if got := recorder.Header().Get("Tus-Max-Size"); got != "1024" {
+			t.Errorf("Tus-Max-Size = %q, want 1024", got)
+		}
+		if got := recorder.Header().Get("Tus-Extension"); !strings.Contains(got, "checksum") {
+			t.Errorf("Tus-Extension = %q, want checksum", got)
+		}
+	})
+
+	t.Run("rejects unsupported version", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/api/v1/uploads/resumable", nil)
+		req.Header.Set("Tus-Resumable", "0.2.2")
+		recorder := httptest.NewRecorder()
+
+		handler.ServeHTTP(recorder, req)
+
+		if recorder.Code != http.StatusPreconditionFailed {
+			t.Errorf("Status = %d, want %d", recorder.Code, http.StatusPreconditionFailed)
+		}
+		if got := recorder.Header().Get("Tus-Version"); got != tusVersion {
+			t.Errorf("Tus-Version = %q, want %s", got, tusVersion)
+		}
+	})
+}
+
+func TestHandlers_CreateResumableUpload_Validation(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	h := &Handlers{logger: logger}
+	h.SetUploadLimits(1024, 0, 0)
+
+	encode := func(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) }
+
+	tests := []struct {
+		name     string
+		length   string
+		metadata string
+		want     int
+	}{
+		{"missing length", "", "filename " + encode("a.jpg"), http.StatusBadRequest},
+		{"zero length", "0", "filename " + encode("a.jpg"), http.StatusBadRequest},
+		{"too large", "2048", "filename " + encode("a.jpg"), http.StatusRequestEntityTooLarge},
+		{"missing filename", "10", "", http.StatusBadRequest},
+		{"invalid type", "10", "filename " + encode("doc.pdf"), http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/api/v1/uploads/resumable", nil)
+			req.Header.Set("Upload-Length", tt.length)
+			req.Header.Set("Upload-Metadata", tt.metadata)
+			recorder := httptest.NewRecorder()
+
+			h.CreateResumableUpload(recorder, req)
+
+			if recorder.Code != tt.want {
+				t.Errorf("Status = %d, want %d", recorder.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandlers_PatchResumableUpload_Validation(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	h := &Handlers{logger: logger}
+
+	tests := []struct {
+		name        string
+		contentType string
+		offset      string
+		checksum    string
+		want        int
+	}{
+		{"wrong content type", "application/json", "0", "", http.StatusUnsupportedMediaType},
+		{"missing offset", tusContentType, "", "", http.StatusBadRequest},
+		{"negative offset", tusContentType, "-1", "", http.StatusBadRequest},
+		{"unsupported checksum", tusContentType, "0", "crc32 AAAA", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("PATCH", "/api/v1/uploads/resumable/x", strings.NewReader("data"))
+			req.Header.Set("Content-Type", tt.contentType)
+			req.Header.Set("Upload-Offset", tt.offset)
+			if tt.checksum != "" {
+				req.Header.Set("Upload-Checksum", tt.checksum)
+			}
+			recorder := httptest.NewRecorder()
+
+			h.PatchResumableUpload(recorder, req)
+
+			if recorder.Code != tt.want {
+				t.Errorf("Status = %d, want %d", recorder.Code, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,215 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/timkrebs/image-processor/internal/database"
+	"github.com/timkrebs/image-processor/internal/models"
+	"github.com/timkrebs/image-processor/internal/storage"
+)
+
+// Upload defaults, overridable with SetUploadLimits
+const (
+	defaultMaxFileSize     = 50 << 20 // 50MB
+	defaultUploadURLExpiry = 15 * time.Minute
+)
+
+// uploadClaimWindow is how long after its URL expires an upload can still be turned into a job
+const uploadClaimWindow = time.Hour
+
+// SetUploadLimits sets the maximum size of a single image, the validity of presigned
+// upload URLs and how long idle resumable uploads are kept
+func (h *Handlers) SetUploadLimits(maxFileSize int64, urlExpiry, resumableTTL time.Duration) {
+	h.maxFileSize = maxFileSize
+	h.uploadURLExpiry = urlExpiry
+	h.resumableTTL = resumableTTL
+}
+
+// fileSizeLimit returns the maximum size of a single image
+func (h *Handlers) fileSizeLimit() int64 {
+	if h.maxFileSize < 1 {
+		return defaultMaxFileSize
+	}
+	return h.maxFileSize
+}
+
+// uploadExpiry returns the validity of presigned upload URLs
+func (h *Handlers) uploadExpiry() time.Duration {
+	if h.uploadURLExpiry <= 0 {
+		return defaultUploadURLExpiry
+	}
+	return h.uploadURLExpiry
+}
+
+// CreateUpload handles POST /api/v1/uploads
+// Returns a presigned URL the client PUTs the image to, bypassing the API.
+func (h *Handlers) CreateUpload(w http.ResponseWriter, r *http.Request) {
+	var req models.CreateUploadRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	filename := path.Base(strings.ReplaceAll(req.Filename, "\\", "/"))
+	if req.Filename == "" || filename == "." || filename == "/" {
+		h.writeError(w, http.StatusBadRequest, "filename is required")
+		return
+	}
+
+	contentType := req.ContentType
+	if contentType == "" {
+		contentType = detectContentType(filename)
+	}
+	if !isValidImageType(contentType) {
+		h.writeError(w, http.StatusBadRequest, "invalid image type, must be JPEG, PNG, or GIF")
+		return
+	}
+
+	if req.Size < 0 || req.Size > h.fileSizeLimit() {
+		h.writeError(w, http.StatusBadRequest, fmt.Sprintf("size must be at most %d bytes", h.fileSizeLimit()))
+		return
+	}
+
+	owner := requestOwner(r)
+	now := time.Now()
+	expiry := h.uploadExpiry()
+
+	// The upload ID becomes the job ID, so the key matches regular originals
+	upload := &models.Upload{
+		ID:          uuid.New(),
+		UserID:      owner.UserID,
+		AnonymousID: owner.AnonymousID,
+		Filename:    filename,
+		ContentType: contentType,
+		Size:        req.Size,
+		Status:      models.UploadStatusPending,
+		ExpiresAt:   now.Add(expiry + uploadClaimWindow),
+		CreatedAt:   now,
+	}
+	upload.ObjectKey = fmt.Sprintf("users/%s/original/%s/%s", owner.UserID.String(), upload.ID.String(), filename)
+
+	url, err := h.storage.Presign(r.Context(), http.MethodPut, upload.ObjectKey, expiry, storage.PresignOptions{ContentType: contentType})
+	if errors.Is(err, storage.ErrNotSupported) {
+		h.writeError(w, http.StatusNotImplemented, "direct uploads are not supported by the storage backend")
+		return
+	}
+	if err != nil {
+		h.logger.Error("failed to presign upload", "error", err)
+		h.writeError(w, http.StatusInternalServerError, "failed to create upload")
+		return
+	}
+
+	if err := h.jobRepo.CreateUpload(r.Context(), upload); err != nil {
+		h.logger.Error("failed to create upload", "error", err)
+		h.writeError(w, http.StatusInternalServerError, "failed to create upload")
+		return
+	}
+
+	h.logger.Info("upload created", "upload_id", upload.ID, "size", upload.Size)
+	h.writeJSON(w, http.StatusCreated, models.CreateUploadResponse{
+		UploadID:  upload.ID,
+		URL:       url,
+		Method:    http.MethodPut,
+		Headers:   map[string]string{"Content-Type": contentType},
+		ExpiresAt: now.Add(expiry),
+	})
+}
+
+// createJobFromUpload creates a job from a completed direct-to-storage upload,
+// verifying ownership and the stored object's size and type first
+func (h *Handlers) createJobFromUpload(w http.ResponseWriter, r *http.Request, owner models.JobOwner, uploadIDValue string) {
+	ctx := r.Context()
+
+	uploadID, err := uuid.Parse(uploadIDValue)
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "invalid upload ID")
+		return
+	}
+
+	upload, err := h.jobRepo.GetUpload(ctx, uploadID)
+	if errors.Is(err, database.ErrUploadNotFound) || (err == nil && !owner.Owns(upload.UserID, upload.AnonymousID)) {
+		h.writeError(w, http.StatusNotFound, "upload not found")
+		return
+	}
+	if err != nil {
+		h.logger.Error("failed to get upload", "error", err)
+		h.writeError(w, http.StatusInternalServerError, "failed to get upload")
+		return
+	}
+	if upload.Status != models.UploadStatusPending {
+		h.writeError(w, http.StatusConflict, database.ErrUploadClaimed.Error())
+		return
+	}
+	if time.Now().After(upload.ExpiresAt) {
+		h.writeError(w, http.StatusGone, "upload expired")
+		return
+	}
+
+	opts, err := parseJobOptions(r, time.Now())
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !h.checkJobOptions(w, opts) || !h.resolvePreset(w, r, &opts) {
+		return
+	}
+
+	// Trust the stored object, not what the client declared
+	info, err := h.storage.Stat(ctx, upload.ObjectKey)
+	if storage.IsNotFound(err) {
+		h.writeError(w, http.StatusConflict, "upload not completed")
+		return
+	}
+	if err != nil {
+		h.logger.Error("failed to stat upload", "upload_id", upload.ID, "error", err)
+		h.writeError(w, http.StatusInternalServerError, "failed to verify upload")
+		return
+	}
+	if info.Size > h.fileSizeLimit() {
+		h.writeError(w, http.StatusBadRequest, fmt.Sprintf("uploaded file exceeds maximum size of %d bytes", h.fileSizeLimit()))
+		return
+	}
+	if !isValidImageType(info.ContentType) {
+		h.writeError(w, http.StatusBadRequest, "invalid image type, must be JPEG, PNG, or GIF")
+		return
+	}
+
+	if !h.checkQuota(w, r, owner, 1, info.Size) {
+		return
+	}
+
+	// Claim first so concurrent requests cannot create two jobs from one upload
+	if err := h.jobRepo.ClaimUpload(ctx, upload.ID); err != nil {
+		if errors.Is(err, database.ErrUploadClaimed) {
+			h.writeError(w, http.StatusConflict, err.Error())
+			return
+		}
+		h.logger.Error("failed to claim upload", "error", err)
+		h.writeError(w, http.StatusInternalServerError, "failed to create job")
+		return
+	}
+
+	job, err := h.persistJob(ctx, upload.ID, owner, opts, nil, upload.ObjectKey, "", upload.Filename, info.ContentType, info.Size)
+	if err != nil {
+		h.logger.Error("failed to create job from upload", "upload_id", upload.ID, "error", err)
+		if job == nil {
+			// Nothing references the upload yet, so it can be retried
+			if releaseErr := h.jobRepo.ReleaseUpload(ctx, upload.ID); releaseErr != nil {
+				h.logger.Error("failed to release upload", "upload_id", upload.ID, "error", releaseErr)
+			}
+		}
+		h.writeJobError(w, owner, err)
+		return
+	}
+
+	h.logger.Info("job created", "job_id", job.ID, "upload_id", upload.ID, "priority", job.Priority, "status", job.Status, "operations", len(job.Operations))
+	h.writeJSON(w, http.StatusCreated, job)
+}
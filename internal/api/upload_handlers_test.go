@@ -0,0 +1,72 @@
+package api
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestHandlers_CreateUpload_Validation(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	h := &Handlers{logger: logger}
+	h.SetUploadLimits(1024, 0, 0)
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"invalid JSON", `{`},
+		{"missing filename", `{"content_type":"image/png","size":10}`},
+		{"directory filename", `{"filename":"/","size":10}`},
+		{"invalid type", `{"filename":"doc.pdf","size":10}`},
+		{"too large", `{"filename":"a.jpg","size":2048}`},
+		{"negative size", `{"filename":"a.jpg","size":-1}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/api/v1/uploads", strings.NewReader(tt.body))
+			recorder := httptest.NewRecorder()
+
+			h.CreateUpload(recorder, req)
+
+			if recorder.Code != http.StatusBadRequest {
+				t.Errorf("Status = %d, want %d", recorder.Code, http.StatusBadRequest)
+			}
+		})
+	}
+}
+
+func TestHandlers_CreateJob_InvalidUploadID(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	h := &Handlers{logger: logger}
+
+	req := httptest.NewRequest("POST", "/api/v1/jobs", strings.NewReader("upload_id=not-a-uuid"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+
+	h.CreateJob(recorder, req)
+
+	if recorder.Code != http.StatusBadRequest {
+		t.Errorf("Status = %d, want %d", recorder.Code, http.StatusBadRequest)
+	}
+	if !strings.Contains(recorder.Body.String(), "invalid upload ID") {
+		t.Errorf("Body = %s, want invalid upload ID error", recorder.Body.String())
+	}
+}
+
+func TestHandlers_UploadDefaults(t *testing.T) {
+	h := &Handlers{}
+	if got := h.fileSizeLimit(); got != defaultMaxFileSize {
+		t.Errorf("fileSizeLimit() = %d, want %d", got, defaultMaxFileSize)
+	}
+	if got := h.uploadExpiry(); got != defaultUploadURLExpiry {
+		t.Errorf("uploadExpiry() = %v, want %v", got, defaultUploadURLExpiry)
+	}
+	if got := h.resumableUploadTTL(); got != defaultResumableUploadTTL {
+		t.Errorf("resumableUploadTTL() = %v, want %v", got, defaultResumableUploadTTL)
+	}
+}
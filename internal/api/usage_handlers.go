@@ -0,0 +1,128 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/timkrebs/image-processor/internal/models"
+)
+
+// activeJobsRetryAfter is the Retry-After hint when the concurrent job quota is exhausted
+const activeJobsRetryAfter = 30 * time.Second
+
+// checkQuota enforces the owner's quota for the given number of new jobs with
+// uploads totaling uploadSize bytes. It writes a 429 response and returns false
+// when the submission is rejected.
+func (h *Handlers) checkQuota(w http.ResponseWriter, r *http.Request, owner models.JobOwner, jobs int, uploadSize int64) bool {
+	if h.quotas == (models.QuotaLimits{}) {
+		return true
+	}
+
+	ctx := r.Context()
+	now := time.Now()
+
+	limits, err := h.db.GetQuotaLimits(ctx, owner.UserID, h.quotas)
+	if err != nil {
+		h.logger.Error("failed to get quota limits", "error", err)
+		h.writeError(w, http.StatusInternalServerError, "failed to check quota")
+		return false
+	}
+
+	usage, err := h.db.GetQuotaUsage(ctx, owner, models.QuotaDayStart(now))
+	if err != nil {
+		h.logger.Error("failed to get quota usage", "error", err)
+		h.writeError(w, http.StatusInternalServerError, "failed to check quota")
+		return false
+	}
+
+	err = limits.Check(usage, jobs, uploadSize)
+	var exceeded *models.QuotaExceededError
+	if !errors.As(err, &exceeded) {
+		return true
+	}
+
+	h.writeQuotaExceeded(w, owner, exceeded, now)
+	return false
+}
+
+// createJobWithinQuota inserts a job, enforcing its owner's quota in the same
+// transaction when quotas are enabled. checkQuota turns most submissions over
+// quota away earlier, before anything is uploaded, but only this check holds
+// against concurrent submissions.
+func (h *Handlers) createJobWithinQuota(ctx context.Context, job *models.Job) error {
+	if h.quotas == (models.QuotaLimits{}) {
+		return h.jobRepo.Create(ctx, job)
+	}
+
+	limits, err := h.db.GetQuotaLimits(ctx, job.UserID, h.quotas)
+	if err != nil {
+		return fmt.Errorf("failed to get quota limits: %w", err)
+	}
+	return h.jobRepo.CreateWithinQuota(ctx, job, limits, models.QuotaDayStart(time.Now()))
+}
+
+// writeJobError writes the response for a job that couldn't be created: 429
+// if it would exceed a quota, 500 otherwise
+func (h *Handlers) writeJobError(w http.ResponseWriter, owner models.JobOwner, err error) {
+	var exceeded *models.QuotaExceededError
+	if errors.As(err, &exceeded) {
+		h.writeQuotaExceeded(w, owner, exceeded, time.Now())
+		return
+	}
+	h.writeError(w, http.StatusInternalServerError, jobErrorMessage(err))
+}
+
+// writeQuotaExceeded writes a 429 response telling the client when to try again
+func (h *Handlers) writeQuotaExceeded(w http.ResponseWriter, owner models.JobOwner, exceeded *models.QuotaExceededError, now time.Time) {
+	retryAfter := activeJobsRetryAfter
+	switch exceeded.Quota {
+	case models.QuotaJobsPerDay:
+		retryAfter = models.QuotaDayStart(now).Add(24 * time.Hour).Sub(now)
+	case models.QuotaBytesStored:
+		// Storage frees up as the cleanup worker expires completed jobs
+		retryAfter = time.Hour
+	}
+
+	h.logger.Info("quota exceeded", "user_id", owner.UserID, "quota", exceeded.Quota, "used", exceeded.Used, "limit", exceeded.Limit)
+	w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(retryAfter)))
+	h.writeError(w, http.StatusTooManyRequests, exceeded.Error())
+}
+
+// GetUsage handles GET /api/v1/me/usage
+func (h *Handlers) GetUsage(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	owner := requestOwner(r)
+	now := time.Now()
+
+	limits, err := h.db.GetQuotaLimits(ctx, owner.UserID, h.quotas)
+	if err != nil {
+		h.logger.Error("failed to get quota limits", "error", err)
+		h.writeError(w, http.StatusInternalServerError, "failed to get usage")
+		return
+	}
+
+	usage, err := h.db.GetQuotaUsage(ctx, owner, models.QuotaDayStart(now))
+	if err != nil {
+		h.logger.Error("failed to get quota usage", "error", err)
+		h.writeError(w, http.StatusInternalServerError, "failed to get usage")
+		return
+	}
+
+	response := models.UsageResponse{
+		Limits:   limits,
+		Usage:    usage,
+		ResetsAt: models.QuotaDayStart(now).Add(24 * time.Hour),
+	}
+	if h.limiter != nil {
+		response.RateLimit = &models.RateLimit{
+			RequestsPerMinute: h.limiter.RequestsPerMinute(),
+			Burst:             h.limiter.Burst(),
+		}
+	}
+
+	h.writeJSON(w, http.StatusOK, response)
+}
@@ -0,0 +1,42 @@
+package api
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/timkrebs/image-processor/internal/models"
+)
+
+func TestHandlers_writeJobError(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	h := &Handlers{logger: logger}
+
+	exceeded := &models.QuotaExceededError{Quota: models.QuotaActiveJobs, Limit: 2, Used: 2}
+	tests := []struct {
+		name           string
+		err            error
+		wantStatus     int
+		wantRetryAfter string
+	}{
+		{"quota exceeded", &jobError{err: exceeded, message: exceeded.Error()}, http.StatusTooManyRequests, "30"},
+		{"other error", &jobError{err: errors.New("connection refused"), message: "failed to create job"}, http.StatusInternalServerError, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			h.writeJobError(recorder, models.JobOwner{}, tt.err)
+
+			if recorder.Code != tt.wantStatus {
+				t.Errorf("Status = %d, want %d", recorder.Code, tt.wantStatus)
+			}
+			if got := recorder.Header().Get("Retry-After"); got != tt.wantRetryAfter {
+				t.Errorf("Retry-After = %q, want %q", got, tt.wantRetryAfter)
+			}
+		})
+	}
+}
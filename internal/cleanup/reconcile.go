@@ -0,0 +1,93 @@
+package cleanup
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/timkrebs/image-processor/internal/database"
+	"github.com/timkrebs/image-processor/internal/storage"
+)
+
+// ReconcileOptions controls a comparison of storage against the database
+type ReconcileOptions struct {
+	// Prefix limits the comparison to keys starting with it
+	Prefix string
+	// Grace skips objects modified more recently, which may belong to requests
+	// that have not been recorded in the database yet
+	Grace time.Duration
+	// Delete removes orphaned objects instead of only reporting them
+	Delete bool
+}
+
+// ReconcileReport lists the differences between storage and the database
+type ReconcileReport struct {
+	Scanned       int      `json:"scanned"`
+	Orphaned      []string `json:"orphaned"`
+	OrphanedBytes int64    `json:"orphaned_bytes"`
+	Missing       []string `json:"missing"`
+	Deleted       int      `json:"deleted"`
+}
+
+// Reconcile finds objects in store that nothing in refs points to, and objects
+// refs requires that are gone from store. refs must be loaded before calling
+// Reconcile so that objects written in the meantime are covered by the grace period.
+func Reconcile(ctx context.Context, store storage.Backend, refs *database.ObjectReferences, opts ReconcileOptions) (*ReconcileReport, error) {
+	report := &ReconcileReport{Orphaned: []string{}, Missing: []string{}}
+	cutoff := time.Now().Add(-opts.Grace)
+	seen := make(map[string]bool)
+
+	err := store.List(ctx, opts.Prefix, func(info storage.ObjectInfo) error {
+		report.Scanned++
+		seen[info.Key] = true
+
+		if isReferenced(info.Key, refs) || info.LastModified.After(cutoff) {
+			return nil
+		}
+		report.Orphaned = append(report.Orphaned, info.Key)
+		report.OrphanedBytes += info.Size
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list objects: %w", err)
+	}
+
+	for key, required := range refs.Keys {
+		if required && strings.HasPrefix(key, opts.Prefix) && !seen[key] {
+			report.Missing = append(report.Missing, key)
+		}
+	}
+
+	if opts.Delete && len(report.Orphaned) > 0 {
+		if err := store.DeleteMany(ctx, report.Orphaned); err != nil {
+			return report, fmt.Errorf("failed to delete orphaned objects: %w", err)
+		}
+		report.Deleted = len(report.Orphaned)
+	}
+
+	return report, nil
+}
+
+// isReferenced reports whether key is recorded in refs, or is a derived image of
+// a job that still exists: users/<user>/derived/<job>/...
+func isReferenced(key string, refs *database.ObjectReferences) bool {
+	if _, ok := refs.Keys[key]; ok {
+		return true
+	}
+
+	rest, ok := strings.CutPrefix(key, "users/")
+	if !ok {
+		return false
+	}
+	_, rest, _ = strings.Cut(rest, "/")
+	rest, ok = strings.CutPrefix(rest, "derived/")
+	if !ok {
+		return false
+	}
+	jobID, _, _ := strings.Cut(rest, "/")
+	id, err := uuid.Parse(jobID)
+	return err == nil && refs.Jobs[id]
+}
@@ -0,0 +1,95 @@
+package cleanup
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/timkrebs/image-processor/internal/database"
+	"github.com/timkrebs/image-processor/internal/storage"
+)
+
+func TestReconcile(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemory()
+
+	user := uuid.New()
+	liveJob := uuid.New()
+	goneJob := uuid.New()
+	keys := map[string]string{
+		"original":      "users/" + user.String() + "/original/a/photo.jpg",
+		"processed":     "users/" + user.String() + "/processed/" + liveJob.String() + "/photo.jpg",
+		"derived":       "users/" + user.String() + "/derived/" + liveJob.String() + "/w100.jpg",
+		"orphanDerived": "users/" + user.String() + "/derived/" + goneJob.String() + "/w100.jpg",
+		"orphan":        "users/" + user.String() + "/staging/" + uuid.NewString(),
+	}
+	for _, key := range keys {
+		if err := store.Upload(ctx, key, strings.NewReader("data"), 4, "image/jpeg"); err != nil {
+			t.Fatalf("Upload(%s) error = %v", key, err)
+		}
+	}
+
+	missing := "users/" + user.String() + "/processed/" + uuid.NewString() + "/lost.jpg"
+	refs := &database.ObjectReferences{
+		Keys: map[string]bool{
+			keys["original"]:  true,
+			keys["processed"]: true,
+			missing:           true,
+			"users/" + user.String() + "/original/b/pending.jpg": false,
+		},
+		Jobs: map[uuid.UUID]bool{liveJob: true},
+	}
+
+	report, err := Reconcile(ctx, store, refs, ReconcileOptions{})
+	if err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+
+	if report.Scanned != len(keys) {
+		t.Errorf("Scanned = %d, want %d", report.Scanned, len(keys))
+	}
+	slices.Sort(report.Orphaned)
+	wantOrphaned := []string{keys["orphanDerived"], keys["orphan"]}
+	slices.Sort(wantOrphaned)
+	if !slices.Equal(report.Orphaned, wantOrphaned) {
+		t.Errorf("Orphaned = %v, want %v", report.Orphaned, wantOrphaned)
+	}
+	if report.OrphanedBytes != 8 {
+		t.Errorf("OrphanedBytes = %d, want 8", report.OrphanedBytes)
+	}
+	if !slices.Equal(report.Missing, []string{missing}) {
+		t.Errorf("Missing = %v, want [%s]", report.Missing, missing)
+	}
+	if report.Deleted != 0 {
+		t.Errorf("Deleted = %d without Delete", report.Deleted)
+	}
+
+	// Recently written objects may not be recorded yet
+	report, err = Reconcile(ctx, store, refs, ReconcileOptions{Grace: time.Hour})
+	if err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	if len(report.Orphaned) != 0 {
+		t.Errorf("Orphaned = %v within grace period, want none", report.Orphaned)
+	}
+
+	report, err = Reconcile(ctx, store, refs, ReconcileOptions{Delete: true})
+	if err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	if report.Deleted != 2 {
+		t.Errorf("Deleted = %d, want 2", report.Deleted)
+	}
+	for _, key := range wantOrphaned {
+		if exists, _ := store.Exists(ctx, key); exists {
+			t.Errorf("orphan %s still exists", key)
+		}
+	}
+	if exists, _ := store.Exists(ctx, keys["derived"]); !exists {
+		t.Error("derived image of a live job was deleted")
+	}
+}
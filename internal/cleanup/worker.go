@@ -6,15 +6,17 @@ import (
 	"log/slog"
 	"time"
 
+	"github.com/google/uuid"
+
 	"github.com/timkrebs/image-processor/internal/database"
 	"github.com/timkrebs/image-processor/internal/models"
 	"github.com/timkrebs/image-processor/internal/storage"
 )
 
-// Worker handles periodic cleanup of expired jobs and their associated files
+// Worker handles periodic cleanup of expired jobs, unclaimed uploads and their associated files
 type Worker struct {
 	jobRepo   *database.JobRepository
-	storage   *storage.Storage
+	storage   storage.Backend
 	logger    *slog.Logger
 	interval  time.Duration
 	batchSize int
@@ -27,7 +29,7 @@ type Config struct {
 }
 
 // NewWorker creates a new cleanup worker
-func NewWorker(jobRepo *database.JobRepository, storage *storage.Storage, cfg Config, logger *slog.Logger) *Worker {
+func NewWorker(jobRepo *database.JobRepository, store storage.Backend, cfg Config, logger *slog.Logger) *Worker {
 	if cfg.Interval == 0 {
 		cfg.Interval = 5 * time.Minute
 	}
@@ -37,7 +39,7 @@ func NewWorker(jobRepo *database.JobRepository, storage *storage.Storage, cfg Co
 
 	return &Worker{
 		jobRepo:   jobRepo,
-		storage:   storage,
+		storage:   store,
 		logger:    logger,
 		interval:  cfg.Interval,
 		batchSize: cfg.BatchSize,
@@ -60,6 +62,9 @@ func (w *Worker) Start(ctx context.Context) {
 			if err := w.cleanup(ctx); err != nil {
 				w.logger.Error("cleanup failed", "error", err)
 			}
+			if err := w.cleanupUploads(ctx); err != nil {
+				w.logger.Error("upload cleanup failed", "error", err)
+			}
 		}
 	}
 }
@@ -81,20 +86,7 @@ func (w *Worker) cleanup(ctx context.Context) error {
 
 	w.logger.Info("found jobs to cleanup", "count", len(jobs))
 
-	cleanedCount := 0
-	errorCount := 0
-
-	for i := range jobs {
-		if err := w.cleanupJob(ctx, jobs[i]); err != nil {
-			w.logger.Error("failed to cleanup job",
-				"job_id", jobs[i].ID,
-				"error", err,
-			)
-			errorCount++
-			continue
-		}
-		cleanedCount++
-	}
+	cleanedCount, errorCount := w.Purge(ctx, jobs)
 
 	duration := time.Since(startTime)
 	w.logger.Info("cleanup cycle completed",
@@ -107,47 +99,161 @@ func (w *Worker) cleanup(ctx context.Context) error {
 	return nil
 }
 
-// cleanupJob removes a single job and its associated files
-func (w *Worker) cleanupJob(ctx context.Context, job *models.Job) error {
-	logger := w.logger.With("job_id", job.ID)
-	var deleteErrors []error
-
-	if job.OriginalKey != "" {
-		logger.Info("deleting original file", "key", job.OriginalKey)
-		if err := w.storage.Delete(ctx, job.OriginalKey); err != nil {
-			logger.Error("failed to delete original file",
-				"key", job.OriginalKey,
+// Purge removes jobs and their files regardless of their retention, and returns how
+// many were removed and how many failed
+func (w *Worker) Purge(ctx context.Context, jobs []*models.Job) (int, int) {
+	failed := w.PurgeJobs(ctx, jobs)
+	return len(jobs) - len(failed), len(failed)
+}
+
+// PurgeJobs is Purge reporting why each job that couldn't be removed failed. The
+// whole batch is cleaned up at once, with a fallback to one job at a time so that
+// a job whose files can't be deleted doesn't hold up the others.
+func (w *Worker) PurgeJobs(ctx context.Context, jobs []*models.Job) map[uuid.UUID]error {
+	failed := make(map[uuid.UUID]error)
+	if len(jobs) == 0 {
+		return failed
+	}
+	err := w.cleanupJobs(ctx, jobs)
+	if err == nil {
+		return failed
+	}
+	w.logger.Warn("batch cleanup failed, retrying jobs individually", "error", err)
+
+	for i := range jobs {
+		if err := w.cleanupJob(ctx, jobs[i]); err != nil {
+			w.logger.Error("failed to cleanup job",
+				"job_id", jobs[i].ID,
 				"error", err,
 			)
-			deleteErrors = append(deleteErrors, fmt.Errorf("original file: %w", err))
-		} else {
-			logger.Info("successfully deleted original file", "key", job.OriginalKey)
+			failed[jobs[i].ID] = err
 		}
 	}
+	return failed
+}
 
-	if job.ProcessedKey != "" {
-		logger.Info("deleting processed file", "key", job.ProcessedKey)
-		if err := w.storage.Delete(ctx, job.ProcessedKey); err != nil {
-			logger.Error("failed to delete processed file",
-				"key", job.ProcessedKey,
-				"error", err,
-			)
-			deleteErrors = append(deleteErrors, fmt.Errorf("processed file: %w", err))
-		} else {
-			logger.Info("successfully deleted processed file", "key", job.ProcessedKey)
+// cleanupJob removes a single job and its associated files
+func (w *Worker) cleanupJob(ctx context.Context, job *models.Job) error {
+	return w.cleanupJobs(ctx, []*models.Job{job})
+}
+
+// cleanupJobs removes jobs and their associated files, deleting the files in as
+// few storage requests as possible. Nothing is deleted from the database unless
+// all files are gone, so failed cleanups are retried.
+func (w *Worker) cleanupJobs(ctx context.Context, jobs []*models.Job) error {
+	var refs []database.ObjectRef
+	var derived []string
+	ids := make([]uuid.UUID, 0, len(jobs))
+
+	for _, job := range jobs {
+		ids = append(ids, job.ID)
+
+		// Originals and results may be shared with other jobs, so only the last reference deletes them
+		if job.OriginalKey != "" && job.OriginalDeletedAt == nil {
+			refs = append(refs, database.ObjectRef{Key: job.OriginalKey, JobID: job.ID})
+		}
+		if job.ProcessedKey != "" {
+			refs = append(refs, database.ObjectRef{Key: job.ProcessedKey, JobID: job.ID})
+		}
+
+		err := w.storage.List(ctx, job.DerivedPrefix(), func(info storage.ObjectInfo) error {
+			derived = append(derived, info.Key)
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to list derived files of job %s: %w", job.ID, err)
 		}
 	}
 
-	// If file deletion failed, don't delete the job record yet
-	if len(deleteErrors) > 0 {
-		return fmt.Errorf("failed to delete %d file(s): %v", len(deleteErrors), deleteErrors)
+	if len(derived) > 0 {
+		if err := w.storage.DeleteMany(ctx, derived); err != nil {
+			return fmt.Errorf("failed to delete derived files: %w", err)
+		}
+	}
+
+	var deleted []string
+	err := w.jobRepo.ReleaseObjectRefs(ctx, refs, func(keys []string) error {
+		deleted = keys
+		return w.storage.DeleteMany(ctx, keys)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to release files: %w", err)
+	}
+
+	if err := w.jobRepo.DeleteJobs(ctx, ids); err != nil {
+		return err
 	}
 
-	logger.Info("deleting job record")
-	if err := w.jobRepo.DeleteJob(ctx, job.ID); err != nil {
+	w.logger.Info("jobs cleaned up",
+		"jobs", len(jobs),
+		"files_deleted", len(deleted)+len(derived),
+		"files_kept", len(refs)-len(deleted),
+	)
+	return nil
+}
+
+// cleanupUploads removes expired upload records and the objects of uploads no job claimed
+func (w *Worker) cleanupUploads(ctx context.Context) error {
+	uploads, err := w.jobRepo.GetExpiredUploads(ctx, w.batchSize)
+	if err != nil {
 		return err
 	}
 
-	logger.Info("job cleaned up successfully")
+	if len(uploads) == 0 {
+		return nil
+	}
+
+	cleanedCount := 0
+	errorCount := 0
+
+	// Claimed objects now belong to their job and are removed with it
+	var keys []string
+	var expired []*models.Upload
+	for _, upload := range uploads {
+		if upload.Status == models.UploadStatusPending {
+			// Abandoned resumable uploads also hold multipart parts and a buffered tail
+			if upload.MultipartID != "" && upload.CompletedAt == nil {
+				if err := w.abortResumableUpload(ctx, upload); err != nil {
+					w.logger.Error("failed to abort resumable upload",
+						"upload_id", upload.ID,
+						"error", err,
+					)
+					errorCount++
+					continue
+				}
+				keys = append(keys, upload.IncompleteKey())
+			}
+			keys = append(keys, upload.ObjectKey)
+		}
+		expired = append(expired, upload)
+	}
+
+	if err := w.storage.DeleteMany(ctx, keys); err != nil {
+		return fmt.Errorf("failed to delete unclaimed uploads: %w", err)
+	}
+
+	for _, upload := range expired {
+		if err := w.jobRepo.DeleteUpload(ctx, upload.ID); err != nil {
+			w.logger.Error("failed to delete upload record", "upload_id", upload.ID, "error", err)
+			errorCount++
+			continue
+		}
+		cleanedCount++
+	}
+
+	w.logger.Info("upload cleanup completed",
+		"cleaned", cleanedCount,
+		"errors", errorCount,
+		"total", len(uploads),
+	)
+
+	return nil
+}
+
+// abortResumableUpload drops the stored parts of an unfinished resumable upload
+func (w *Worker) abortResumableUpload(ctx context.Context, upload *models.Upload) error {
+	if multipart, ok := w.storage.(storage.MultipartBackend); ok {
+		return multipart.AbortMultipartUpload(ctx, upload.ObjectKey, upload.MultipartID)
+	}
 	return nil
 }
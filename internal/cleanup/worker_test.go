@@ -333,3 +333,105 @@ func TestNewWorker_DefaultConfig(t *testing.T) {
 		t.Errorf("expected default batch size 100, got %d", worker.batchSize)
 	}
 }
+
+func TestWorker_CleanupUploads(t *testing.T) {
+	worker, db, storageClient, userID := setupCleanupTest(t)
+	defer db.Close()
+
+	ctx := context.Background()
+
+	uploadID := uuid.New()
+	objectKey := "cleanup-test/upload-" + uploadID.String() + ".jpg"
+	content := []byte("unclaimed upload")
+	if err := storageClient.Upload(ctx, objectKey, bytes.NewReader(content), int64(len(content)), "image/jpeg"); err != nil {
+		t.Fatalf("failed to upload file: %v", err)
+	}
+
+	upload := &models.Upload{
+		ID:          uploadID,
+		UserID:      userID,
+		ObjectKey:   objectKey,
+		Filename:    "upload.jpg",
+		ContentType: "image/jpeg",
+		Size:        int64(len(content)),
+		Status:      models.UploadStatusPending,
+		ExpiresAt:   time.Now().Add(-1 * time.Minute),
+		CreatedAt:   time.Now().Add(-2 * time.Hour),
+	}
+	if err := worker.jobRepo.CreateUpload(ctx, upload); err != nil {
+		t.Fatalf("failed to create upload: %v", err)
+	}
+
+	if err := worker.cleanupUploads(ctx); err != nil {
+		t.Fatalf("cleanupUploads failed: %v", err)
+	}
+
+	if _, err := worker.jobRepo.GetUpload(ctx, uploadID); err != database.ErrUploadNotFound {
+		t.Errorf("GetUpload() error = %v, want ErrUploadNotFound", err)
+	}
+
+	exists, err := storageClient.Exists(ctx, objectKey)
+	if err != nil {
+		t.Fatalf("failed to check object: %v", err)
+	}
+	if exists {
+		t.Error("unclaimed upload object should have been deleted")
+	}
+}
+
+func TestWorker_CleanupSharedObject(t *testing.T) {
+	worker, db, storageClient, userID := setupCleanupTest(t)
+	defer db.Close()
+
+	ctx := context.Background()
+
+	sharedKey := "cleanup-test/shared-" + uuid.New().String() + ".txt"
+	content := []byte("shared content")
+	if err := storageClient.Upload(ctx, sharedKey, bytes.NewReader(content), int64(len(content)), "text/plain"); err != nil {
+		t.Fatalf("failed to upload shared file: %v", err)
+	}
+
+	var jobs []*models.Job
+	for i := 0; i < 2; i++ {
+		job := &models.Job{
+			ID:           uuid.New(),
+			UserID:       userID,
+			Status:       "completed",
+			OriginalKey:  sharedKey,
+			OriginalName: "test.txt",
+			ContentType:  "text/plain",
+			FileSize:     int64(len(content)),
+			Operations:   []models.Operation{},
+		}
+		if err := worker.jobRepo.Create(ctx, job); err != nil {
+			t.Fatalf("failed to create job: %v", err)
+		}
+		jobs = append(jobs, job)
+	}
+
+	if err := worker.jobRepo.AcquireObjectRefs(ctx, sharedKey, jobs[0].ID, jobs[1].ID); err != nil {
+		t.Fatalf("failed to acquire object refs: %v", err)
+	}
+
+	if err := worker.cleanupJob(ctx, jobs[0]); err != nil {
+		t.Fatalf("cleanupJob() error = %v", err)
+	}
+	exists, err := storageClient.Exists(ctx, sharedKey)
+	if err != nil {
+		t.Fatalf("failed to check shared file: %v", err)
+	}
+	if !exists {
+		t.Fatal("shared file should be kept while another job references it")
+	}
+
+	if err := worker.cleanupJob(ctx, jobs[1]); err != nil {
+		t.Fatalf("cleanupJob() error = %v", err)
+	}
+	exists, err = storageClient.Exists(ctx, sharedKey)
+	if err != nil {
+		t.Fatalf("failed to check shared file: %v", err)
+	}
+	if exists {
+		t.Error("shared file should be deleted with its last reference")
+	}
+}
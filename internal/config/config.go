@@ -13,14 +13,70 @@ type Config struct {
 	// Redis settings
 	RedisAddr     string `envconfig:"REDIS_ADDR" default:"localhost:6379"`
 	RedisPassword string `envconfig:"REDIS_PASSWORD" default:""`
+	// Storage backend: minio, local (files under StorageLocalPath) or memory (tests only)
+	StorageBackend   string `envconfig:"STORAGE_BACKEND" default:"minio"`
+	StorageLocalPath string `envconfig:"STORAGE_LOCAL_PATH" default:"./data"`
+	// Server-side encryption of stored objects: empty (none), sse-s3 or sse-c.
+	// SSE-C derives a key per user from the first of the base64 master keys;
+	// the others still decrypt objects written before a key rotation.
+	StorageEncryption     string   `envconfig:"STORAGE_ENCRYPTION" default:""`
+	StorageEncryptionKeys []string `envconfig:"STORAGE_ENCRYPTION_KEYS"`
 	// MinIO settings
 	MinIOEndpoint  string `envconfig:"MINIO_ENDPOINT" default:"localhost:9000"`
 	MinIOAccessKey string `envconfig:"MINIO_ACCESS_KEY" default:"minioadmin"`
 	MinIOSecretKey string `envconfig:"MINIO_SECRET_KEY" default:"minioadmin"`
 	MinIOBucket    string `envconfig:"MINIO_BUCKET" default:"images"`
+	// Optional separate bucket for processed outputs; empty stores them in MinIOBucket
+	MinIOProcessedBucket string `envconfig:"MINIO_PROCESSED_BUCKET" default:""`
+	// Key prefixes separating originals from processed outputs within a bucket
+	StorageOriginalsPrefix string `envconfig:"STORAGE_ORIGINALS_PREFIX" default:""`
+	StorageProcessedPrefix string `envconfig:"STORAGE_PROCESSED_PREFIX" default:""`
+	// Days after which bucket lifecycle rules expire objects the cleanup worker missed (0 = no rule)
+	StorageOriginalsExpiryDays int `envconfig:"STORAGE_ORIGINALS_EXPIRY_DAYS" default:"0"`
+	StorageProcessedExpiryDays int `envconfig:"STORAGE_PROCESSED_EXPIRY_DAYS" default:"0"`
+	// Let submitters delete originals as soon as processing succeeds (delete_original=true)
+	AllowDeleteOriginal bool `envconfig:"ALLOW_DELETE_ORIGINAL" default:"false"`
 	// Queue settings
 	QueueStreamName    string `envconfig:"QUEUE_STREAM_NAME" default:"image-jobs"`
 	QueueConsumerGroup string `envconfig:"QUEUE_CONSUMER_GROUP" default:"workers"`
+	// Relative read shares of the priority streams
+	QueueWeightInteractive int `envconfig:"QUEUE_WEIGHT_INTERACTIVE" default:"6"`
+	QueueWeightDefault     int `envconfig:"QUEUE_WEIGHT_DEFAULT" default:"3"`
+	QueueWeightBulk        int `envconfig:"QUEUE_WEIGHT_BULK" default:"1"`
+	// Proxies (CIDRs or IPs) whose X-Forwarded-For and X-Real-IP headers are believed
+	TrustedProxies []string `envconfig:"TRUSTED_PROXIES"`
+	// Job submission rate limit per session user or client IP (0 disables it)
+	RateLimitPerMinute int `envconfig:"RATE_LIMIT_PER_MINUTE" default:"60"`
+	RateLimitBurst     int `envconfig:"RATE_LIMIT_BURST" default:"20"`
+	// Default per-user quotas (0 disables a quota), overridable in user_quotas
+	QuotaJobsPerDay  int   `envconfig:"QUOTA_JOBS_PER_DAY" default:"1000"`
+	QuotaBytesStored int64 `envconfig:"QUOTA_BYTES_STORED" default:"5368709120"` // 5GB
+	QuotaActiveJobs  int   `envconfig:"QUOTA_ACTIVE_JOBS" default:"50"`
+	// Validity of presigned upload URLs
+	UploadURLExpiry time.Duration `envconfig:"UPLOAD_URL_EXPIRY" default:"15m"`
+	// How images are served: "proxy" streams them through the API, "redirect" sends
+	// clients to a presigned storage URL
+	ImageDelivery    string        `envconfig:"IMAGE_DELIVERY" default:"proxy"`
+	ImageURLExpiry   time.Duration `envconfig:"IMAGE_URL_EXPIRY" default:"15m"`
+	ImageCacheMaxAge time.Duration `envconfig:"IMAGE_CACHE_MAX_AGE" default:"1h"`
+	// On-the-fly transformation URLs, disabled while no signing key is set
+	TransformSigningKey   string `envconfig:"TRANSFORM_SIGNING_KEY" default:""`
+	TransformConcurrency  int    `envconfig:"TRANSFORM_CONCURRENCY" default:"4"`
+	TransformMaxDimension int    `envconfig:"TRANSFORM_MAX_DIMENSION" default:"4096"`
+	// Comparison images rendered at a time
+	CompareConcurrency int `envconfig:"COMPARE_CONCURRENCY" default:"2"`
+	// How long an idle resumable upload is kept before it is aborted
+	ResumableUploadTTL time.Duration `envconfig:"RESUMABLE_UPLOAD_TTL" default:"24h"`
+	// Batch submission limits
+	BatchMaxUploadSize int64 `envconfig:"BATCH_MAX_UPLOAD_SIZE" default:"524288000"` // 500MB
+	BatchMaxFiles      int   `envconfig:"BATCH_MAX_FILES" default:"500"`
+	// Anonymous job submission. When disabled all job endpoints require a session.
+	// Anonymous clients are identified by a cookie signed with AnonymousCookieSecret.
+	AnonymousCookieSecret string        `envconfig:"ANONYMOUS_COOKIE_SECRET" default:""`
+	AnonymousCookieTTL    time.Duration `envconfig:"ANONYMOUS_COOKIE_TTL" default:"720h"`
+	AllowAnonymous        bool          `envconfig:"ALLOW_ANONYMOUS" default:"true"`
+	// Measure SSIM/PSNR of each output against its original, which costs a second decode
+	WorkerMeasureQuality bool `envconfig:"WORKER_MEASURE_QUALITY" default:"false"`
 	// Logging
 	LogLevel  string `envconfig:"LOG_LEVEL" default:"info"`
 	LogFormat string `envconfig:"LOG_FORMAT" default:"json"`
@@ -30,6 +86,7 @@ type Config struct {
 	ShutdownTimeout time.Duration `envconfig:"SHUTDOWN_TIMEOUT" default:"10s"`
 	// Worker settings
 	WorkerPollTimeout time.Duration `envconfig:"WORKER_POLL_TIMEOUT" default:"5s"`
+	SchedulerInterval time.Duration `envconfig:"SCHEDULER_INTERVAL" default:"1s"`
 	MaxUploadSize     int64         `envconfig:"MAX_UPLOAD_SIZE" default:"52428800"` // 50MB
 	HTTPPort          int           `envconfig:"HTTP_PORT" default:"8080"`
 	DatabaseMaxConn   int           `envconfig:"DATABASE_MAX_CONN" default:"25"`
@@ -10,11 +10,21 @@ func TestLoad_Defaults(t *testing.T) {
 	// Clear relevant environment variables to test defaults
 	envVars := []string{
 		"DATABASE_URL", "REDIS_ADDR", "REDIS_PASSWORD", "REDIS_DB",
+		"STORAGE_BACKEND", "STORAGE_LOCAL_PATH", "STORAGE_ENCRYPTION", "STORAGE_ENCRYPTION_KEYS",
 		"MINIO_ENDPOINT", "MINIO_ACCESS_KEY", "MINIO_SECRET_KEY", "MINIO_BUCKET", "MINIO_USE_SSL",
+		"MINIO_PROCESSED_BUCKET", "STORAGE_ORIGINALS_PREFIX", "STORAGE_PROCESSED_PREFIX",
+		"STORAGE_ORIGINALS_EXPIRY_DAYS", "STORAGE_PROCESSED_EXPIRY_DAYS", "ALLOW_DELETE_ORIGINAL",
 		"QUEUE_STREAM_NAME", "QUEUE_CONSUMER_GROUP",
+		"QUEUE_WEIGHT_INTERACTIVE", "QUEUE_WEIGHT_DEFAULT", "QUEUE_WEIGHT_BULK",
+		"RATE_LIMIT_PER_MINUTE", "RATE_LIMIT_BURST",
+		"QUOTA_JOBS_PER_DAY", "QUOTA_BYTES_STORED", "QUOTA_ACTIVE_JOBS",
+		"ALLOW_ANONYMOUS", "ANONYMOUS_COOKIE_SECRET", "ANONYMOUS_COOKIE_TTL",
+		"BATCH_MAX_FILES", "BATCH_MAX_UPLOAD_SIZE", "UPLOAD_URL_EXPIRY", "RESUMABLE_UPLOAD_TTL",
+		"IMAGE_DELIVERY", "IMAGE_URL_EXPIRY", "IMAGE_CACHE_MAX_AGE",
+		"TRANSFORM_SIGNING_KEY", "TRANSFORM_CONCURRENCY", "TRANSFORM_MAX_DIMENSION",
 		"LOG_LEVEL", "LOG_FORMAT",
 		"READ_TIMEOUT", "WRITE_TIMEOUT", "SHUTDOWN_TIMEOUT",
-		"WORKER_POLL_TIMEOUT", "MAX_UPLOAD_SIZE", "HTTP_PORT",
+		"WORKER_POLL_TIMEOUT", "SCHEDULER_INTERVAL", "MAX_UPLOAD_SIZE", "HTTP_PORT",
 		"DATABASE_MAX_CONN", "WORKER_CONCURRENCY",
 	}
 
@@ -58,6 +68,26 @@ func TestLoad_Defaults(t *testing.T) {
 		t.Errorf("RedisDB = %d, want 0", cfg.RedisDB)
 	}
 
+	// Test storage defaults
+	if cfg.StorageBackend != "minio" {
+		t.Errorf("StorageBackend = %q, want minio", cfg.StorageBackend)
+	}
+	if cfg.StorageLocalPath != "./data" {
+		t.Errorf("StorageLocalPath = %q, want ./data", cfg.StorageLocalPath)
+	}
+	if cfg.StorageEncryption != "" || len(cfg.StorageEncryptionKeys) != 0 {
+		t.Errorf("StorageEncryption = %q with %d keys, want none", cfg.StorageEncryption, len(cfg.StorageEncryptionKeys))
+	}
+	if cfg.MinIOProcessedBucket != "" || cfg.StorageOriginalsPrefix != "" || cfg.StorageProcessedPrefix != "" {
+		t.Errorf("processed outputs should share the originals' bucket and prefix by default")
+	}
+	if cfg.StorageOriginalsExpiryDays != 0 || cfg.StorageProcessedExpiryDays != 0 {
+		t.Errorf("lifecycle expiry should be disabled by default")
+	}
+	if cfg.AllowDeleteOriginal {
+		t.Error("AllowDeleteOriginal should be false by default")
+	}
+
 	// Test MinIO defaults
 	if cfg.MinIOEndpoint != "localhost:9000" {
 		t.Errorf("MinIOEndpoint = %q, want localhost:9000", cfg.MinIOEndpoint)
@@ -82,6 +112,10 @@ func TestLoad_Defaults(t *testing.T) {
 	if cfg.QueueConsumerGroup != "workers" {
 		t.Errorf("QueueConsumerGroup = %q, want workers", cfg.QueueConsumerGroup)
 	}
+	if cfg.QueueWeightInteractive != 6 || cfg.QueueWeightDefault != 3 || cfg.QueueWeightBulk != 1 {
+		t.Errorf("Queue weights = %d/%d/%d, want 6/3/1",
+			cfg.QueueWeightInteractive, cfg.QueueWeightDefault, cfg.QueueWeightBulk)
+	}
 
 	// Test Logging defaults
 	if cfg.LogLevel != "info" {
@@ -105,10 +139,74 @@ func TestLoad_Defaults(t *testing.T) {
 		t.Errorf("HTTPPort = %d, want 8080", cfg.HTTPPort)
 	}
 
+	// Test rate limit and quota defaults
+	if cfg.RateLimitPerMinute != 60 {
+		t.Errorf("RateLimitPerMinute = %d, want 60", cfg.RateLimitPerMinute)
+	}
+	if cfg.RateLimitBurst != 20 {
+		t.Errorf("RateLimitBurst = %d, want 20", cfg.RateLimitBurst)
+	}
+	if cfg.QuotaJobsPerDay != 1000 {
+		t.Errorf("QuotaJobsPerDay = %d, want 1000", cfg.QuotaJobsPerDay)
+	}
+	if cfg.QuotaBytesStored != 5368709120 {
+		t.Errorf("QuotaBytesStored = %d, want 5368709120", cfg.QuotaBytesStored)
+	}
+	if cfg.QuotaActiveJobs != 50 {
+		t.Errorf("QuotaActiveJobs = %d, want 50", cfg.QuotaActiveJobs)
+	}
+
+	// Test batch defaults
+	if cfg.BatchMaxFiles != 500 {
+		t.Errorf("BatchMaxFiles = %d, want 500", cfg.BatchMaxFiles)
+	}
+	if cfg.BatchMaxUploadSize != 524288000 {
+		t.Errorf("BatchMaxUploadSize = %d, want 524288000", cfg.BatchMaxUploadSize)
+	}
+
+	if cfg.UploadURLExpiry != 15*time.Minute {
+		t.Errorf("UploadURLExpiry = %v, want 15m", cfg.UploadURLExpiry)
+	}
+	if cfg.ResumableUploadTTL != 24*time.Hour {
+		t.Errorf("ResumableUploadTTL = %v, want 24h", cfg.ResumableUploadTTL)
+	}
+	if cfg.ImageDelivery != "proxy" {
+		t.Errorf("ImageDelivery = %q, want proxy", cfg.ImageDelivery)
+	}
+	if cfg.ImageURLExpiry != 15*time.Minute {
+		t.Errorf("ImageURLExpiry = %v, want 15m", cfg.ImageURLExpiry)
+	}
+	if cfg.ImageCacheMaxAge != time.Hour {
+		t.Errorf("ImageCacheMaxAge = %v, want 1h", cfg.ImageCacheMaxAge)
+	}
+	if cfg.TransformSigningKey != "" {
+		t.Errorf("TransformSigningKey = %q, want empty", cfg.TransformSigningKey)
+	}
+	if cfg.TransformConcurrency != 4 {
+		t.Errorf("TransformConcurrency = %d, want 4", cfg.TransformConcurrency)
+	}
+	if cfg.TransformMaxDimension != 4096 {
+		t.Errorf("TransformMaxDimension = %d, want 4096", cfg.TransformMaxDimension)
+	}
+
+	// Test anonymous submission defaults
+	if !cfg.AllowAnonymous {
+		t.Error("AllowAnonymous = false, want true")
+	}
+	if cfg.AnonymousCookieSecret != "" {
+		t.Errorf("AnonymousCookieSecret = %q, want empty", cfg.AnonymousCookieSecret)
+	}
+	if cfg.AnonymousCookieTTL != 720*time.Hour {
+		t.Errorf("AnonymousCookieTTL = %v, want 720h", cfg.AnonymousCookieTTL)
+	}
+
 	// Test Worker defaults
 	if cfg.WorkerPollTimeout != 5*time.Second {
 		t.Errorf("WorkerPollTimeout = %v, want 5s", cfg.WorkerPollTimeout)
 	}
+	if cfg.SchedulerInterval != time.Second {
+		t.Errorf("SchedulerInterval = %v, want 1s", cfg.SchedulerInterval)
+	}
 	if cfg.MaxUploadSize != 52428800 {
 		t.Errorf("MaxUploadSize = %d, want 52428800", cfg.MaxUploadSize)
 	}
@@ -0,0 +1,109 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/timkrebs/image-processor/internal/models"
+)
+
+// ErrBatchNotFound is returned when a batch is not found
+var ErrBatchNotFound = errors.New("batch not found")
+
+// CreateBatch inserts a new batch into the database
+func (r *JobRepository) CreateBatch(ctx context.Context, batch *models.Batch) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	operations, err := json.Marshal(batch.Operations)
+	if err != nil {
+		return fmt.Errorf("failed to marshal operations: %w", err)
+	}
+
+	query := `
+		INSERT INTO batches (id, user_id, anonymous_id, priority, operations, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+
+	_, err = r.db.ExecContext(ctx, query,
+		batch.ID,
+		batch.UserID,
+		batch.AnonymousID,
+		batch.Priority,
+		string(operations),
+		batch.CreatedAt,
+		batch.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create batch: %w", err)
+	}
+
+	return nil
+}
+
+// GetBatch retrieves a batch by its ID, without its items
+func (r *JobRepository) GetBatch(ctx context.Context, id uuid.UUID) (*models.Batch, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	query := `
+		SELECT id, user_id, anonymous_id, priority, operations, created_at, updated_at
+		FROM batches
+		WHERE id = $1
+	`
+
+	batch := &models.Batch{}
+	var anonymousID uuid.NullUUID
+	var operations string
+	err := r.db.QueryRowContext(ctx, query, id).Scan(
+		&batch.ID,
+		&batch.UserID,
+		&anonymousID,
+		&batch.Priority,
+		&operations,
+		&batch.CreatedAt,
+		&batch.UpdatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, ErrBatchNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get batch: %w", err)
+	}
+
+	if anonymousID.Valid {
+		batch.AnonymousID = &anonymousID.UUID
+	}
+	if err := json.Unmarshal([]byte(operations), &batch.Operations); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal operations: %w", err)
+	}
+
+	return batch, nil
+}
+
+// ListBatchJobs retrieves all jobs belonging to a batch in submission order
+func (r *JobRepository) ListBatchJobs(ctx context.Context, batchID uuid.UUID) ([]*models.Job, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	query := `
+		SELECT ` + jobColumns + `
+		FROM jobs
+		WHERE batch_id = $1
+		ORDER BY created_at, original_name
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, batchID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list batch jobs: %w", err)
+	}
+	defer rows.Close()
+
+	return scanJobs(rows)
+}
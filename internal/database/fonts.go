@@ -0,0 +1,126 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/timkrebs/image-processor/internal/models"
+)
+
+var (
+	// ErrFontNotFound is returned when a font is not found
+	ErrFontNotFound = errors.New("font not found")
+	// ErrFontExists is returned when the owner already has a font with the name
+	ErrFontExists = errors.New("a font with this name already exists")
+)
+
+const fontColumns = `id, user_id, name, storage_key, size, created_at`
+
+func scanFont(row rowScanner) (*models.Font, error) {
+	font := &models.Font{}
+	err := row.Scan(
+		&font.ID,
+		&font.UserID,
+		&font.Name,
+		&font.StorageKey,
+		&font.Size,
+		&font.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return font, nil
+}
+
+// CreateFont records an uploaded font
+func (r *JobRepository) CreateFont(ctx context.Context, font *models.Font) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO fonts (id, user_id, name, storage_key, size)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING created_at
+	`, font.ID, font.UserID, font.Name, font.StorageKey, font.Size,
+	).Scan(&font.CreatedAt)
+	if isUniqueViolation(err) {
+		return ErrFontExists
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create font: %w", err)
+	}
+	return nil
+}
+
+// GetFont retrieves a font by its ID
+func (r *JobRepository) GetFont(ctx context.Context, id uuid.UUID) (*models.Font, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	query := `SELECT ` + fontColumns + ` FROM fonts WHERE id = $1`
+
+	font, err := scanFont(r.db.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, ErrFontNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get font: %w", err)
+	}
+	return font, nil
+}
+
+// ListFonts retrieves a user's fonts, by name
+func (r *JobRepository) ListFonts(ctx context.Context, userID uuid.UUID) ([]*models.Font, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `
+		SELECT ` + fontColumns + `
+		FROM fonts
+		WHERE user_id = $1
+		ORDER BY lower(name), created_at, id
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list fonts: %w", err)
+	}
+	defer rows.Close()
+
+	fonts := []*models.Font{}
+	for rows.Next() {
+		font, err := scanFont(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan font: %w", err)
+		}
+		fonts = append(fonts, font)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate fonts: %w", err)
+	}
+	return fonts, nil
+}
+
+// DeleteFont deletes a user's font. Jobs still using it fail when processed.
+func (r *JobRepository) DeleteFont(ctx context.Context, id, userID uuid.UUID) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	result, err := r.db.ExecContext(ctx, `DELETE FROM fonts WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete font: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return ErrFontNotFound
+	}
+	return nil
+}
@@ -3,17 +3,25 @@ package database
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 
 	"github.com/timkrebs/image-processor/internal/models"
 )
 
-// ErrNotFound is returned when a job is not found
-var ErrNotFound = errors.New("job not found")
+var (
+	// ErrNotFound is returned when a job is not found
+	ErrNotFound = errors.New("job not found")
+	// ErrJobNotRequeueable is returned when a job's status or deleted original rule out running it again
+	ErrJobNotRequeueable = errors.New("job cannot be requeued")
+)
 
 // JobRepository handles job database operations
 type JobRepository struct {
@@ -25,60 +33,39 @@ func NewJobRepository(db *DB) *JobRepository {
 	return &JobRepository{db: db}
 }
 
-// Create inserts a new job into the database
-func (r *JobRepository) Create(ctx context.Context, job *models.Job) error {
-	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
-	defer cancel()
-
-	if err := job.MarshalOperations(); err != nil {
-		return fmt.Errorf("failed to marshal operations: %w", err)
-	}
-
-	query := `
-		INSERT INTO jobs (id, status, original_key, original_name, content_type, file_size, operations, user_id, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
-	`
-
-	_, err := r.db.ExecContext(ctx, query,
-		job.ID,
-		job.Status,
-		job.OriginalKey,
-		job.OriginalName,
-		job.ContentType,
-		job.FileSize,
-		job.OperationsJSON,
-		job.UserID,
-		job.CreatedAt,
-		job.UpdatedAt,
-	)
-	if err != nil {
-		return fmt.Errorf("failed to create job: %w", err)
-	}
+// jobColumns is the column list selected by all job queries, in scan order
+const jobColumns = `id, status, priority, original_key, processed_key, original_name, content_type,
+		       file_size, operations, error, progress, worker_id, user_id, created_at, updated_at,
+		       started_at, completed_at, processing_time_ms, delete_at, run_at, anonymous_id, batch_id,
+		       original_sha256, operations_sha256, delete_original, original_deleted_at, parent_job_id,
+		       preset_id, preset_version, output_size, ssim, psnr, analysis`
 
-	return nil
+// rowScanner is implemented by *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
 }
 
-// GetByID retrieves a job by its ID
-func (r *JobRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Job, error) {
-	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
-	defer cancel()
-
-	query := `
-		SELECT id, status, original_key, processed_key, original_name, content_type,
-		       file_size, operations, error, progress, worker_id, user_id, created_at, updated_at,
-		       started_at, completed_at, processing_time_ms, delete_at
-		FROM jobs
-		WHERE id = $1
-	`
+// querier is implemented by *DB and *sql.Tx
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
 
+// scanJob scans a single row selected with jobColumns into a job
+func scanJob(row rowScanner) (*models.Job, error) {
 	job := &models.Job{}
-	var processedKey, errorMsg, workerID sql.NullString
-	var startedAt, completedAt, deleteAt sql.NullTime
-	var processingTime sql.NullInt64
-
-	err := r.db.QueryRowContext(ctx, query, id).Scan(
+	var processedKey, errorMsg, workerID, originalSHA256, operationsHash sql.NullString
+	var startedAt, completedAt, deleteAt, runAt, originalDeletedAt sql.NullTime
+	var processingTime, outputSize sql.NullInt64
+	var ssim, psnr sql.NullFloat64
+	var analysis []byte
+	var anonymousID, batchID, parentJobID, presetID uuid.NullUUID
+	var presetVersion sql.NullInt32
+
+	err := row.Scan(
 		&job.ID,
 		&job.Status,
+		&job.Priority,
 		&job.OriginalKey,
 		&processedKey,
 		&job.OriginalName,
@@ -95,12 +82,23 @@ func (r *JobRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Job,
 		&completedAt,
 		&processingTime,
 		&deleteAt,
+		&runAt,
+		&anonymousID,
+		&batchID,
+		&originalSHA256,
+		&operationsHash,
+		&job.DeleteOriginal,
+		&originalDeletedAt,
+		&parentJobID,
+		&presetID,
+		&presetVersion,
+		&outputSize,
+		&ssim,
+		&psnr,
+		&analysis,
 	)
-	if err == sql.ErrNoRows {
-		return nil, ErrNotFound
-	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to get job: %w", err)
+		return nil, err
 	}
 
 	if processedKey.Valid {
@@ -124,16 +122,195 @@ func (r *JobRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Job,
 	if deleteAt.Valid {
 		job.DeleteAt = &deleteAt.Time
 	}
+	if runAt.Valid {
+		job.RunAt = &runAt.Time
+	}
+	if anonymousID.Valid {
+		job.AnonymousID = &anonymousID.UUID
+	}
+	if batchID.Valid {
+		job.BatchID = &batchID.UUID
+	}
+	if originalDeletedAt.Valid {
+		job.OriginalDeletedAt = &originalDeletedAt.Time
+	}
+	if parentJobID.Valid {
+		job.ParentJobID = &parentJobID.UUID
+	}
+	if presetID.Valid {
+		job.PresetID = &presetID.UUID
+	}
+	if presetVersion.Valid {
+		version := int(presetVersion.Int32)
+		job.PresetVersion = &version
+	}
+	if outputSize.Valid {
+		job.SetOutputSize(outputSize.Int64)
+	}
+	if ssim.Valid && psnr.Valid {
+		job.SSIM = &ssim.Float64
+		job.PSNR = &psnr.Float64
+	}
+	job.OriginalSHA256 = originalSHA256.String
+	job.OperationsHash = operationsHash.String
 
 	if err := job.UnmarshalOperations(); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal operations: %w", err)
 	}
+	if analysis != nil {
+		if err := json.Unmarshal(analysis, &job.Analysis); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal analysis: %w", err)
+		}
+	}
+
+	return job, nil
+}
+
+// ownerCondition returns the WHERE condition and argument restricting jobs to an owner.
+// Anonymous clients only see their own jobs; users only see jobs submitted with their session.
+// It decides visibility only; quotas are counted per user, see quotaUsage.
+func ownerCondition(owner models.JobOwner) (string, interface{}) {
+	if owner.AnonymousID != nil {
+		return "anonymous_id = $1", *owner.AnonymousID
+	}
+	return "user_id = $1 AND anonymous_id IS NULL", owner.UserID
+}
+
+// scanJobs scans all rows selected with jobColumns
+func scanJobs(rows *sql.Rows) ([]*models.Job, error) {
+	var jobs []*models.Job
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan job: %w", err)
+		}
+		jobs = append(jobs, job)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate jobs: %w", err)
+	}
+	return jobs, nil
+}
+
+// Create inserts a new job into the database
+func (r *JobRepository) Create(ctx context.Context, job *models.Job) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	return insertJob(ctx, r.db, job)
+}
+
+// insertJob inserts a job using q, which may be a transaction
+func insertJob(ctx context.Context, q querier, job *models.Job) error {
+	if err := job.MarshalOperations(); err != nil {
+		return fmt.Errorf("failed to marshal operations: %w", err)
+	}
+	operationsHash, err := models.OperationsDigest(job.Operations)
+	if err != nil {
+		return fmt.Errorf("failed to hash operations: %w", err)
+	}
+	job.OperationsHash = operationsHash
+
+	query := `
+		INSERT INTO jobs (id, status, priority, original_key, original_name, content_type, file_size, operations, user_id, anonymous_id, batch_id, run_at, created_at, updated_at,
+		                  original_sha256, operations_sha256, delete_original, parent_job_id, preset_id, preset_version)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, NULLIF($15, ''), $16, $17, $18, $19, $20)
+	`
+
+	if job.Priority == "" {
+		job.Priority = models.PriorityDefault
+	}
+
+	_, err = q.ExecContext(ctx, query,
+		job.ID,
+		job.Status,
+		job.Priority,
+		job.OriginalKey,
+		job.OriginalName,
+		job.ContentType,
+		job.FileSize,
+		job.OperationsJSON,
+		job.UserID,
+		job.AnonymousID,
+		job.BatchID,
+		job.RunAt,
+		job.CreatedAt,
+		job.UpdatedAt,
+		job.OriginalSHA256,
+		job.OperationsHash,
+		job.DeleteOriginal,
+		job.ParentJobID,
+		job.PresetID,
+		job.PresetVersion,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create job: %w", err)
+	}
+
+	return nil
+}
+
+// GetByID retrieves a job by its ID
+func (r *JobRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Job, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	query := `
+		SELECT ` + jobColumns + `
+		FROM jobs
+		WHERE id = $1
+	`
+
+	job, err := scanJob(r.db.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get job: %w", err)
+	}
 
 	return job, nil
 }
 
-// List retrieves a paginated list of jobs filtered by user
-func (r *JobRepository) List(ctx context.Context, userID uuid.UUID, page, pageSize int) ([]*models.Job, int, error) {
+// maxLineage bounds the number of jobs ListLineage returns
+const maxLineage = 100
+
+// ListLineage retrieves the jobs related to a job by retries and clones: its
+// oldest remaining ancestor and everything created from it, oldest first
+func (r *JobRepository) ListLineage(ctx context.Context, owner models.JobOwner, id uuid.UUID) ([]*models.Job, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	condition, ownerArg := ownerCondition(owner)
+	query := `
+		WITH RECURSIVE ancestors AS (
+			SELECT id, parent_job_id FROM jobs WHERE id = $2
+			UNION ALL
+			SELECT j.id, j.parent_job_id FROM jobs j JOIN ancestors a ON j.id = a.parent_job_id
+		), lineage AS (
+			SELECT id FROM ancestors WHERE parent_job_id IS NULL
+			UNION ALL
+			SELECT j.id FROM jobs j JOIN lineage l ON j.parent_job_id = l.id
+		)
+		SELECT ` + jobColumns + `
+		FROM jobs
+		WHERE id IN (SELECT id FROM lineage) AND ` + condition + `
+		ORDER BY created_at, id
+		LIMIT $3
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, ownerArg, id, maxLineage)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list job lineage: %w", err)
+	}
+	defer rows.Close()
+
+	return scanJobs(rows)
+}
+
+// List retrieves a paginated list of an owner's jobs matching filter. The
+// filter's UserID and Limit are ignored; the owner and page decide them.
+func (r *JobRepository) List(ctx context.Context, owner models.JobOwner, filter JobFilter, page, pageSize int) ([]*models.Job, int, error) {
 	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 
@@ -145,90 +322,307 @@ func (r *JobRepository) List(ctx context.Context, userID uuid.UUID, page, pageSi
 	}
 	offset := (page - 1) * pageSize
 
-	// Get total count for user
+	condition, ownerArg := ownerCondition(owner)
+	filter.UserID = nil
+	conditions, args := filter.conditions([]string{condition}, []interface{}{ownerArg})
+	where := ` WHERE ` + strings.Join(conditions, " AND ")
+
+	// Get total count for owner
 	var total int
-	countQuery := `SELECT COUNT(*) FROM jobs WHERE user_id = $1`
-	if err := r.db.QueryRowContext(ctx, countQuery, userID).Scan(&total); err != nil {
+	countQuery := `SELECT COUNT(*) FROM jobs` + where
+	if err := r.db.QueryRowContext(ctx, countQuery, args...).Scan(&total); err != nil {
 		return nil, 0, fmt.Errorf("failed to count jobs: %w", err)
 	}
 
-	query := `
-		SELECT id, status, original_key, processed_key, original_name, content_type,
-		       file_size, operations, error, progress, worker_id, user_id, created_at, updated_at,
-		       started_at, completed_at, processing_time_ms, delete_at
-		FROM jobs
-		WHERE user_id = $1
-		ORDER BY created_at DESC
-		LIMIT $2 OFFSET $3
-	`
+	args = append(args, pageSize, offset)
+	query := `SELECT ` + jobColumns + ` FROM jobs` + where + filter.orderBy() +
+		fmt.Sprintf(" LIMIT $%d OFFSET $%d", len(args)-1, len(args))
 
-	rows, err := r.db.QueryContext(ctx, query, userID, pageSize, offset)
+	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, 0, fmt.Errorf("failed to list jobs: %w", err)
 	}
 	defer rows.Close()
 
-	var jobs []*models.Job
-	for rows.Next() {
-		job := &models.Job{}
-		var processedKey, errorMsg, workerID sql.NullString
-		var startedAt, completedAt, deleteAt sql.NullTime
-		var processingTime sql.NullInt64
-
-		err := rows.Scan(
-			&job.ID,
-			&job.Status,
-			&job.OriginalKey,
-			&processedKey,
-			&job.OriginalName,
-			&job.ContentType,
-			&job.FileSize,
-			&job.OperationsJSON,
-			&errorMsg,
-			&job.Progress,
-			&workerID,
-			&job.UserID,
-			&job.CreatedAt,
-			&job.UpdatedAt,
-			&startedAt,
-			&completedAt,
-			&processingTime,
-			&deleteAt,
-		)
-		if err != nil {
-			return nil, 0, fmt.Errorf("failed to scan job: %w", err)
-		}
+	jobs, err := scanJobs(rows)
+	if err != nil {
+		return nil, 0, err
+	}
 
-		if processedKey.Valid {
-			job.ProcessedKey = processedKey.String
-		}
-		if errorMsg.Valid {
-			job.Error = errorMsg.String
-		}
-		if workerID.Valid {
-			job.WorkerID = workerID.String
-		}
-		if startedAt.Valid {
-			job.StartedAt = &startedAt.Time
-		}
-		if completedAt.Valid {
-			job.CompletedAt = &completedAt.Time
-		}
-		if processingTime.Valid {
-			job.ProcessingTime = &processingTime.Int64
+	return jobs, total, nil
+}
+
+// JobCursor is a position in a job list ordered by (created_at, id)
+type JobCursor struct {
+	CreatedAt time.Time
+	ID        uuid.UUID
+	// Backward selects the jobs before the position instead of after it
+	Backward bool
+}
+
+// ListAfter retrieves up to limit of an owner's jobs matching filter that follow
+// cursor, or the first jobs without one. Unlike List it seeks on the
+// (created_at, id) order instead of counting and skipping rows, so pages stay
+// fast and stable while new jobs arrive. The filter must be sorted by
+// created_at. hasMore reports whether further jobs exist in the cursor's direction.
+func (r *JobRepository) ListAfter(ctx context.Context, owner models.JobOwner, filter JobFilter, cursor *JobCursor, limit int) ([]*models.Job, bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	if filter.Sort != "" && filter.Sort != "created_at" {
+		return nil, false, fmt.Errorf("cursor pagination requires sorting by created_at, not %s", filter.Sort)
+	}
+	if limit < 1 {
+		limit = 10
+	}
+
+	condition, ownerArg := ownerCondition(owner)
+	filter.UserID = nil
+	conditions, args := filter.conditions([]string{condition}, []interface{}{ownerArg})
+
+	// Walking backward reads the list in reverse and flips the page afterwards
+	ascending := filter.Ascending
+	if cursor != nil {
+		if cursor.Backward {
+			ascending = !ascending
 		}
-		if deleteAt.Valid {
-			job.DeleteAt = &deleteAt.Time
+		op := "<"
+		if ascending {
+			op = ">"
 		}
+		args = append(args, cursor.CreatedAt, cursor.ID)
+		conditions = append(conditions, fmt.Sprintf("(created_at, id) %s ($%d, $%d)", op, len(args)-1, len(args)))
+	}
+	direction := "DESC"
+	if ascending {
+		direction = "ASC"
+	}
 
-		if err := job.UnmarshalOperations(); err != nil {
-			return nil, 0, fmt.Errorf("failed to unmarshal operations: %w", err)
+	// One extra row tells whether another page follows
+	args = append(args, limit+1)
+	query := `SELECT ` + jobColumns + ` FROM jobs WHERE ` + strings.Join(conditions, " AND ") +
+		fmt.Sprintf(" ORDER BY created_at %s, id %s LIMIT $%d", direction, direction, len(args))
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to list jobs: %w", err)
+	}
+	defer rows.Close()
+
+	jobs, err := scanJobs(rows)
+	if err != nil {
+		return nil, false, err
+	}
+
+	hasMore := len(jobs) > limit
+	if hasMore {
+		jobs = jobs[:limit]
+	}
+	if cursor != nil && cursor.Backward {
+		slices.Reverse(jobs)
+	}
+
+	return jobs, hasMore, nil
+}
+
+// EstimateCount returns the planner's estimate of how many of an owner's jobs
+// match filter. It costs no more than planning the query, however many jobs
+// there are, but can be off by a wide margin.
+func (r *JobRepository) EstimateCount(ctx context.Context, owner models.JobOwner, filter JobFilter) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	condition, ownerArg := ownerCondition(owner)
+	filter.UserID = nil
+	conditions, args := filter.conditions([]string{condition}, []interface{}{ownerArg})
+
+	var plan string
+	query := `EXPLAIN (FORMAT JSON) SELECT 1 FROM jobs WHERE ` + strings.Join(conditions, " AND ")
+	if err := r.db.QueryRowContext(ctx, query, args...).Scan(&plan); err != nil {
+		return 0, fmt.Errorf("failed to estimate job count: %w", err)
+	}
+
+	var explained []struct {
+		Plan struct {
+			Rows float64 `json:"Plan Rows"`
+		} `json:"Plan"`
+	}
+	if err := json.Unmarshal([]byte(plan), &explained); err != nil {
+		return 0, fmt.Errorf("failed to parse query plan: %w", err)
+	}
+	if len(explained) == 0 {
+		return 0, errors.New("failed to parse query plan: empty plan")
+	}
+
+	return int64(explained[0].Plan.Rows), nil
+}
+
+// jobSortColumns maps the sort fields accepted by JobFilter.Sort to their columns
+var jobSortColumns = map[string]string{
+	"created_at":         "created_at",
+	"updated_at":         "updated_at",
+	"completed_at":       "completed_at",
+	"status":             "status",
+	"priority":           "priority",
+	"original_name":      "lower(original_name)",
+	"content_type":       "content_type",
+	"file_size":          "file_size",
+	"processing_time_ms": "processing_time_ms",
+}
+
+// ValidJobSort reports whether field can be used as JobFilter.Sort
+func ValidJobSort(field string) bool {
+	_, ok := jobSortColumns[field]
+	return ok
+}
+
+// JobFilter selects jobs by their attributes; zero fields don't restrict the result
+type JobFilter struct {
+	IDs      []uuid.UUID
+	Statuses []models.JobStatus
+	// Operations matches jobs that contain any of these operations
+	Operations    []models.OperationType
+	UserID        *uuid.UUID
+	ContentTypes  []string
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	// NamePrefix matches original names starting with it, ignoring case
+	NamePrefix string
+	// NameSearch matches original names containing it, ignoring case
+	NameSearch string
+	MinSize    int64
+	MaxSize    int64
+	// Sort is one of the fields accepted by ValidJobSort; created_at by default
+	Sort      string
+	Ascending bool
+	Limit     int
+}
+
+// IsEmpty reports whether the filter matches every job
+func (f JobFilter) IsEmpty() bool {
+	conditions, _ := f.conditions(nil, nil)
+	return len(conditions) == 0
+}
+
+// conditions appends the filter's WHERE conditions and their arguments
+func (f JobFilter) conditions(conditions []string, args []interface{}) ([]string, []interface{}) {
+	add := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if len(f.IDs) > 0 {
+		add("id = ANY($%d)", pq.Array(f.IDs))
+	}
+	if len(f.Statuses) > 0 {
+		add("status = ANY($%d)", pq.Array(f.Statuses))
+	}
+	if len(f.Operations) > 0 {
+		// One containment pattern per operation, so the GIN index on operations is used
+		patterns := make([]string, len(f.Operations))
+		for i, op := range f.Operations {
+			patterns[i] = fmt.Sprintf(`[{"operation":%q}]`, op)
 		}
+		add("operations @> ANY($%d::jsonb[])", pq.Array(patterns))
+	}
+	if f.UserID != nil {
+		add("user_id = $%d", *f.UserID)
+	}
+	if len(f.ContentTypes) > 0 {
+		add("content_type = ANY($%d)", pq.Array(f.ContentTypes))
+	}
+	if !f.CreatedAfter.IsZero() {
+		add("created_at >= $%d", f.CreatedAfter)
+	}
+	if !f.CreatedBefore.IsZero() {
+		add("created_at < $%d", f.CreatedBefore)
+	}
+	if f.NamePrefix != "" {
+		add(`lower(original_name) LIKE lower($%d) || '%%'`, escapeLike(f.NamePrefix))
+	}
+	if f.NameSearch != "" {
+		add(`original_name ILIKE '%%' || $%d || '%%'`, escapeLike(f.NameSearch))
+	}
+	if f.MinSize > 0 {
+		add("file_size >= $%d", f.MinSize)
+	}
+	if f.MaxSize > 0 {
+		add("file_size <= $%d", f.MaxSize)
+	}
 
-		jobs = append(jobs, job)
+	return conditions, args
+}
+
+// orderBy returns the ORDER BY clause for the filter's sort, with the ID
+// breaking ties so that pages don't overlap
+func (f JobFilter) orderBy() string {
+	column, ok := jobSortColumns[f.Sort]
+	if !ok {
+		column = "created_at"
 	}
+	direction := "DESC"
+	if f.Ascending {
+		direction = "ASC"
+	}
+	return fmt.Sprintf(" ORDER BY %s %s NULLS LAST, id %s", column, direction, direction)
+}
 
-	return jobs, total, nil
+// escapeLike escapes the LIKE wildcards in s so that it matches literally
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// ListJobs retrieves jobs of all owners matching filter
+func (r *JobRepository) ListJobs(ctx context.Context, filter JobFilter) ([]*models.Job, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	conditions, args := filter.conditions(nil, nil)
+
+	query := `SELECT ` + jobColumns + ` FROM jobs`
+	if len(conditions) > 0 {
+		query += ` WHERE ` + strings.Join(conditions, " AND ")
+	}
+	query += filter.orderBy()
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		query += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list jobs: %w", err)
+	}
+	defer rows.Close()
+
+	return scanJobs(rows)
+}
+
+// RequeueJob resets a job in one of the given statuses to queued so that it runs
+// again. Jobs whose original has been deleted can't be requeued.
+func (r *JobRepository) RequeueJob(ctx context.Context, id uuid.UUID, from ...models.JobStatus) (*models.Job, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	query := `
+		UPDATE jobs
+		SET status = $1, error = NULL, progress = 0, worker_id = NULL, started_at = NULL,
+		    completed_at = NULL, processing_time_ms = NULL
+		WHERE id = $2 AND status = ANY($3) AND original_deleted_at IS NULL
+		RETURNING ` + jobColumns
+
+	job, err := scanJob(r.db.QueryRowContext(ctx, query, models.JobStatusQueued, id, pq.Array(from)))
+	if err == sql.ErrNoRows {
+		if _, getErr := r.GetByID(ctx, id); getErr != nil {
+			return nil, getErr
+		}
+		return nil, ErrJobNotRequeueable
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to requeue job: %w", err)
+	}
+
+	return job, nil
 }
 
 // UpdateStatus updates the status of a job
@@ -250,6 +644,17 @@ func (r *JobRepository) UpdateStatus(ctx context.Context, id uuid.UUID, status m
 	return nil
 }
 
+// MarkQueued moves a scheduled job to queued once it has been dispatched to the stream.
+// Jobs that were canceled in the meantime are left untouched.
+func (r *JobRepository) MarkQueued(ctx context.Context, id uuid.UUID) error {
+	query := `UPDATE jobs SET status = $1 WHERE id = $2 AND status = $3`
+	_, err := r.db.ExecContext(ctx, query, models.JobStatusQueued, id, models.JobStatusScheduled)
+	if err != nil {
+		return fmt.Errorf("failed to mark job queued: %w", err)
+	}
+	return nil
+}
+
 // UpdateProgress updates the progress of a job
 func (r *JobRepository) UpdateProgress(ctx context.Context, id uuid.UUID, progress int) error {
 	query := `UPDATE jobs SET progress = $1 WHERE id = $2`
@@ -297,6 +702,107 @@ func (r *JobRepository) CompleteJob(ctx context.Context, id uuid.UUID, processed
 	return err
 }
 
+// RecordOutput records the size of a job's output and, if it was measured, its quality
+func (r *JobRepository) RecordOutput(ctx context.Context, id uuid.UUID, size int64, quality *models.OutputQuality) error {
+	var ssim, psnr sql.NullFloat64
+	if quality != nil {
+		ssim = sql.NullFloat64{Float64: quality.SSIM, Valid: true}
+		psnr = sql.NullFloat64{Float64: quality.PSNR, Valid: true}
+	}
+
+	query := `
+		UPDATE jobs
+		SET output_size = $1, ssim = $2, psnr = $3
+		WHERE id = $4
+	`
+	_, err := r.db.ExecContext(ctx, query, size, ssim, psnr, id)
+	return err
+}
+
+// RecordAnalysis stores the results of a job's analysis operations, with its
+// hashes also as integers for near-duplicate searches
+func (r *JobRepository) RecordAnalysis(ctx context.Context, id uuid.UUID, analysis *models.ImageAnalysis) error {
+	data, err := json.Marshal(analysis)
+	if err != nil {
+		return fmt.Errorf("failed to marshal analysis: %w", err)
+	}
+
+	hashes := make([]sql.NullInt64, 2)
+	for i, kind := range []string{models.HashPHash, models.HashDHash} {
+		if value := analysis.Hash(kind); value != "" {
+			hash, err := models.ParseImageHash(value)
+			if err != nil {
+				return err
+			}
+			hashes[i] = sql.NullInt64{Int64: int64(hash), Valid: true}
+		}
+	}
+
+	query := `
+		UPDATE jobs
+		SET analysis = $1, phash = $2, dhash = $3
+		WHERE id = $4
+	`
+	_, err = r.db.ExecContext(ctx, query, data, hashes[0], hashes[1], id)
+	return err
+}
+
+// FindSimilar returns up to limit of an owner's other jobs whose hash of the
+// given kind is within maxDistance bits of hash, closest first
+func (r *JobRepository) FindSimilar(ctx context.Context, owner models.JobOwner, id uuid.UUID, kind string, hash uint64, maxDistance, limit int) ([]*models.SimilarJob, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	// The column name can't be a parameter, so only known kinds get this far
+	if _, err := models.ParseHashKind(kind); err != nil {
+		return nil, err
+	}
+
+	condition, ownerArg := ownerCondition(owner)
+	query := `
+		SELECT ` + jobColumns + `, distance
+		FROM (
+			SELECT *, bit_count((` + kind + ` # $3)::bit(64)) AS distance
+			FROM jobs
+			WHERE ` + condition + ` AND id <> $2 AND ` + kind + ` IS NOT NULL
+		) AS candidates
+		WHERE distance <= $4
+		ORDER BY distance, created_at DESC
+		LIMIT $5
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, ownerArg, id, int64(hash), maxDistance, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find similar jobs: %w", err)
+	}
+	defer rows.Close()
+
+	var similar []*models.SimilarJob
+	for rows.Next() {
+		match := &models.SimilarJob{}
+		job, err := scanJob(distanceScanner{rows, &match.Distance})
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan job: %w", err)
+		}
+		match.Job = job
+		similar = append(similar, match)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate jobs: %w", err)
+	}
+	return similar, nil
+}
+
+// distanceScanner scans a row of jobColumns followed by a hash distance
+type distanceScanner struct {
+	rowScanner
+	distance *int
+}
+
+func (s distanceScanner) Scan(dest ...interface{}) error {
+	return s.rowScanner.Scan(append(dest, s.distance)...)
+}
+
 // FailJob marks a job as failed with an error message
 func (r *JobRepository) FailJob(ctx context.Context, id uuid.UUID, errorMsg string) error {
 	now := time.Now()
@@ -314,12 +820,13 @@ func (r *JobRepository) CancelJob(ctx context.Context, id uuid.UUID) error {
 	query := `
 		UPDATE jobs
 		SET status = $1
-		WHERE id = $2 AND status IN ($3, $4)
+		WHERE id = $2 AND status IN ($3, $4, $5)
 	`
 	result, err := r.db.ExecContext(ctx, query,
 		models.JobStatusCancelled,
 		id,
 		models.JobStatusPending,
+		models.JobStatusScheduled,
 		models.JobStatusQueued,
 	)
 	if err != nil {
@@ -337,6 +844,41 @@ func (r *JobRepository) CancelJob(ctx context.Context, id uuid.UUID) error {
 	return nil
 }
 
+// CancelJobs cancels those of an owner's jobs that haven't started processing,
+// all in one statement, and returns the IDs of the jobs it canceled
+func (r *JobRepository) CancelJobs(ctx context.Context, owner models.JobOwner, ids []uuid.UUID) ([]uuid.UUID, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	condition, ownerArg := ownerCondition(owner)
+	query := `
+		UPDATE jobs
+		SET status = $2, updated_at = NOW()
+		WHERE ` + condition + ` AND id = ANY($3) AND status = ANY($4)
+		RETURNING id
+	`
+	cancelable := []models.JobStatus{models.JobStatusPending, models.JobStatusScheduled, models.JobStatusQueued}
+
+	rows, err := r.db.QueryContext(ctx, query, ownerArg, models.JobStatusCancelled, pq.Array(ids), pq.Array(cancelable))
+	if err != nil {
+		return nil, fmt.Errorf("failed to cancel jobs: %w", err)
+	}
+	defer rows.Close()
+
+	var canceled []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan canceled job: %w", err)
+		}
+		canceled = append(canceled, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to cancel jobs: %w", err)
+	}
+	return canceled, nil
+}
+
 // GetPendingJobsCount returns the count of pending jobs
 func (r *JobRepository) GetPendingJobsCount(ctx context.Context) (int, error) {
 	var count int
@@ -348,9 +890,7 @@ func (r *JobRepository) GetPendingJobsCount(ctx context.Context) (int, error) {
 // GetJobsToCleanup returns jobs that should be deleted (delete_at < now)
 func (r *JobRepository) GetJobsToCleanup(ctx context.Context, limit int) ([]*models.Job, error) {
 	query := `
-		SELECT id, status, original_key, processed_key, original_name, content_type,
-		       file_size, operations, error, progress, worker_id, user_id, created_at, updated_at,
-		       started_at, completed_at, processing_time_ms, delete_at
+		SELECT ` + jobColumns + `
 		FROM jobs
 		WHERE delete_at IS NOT NULL AND delete_at < NOW()
 		ORDER BY delete_at ASC
@@ -363,67 +903,27 @@ func (r *JobRepository) GetJobsToCleanup(ctx context.Context, limit int) ([]*mod
 	}
 	defer rows.Close()
 
-	var jobs []*models.Job
-	for rows.Next() {
-		job := &models.Job{}
-		var processedKey, errorMsg, workerID sql.NullString
-		var startedAt, completedAt, deleteAt sql.NullTime
-		var processingTime sql.NullInt64
-
-		err := rows.Scan(
-			&job.ID,
-			&job.Status,
-			&job.OriginalKey,
-			&processedKey,
-			&job.OriginalName,
-			&job.ContentType,
-			&job.FileSize,
-			&job.OperationsJSON,
-			&errorMsg,
-			&job.Progress,
-			&workerID,
-			&job.UserID,
-			&job.CreatedAt,
-			&job.UpdatedAt,
-			&startedAt,
-			&completedAt,
-			&processingTime,
-			&deleteAt,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan job: %w", err)
-		}
+	return scanJobs(rows)
+}
 
-		if processedKey.Valid {
-			job.ProcessedKey = processedKey.String
-		}
-		if errorMsg.Valid {
-			job.Error = errorMsg.String
-		}
-		if workerID.Valid {
-			job.WorkerID = workerID.String
-		}
-		if startedAt.Valid {
-			job.StartedAt = &startedAt.Time
-		}
-		if completedAt.Valid {
-			job.CompletedAt = &completedAt.Time
-		}
-		if processingTime.Valid {
-			job.ProcessingTime = &processingTime.Int64
-		}
-		if deleteAt.Valid {
-			job.DeleteAt = &deleteAt.Time
-		}
+// DeleteJobs permanently deletes jobs from the database
+func (r *JobRepository) DeleteJobs(ctx context.Context, ids []uuid.UUID) error {
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM jobs WHERE id = ANY($1)`, pq.Array(ids)); err != nil {
+		return fmt.Errorf("failed to delete jobs: %w", err)
+	}
+	return nil
+}
 
-		if err := job.UnmarshalOperations(); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal operations: %w", err)
-		}
+// MarkOriginalDeleted records that a job's original was deleted after processing
+func (r *JobRepository) MarkOriginalDeleted(ctx context.Context, id uuid.UUID) error {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
 
-		jobs = append(jobs, job)
+	query := `UPDATE jobs SET original_deleted_at = NOW(), updated_at = NOW() WHERE id = $1 AND original_deleted_at IS NULL`
+	if _, err := r.db.ExecContext(ctx, query, id); err != nil {
+		return fmt.Errorf("failed to mark original deleted: %w", err)
 	}
-
-	return jobs, nil
+	return nil
 }
 
 // DeleteJob permanently deletes a job from the database
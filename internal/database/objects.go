@@ -0,0 +1,275 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+
+	"github.com/timkrebs/image-processor/internal/models"
+)
+
+// lockObjectKey serializes reference changes to one object key for the rest of tx
+func lockObjectKey(ctx context.Context, tx *sql.Tx, key string) error {
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext($1))`, key); err != nil {
+		return fmt.Errorf("failed to lock object key: %w", err)
+	}
+	return nil
+}
+
+// AcquireObjectRefs records that the given jobs reference a shared storage object.
+// Callers must check the object still exists afterwards: a concurrent release may
+// have deleted it just before.
+func (r *JobRepository) AcquireObjectRefs(ctx context.Context, key string, jobIDs ...uuid.UUID) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback() //nolint:errcheck // no-op after commit
+
+	if err := lockObjectKey(ctx, tx, key); err != nil {
+		return err
+	}
+
+	for _, jobID := range jobIDs {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO object_refs (object_key, job_id)
+			VALUES ($1, $2)
+			ON CONFLICT DO NOTHING
+		`, key, jobID)
+		if err != nil {
+			return fmt.Errorf("failed to acquire object reference: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit object references: %w", err)
+	}
+	return nil
+}
+
+// ObjectRef is a job's reference to a storage object
+type ObjectRef struct {
+	Key   string
+	JobID uuid.UUID
+}
+
+// ReleaseObjectRef drops a job's reference to a storage object and calls deleteObject
+// if no references remain. Objects never shared have no references recorded and are
+// always deleted. Releasing twice is harmless, so failed cleanups can be retried.
+func (r *JobRepository) ReleaseObjectRef(ctx context.Context, key string, jobID uuid.UUID, deleteObject func() error) error {
+	return r.ReleaseObjectRefs(ctx, []ObjectRef{{Key: key, JobID: jobID}}, func([]string) error {
+		return deleteObject()
+	})
+}
+
+// ReleaseObjectRefs drops several references at once, like ReleaseObjectRef, and
+// calls deleteObjects once with every object left without references
+func (r *JobRepository) ReleaseObjectRefs(ctx context.Context, refs []ObjectRef, deleteObjects func(keys []string) error) error {
+	if len(refs) == 0 {
+		return nil
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback() //nolint:errcheck // no-op after commit
+
+	// Lock in a fixed order so concurrent releases cannot deadlock
+	keys := make([]string, 0, len(refs))
+	for _, ref := range refs {
+		keys = append(keys, ref.Key)
+	}
+	slices.Sort(keys)
+	keys = slices.Compact(keys)
+
+	for _, key := range keys {
+		if err := lockObjectKey(ctx, tx, key); err != nil {
+			return err
+		}
+	}
+
+	for _, ref := range refs {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM object_refs WHERE object_key = $1 AND job_id = $2`, ref.Key, ref.JobID); err != nil {
+			return fmt.Errorf("failed to release object reference: %w", err)
+		}
+	}
+
+	rows, err := tx.QueryContext(ctx, `SELECT DISTINCT object_key FROM object_refs WHERE object_key = ANY($1)`, pq.Array(keys))
+	if err != nil {
+		return fmt.Errorf("failed to count object references: %w", err)
+	}
+	referenced := make(map[string]bool)
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan object reference: %w", err)
+		}
+		referenced[key] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to count object references: %w", err)
+	}
+
+	unreferenced := slices.DeleteFunc(keys, func(key string) bool { return referenced[key] })
+
+	// Delete while holding the locks so no new reference can see the objects in between
+	if len(unreferenced) > 0 {
+		if err := deleteObjects(unreferenced); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit object release: %w", err)
+	}
+	return nil
+}
+
+// FindCompletedResult returns a completed job of the same user with the same original
+// content and operations as job, or ErrNotFound. Jobs about to be cleaned up are skipped.
+func (r *JobRepository) FindCompletedResult(ctx context.Context, job *models.Job) (*models.Job, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	query := `
+		SELECT ` + jobColumns + `
+		FROM jobs
+		WHERE user_id = $1
+		  AND original_sha256 = $2
+		  AND operations_sha256 = $3
+		  AND status = $4
+		  AND processed_key IS NOT NULL
+		  AND id <> $5
+		  AND (delete_at IS NULL OR delete_at > NOW() + INTERVAL '5 minutes')
+		ORDER BY completed_at DESC
+		LIMIT 1
+	`
+
+	result, err := scanJob(r.db.QueryRowContext(ctx, query,
+		job.UserID, job.OriginalSHA256, job.OperationsHash, models.JobStatusCompleted, job.ID))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find completed result: %w", err)
+	}
+
+	return result, nil
+}
+
+// ObjectReferences are the storage objects the database refers to
+type ObjectReferences struct {
+	// Keys maps each referenced key to whether the object must exist
+	Keys map[string]bool
+	// Jobs holds the IDs of all jobs; their derived images are in use
+	Jobs map[uuid.UUID]bool
+}
+
+// ListObjectReferences collects every storage key referenced by jobs, uploads,
+// shared object references and fonts. Originals, results of completed jobs and
+// fonts must exist; upload objects and incomplete uploads may not have been
+// written yet.
+func (r *JobRepository) ListObjectReferences(ctx context.Context) (*ObjectReferences, error) {
+	refs := &ObjectReferences{Keys: make(map[string]bool), Jobs: make(map[uuid.UUID]bool)}
+
+	add := func(key string, required bool) {
+		refs.Keys[key] = refs.Keys[key] || required
+	}
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, status, original_key, processed_key, original_deleted_at IS NOT NULL
+		FROM jobs
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list job objects: %w", err)
+	}
+	for rows.Next() {
+		var id uuid.UUID
+		var status models.JobStatus
+		var originalKey string
+		var processedKey sql.NullString
+		var originalDeleted bool
+		if err := rows.Scan(&id, &status, &originalKey, &processedKey, &originalDeleted); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan job objects: %w", err)
+		}
+		refs.Jobs[id] = true
+		if originalKey != "" && !originalDeleted {
+			add(originalKey, true)
+		}
+		if processedKey.Valid && processedKey.String != "" {
+			add(processedKey.String, status == models.JobStatusCompleted)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate job objects: %w", err)
+	}
+
+	rows, err = r.db.QueryContext(ctx, `SELECT id, object_key, multipart_id IS NOT NULL FROM uploads`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list upload objects: %w", err)
+	}
+	for rows.Next() {
+		var upload models.Upload
+		var resumable bool
+		if err := rows.Scan(&upload.ID, &upload.ObjectKey, &resumable); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan upload objects: %w", err)
+		}
+		add(upload.ObjectKey, false)
+		if resumable {
+			add(upload.IncompleteKey(), false)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate upload objects: %w", err)
+	}
+
+	rows, err = r.db.QueryContext(ctx, `SELECT DISTINCT object_key FROM object_refs`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list object references: %w", err)
+	}
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan object reference: %w", err)
+		}
+		add(key, false)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate object references: %w", err)
+	}
+
+	rows, err = r.db.QueryContext(ctx, `SELECT storage_key FROM fonts`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list font objects: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, fmt.Errorf("failed to scan font object: %w", err)
+		}
+		add(key, true)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate font objects: %w", err)
+	}
+
+	return refs, nil
+}
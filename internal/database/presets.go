@@ -0,0 +1,319 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+
+	"github.com/timkrebs/image-processor/internal/models"
+)
+
+var (
+	// ErrPresetNotFound is returned when a preset or preset version is not found
+	ErrPresetNotFound = errors.New("preset not found")
+	// ErrPresetExists is returned when the owner already has a preset with the name
+	ErrPresetExists = errors.New("a preset with this name already exists")
+	// ErrPresetVersionConflict is returned when a preset changed since the version an update was based on
+	ErrPresetVersionConflict = errors.New("preset was changed by another request")
+)
+
+const presetColumns = `id, user_id, name, description, shared, version, operations, created_at, updated_at`
+
+func scanPreset(row rowScanner) (*models.Preset, error) {
+	preset := &models.Preset{}
+	var operations string
+
+	err := row.Scan(
+		&preset.ID,
+		&preset.UserID,
+		&preset.Name,
+		&preset.Description,
+		&preset.Shared,
+		&preset.Version,
+		&operations,
+		&preset.CreatedAt,
+		&preset.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal([]byte(operations), &preset.Operations); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal operations: %w", err)
+	}
+	return preset, nil
+}
+
+// isUniqueViolation reports whether err is a PostgreSQL unique constraint violation
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+// CreatePreset inserts a new preset as version 1
+func (r *JobRepository) CreatePreset(ctx context.Context, preset *models.Preset) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	operations, err := json.Marshal(preset.Operations)
+	if err != nil {
+		return fmt.Errorf("failed to marshal operations: %w", err)
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback() //nolint:errcheck // no-op after commit
+
+	preset.Version = 1
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO presets (id, user_id, name, description, shared, version, operations)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING created_at, updated_at
+	`, preset.ID, preset.UserID, preset.Name, preset.Description, preset.Shared, preset.Version, string(operations),
+	).Scan(&preset.CreatedAt, &preset.UpdatedAt)
+	if isUniqueViolation(err) {
+		return ErrPresetExists
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create preset: %w", err)
+	}
+
+	if err := insertPresetVersion(ctx, tx, preset.ID, preset.Version, operations); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit preset: %w", err)
+	}
+	return nil
+}
+
+// insertPresetVersion records the operations of a preset version, once
+func insertPresetVersion(ctx context.Context, tx *sql.Tx, presetID uuid.UUID, version int, operations []byte) error {
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO preset_versions (preset_id, version, operations)
+		VALUES ($1, $2, $3)
+		ON CONFLICT DO NOTHING
+	`, presetID, version, string(operations))
+	if err != nil {
+		return fmt.Errorf("failed to record preset version: %w", err)
+	}
+	return nil
+}
+
+// UpdatePreset replaces a user's preset. The version is incremented only if the
+// operations change. A non-zero expectedVersion must match the current version.
+func (r *JobRepository) UpdatePreset(ctx context.Context, preset *models.Preset, expectedVersion int) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	operations, err := json.Marshal(preset.Operations)
+	if err != nil {
+		return fmt.Errorf("failed to marshal operations: %w", err)
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback() //nolint:errcheck // no-op after commit
+
+	var current int
+	err = tx.QueryRowContext(ctx, `
+		SELECT version FROM presets WHERE id = $1 AND user_id = $2 FOR UPDATE
+	`, preset.ID, preset.UserID).Scan(&current)
+	if err == sql.ErrNoRows {
+		return ErrPresetNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to lock preset: %w", err)
+	}
+	if expectedVersion != 0 && expectedVersion != current {
+		return ErrPresetVersionConflict
+	}
+
+	err = tx.QueryRowContext(ctx, `
+		UPDATE presets
+		SET name = $2, description = $3, shared = $4, operations = $5,
+		    version = CASE WHEN operations = $5::jsonb THEN version ELSE version + 1 END
+		WHERE id = $1
+		RETURNING version, created_at, updated_at
+	`, preset.ID, preset.Name, preset.Description, preset.Shared, string(operations),
+	).Scan(&preset.Version, &preset.CreatedAt, &preset.UpdatedAt)
+	if isUniqueViolation(err) {
+		return ErrPresetExists
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update preset: %w", err)
+	}
+
+	if err := insertPresetVersion(ctx, tx, preset.ID, preset.Version, operations); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit preset: %w", err)
+	}
+	return nil
+}
+
+// GetPreset retrieves a preset by its ID
+func (r *JobRepository) GetPreset(ctx context.Context, id uuid.UUID) (*models.Preset, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	query := `SELECT ` + presetColumns + ` FROM presets WHERE id = $1`
+
+	preset, err := scanPreset(r.db.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, ErrPresetNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get preset: %w", err)
+	}
+	return preset, nil
+}
+
+// FindPreset retrieves a user's own preset by name. Shared names are not
+// unique, so whoever saved one first could squat it; presets of other users
+// are only reachable by ID.
+func (r *JobRepository) FindPreset(ctx context.Context, userID uuid.UUID, name string) (*models.Preset, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	query := `SELECT ` + presetColumns + ` FROM presets WHERE user_id = $1 AND name = $2`
+
+	preset, err := scanPreset(r.db.QueryRowContext(ctx, query, userID, name))
+	if err == sql.ErrNoRows {
+		return nil, ErrPresetNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find preset: %w", err)
+	}
+	return preset, nil
+}
+
+// ListPresets retrieves a user's presets and all shared presets, by name
+func (r *JobRepository) ListPresets(ctx context.Context, userID uuid.UUID) ([]*models.Preset, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `
+		SELECT ` + presetColumns + `
+		FROM presets
+		WHERE user_id = $1 OR shared
+		ORDER BY lower(name), user_id = $1 DESC, created_at, id
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list presets: %w", err)
+	}
+	defer rows.Close()
+
+	presets := []*models.Preset{}
+	for rows.Next() {
+		preset, err := scanPreset(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan preset: %w", err)
+		}
+		presets = append(presets, preset)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate presets: %w", err)
+	}
+	return presets, nil
+}
+
+// GetPresetVersion retrieves the operations of one version of a preset
+func (r *JobRepository) GetPresetVersion(ctx context.Context, presetID uuid.UUID, version int) (*models.PresetVersion, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	query := `
+		SELECT version, operations, created_at
+		FROM preset_versions
+		WHERE preset_id = $1 AND version = $2
+	`
+
+	v, err := scanPresetVersion(r.db.QueryRowContext(ctx, query, presetID, version))
+	if err == sql.ErrNoRows {
+		return nil, ErrPresetNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get preset version: %w", err)
+	}
+	return v, nil
+}
+
+// ListPresetVersions retrieves all versions of a preset, newest first
+func (r *JobRepository) ListPresetVersions(ctx context.Context, presetID uuid.UUID) ([]*models.PresetVersion, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `
+		SELECT version, operations, created_at
+		FROM preset_versions
+		WHERE preset_id = $1
+		ORDER BY version DESC
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, presetID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list preset versions: %w", err)
+	}
+	defer rows.Close()
+
+	versions := []*models.PresetVersion{}
+	for rows.Next() {
+		v, err := scanPresetVersion(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan preset version: %w", err)
+		}
+		versions = append(versions, v)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate preset versions: %w", err)
+	}
+	return versions, nil
+}
+
+func scanPresetVersion(row rowScanner) (*models.PresetVersion, error) {
+	v := &models.PresetVersion{}
+	var operations string
+	if err := row.Scan(&v.Version, &operations, &v.CreatedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(operations), &v.Operations); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal operations: %w", err)
+	}
+	return v, nil
+}
+
+// DeletePreset deletes a user's preset with all its versions. Jobs created
+// from it keep their operations.
+func (r *JobRepository) DeletePreset(ctx context.Context, id, userID uuid.UUID) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	result, err := r.db.ExecContext(ctx, `DELETE FROM presets WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete preset: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return ErrPresetNotFound
+	}
+	return nil
+}
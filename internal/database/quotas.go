@@ -0,0 +1,116 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/timkrebs/image-processor/internal/models"
+)
+
+// GetQuotaLimits returns the quota limits for a user, falling back to the given
+// defaults for any limit without a per-user override
+func (db *DB) GetQuotaLimits(ctx context.Context, userID uuid.UUID, defaults models.QuotaLimits) (models.QuotaLimits, error) {
+	limits := defaults
+	query := `
+		SELECT COALESCE(max_jobs_per_day, $2), COALESCE(max_bytes_stored, $3), COALESCE(max_active_jobs, $4)
+		FROM user_quotas
+		WHERE user_id = $1`
+
+	err := db.QueryRowContext(ctx, query, userID, defaults.JobsPerDay, defaults.BytesStored, defaults.ActiveJobs).Scan(
+		&limits.JobsPerDay,
+		&limits.BytesStored,
+		&limits.ActiveJobs,
+	)
+	if err == sql.ErrNoRows {
+		return defaults, nil
+	}
+	if err != nil {
+		return defaults, fmt.Errorf("failed to get quota limits: %w", err)
+	}
+
+	return limits, nil
+}
+
+// GetQuotaUsage returns an owner's jobs submitted since dayStart, stored bytes and active jobs.
+// Anonymous owners share their user's quota: a new anonymous ID is only a cookie away.
+func (db *DB) GetQuotaUsage(ctx context.Context, owner models.JobOwner, dayStart time.Time) (models.QuotaUsage, error) {
+	return quotaUsage(ctx, db, owner.UserID, dayStart)
+}
+
+// CreateWithinQuota inserts a job like Create, unless its owner's usage leaves
+// no room for it within limits, in which case it returns a
+// *models.QuotaExceededError. Usage is counted while holding a lock on the
+// owner, so concurrent submissions can't all pass the check.
+func (r *JobRepository) CreateWithinQuota(ctx context.Context, job *models.Job, limits models.QuotaLimits, dayStart time.Time) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback() //nolint:errcheck // no-op after commit
+
+	if err := lockQuotaUser(ctx, tx, job.UserID); err != nil {
+		return err
+	}
+
+	usage, err := quotaUsage(ctx, tx, job.UserID, dayStart)
+	if err != nil {
+		return err
+	}
+	if err := limits.Check(usage, 1, job.FileSize); err != nil {
+		return err
+	}
+
+	if err := insertJob(ctx, tx, job); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit job: %w", err)
+	}
+	return nil
+}
+
+// lockQuotaUser serializes quota-checked job inserts of one user for the rest of tx
+func lockQuotaUser(ctx context.Context, tx *sql.Tx, userID uuid.UUID) error {
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext($1))`, "quota:"+userID.String()); err != nil {
+		return fmt.Errorf("failed to lock quota user: %w", err)
+	}
+	return nil
+}
+
+// quotaUsage counts the usage of all of a user's jobs, anonymous ones
+// included, using q, which may be a transaction
+func quotaUsage(ctx context.Context, q querier, userID uuid.UUID, dayStart time.Time) (models.QuotaUsage, error) {
+	var usage models.QuotaUsage
+	query := `
+		SELECT
+			COUNT(*) FILTER (WHERE created_at >= $2),
+			COALESCE(SUM(file_size), 0),
+			COUNT(*) FILTER (WHERE status IN ($3, $4, $5, $6))
+		FROM jobs
+		WHERE user_id = $1`
+
+	err := q.QueryRowContext(ctx, query,
+		userID,
+		dayStart,
+		models.JobStatusPending,
+		models.JobStatusScheduled,
+		models.JobStatusQueued,
+		models.JobStatusProcessing,
+	).Scan(
+		&usage.JobsToday,
+		&usage.BytesStored,
+		&usage.ActiveJobs,
+	)
+	if err != nil {
+		return usage, fmt.Errorf("failed to get quota usage: %w", err)
+	}
+
+	return usage, nil
+}
@@ -0,0 +1,215 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/timkrebs/image-processor/internal/models"
+)
+
+// ErrUploadNotFound is returned when an upload is not found
+var ErrUploadNotFound = errors.New("upload not found")
+
+// ErrUploadClaimed is returned when an upload was already used to create a job
+var ErrUploadClaimed = errors.New("upload already used")
+
+const uploadColumns = `id, user_id, anonymous_id, object_key, filename, content_type, size, status,
+		       expires_at, created_at, multipart_id, completed_at`
+
+func scanUpload(row rowScanner) (*models.Upload, error) {
+	upload := &models.Upload{}
+	var anonymousID uuid.NullUUID
+	var multipartID sql.NullString
+	var completedAt sql.NullTime
+
+	err := row.Scan(
+		&upload.ID,
+		&upload.UserID,
+		&anonymousID,
+		&upload.ObjectKey,
+		&upload.Filename,
+		&upload.ContentType,
+		&upload.Size,
+		&upload.Status,
+		&upload.ExpiresAt,
+		&upload.CreatedAt,
+		&multipartID,
+		&completedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if anonymousID.Valid {
+		upload.AnonymousID = &anonymousID.UUID
+	}
+	if multipartID.Valid {
+		upload.MultipartID = multipartID.String
+	}
+	if completedAt.Valid {
+		upload.CompletedAt = &completedAt.Time
+	}
+	return upload, nil
+}
+
+// CreateUpload inserts a new pending upload
+func (r *JobRepository) CreateUpload(ctx context.Context, upload *models.Upload) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `
+		INSERT INTO uploads (id, user_id, anonymous_id, object_key, filename, content_type, size, status, expires_at, created_at, multipart_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, NULLIF($11, ''))
+	`
+
+	_, err := r.db.ExecContext(ctx, query,
+		upload.ID,
+		upload.UserID,
+		upload.AnonymousID,
+		upload.ObjectKey,
+		upload.Filename,
+		upload.ContentType,
+		upload.Size,
+		upload.Status,
+		upload.ExpiresAt,
+		upload.CreatedAt,
+		upload.MultipartID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create upload: %w", err)
+	}
+
+	return nil
+}
+
+// GetUpload retrieves an upload by its ID
+func (r *JobRepository) GetUpload(ctx context.Context, id uuid.UUID) (*models.Upload, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	query := `SELECT ` + uploadColumns + ` FROM uploads WHERE id = $1`
+
+	upload, err := scanUpload(r.db.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, ErrUploadNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get upload: %w", err)
+	}
+
+	return upload, nil
+}
+
+// ClaimUpload marks a pending upload as used so it can only create one job
+func (r *JobRepository) ClaimUpload(ctx context.Context, id uuid.UUID) error {
+	query := `UPDATE uploads SET status = $1 WHERE id = $2 AND status = $3`
+	result, err := r.db.ExecContext(ctx, query, models.UploadStatusClaimed, id, models.UploadStatusPending)
+	if err != nil {
+		return fmt.Errorf("failed to claim upload: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return ErrUploadClaimed
+	}
+
+	return nil
+}
+
+// ReleaseUpload returns a claimed upload to pending after job creation failed
+func (r *JobRepository) ReleaseUpload(ctx context.Context, id uuid.UUID) error {
+	query := `UPDATE uploads SET status = $1 WHERE id = $2 AND status = $3`
+	if _, err := r.db.ExecContext(ctx, query, models.UploadStatusPending, id, models.UploadStatusClaimed); err != nil {
+		return fmt.Errorf("failed to release upload: %w", err)
+	}
+	return nil
+}
+
+// LockUpload takes a lease on an upload for writing a chunk. It returns false if
+// another request holds an unexpired lease.
+func (r *JobRepository) LockUpload(ctx context.Context, id uuid.UUID, lease time.Duration) (bool, error) {
+	query := `
+		UPDATE uploads SET locked_until = $2
+		WHERE id = $1 AND (locked_until IS NULL OR locked_until < NOW())
+	`
+	result, err := r.db.ExecContext(ctx, query, id, time.Now().Add(lease))
+	if err != nil {
+		return false, fmt.Errorf("failed to lock upload: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	return rows == 1, nil
+}
+
+// UnlockUpload releases the write lease and extends the upload's expiry
+func (r *JobRepository) UnlockUpload(ctx context.Context, id uuid.UUID, expiresAt time.Time) error {
+	query := `UPDATE uploads SET locked_until = NULL, expires_at = $2 WHERE id = $1`
+	if _, err := r.db.ExecContext(ctx, query, id, expiresAt); err != nil {
+		return fmt.Errorf("failed to unlock upload: %w", err)
+	}
+	return nil
+}
+
+// CompleteUpload records that all bytes of a resumable upload are in storage
+func (r *JobRepository) CompleteUpload(ctx context.Context, id uuid.UUID) error {
+	query := `UPDATE uploads SET completed_at = NOW() WHERE id = $1 AND completed_at IS NULL`
+	if _, err := r.db.ExecContext(ctx, query, id); err != nil {
+		return fmt.Errorf("failed to complete upload: %w", err)
+	}
+	return nil
+}
+
+// GetExpiredUploads retrieves uploads past their claim deadline that no request is writing to
+func (r *JobRepository) GetExpiredUploads(ctx context.Context, limit int) ([]*models.Upload, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	query := `
+		SELECT ` + uploadColumns + `
+		FROM uploads
+		WHERE expires_at <= NOW()
+		  AND (locked_until IS NULL OR locked_until <= NOW())
+		ORDER BY expires_at ASC
+		LIMIT $1
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query expired uploads: %w", err)
+	}
+	defer rows.Close()
+
+	var uploads []*models.Upload
+	for rows.Next() {
+		upload, err := scanUpload(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan upload: %w", err)
+		}
+		uploads = append(uploads, upload)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate uploads: %w", err)
+	}
+
+	return uploads, nil
+}
+
+// DeleteUpload removes an upload record
+func (r *JobRepository) DeleteUpload(ctx context.Context, id uuid.UUID) error {
+	query := `DELETE FROM uploads WHERE id = $1`
+	if _, err := r.db.ExecContext(ctx, query, id); err != nil {
+		return fmt.Errorf("failed to delete upload: %w", err)
+	}
+	return nil
+}
@@ -55,15 +55,13 @@ func (db *DB) CreateUser(ctx context.Context, req *models.CreateUserRequest) (*m
 	return user, nil
 }
 
-// GetUserByEmail retrieves a user by email address
-func (db *DB) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
-	user := &models.User{}
-	query := `
-		SELECT id, email, password_hash, username, created_at, updated_at, last_login_at
-		FROM users
-		WHERE email = $1`
+// userColumns is the column list selected by all user queries, in scan order
+const userColumns = `id, email, password_hash, username, created_at, updated_at, last_login_at, disabled_at, session_epoch`
 
-	err := db.QueryRowContext(ctx, query, email).Scan(
+// scanUser scans a single row selected with userColumns into a user
+func scanUser(row rowScanner) (*models.User, error) {
+	user := &models.User{}
+	err := row.Scan(
 		&user.ID,
 		&user.Email,
 		&user.PasswordHash,
@@ -71,8 +69,20 @@ func (db *DB) GetUserByEmail(ctx context.Context, email string) (*models.User, e
 		&user.CreatedAt,
 		&user.UpdatedAt,
 		&user.LastLoginAt,
+		&user.DisabledAt,
+		&user.SessionEpoch,
 	)
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// GetUserByEmail retrieves a user by email address
+func (db *DB) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE email = $1`
 
+	user, err := scanUser(db.QueryRowContext(ctx, query, email))
 	if err == sql.ErrNoRows {
 		return nil, ErrUserNotFound
 	}
@@ -85,22 +95,9 @@ func (db *DB) GetUserByEmail(ctx context.Context, email string) (*models.User, e
 
 // GetUserByID retrieves a user by their ID
 func (db *DB) GetUserByID(ctx context.Context, userID uuid.UUID) (*models.User, error) {
-	user := &models.User{}
-	query := `
-		SELECT id, email, password_hash, username, created_at, updated_at, last_login_at
-		FROM users
-		WHERE id = $1`
-
-	err := db.QueryRowContext(ctx, query, userID).Scan(
-		&user.ID,
-		&user.Email,
-		&user.PasswordHash,
-		&user.Username,
-		&user.CreatedAt,
-		&user.UpdatedAt,
-		&user.LastLoginAt,
-	)
+	query := `SELECT ` + userColumns + ` FROM users WHERE id = $1`
 
+	user, err := scanUser(db.QueryRowContext(ctx, query, userID))
 	if err == sql.ErrNoRows {
 		return nil, ErrUserNotFound
 	}
@@ -111,6 +108,68 @@ func (db *DB) GetUserByID(ctx context.Context, userID uuid.UUID) (*models.User,
 	return user, nil
 }
 
+// ListUsers retrieves users ordered by creation time, newest first
+func (db *DB) ListUsers(ctx context.Context, limit, offset int) ([]*models.User, error) {
+	query := `
+		SELECT ` + userColumns + `
+		FROM users
+		ORDER BY created_at DESC, id
+		LIMIT $1 OFFSET $2`
+
+	rows, err := db.QueryContext(ctx, query, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", err)
+	}
+	defer rows.Close()
+
+	var users []*models.User
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan user: %w", err)
+		}
+		users = append(users, user)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate users: %w", err)
+	}
+
+	return users, nil
+}
+
+// SetUserDisabled disables or re-enables a user account. Disabling revokes the
+// user's sessions, so they stay logged out once re-enabled.
+func (db *DB) SetUserDisabled(ctx context.Context, userID uuid.UUID, disabled bool) error {
+	query := `UPDATE users SET disabled_at = NULL WHERE id = $1`
+	if disabled {
+		query = `UPDATE users SET disabled_at = COALESCE(disabled_at, NOW()), session_epoch = session_epoch + 1 WHERE id = $1`
+	}
+	return db.updateUser(ctx, query, userID)
+}
+
+// UpdatePassword replaces a user's password hash and revokes their sessions
+func (db *DB) UpdatePassword(ctx context.Context, userID uuid.UUID, passwordHash string) error {
+	return db.updateUser(ctx, `UPDATE users SET password_hash = $2, session_epoch = session_epoch + 1 WHERE id = $1`, userID, passwordHash)
+}
+
+// updateUser runs an update of a single user and reports ErrUserNotFound if it didn't match
+func (db *DB) updateUser(ctx context.Context, query string, userID uuid.UUID, args ...interface{}) error {
+	result, err := db.ExecContext(ctx, query, append([]interface{}{userID}, args...)...)
+	if err != nil {
+		return fmt.Errorf("failed to update user: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return ErrUserNotFound
+	}
+
+	return nil
+}
+
 // UpdateLastLogin updates the last login timestamp for a user
 func (db *DB) UpdateLastLogin(ctx context.Context, userID uuid.UUID) error {
 	query := `UPDATE users SET last_login_at = NOW() WHERE id = $1`
@@ -2,10 +2,15 @@ package frontend
 
 import (
 	"encoding/json"
+	"fmt"
 	"io"
 	"log/slog"
+	"net"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 
@@ -30,6 +35,30 @@ func NewHandlers(apiURL string, logger *slog.Logger) *Handlers {
 	}
 }
 
+// apiGet fetches an API path on behalf of the browser, forwarding its cookies
+// so the API sees the same session or anonymous identity
+func (h *Handlers) apiGet(w http.ResponseWriter, r *http.Request, path string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(r.Context(), http.MethodGet, h.apiURL+path, http.NoBody)
+	if err != nil {
+		return nil, err
+	}
+	if cookie := r.Header.Get("Cookie"); cookie != "" {
+		req.Header.Set("Cookie", cookie)
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Pass through newly issued identity cookies
+	for _, cookie := range resp.Header.Values("Set-Cookie") {
+		w.Header().Add("Set-Cookie", cookie)
+	}
+
+	return resp, nil
+}
+
 // Home renders the home page
 func (h *Handlers) Home(w http.ResponseWriter, r *http.Request) {
 	// Fetch queue stats from API
@@ -61,9 +90,11 @@ func (h *Handlers) Home(w http.ResponseWriter, r *http.Request) {
 // Upload renders the upload page
 func (h *Handlers) Upload(w http.ResponseWriter, r *http.Request) {
 	data := PageData{
-		Title:   "Upload",
-		Active:  "upload",
-		Content: nil,
+		Title:  "Upload",
+		Active: "upload",
+		Content: UploadData{
+			Presets: h.presets(w, r),
+		},
 	}
 
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
@@ -73,6 +104,30 @@ func (h *Handlers) Upload(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// presets fetches the presets the browser's user can start from. Presets are
+// optional on the upload page, so failures are only logged.
+func (h *Handlers) presets(w http.ResponseWriter, r *http.Request) []*models.Preset {
+	resp, err := h.apiGet(w, r, "/api/v1/presets")
+	if err != nil {
+		h.logger.Warn("failed to fetch presets", "error", err)
+		return nil
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil
+	}
+
+	var result struct {
+		Presets []*models.Preset `json:"presets"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		h.logger.Warn("failed to decode presets", "error", err)
+		return nil
+	}
+	return result.Presets
+}
+
 // Jobs renders the jobs list page
 func (h *Handlers) Jobs(w http.ResponseWriter, r *http.Request) {
 	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
@@ -80,8 +135,26 @@ func (h *Handlers) Jobs(w http.ResponseWriter, r *http.Request) {
 		page = 1
 	}
 
+	filter := parseJobsFilter(r.URL.Query())
+	content := JobsData{
+		Page:       page,
+		Filter:     filter,
+		Statuses:   models.JobStatuses,
+		Operations: jobOperations,
+		Sorts:      jobSorts,
+	}
+
+	query, err := filter.apiQuery()
+	if err != nil {
+		content.Error = err.Error()
+		h.renderJobs(w, content)
+		return
+	}
+	query.Set("page", strconv.Itoa(page))
+	query.Set("page_size", "12")
+
 	// Fetch jobs from API
-	resp, err := h.client.Get(h.apiURL + "/api/v1/jobs?page=" + strconv.Itoa(page) + "&page_size=12")
+	resp, err := h.apiGet(w, r, "/api/v1/jobs?"+query.Encode())
 	if err != nil {
 		h.logger.Error("failed to fetch jobs", "error", err)
 		http.Error(w, "Failed to fetch jobs", http.StatusInternalServerError)
@@ -89,6 +162,21 @@ func (h *Handlers) Jobs(w http.ResponseWriter, r *http.Request) {
 	}
 	defer resp.Body.Close()
 
+	switch resp.StatusCode {
+	case http.StatusUnauthorized:
+		http.Error(w, "Sign in to view jobs", http.StatusUnauthorized)
+		return
+	case http.StatusBadRequest:
+		// The API rejected a filter; show why next to the form
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&apiErr)
+		content.Error = apiErr.Error
+		h.renderJobs(w, content)
+		return
+	}
+
 	var jobsResp models.JobListResponse
 	if err := json.NewDecoder(resp.Body).Decode(&jobsResp); err != nil {
 		h.logger.Error("failed to decode jobs", "error", err)
@@ -96,30 +184,176 @@ func (h *Handlers) Jobs(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	content.Jobs = jobsResp.Jobs
+	content.Page = jobsResp.Page
+	content.TotalPages = jobsResp.TotalPages
+	content.Total = jobsResp.Total
+	h.renderJobs(w, content)
+}
+
+func (h *Handlers) renderJobs(w http.ResponseWriter, content JobsData) {
 	data := PageData{
-		Title:  "Jobs",
-		Active: "jobs",
-		Content: JobsData{
-			Jobs:       jobsResp.Jobs,
-			Page:       jobsResp.Page,
-			TotalPages: jobsResp.TotalPages,
-			Total:      jobsResp.Total,
-		},
+		Title:   "Jobs",
+		Active:  "jobs",
+		Content: content,
 	}
 
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 	if err := h.templates.Render(w, "jobs", data); err != nil {
 		h.logger.Error("failed to render jobs", "error", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 	}
 }
 
+// jobOperations are the operations the jobs page can filter by
+var jobOperations = []models.OperationType{
+	models.OperationResize,
+	models.OperationThumbnail,
+	models.OperationBlur,
+	models.OperationSharpen,
+	models.OperationGrayscale,
+	models.OperationSepia,
+	models.OperationRotate,
+	models.OperationFlip,
+	models.OperationBrightness,
+	models.OperationContrast,
+	models.OperationSaturation,
+	models.OperationWatermark,
+	models.OperationText,
+	models.OperationColorProfile,
+	models.OperationPad,
+	models.OperationBorder,
+	models.OperationRoundCorners,
+	models.OperationBackground,
+	models.OperationFlatten,
+	models.OperationTrim,
+	models.OperationPHash,
+	models.OperationDHash,
+	models.OperationPalette,
+	models.OperationAverageColor,
+	models.OperationBlurHash,
+	models.OperationHistogram,
+}
+
+// jobSorts are the sort fields offered on the jobs page
+var jobSorts = []SortOption{
+	{Value: "created_at", Label: "Created"},
+	{Value: "updated_at", Label: "Updated"},
+	{Value: "completed_at", Label: "Completed"},
+	{Value: "original_name", Label: "Name"},
+	{Value: "file_size", Label: "Size"},
+	{Value: "processing_time_ms", Label: "Processing time"},
+	{Value: "status", Label: "Status"},
+}
+
+// parseJobsFilter reads the jobs page filter form from the query string
+func parseJobsFilter(query url.Values) JobsFilter {
+	var statuses []string
+	for _, status := range query["status"] {
+		if status != "" {
+			statuses = append(statuses, status)
+		}
+	}
+
+	return JobsFilter{
+		Statuses:    statuses,
+		Operation:   query.Get("operation"),
+		Name:        strings.TrimSpace(query.Get("name")),
+		ContentType: query.Get("content_type"),
+		CreatedFrom: query.Get("created_from"),
+		CreatedTo:   query.Get("created_to"),
+		MinSizeKB:   query.Get("min_size_kb"),
+		MaxSizeKB:   query.Get("max_size_kb"),
+		Sort:        query.Get("sort"),
+		Order:       query.Get("order"),
+	}
+}
+
+// values encodes the filter form for links that keep the current filters
+func (f JobsFilter) values() url.Values {
+	query := url.Values{}
+	for _, status := range f.Statuses {
+		query.Add("status", status)
+	}
+	for name, value := range map[string]string{
+		"operation":    f.Operation,
+		"name":         f.Name,
+		"content_type": f.ContentType,
+		"created_from": f.CreatedFrom,
+		"created_to":   f.CreatedTo,
+		"min_size_kb":  f.MinSizeKB,
+		"max_size_kb":  f.MaxSizeKB,
+		"sort":         f.Sort,
+		"order":        f.Order,
+	} {
+		if value != "" {
+			query.Set(name, value)
+		}
+	}
+	return query
+}
+
+// apiQuery converts the filter form to the query parameters of the job list API.
+// Dates are whole days in UTC and sizes are in kilobytes.
+func (f JobsFilter) apiQuery() (url.Values, error) {
+	query := url.Values{}
+	if len(f.Statuses) > 0 {
+		query.Set("status", strings.Join(f.Statuses, ","))
+	}
+	for name, value := range map[string]string{
+		"operation":    f.Operation,
+		"name":         f.Name,
+		"content_type": f.ContentType,
+		"sort":         f.Sort,
+		"order":        f.Order,
+	} {
+		if value != "" {
+			query.Set(name, value)
+		}
+	}
+
+	for name, day := range map[string]struct {
+		value string
+		next  bool
+	}{
+		"created_after":  {f.CreatedFrom, false},
+		"created_before": {f.CreatedTo, true},
+	} {
+		if day.value == "" {
+			continue
+		}
+		t, err := time.Parse(time.DateOnly, day.value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid date %q", day.value)
+		}
+		if day.next {
+			t = t.AddDate(0, 0, 1)
+		}
+		query.Set(name, t.Format(time.RFC3339))
+	}
+
+	for name, kb := range map[string]string{
+		"min_size": f.MinSizeKB,
+		"max_size": f.MaxSizeKB,
+	} {
+		if kb == "" {
+			continue
+		}
+		n, err := strconv.ParseInt(kb, 10, 64)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid size %q", kb)
+		}
+		query.Set(name, strconv.FormatInt(n*1024, 10))
+	}
+
+	return query, nil
+}
+
 // JobDetail renders a single job detail page
 func (h *Handlers) JobDetail(w http.ResponseWriter, r *http.Request) {
 	jobID := chi.URLParam(r, "id")
 
 	// Fetch job from API
-	resp, err := h.client.Get(h.apiURL + "/api/v1/jobs/" + jobID)
+	resp, err := h.apiGet(w, r, "/api/v1/jobs/"+jobID)
 	if err != nil {
 		h.logger.Error("failed to fetch job", "error", err)
 		http.Error(w, "Failed to fetch job", http.StatusInternalServerError)
@@ -127,6 +361,11 @@ func (h *Handlers) JobDetail(w http.ResponseWriter, r *http.Request) {
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusUnauthorized {
+		http.Error(w, "Sign in to view jobs", http.StatusUnauthorized)
+		return
+	}
+
 	if resp.StatusCode == http.StatusNotFound {
 		http.Error(w, "Job not found", http.StatusNotFound)
 		return
@@ -143,7 +382,8 @@ func (h *Handlers) JobDetail(w http.ResponseWriter, r *http.Request) {
 		Title:  "Job Details",
 		Active: "jobs",
 		Content: JobDetailData{
-			Job: &job,
+			Job:     &job,
+			History: h.jobHistory(w, r, jobID),
 		},
 	}
 
@@ -154,6 +394,30 @@ func (h *Handlers) JobDetail(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// jobHistory fetches the jobs retried or cloned from the same upload as a job.
+// The history is optional on the detail page, so failures are only logged.
+func (h *Handlers) jobHistory(w http.ResponseWriter, r *http.Request, jobID string) []*models.Job {
+	resp, err := h.apiGet(w, r, "/api/v1/jobs/"+jobID+"/history")
+	if err != nil {
+		h.logger.Warn("failed to fetch job history", "error", err)
+		return nil
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil
+	}
+
+	var history struct {
+		Jobs []*models.Job `json:"jobs"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&history); err != nil {
+		h.logger.Warn("failed to decode job history", "error", err)
+		return nil
+	}
+	return history.Jobs
+}
+
 // ProxyAPI proxies requests to the API service
 func (h *Handlers) ProxyAPI(w http.ResponseWriter, r *http.Request) {
 	// Create proxy request
@@ -176,6 +440,14 @@ func (h *Handlers) ProxyAPI(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// Append the peer so the API, trusting this proxy, can tell clients apart
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		if prior := proxyReq.Header.Values("X-Forwarded-For"); len(prior) > 0 {
+			host = strings.Join(prior, ", ") + ", " + host
+		}
+		proxyReq.Header.Set("X-Forwarded-For", host)
+	}
+
 	// Execute request
 	resp, err := h.client.Do(proxyReq)
 	if err != nil {
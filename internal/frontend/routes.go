@@ -14,7 +14,6 @@ func NewRouter(handlers *Handlers, staticDir string, logger *slog.Logger) *chi.M
 
 	// Middleware
 	r.Use(middleware.RequestID)
-	r.Use(middleware.RealIP)
 	r.Use(middleware.Recoverer)
 	r.Use(middleware.Logger)
 
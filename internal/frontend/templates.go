@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"html/template"
 	"io"
+	"slices"
+	"strconv"
 	"time"
 
 	"github.com/timkrebs/image-processor/internal/models"
@@ -87,17 +89,66 @@ type HomeData struct {
 	Stats *models.QueueStats
 }
 
+// UploadData holds upload page data
+type UploadData struct {
+	Presets []*models.Preset
+}
+
 // JobsData holds jobs list page data
 type JobsData struct {
 	Jobs       []*models.Job
 	Page       int
 	TotalPages int
 	Total      int
+	Filter     JobsFilter
+	Error      string
+	Statuses   []models.JobStatus
+	Operations []models.OperationType
+	Sorts      []SortOption
+}
+
+// PageURL links to a page of the jobs list with the current filters
+func (d JobsData) PageURL(page int) template.URL {
+	query := d.Filter.values()
+	query.Set("page", strconv.Itoa(page))
+	return template.URL("/jobs?" + query.Encode())
+}
+
+// JobsFilter holds the values of the jobs page filter form
+type JobsFilter struct {
+	Statuses    []string
+	Operation   string
+	Name        string
+	ContentType string
+	CreatedFrom string // YYYY-MM-DD
+	CreatedTo   string // YYYY-MM-DD, inclusive
+	MinSizeKB   string
+	MaxSizeKB   string
+	Sort        string
+	Order       string
+}
+
+// HasStatus reports whether status is selected
+func (f JobsFilter) HasStatus(status models.JobStatus) bool {
+	return slices.Contains(f.Statuses, string(status))
+}
+
+// Active reports whether any filter is set
+func (f JobsFilter) Active() bool {
+	return len(f.Statuses) > 0 || f.Operation != "" || f.Name != "" || f.ContentType != "" ||
+		f.CreatedFrom != "" || f.CreatedTo != "" || f.MinSizeKB != "" || f.MaxSizeKB != ""
+}
+
+// SortOption is a choice in the jobs page sort menu
+type SortOption struct {
+	Value string
+	Label string
 }
 
 // JobDetailData holds job detail page data
 type JobDetailData struct {
-	Job *models.Job
+	Job     *models.Job
+	History []*models.Job
 }
 
 const homeTemplate = `
@@ -195,6 +246,16 @@ const uploadTemplate = `
 
                 <h2>Processing Operations</h2>
 
+                <div class="form-group">
+                    <label for="preset-select">Start From Preset</label>
+                    <select id="preset-select" onchange="loadPreset(this.value)">
+                        <option value="">No preset</option>
+                        {{range .Content.Presets}}
+                        <option value="{{.ID}}">{{.Name}} (v{{.Version}}{{if .Shared}}, shared{{end}})</option>
+                        {{end}}
+                    </select>
+                </div>
+
                 <div class="form-group">
                     <label for="operation-select">Add Operation</label>
                     <select id="operation-select" onchange="showParams(this.value)">
@@ -210,6 +271,23 @@ const uploadTemplate = `
                         <option value="brightness">Brightness</option>
                         <option value="contrast">Contrast</option>
                         <option value="saturation">Saturation</option>
+                        <option value="color_profile">Color profile</option>
+                        <option value="text">Text</option>
+                        <optgroup label="Canvas">
+                            <option value="pad">Pad</option>
+                            <option value="border">Border</option>
+                            <option value="round_corners">Round corners</option>
+                            <option value="background">Background</option>
+                            <option value="trim">Trim</option>
+                        </optgroup>
+                        <optgroup label="Analysis">
+                            <option value="phash">Perceptual hash</option>
+                            <option value="dhash">Difference hash</option>
+                            <option value="palette">Dominant colors</option>
+                            <option value="average_color">Average color</option>
+                            <option value="blurhash">Blurhash</option>
+                            <option value="histogram">Histogram</option>
+                        </optgroup>
                     </select>
                 </div>
 
@@ -222,6 +300,9 @@ const uploadTemplate = `
                         <label>Height (px)</label>
                         <input type="number" id="param-height" placeholder="600">
                     </div>
+                    <div class="form-group">
+                        <label><input type="checkbox" id="param-linear"> Resize in linear light</label>
+                    </div>
                 </div>
 
                 <div id="params-thumbnail" class="param-group" style="display:none;">
@@ -279,13 +360,121 @@ const uploadTemplate = `
                     </div>
                 </div>
 
+                <div id="params-color_profile" class="param-group" style="display:none;">
+                    <div class="form-group">
+                        <label>ICC profile</label>
+                        <select id="param-profile-mode">
+                            <option value="srgb">Convert to sRGB</option>
+                            <option value="embed">Convert to sRGB and embed profile</option>
+                            <option value="preserve">Keep original profile</option>
+                        </select>
+                    </div>
+                </div>
+
+                <div id="params-text" class="param-group" style="display:none;">
+                    <div class="form-group">
+                        <label>Text</label>
+                        <input type="text" id="param-text" placeholder="Caption">
+                    </div>
+                    <div class="form-group">
+                        <label>Font</label>
+                        <select id="param-font">
+                            <option value="go">Go</option>
+                            <option value="go-bold">Go Bold</option>
+                            <option value="go-italic">Go Italic</option>
+                            <option value="go-bold-italic">Go Bold Italic</option>
+                            <option value="go-medium">Go Medium</option>
+                            <option value="go-mono">Go Mono</option>
+                            <option value="go-mono-bold">Go Mono Bold</option>
+                        </select>
+                    </div>
+                    <div class="form-group">
+                        <label>Size (px)</label>
+                        <input type="number" id="param-text-size" value="32" min="1" max="1000">
+                    </div>
+                    <div class="form-group">
+                        <label>Color</label>
+                        <input type="text" id="param-text-color" value="#ffffff">
+                    </div>
+                    <div class="form-group">
+                        <label>Position</label>
+                        <select id="param-position">
+                            <option value="top-left">Top left</option>
+                            <option value="top">Top</option>
+                            <option value="top-right">Top right</option>
+                            <option value="left">Left</option>
+                            <option value="center">Center</option>
+                            <option value="right">Right</option>
+                            <option value="bottom-left">Bottom left</option>
+                            <option value="bottom" selected>Bottom</option>
+                            <option value="bottom-right">Bottom right</option>
+                        </select>
+                    </div>
+                </div>
+
+                <div id="params-pad" class="param-group" style="display:none;">
+                    <div class="form-group">
+                        <label>Padding (px)</label>
+                        <input type="number" id="param-pad-all" value="20" min="0">
+                    </div>
+                    <div class="form-group">
+                        <label>Color</label>
+                        <input type="text" id="param-pad-color" value="transparent">
+                    </div>
+                </div>
+
+                <div id="params-border" class="param-group" style="display:none;">
+                    <div class="form-group">
+                        <label>Size (px)</label>
+                        <input type="number" id="param-border-size" value="10" min="0">
+                    </div>
+                    <div class="form-group">
+                        <label>Color</label>
+                        <input type="text" id="param-border-color" value="#000000">
+                    </div>
+                </div>
+
+                <div id="params-round_corners" class="param-group" style="display:none;">
+                    <div class="form-group">
+                        <label>Radius (px)</label>
+                        <input type="number" id="param-radius" value="20" min="0">
+                    </div>
+                    <div class="form-group">
+                        <label><input type="checkbox" id="param-circle"> Circle</label>
+                    </div>
+                </div>
+
+                <div id="params-background" class="param-group" style="display:none;">
+                    <div class="form-group">
+                        <label>Color</label>
+                        <input type="text" id="param-background-color" value="#ffffff">
+                    </div>
+                </div>
+
+                <div id="params-trim" class="param-group" style="display:none;">
+                    <div class="form-group">
+                        <label>Tolerance (0 to 255)</label>
+                        <input type="number" id="param-tolerance" value="10" min="0" max="255">
+                    </div>
+                </div>
+
+                <div id="params-palette" class="param-group" style="display:none;">
+                    <div class="form-group">
+                        <label>Colors (1 to 16)</label>
+                        <input type="number" id="param-colors" value="5" min="1" max="16">
+                    </div>
+                </div>
+
                 <button type="button" class="btn btn-outline btn-sm" onclick="addOperation()">+ Add Operation</button>
+                <button type="button" class="btn btn-outline btn-sm" onclick="savePreset()">Save as Preset</button>
 
                 <div id="operations-list" class="operations-list" style="margin-top: 20px;">
                     <p style="color: #666; font-size: 0.9rem;">No operations added yet</p>
                 </div>
 
                 <input type="hidden" id="operations-input" name="operations" value="[]">
+                <input type="hidden" id="preset-input" name="preset_id" value="">
+                <input type="hidden" name="priority" value="interactive">
 
                 <div style="margin-top: 30px;">
                     <button type="submit" id="submit-btn" class="btn" disabled>Process Image</button>
@@ -329,7 +518,77 @@ const jobsTemplate = `
         <div class="container">
             <h2>Processing Jobs</h2>
             {{with .Content}}
-            {{if .Jobs}}
+            <form method="get" action="/jobs" class="job-filters">
+                <div class="form-group">
+                    <label for="filter-status">Status</label>
+                    <select id="filter-status" name="status" multiple size="4">
+                        {{range .Statuses}}
+                        <option value="{{.}}" {{if $.Content.Filter.HasStatus .}}selected{{end}}>{{.}}</option>
+                        {{end}}
+                    </select>
+                </div>
+                <div class="form-group">
+                    <label for="filter-operation">Operation</label>
+                    <select id="filter-operation" name="operation">
+                        <option value="">Any</option>
+                        {{range .Operations}}
+                        <option value="{{.}}" {{if eq (print .) $.Content.Filter.Operation}}selected{{end}}>{{.}}</option>
+                        {{end}}
+                    </select>
+                </div>
+                <div class="form-group">
+                    <label for="filter-name">Name contains</label>
+                    <input type="text" id="filter-name" name="name" value="{{.Filter.Name}}">
+                </div>
+                <div class="form-group">
+                    <label for="filter-content-type">Type</label>
+                    <select id="filter-content-type" name="content_type">
+                        <option value="">Any</option>
+                        <option value="image/jpeg" {{if eq .Filter.ContentType "image/jpeg"}}selected{{end}}>JPEG</option>
+                        <option value="image/png" {{if eq .Filter.ContentType "image/png"}}selected{{end}}>PNG</option>
+                        <option value="image/gif" {{if eq .Filter.ContentType "image/gif"}}selected{{end}}>GIF</option>
+                    </select>
+                </div>
+                <div class="form-group">
+                    <label for="filter-created-from">Created from</label>
+                    <input type="date" id="filter-created-from" name="created_from" value="{{.Filter.CreatedFrom}}">
+                </div>
+                <div class="form-group">
+                    <label for="filter-created-to">Created to</label>
+                    <input type="date" id="filter-created-to" name="created_to" value="{{.Filter.CreatedTo}}">
+                </div>
+                <div class="form-group">
+                    <label for="filter-min-size">Min size (KB)</label>
+                    <input type="number" id="filter-min-size" name="min_size_kb" min="0" value="{{.Filter.MinSizeKB}}">
+                </div>
+                <div class="form-group">
+                    <label for="filter-max-size">Max size (KB)</label>
+                    <input type="number" id="filter-max-size" name="max_size_kb" min="0" value="{{.Filter.MaxSizeKB}}">
+                </div>
+                <div class="form-group">
+                    <label for="filter-sort">Sort by</label>
+                    <select id="filter-sort" name="sort">
+                        {{range .Sorts}}
+                        <option value="{{.Value}}" {{if eq .Value $.Content.Filter.Sort}}selected{{end}}>{{.Label}}</option>
+                        {{end}}
+                    </select>
+                </div>
+                <div class="form-group">
+                    <label for="filter-order">Order</label>
+                    <select id="filter-order" name="order">
+                        <option value="desc">Descending</option>
+                        <option value="asc" {{if eq .Filter.Order "asc"}}selected{{end}}>Ascending</option>
+                    </select>
+                </div>
+                <div class="filter-actions">
+                    <button type="submit" class="btn btn-sm">Filter</button>
+                    <a href="/jobs" class="btn btn-outline btn-sm">Reset</a>
+                </div>
+            </form>
+
+            {{if .Error}}
+            <div class="alert alert-error">{{.Error}}</div>
+            {{else if .Jobs}}
             <div class="jobs-grid">
                 {{range .Jobs}}
                 <div class="job-card">
@@ -384,11 +643,11 @@ const jobsTemplate = `
             {{if gt .TotalPages 1}}
             <div style="margin-top: 30px; text-align: center;">
                 {{if gt .Page 1}}
-                <a href="/jobs?page={{subtract .Page 1}}" class="btn btn-outline btn-sm">← Previous</a>
+                <a href="{{.PageURL (subtract .Page 1)}}" class="btn btn-outline btn-sm">← Previous</a>
                 {{end}}
                 <span style="margin: 0 20px;">Page {{.Page}} of {{.TotalPages}}</span>
                 {{if lt .Page .TotalPages}}
-                <a href="/jobs?page={{add .Page 1}}" class="btn btn-outline btn-sm">Next →</a>
+                <a href="{{.PageURL (add .Page 1)}}" class="btn btn-outline btn-sm">Next →</a>
                 {{end}}
             </div>
             {{end}}
@@ -396,8 +655,12 @@ const jobsTemplate = `
             {{else}}
             <div class="empty-state">
                 <div class="icon">📷</div>
+                {{if .Filter.Active}}
+                <p>No jobs match these filters</p>
+                {{else}}
                 <p>No jobs yet</p>
                 <a href="/upload" class="btn" style="margin-top: 20px;">Upload Your First Image</a>
+                {{end}}
             </div>
             {{end}}
             {{end}}
@@ -468,6 +731,9 @@ const jobDetailTemplate = `
                     <p><strong>File Size:</strong> {{formatBytes .FileSize}}</p>
                     <p><strong>Content Type:</strong> {{.ContentType}}</p>
                     <p><strong>Created:</strong> {{formatTime .CreatedAt}}</p>
+                    {{if .RunAt}}
+                    <p><strong>Scheduled For:</strong> {{formatTimePtr .RunAt}}</p>
+                    {{end}}
                     <p><strong>Started:</strong> {{formatTimePtr .StartedAt}}</p>
                     <p><strong>Completed:</strong> {{formatTimePtr .CompletedAt}}</p>
                     {{if .ProcessingTime}}
@@ -479,6 +745,9 @@ const jobDetailTemplate = `
                     {{if .Error}}
                     <p><strong>Error:</strong> <span style="color: #666;">{{.Error}}</span></p>
                     {{end}}
+                    {{if .ParentJobID}}
+                    <p><strong>Created From:</strong> <a href="/jobs/{{.ParentJobID}}">{{shortID .ParentJobID.String}}</a></p>
+                    {{end}}
                 </div>
 
                 <div style="margin-top: 20px;">
@@ -494,13 +763,33 @@ const jobDetailTemplate = `
 
                 <div class="job-actions" style="margin-top: 20px;">
                     {{if eq .Status "completed"}}
-                    <a href="/api/v1/images/{{.ID}}" class="btn btn-sm" download>Download Processed</a>
-                    <a href="/api/v1/images/{{.ID}}?original=true" class="btn btn-sm btn-outline" download>Download Original</a>
+                    <a href="/api/v1/images/{{.ID}}?download=true" class="btn btn-sm" download>Download Processed</a>
+                    <a href="/api/v1/images/{{.ID}}?original=true&download=true" class="btn btn-sm btn-outline" download>Download Original</a>
+                    {{end}}
+                    {{if or (eq .Status "failed") (eq .Status "canceled")}}
+                    <button type="button" class="btn btn-sm" onclick="retryJob('{{.ID}}', this)">Retry</button>
                     {{end}}
                     <a href="/jobs" class="btn btn-sm btn-outline">Back to Jobs</a>
                 </div>
             </div>
             {{end}}
+
+            {{if gt (len .History) 1}}
+            <div style="margin-top: 30px; max-width: 600px;">
+                <h3 style="font-size: 1rem; margin-bottom: 10px;">History</h3>
+                {{range .History}}
+                <div class="operation-item">
+                    {{if eq .ID $.Content.Job.ID}}
+                    <strong>{{shortID .ID.String}}</strong>
+                    {{else}}
+                    <a href="/jobs/{{.ID}}">{{shortID .ID.String}}</a>
+                    {{end}}
+                    <span>{{formatTime .CreatedAt}}</span>
+                    <span class="job-status {{.Status}}">{{.Status}}</span>
+                </div>
+                {{end}}
+            </div>
+            {{end}}
             {{end}}
         </div>
     </main>
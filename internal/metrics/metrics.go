@@ -5,6 +5,8 @@ import (
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/timkrebs/image-processor/internal/models"
 )
 
 // HTTPMetrics holds HTTP-related Prometheus metrics
@@ -50,6 +52,10 @@ type JobMetrics struct {
 	JobsTotal          *prometheus.CounterVec
 	JobsActive         *prometheus.GaugeVec
 	OperationDuration  *prometheus.HistogramVec
+	OutputBytes        *prometheus.HistogramVec
+	CompressionRatio   *prometheus.HistogramVec
+	OutputSSIM         prometheus.Histogram
+	OutputPSNR         prometheus.Histogram
 }
 
 // NewJobMetrics creates job processing metrics collectors
@@ -89,12 +95,61 @@ func NewJobMetrics(namespace string) *JobMetrics {
 			},
 			[]string{"operation"},
 		),
+		OutputBytes: promauto.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace: namespace,
+				Name:      "job_output_bytes",
+				Help:      "Size of processed images in bytes",
+				Buckets:   prometheus.ExponentialBuckets(1<<10, 4, 10),
+			},
+			[]string{"content_type"},
+		),
+		CompressionRatio: promauto.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace: namespace,
+				Name:      "job_compression_ratio",
+				Help:      "Size of originals divided by the size of their processed images",
+				Buckets:   []float64{.25, .5, 1, 1.5, 2, 3, 5, 10, 20, 50},
+			},
+			[]string{"content_type"},
+		),
+		OutputSSIM: promauto.NewHistogram(
+			prometheus.HistogramOpts{
+				Namespace: namespace,
+				Name:      "job_output_ssim",
+				Help:      "Structural similarity of processed images to their resized originals",
+				Buckets:   []float64{.5, .7, .8, .85, .9, .93, .95, .97, .98, .99, 1},
+			},
+		),
+		OutputPSNR: promauto.NewHistogram(
+			prometheus.HistogramOpts{
+				Namespace: namespace,
+				Name:      "job_output_psnr_db",
+				Help:      "Peak signal-to-noise ratio of processed images to their resized originals in dB",
+				Buckets:   []float64{20, 25, 30, 32, 35, 38, 40, 45, 50, 100},
+			},
+		),
+	}
+}
+
+// ObserveOutput records the size, compression ratio and, if measured, quality of a job's output
+func (m *JobMetrics) ObserveOutput(job *models.Job, contentType string) {
+	if job.OutputSize != nil {
+		m.OutputBytes.WithLabelValues(contentType).Observe(float64(*job.OutputSize))
+	}
+	if job.CompressionRatio != nil {
+		m.CompressionRatio.WithLabelValues(contentType).Observe(*job.CompressionRatio)
+	}
+	if quality := job.Quality(); quality != nil {
+		m.OutputSSIM.Observe(quality.SSIM)
+		m.OutputPSNR.Observe(quality.PSNR)
 	}
 }
 
 // QueueMetrics holds queue-related Prometheus metrics
 type QueueMetrics struct {
 	Depth            prometheus.Gauge
+	DepthByPriority  *prometheus.GaugeVec
 	MessagesProduced prometheus.Counter
 	MessagesConsumed prometheus.Counter
 	MessagesFailed   prometheus.Counter
@@ -111,6 +166,14 @@ func NewQueueMetrics(namespace string) *QueueMetrics {
 				Help:      "Current number of messages in the queue",
 			},
 		),
+		DepthByPriority: promauto.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "queue_depth_by_priority",
+				Help:      "Current number of messages in each priority stream",
+			},
+			[]string{"priority"},
+		),
 		MessagesProduced: promauto.NewCounter(
 			prometheus.CounterOpts{
 				Namespace: namespace,
@@ -0,0 +1,385 @@
+// Package migrate applies the embedded SQL migrations to PostgreSQL.
+//
+// The applied version is recorded in a schema_migrations table that uses the
+// same layout as golang-migrate (a single version row with a dirty flag), so
+// databases migrated with the migrate CLI are picked up as they are.
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+
+	"github.com/timkrebs/image-processor/migrations"
+)
+
+// lockID is the session advisory lock held while migrations run, so that
+// concurrent runners (several pods starting at once) apply them only once
+const lockID int64 = 0x696d6770726f63 // "imgproc"
+
+var (
+	// ErrDirty is returned when a previous migration failed half-way and the
+	// schema has to be repaired by hand before migrating again
+	ErrDirty = errors.New("database schema is dirty")
+	// ErrSchemaOutdated is returned by Check when the database is behind the
+	// migrations compiled into the binary
+	ErrSchemaOutdated = errors.New("database schema is outdated")
+	// ErrUnknownVersion is returned when the database is at a version this
+	// binary has no migration for
+	ErrUnknownVersion = errors.New("unknown schema version")
+)
+
+var fileName = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// Migration is one versioned schema change
+type Migration struct {
+	Version int    `json:"version"`
+	Name    string `json:"name"`
+	Up      string `json:"-"`
+	Down    string `json:"-"`
+}
+
+// Status describes whether a migration has been applied
+type Status struct {
+	Migration
+	Applied bool `json:"applied"`
+}
+
+// Load reads NNN_name.up.sql / NNN_name.down.sql pairs from fsys, ordered by
+// version. Every migration needs an up file; the down file is optional.
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		m := fileName.FindStringSubmatch(entry.Name())
+		if m == nil {
+			continue
+		}
+
+		version, err := strconv.Atoi(m[1])
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("invalid migration version in %s", entry.Name())
+		}
+		body, err := fs.ReadFile(fsys, path.Clean(entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", entry.Name(), err)
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = migration
+		} else if migration.Name != m[2] {
+			return nil, fmt.Errorf("migration version %d is used by both %s and %s", version, migration.Name, m[2])
+		}
+
+		if m[3] == "up" {
+			migration.Up = string(body)
+		} else {
+			migration.Down = string(body)
+		}
+	}
+
+	result := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" {
+			return nil, fmt.Errorf("migration %03d_%s has no up file", migration.Version, migration.Name)
+		}
+		result = append(result, *migration)
+	}
+	slices.SortFunc(result, func(a, b Migration) int { return a.Version - b.Version })
+
+	return result, nil
+}
+
+// Migrator applies migrations to a database
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+	logger     *slog.Logger
+}
+
+// New creates a Migrator for the migrations embedded in the binary
+func New(db *sql.DB, logger *slog.Logger) (*Migrator, error) {
+	return NewFromFS(db, migrations.FS, logger)
+}
+
+// NewFromFS creates a Migrator for the migrations in fsys
+func NewFromFS(db *sql.DB, fsys fs.FS, logger *slog.Logger) (*Migrator, error) {
+	list, err := Load(fsys)
+	if err != nil {
+		return nil, err
+	}
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	return &Migrator{db: db, migrations: list, logger: logger}, nil
+}
+
+// Migrations returns the known migrations, ordered by version
+func (m *Migrator) Migrations() []Migration {
+	return m.migrations
+}
+
+// Latest returns the version the binary expects, or 0 without migrations
+func (m *Migrator) Latest() int {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Version returns the current schema version; 0 means nothing was applied
+func (m *Migrator) Version(ctx context.Context) (int, bool, error) {
+	return readVersion(ctx, m.db)
+}
+
+// Check returns an error if the database is dirty or older than Latest.
+// A newer schema is accepted so that old binaries keep running during a
+// rolling deploy.
+func (m *Migrator) Check(ctx context.Context) error {
+	version, dirty, err := m.Version(ctx)
+	if err != nil {
+		return err
+	}
+	if dirty {
+		return fmt.Errorf("%w at version %d", ErrDirty, version)
+	}
+	if latest := m.Latest(); version < latest {
+		return fmt.Errorf("%w: database is at version %d, binary requires %d", ErrSchemaOutdated, version, latest)
+	}
+	return nil
+}
+
+// Status lists every known migration and whether it has been applied
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	version, _, err := m.Version(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]Status, len(m.migrations))
+	for i, migration := range m.migrations {
+		result[i] = Status{Migration: migration, Applied: migration.Version <= version}
+	}
+	return result, nil
+}
+
+// Up applies pending migrations up to and including target; target <= 0
+// means all of them. It returns the number of migrations applied.
+func (m *Migrator) Up(ctx context.Context, target int) (int, error) {
+	if target <= 0 {
+		target = m.Latest()
+	}
+
+	applied := 0
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		version, err := m.cleanVersion(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			if migration.Version <= version || migration.Version > target {
+				continue
+			}
+			if err := m.apply(ctx, conn, migration.Up, migration.Version); err != nil {
+				return fmt.Errorf("migration %03d_%s failed: %w", migration.Version, migration.Name, err)
+			}
+			m.logger.Info("applied migration", "version", migration.Version, "name", migration.Name)
+			applied++
+		}
+		return nil
+	})
+
+	return applied, err
+}
+
+// Down rolls back applied migrations until the schema is at target; a
+// negative target rolls back a single migration. It returns the number of
+// migrations rolled back.
+func (m *Migrator) Down(ctx context.Context, target int) (int, error) {
+	rolledBack := 0
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		version, err := m.cleanVersion(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		idx := m.index(version)
+		if version > 0 && idx < 0 {
+			return fmt.Errorf("%w %d", ErrUnknownVersion, version)
+		}
+		if target < 0 {
+			target = m.previous(idx)
+		}
+
+		for ; idx >= 0 && m.migrations[idx].Version > target; idx-- {
+			migration := m.migrations[idx]
+			if migration.Down == "" {
+				return fmt.Errorf("migration %03d_%s has no down file", migration.Version, migration.Name)
+			}
+			if err := m.apply(ctx, conn, migration.Down, m.previous(idx)); err != nil {
+				return fmt.Errorf("rollback of %03d_%s failed: %w", migration.Version, migration.Name, err)
+			}
+			m.logger.Info("rolled back migration", "version", migration.Version, "name", migration.Name)
+			rolledBack++
+		}
+		return nil
+	})
+
+	return rolledBack, err
+}
+
+// Force records version as applied without running any SQL and clears the
+// dirty flag. Use it to adopt a database whose schema was created by hand,
+// or after repairing a failed migration.
+func (m *Migrator) Force(ctx context.Context, version int) error {
+	if version > 0 && m.index(version) < 0 {
+		return fmt.Errorf("%w %d", ErrUnknownVersion, version)
+	}
+
+	return m.withLock(ctx, func(conn *sql.Conn) error {
+		tx, err := conn.BeginTx(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("failed to begin transaction: %w", err)
+		}
+		defer func() { _ = tx.Rollback() }()
+
+		if err := writeVersion(ctx, tx, version); err != nil {
+			return err
+		}
+		return tx.Commit()
+	})
+}
+
+// withLock runs fn on a dedicated connection holding the migration lock
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get connection: %w", err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", lockID); err != nil {
+		return fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+	defer func() {
+		// The lock is session scoped, so unlock even if ctx was cancelled
+		if _, err := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", lockID); err != nil {
+			m.logger.Warn("failed to release migration lock", "error", err)
+		}
+	}()
+
+	if _, err := conn.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version BIGINT NOT NULL PRIMARY KEY,
+			dirty BOOLEAN NOT NULL
+		)
+	`); err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	return fn(conn)
+}
+
+// cleanVersion reads the current version and refuses to continue if dirty
+func (m *Migrator) cleanVersion(ctx context.Context, conn *sql.Conn) (int, error) {
+	version, dirty, err := readVersion(ctx, conn)
+	if err != nil {
+		return 0, err
+	}
+	if dirty {
+		return 0, fmt.Errorf("%w at version %d; fix the schema and force a version", ErrDirty, version)
+	}
+	return version, nil
+}
+
+// apply runs one migration script and records the resulting version in the
+// same transaction, so a failed migration leaves no partial changes behind
+func (m *Migrator) apply(ctx context.Context, conn *sql.Conn, script string, version int) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return err
+	}
+	if err := writeVersion(ctx, tx, version); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// index returns the position of version in the migrations, or -1
+func (m *Migrator) index(version int) int {
+	return slices.IndexFunc(m.migrations, func(migration Migration) bool {
+		return migration.Version == version
+	})
+}
+
+// previous returns the version before the migration at idx, or 0
+func (m *Migrator) previous(idx int) int {
+	if idx <= 0 {
+		return 0
+	}
+	return m.migrations[idx-1].Version
+}
+
+type queryer interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func readVersion(ctx context.Context, q queryer) (int, bool, error) {
+	var exists bool
+	err := q.QueryRowContext(ctx, "SELECT to_regclass('schema_migrations') IS NOT NULL").Scan(&exists)
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to check schema_migrations: %w", err)
+	}
+	if !exists {
+		return 0, false, nil
+	}
+
+	var version int
+	var dirty bool
+	err = q.QueryRowContext(ctx, "SELECT version, dirty FROM schema_migrations LIMIT 1").Scan(&version, &dirty)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	return version, dirty, nil
+}
+
+func writeVersion(ctx context.Context, tx *sql.Tx, version int) error {
+	if _, err := tx.ExecContext(ctx, "DELETE FROM schema_migrations"); err != nil {
+		return fmt.Errorf("failed to clear schema version: %w", err)
+	}
+	if version <= 0 {
+		return nil
+	}
+	if _, err := tx.ExecContext(ctx,
+		"INSERT INTO schema_migrations (version, dirty) VALUES ($1, false)", version,
+	); err != nil {
+		return fmt.Errorf("failed to record schema version: %w", err)
+	}
+	return nil
+}
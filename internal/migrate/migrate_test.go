@@ -0,0 +1,125 @@
+package migrate
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/timkrebs/image-processor/migrations"
+)
+
+func TestLoad(t *testing.T) {
+	fsys := fstest.MapFS{
+		"002_add_b.up.sql":   {Data: []byte("CREATE TABLE b ();")},
+		"002_add_b.down.sql": {Data: []byte("DROP TABLE b;")},
+		"001_init.up.sql":    {Data: []byte("CREATE TABLE a ();")},
+		"010_no_down.up.sql": {Data: []byte("CREATE TABLE c ();")},
+		"README.md":          {Data: []byte("not a migration")},
+	}
+
+	list, err := Load(fsys)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	want := []struct {
+		version int
+		name    string
+		down    bool
+	}{
+		{1, "init", false},
+		{2, "add_b", true},
+		{10, "no_down", false},
+	}
+	if len(list) != len(want) {
+		t.Fatalf("Load() returned %d migrations, want %d", len(list), len(want))
+	}
+	for i, w := range want {
+		if list[i].Version != w.version || list[i].Name != w.name || (list[i].Down != "") != w.down {
+			t.Errorf("migration %d = {%d %q down=%v}, want {%d %q down=%v}",
+				i, list[i].Version, list[i].Name, list[i].Down != "", w.version, w.name, w.down)
+		}
+	}
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		fsys fstest.MapFS
+	}{
+		{
+			name: "down without up",
+			fsys: fstest.MapFS{"001_init.down.sql": {Data: []byte("DROP TABLE a;")}},
+		},
+		{
+			name: "duplicate version",
+			fsys: fstest.MapFS{
+				"001_init.up.sql":  {Data: []byte("CREATE TABLE a ();")},
+				"001_other.up.sql": {Data: []byte("CREATE TABLE b ();")},
+			},
+		},
+		{
+			name: "zero version",
+			fsys: fstest.MapFS{"000_init.up.sql": {Data: []byte("CREATE TABLE a ();")}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Load(tt.fsys); err == nil {
+				t.Error("Load() error = nil, want error")
+			}
+		})
+	}
+}
+
+func TestEmbeddedMigrations(t *testing.T) {
+	m, err := New(nil, nil)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	list := m.Migrations()
+	if len(list) == 0 {
+		t.Fatal("no embedded migrations")
+	}
+	for i, migration := range list {
+		if migration.Version != i+1 {
+			t.Errorf("migration %s has version %d, want %d", migration.Name, migration.Version, i+1)
+		}
+		if migration.Down == "" {
+			t.Errorf("migration %03d_%s has no down file", migration.Version, migration.Name)
+		}
+	}
+	if m.Latest() != list[len(list)-1].Version {
+		t.Errorf("Latest() = %d, want %d", m.Latest(), list[len(list)-1].Version)
+	}
+
+	if _, err := Load(migrations.FS); err != nil {
+		t.Errorf("Load(migrations.FS) error = %v", err)
+	}
+}
+
+func TestPrevious(t *testing.T) {
+	m := &Migrator{migrations: []Migration{{Version: 1}, {Version: 3}, {Version: 7}}}
+
+	tests := []struct {
+		idx  int
+		want int
+	}{
+		{-1, 0},
+		{0, 0},
+		{1, 1},
+		{2, 3},
+	}
+	for _, tt := range tests {
+		if got := m.previous(tt.idx); got != tt.want {
+			t.Errorf("previous(%d) = %d, want %d", tt.idx, got, tt.want)
+		}
+	}
+	if got := m.index(3); got != 1 {
+		t.Errorf("index(3) = %d, want 1", got)
+	}
+	if got := m.index(2); got != -1 {
+		t.Errorf("index(2) = %d, want -1", got)
+	}
+}
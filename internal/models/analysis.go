@@ -0,0 +1,120 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"math/bits"
+	"strconv"
+
+	"github.com/google/uuid"
+)
+
+// Image hash kinds that near-duplicates can be searched by
+const (
+	HashPHash = "phash"
+	HashDHash = "dhash"
+)
+
+// DefaultSimilarDistance is the Hamming distance up to which images count as near-duplicates
+const DefaultSimilarDistance = 10
+
+// ErrInvalidImageHash is returned for hashes that are not 16 hex digits
+var ErrInvalidImageHash = errors.New("invalid image hash (16 hex digits expected)")
+
+// ImageAnalysis holds the results of a job's analysis operations. Only the
+// analyses that were requested are set.
+type ImageAnalysis struct {
+	Histogram    *HistogramStats `json:"histogram,omitempty"`
+	PHash        string          `json:"phash,omitempty"`
+	DHash        string          `json:"dhash,omitempty"`
+	AverageColor string          `json:"average_color,omitempty"`
+	BlurHash     string          `json:"blurhash,omitempty"`
+	Palette      []PaletteColor  `json:"palette,omitempty"`
+}
+
+// PaletteColor is one dominant color of an image and the share of pixels closest to it
+type PaletteColor struct {
+	Color  string  `json:"color"`
+	Weight float64 `json:"weight"`
+}
+
+// HistogramStats summarizes the distribution of each channel's 0-255 values
+type HistogramStats struct {
+	Red   ChannelStats `json:"red"`
+	Green ChannelStats `json:"green"`
+	Blue  ChannelStats `json:"blue"`
+	Luma  ChannelStats `json:"luma"`
+}
+
+// ChannelStats summarizes the values of one channel
+type ChannelStats struct {
+	Mean   float64 `json:"mean"`
+	StdDev float64 `json:"std_dev"`
+	Min    int     `json:"min"`
+	Max    int     `json:"max"`
+	Median int     `json:"median"`
+}
+
+// Hash returns the analysis' hash of the given kind, or "" if it wasn't computed
+func (a *ImageAnalysis) Hash(kind string) string {
+	if a == nil {
+		return ""
+	}
+	switch kind {
+	case HashPHash:
+		return a.PHash
+	case HashDHash:
+		return a.DHash
+	default:
+		return ""
+	}
+}
+
+// FormatImageHash formats a 64-bit image hash as 16 hex digits
+func FormatImageHash(hash uint64) string {
+	return fmt.Sprintf("%016x", hash)
+}
+
+// ParseImageHash parses a hash formatted by FormatImageHash
+func ParseImageHash(s string) (uint64, error) {
+	if len(s) != 16 {
+		return 0, ErrInvalidImageHash
+	}
+	hash, err := strconv.ParseUint(s, 16, 64)
+	if err != nil {
+		return 0, ErrInvalidImageHash
+	}
+	return hash, nil
+}
+
+// HashDistance returns the number of bits in which two image hashes differ
+func HashDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// ParseHashKind validates an image hash kind, defaulting to pHash when empty
+func ParseHashKind(s string) (string, error) {
+	switch s {
+	case "":
+		return HashPHash, nil
+	case HashPHash, HashDHash:
+		return s, nil
+	default:
+		return "", fmt.Errorf("invalid hash: %s (must be %s or %s)", s, HashPHash, HashDHash)
+	}
+}
+
+// SimilarJob is a job whose image is a near-duplicate of another's
+type SimilarJob struct {
+	Job      *Job `json:"job"`
+	Distance int  `json:"distance"`
+}
+
+// SimilarJobsResponse lists the near-duplicates of a job's image, closest first
+type SimilarJobsResponse struct {
+	Hash        string        `json:"hash"`
+	Value       string        `json:"value"`
+	Matches     []*SimilarJob `json:"matches"`
+	MaxDistance int           `json:"max_distance"`
+	JobID       uuid.UUID     `json:"job_id"`
+}
@@ -0,0 +1,94 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestOperationType_IsAnalysis(t *testing.T) {
+	for _, op := range AnalysisOperations {
+		if !op.IsAnalysis() {
+			t.Errorf("%s.IsAnalysis() = false, want true", op)
+		}
+	}
+	if OperationResize.IsAnalysis() {
+		t.Error("resize.IsAnalysis() = true, want false")
+	}
+}
+
+func TestImageHash_RoundTrip(t *testing.T) {
+	for _, hash := range []uint64{0, 1, 0xfedcba9876543210, ^uint64(0)} {
+		s := FormatImageHash(hash)
+		if len(s) != 16 {
+			t.Errorf("FormatImageHash(%x) = %q, want 16 digits", hash, s)
+		}
+		got, err := ParseImageHash(s)
+		if err != nil || got != hash {
+			t.Errorf("ParseImageHash(%q) = %x, %v, want %x", s, got, err, hash)
+		}
+	}
+
+	for _, s := range []string{"", "abc", "zzzzzzzzzzzzzzzz", "0123456789abcdef0"} {
+		if _, err := ParseImageHash(s); err != ErrInvalidImageHash {
+			t.Errorf("ParseImageHash(%q) error = %v, want ErrInvalidImageHash", s, err)
+		}
+	}
+}
+
+func TestHashDistance(t *testing.T) {
+	tests := []struct {
+		a, b uint64
+		want int
+	}{
+		{0, 0, 0},
+		{0, 1, 1},
+		{0xff, 0x0f, 4},
+		{0, ^uint64(0), 64},
+	}
+	for _, tt := range tests {
+		if got := HashDistance(tt.a, tt.b); got != tt.want {
+			t.Errorf("HashDistance(%x, %x) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestParseHashKind(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{"", HashPHash, false},
+		{"phash", HashPHash, false},
+		{"dhash", HashDHash, false},
+		{"ahash", "", true},
+	}
+	for _, tt := range tests {
+		got, err := ParseHashKind(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseHashKind(%q) = %q, %v", tt.in, got, err)
+		}
+	}
+}
+
+func TestImageAnalysis_Hash(t *testing.T) {
+	var missing *ImageAnalysis
+	if missing.Hash(HashPHash) != "" {
+		t.Error("Hash() of nil analysis should be empty")
+	}
+
+	analysis := &ImageAnalysis{PHash: "00000000000000ff"}
+	if analysis.Hash(HashPHash) != "00000000000000ff" || analysis.Hash(HashDHash) != "" {
+		t.Errorf("Hash() = %q, %q", analysis.Hash(HashPHash), analysis.Hash(HashDHash))
+	}
+}
+
+func TestImageAnalysis_JSONOmitsMissing(t *testing.T) {
+	data, err := json.Marshal(&ImageAnalysis{AverageColor: "#ffffff"})
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	if string(data) != `{"average_color":"#ffffff"}` {
+		t.Errorf("Marshal() = %s", data)
+	}
+}
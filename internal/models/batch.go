@@ -0,0 +1,104 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// BatchStatus summarizes the state of all jobs in a batch
+type BatchStatus string
+
+const (
+	BatchStatusProcessing BatchStatus = "processing"
+	BatchStatusCompleted  BatchStatus = "completed"
+	BatchStatusPartial    BatchStatus = "partial"
+	BatchStatusFailed     BatchStatus = "failed"
+)
+
+// Batch groups jobs submitted together with one operation pipeline
+type Batch struct {
+	CreatedAt   time.Time         `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time         `json:"updated_at" db:"updated_at"`
+	AnonymousID *uuid.UUID        `json:"-" db:"anonymous_id"`
+	StatusCount map[JobStatus]int `json:"status_counts" db:"-"`
+	Status      BatchStatus       `json:"status" db:"-"`
+	Priority    JobPriority       `json:"priority" db:"priority"`
+	Operations  []Operation       `json:"operations" db:"operations"`
+	Items       []*BatchItem      `json:"items" db:"-"`
+	Total       int               `json:"total" db:"-"`
+	Progress    int               `json:"progress" db:"-"`
+	ID          uuid.UUID         `json:"id" db:"id"`
+	UserID      uuid.UUID         `json:"user_id" db:"user_id"`
+}
+
+// BatchItem reports the status of one file in a batch. Files rejected before
+// a job was created have no job ID and carry the error.
+type BatchItem struct {
+	JobID    *uuid.UUID `json:"job_id,omitempty"`
+	Name     string     `json:"name"`
+	Status   JobStatus  `json:"status"`
+	Error    string     `json:"error,omitempty"`
+	Progress int        `json:"progress"`
+}
+
+// NewBatch creates a new batch with the given pipeline
+func NewBatch(operations []Operation, priority JobPriority) *Batch {
+	now := time.Now()
+	return &Batch{
+		ID:         uuid.New(),
+		Priority:   priority,
+		Operations: operations,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+}
+
+// Summarize computes aggregate status and progress from the batch items
+func (b *Batch) Summarize() {
+	b.Total = len(b.Items)
+	b.StatusCount = make(map[JobStatus]int)
+	b.Progress = 0
+
+	if b.Total == 0 {
+		b.Status = BatchStatusCompleted
+		return
+	}
+
+	progress := 0
+	terminal := 0
+	for _, item := range b.Items {
+		b.StatusCount[item.Status]++
+		if item.Status.IsTerminal() {
+			terminal++
+			progress += 100
+		} else {
+			progress += item.Progress
+		}
+	}
+	b.Progress = progress / b.Total
+
+	completed := b.StatusCount[JobStatusCompleted]
+	switch {
+	case terminal < b.Total:
+		b.Status = BatchStatusProcessing
+	case completed == b.Total:
+		b.Status = BatchStatusCompleted
+	case completed == 0:
+		b.Status = BatchStatusFailed
+	default:
+		b.Status = BatchStatusPartial
+	}
+}
+
+// BatchItemFromJob builds the batch item for a job
+func BatchItemFromJob(job *Job) *BatchItem {
+	id := job.ID
+	return &BatchItem{
+		JobID:    &id,
+		Name:     job.OriginalName,
+		Status:   job.Status,
+		Progress: job.Progress,
+		Error:    job.Error,
+	}
+}
@@ -0,0 +1,92 @@
+package models
+
+import (
+	"testing"
+)
+
+func TestBatch_Summarize(t *testing.T) {
+	tests := []struct {
+		name         string
+		items        []*BatchItem
+		wantStatus   BatchStatus
+		wantProgress int
+	}{
+		{
+			name:         "empty",
+			items:        nil,
+			wantStatus:   BatchStatusCompleted,
+			wantProgress: 0,
+		},
+		{
+			name: "in progress",
+			items: []*BatchItem{
+				{Status: JobStatusCompleted},
+				{Status: JobStatusProcessing, Progress: 50},
+				{Status: JobStatusQueued},
+				{Status: JobStatusQueued},
+			},
+			wantStatus:   BatchStatusProcessing,
+			wantProgress: 37,
+		},
+		{
+			name: "all completed",
+			items: []*BatchItem{
+				{Status: JobStatusCompleted, Progress: 100},
+				{Status: JobStatusCompleted, Progress: 100},
+			},
+			wantStatus:   BatchStatusCompleted,
+			wantProgress: 100,
+		},
+		{
+			name: "some failed",
+			items: []*BatchItem{
+				{Status: JobStatusCompleted},
+				{Status: JobStatusFailed},
+				{Status: JobStatusCancelled},
+			},
+			wantStatus:   BatchStatusPartial,
+			wantProgress: 100,
+		},
+		{
+			name: "all failed",
+			items: []*BatchItem{
+				{Status: JobStatusFailed},
+				{Status: JobStatusFailed},
+			},
+			wantStatus:   BatchStatusFailed,
+			wantProgress: 100,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			batch := NewBatch(nil, PriorityBulk)
+			batch.Items = tt.items
+			batch.Summarize()
+
+			if batch.Status != tt.wantStatus {
+				t.Errorf("Status = %q, want %q", batch.Status, tt.wantStatus)
+			}
+			if batch.Progress != tt.wantProgress {
+				t.Errorf("Progress = %d, want %d", batch.Progress, tt.wantProgress)
+			}
+			if batch.Total != len(tt.items) {
+				t.Errorf("Total = %d, want %d", batch.Total, len(tt.items))
+			}
+		})
+	}
+}
+
+func TestBatchItemFromJob(t *testing.T) {
+	job := NewJob("key", "photo.jpg", "image/jpeg", 1024, nil)
+	job.Status = JobStatusFailed
+	job.Error = "decode failed"
+
+	item := BatchItemFromJob(job)
+	if item.JobID == nil || *item.JobID != job.ID {
+		t.Errorf("JobID = %v, want %v", item.JobID, job.ID)
+	}
+	if item.Name != "photo.jpg" || item.Status != JobStatusFailed || item.Error != "decode failed" {
+		t.Errorf("item = %+v", item)
+	}
+}
@@ -0,0 +1,84 @@
+package models
+
+import (
+	"errors"
+
+	"github.com/google/uuid"
+)
+
+// BulkAction is what a bulk request does to each selected job
+type BulkAction string
+
+const (
+	BulkActionCancel BulkAction = "cancel"
+	BulkActionDelete BulkAction = "delete"
+	BulkActionRetry  BulkAction = "retry"
+)
+
+// BulkResult is the outcome of a bulk action for one job
+type BulkResult string
+
+const (
+	BulkResultSucceeded BulkResult = "succeeded"
+	BulkResultSkipped   BulkResult = "skipped"
+	BulkResultNotFound  BulkResult = "not_found"
+	BulkResultFailed    BulkResult = "failed"
+)
+
+var (
+	// ErrInvalidBulkAction is returned for an unknown bulk action
+	ErrInvalidBulkAction = errors.New("action must be cancel, delete or retry")
+	// ErrInvalidBulkSelection is returned unless exactly one of IDs and Filter is set
+	ErrInvalidBulkSelection = errors.New("either ids or a non-empty filter is required")
+)
+
+// BulkJobRequest applies an action to the jobs listed by ID or matched by a
+// filter, which takes the query parameters of the job list
+type BulkJobRequest struct {
+	Filter map[string]string `json:"filter,omitempty"`
+	Action BulkAction        `json:"action"`
+	IDs    []uuid.UUID       `json:"ids,omitempty"`
+}
+
+// Validate checks the action and that jobs are selected in exactly one way
+func (r *BulkJobRequest) Validate() error {
+	switch r.Action {
+	case BulkActionCancel, BulkActionDelete, BulkActionRetry:
+	default:
+		return ErrInvalidBulkAction
+	}
+	if (len(r.IDs) > 0) == (len(r.Filter) > 0) {
+		return ErrInvalidBulkSelection
+	}
+	return nil
+}
+
+// BulkJobItem reports what a bulk action did to one job. Retries name the new job.
+type BulkJobItem struct {
+	NewJobID *uuid.UUID `json:"new_job_id,omitempty"`
+	Result   BulkResult `json:"result"`
+	Error    string     `json:"error,omitempty"`
+	ID       uuid.UUID  `json:"id"`
+}
+
+// BulkJobResponse reports the outcome of a bulk request per job
+type BulkJobResponse struct {
+	Action    BulkAction     `json:"action"`
+	Items     []*BulkJobItem `json:"items"`
+	Succeeded int            `json:"succeeded"`
+	Skipped   int            `json:"skipped"`
+	Failed    int            `json:"failed"`
+}
+
+// Add records the outcome for one job and updates the totals
+func (r *BulkJobResponse) Add(item *BulkJobItem) {
+	r.Items = append(r.Items, item)
+	switch item.Result {
+	case BulkResultSucceeded:
+		r.Succeeded++
+	case BulkResultSkipped:
+		r.Skipped++
+	default:
+		r.Failed++
+	}
+}
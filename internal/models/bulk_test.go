@@ -0,0 +1,50 @@
+package models
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestBulkJobRequest_Validate(t *testing.T) {
+	ids := []uuid.UUID{uuid.New()}
+	filter := map[string]string{"status": "failed"}
+
+	tests := []struct {
+		name    string
+		req     BulkJobRequest
+		wantErr error
+	}{
+		{"ids", BulkJobRequest{Action: BulkActionCancel, IDs: ids}, nil},
+		{"filter", BulkJobRequest{Action: BulkActionDelete, Filter: filter}, nil},
+		{"retry", BulkJobRequest{Action: BulkActionRetry, IDs: ids}, nil},
+		{"unknown action", BulkJobRequest{Action: "archive", IDs: ids}, ErrInvalidBulkAction},
+		{"missing action", BulkJobRequest{IDs: ids}, ErrInvalidBulkAction},
+		{"no selection", BulkJobRequest{Action: BulkActionCancel}, ErrInvalidBulkSelection},
+		{"empty filter", BulkJobRequest{Action: BulkActionCancel, Filter: map[string]string{}}, ErrInvalidBulkSelection},
+		{"ids and filter", BulkJobRequest{Action: BulkActionCancel, IDs: ids, Filter: filter}, ErrInvalidBulkSelection},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.req.Validate(); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Validate() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestBulkJobResponse_Add(t *testing.T) {
+	var resp BulkJobResponse
+	for _, result := range []BulkResult{BulkResultSucceeded, BulkResultSucceeded, BulkResultSkipped, BulkResultNotFound, BulkResultFailed} {
+		resp.Add(&BulkJobItem{ID: uuid.New(), Result: result})
+	}
+
+	if resp.Succeeded != 2 || resp.Skipped != 1 || resp.Failed != 2 {
+		t.Errorf("totals = %d/%d/%d, want 2/1/2", resp.Succeeded, resp.Skipped, resp.Failed)
+	}
+	if len(resp.Items) != 5 {
+		t.Errorf("len(Items) = %d, want 5", len(resp.Items))
+	}
+}
@@ -0,0 +1,69 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MaxFontSize bounds the size of uploaded font files
+const MaxFontSize = 5 << 20
+
+var (
+	ErrInvalidFontName = errors.New("invalid font name (1-100 characters, alphanumeric, space, _ . and - only)")
+	ErrFontTooLarge    = fmt.Errorf("font files can be at most %d bytes", MaxFontSize)
+)
+
+var fontNameRegex = regexp.MustCompile(`^[a-zA-Z0-9_.\-][a-zA-Z0-9_. \-]{0,99}$`)
+
+// Font is a TrueType or OpenType font a user uploaded. Text operations use it
+// by passing its ID as the font parameter.
+type Font struct {
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+	Name       string    `json:"name" db:"name"`
+	StorageKey string    `json:"-" db:"storage_key"`
+	Size       int64     `json:"size" db:"size"`
+	ID         uuid.UUID `json:"id" db:"id"`
+	UserID     uuid.UUID `json:"user_id" db:"user_id"`
+}
+
+// ValidateFontName checks that name is usable as a font's name
+func ValidateFontName(name string) error {
+	if !fontNameRegex.MatchString(name) {
+		return ErrInvalidFontName
+	}
+	return nil
+}
+
+// FontKey returns the storage key of a user's uploaded font
+func FontKey(userID, fontID uuid.UUID) string {
+	return fmt.Sprintf("users/%s/fonts/%s", userID.String(), fontID.String())
+}
+
+// FontListResponse lists the embedded fonts by name and the requester's uploaded fonts
+type FontListResponse struct {
+	Builtin []string `json:"builtin"`
+	Fonts   []*Font  `json:"fonts"`
+}
+
+// TextFontIDs returns the uploaded fonts text operations refer to, each once
+func TextFontIDs(operations []Operation) []uuid.UUID {
+	var ids []uuid.UUID
+	seen := make(map[uuid.UUID]bool)
+	for _, op := range operations {
+		if op.Operation != OperationText {
+			continue
+		}
+		name, _ := op.Parameters["font"].(string)
+		id, err := uuid.Parse(name)
+		if err != nil || seen[id] {
+			continue
+		}
+		seen[id] = true
+		ids = append(ids, id)
+	}
+	return ids
+}
@@ -0,0 +1,49 @@
+package models
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestValidateFontName(t *testing.T) {
+	for _, name := range []string{"Brand Sans", "brand-sans_v2.1"} {
+		if err := ValidateFontName(name); err != nil {
+			t.Errorf("ValidateFontName(%q) error = %v", name, err)
+		}
+	}
+	for _, name := range []string{"", " Brand", "brand/sans", strings.Repeat("a", 101)} {
+		if err := ValidateFontName(name); err != ErrInvalidFontName {
+			t.Errorf("ValidateFontName(%q) error = %v, want %v", name, err, ErrInvalidFontName)
+		}
+	}
+}
+
+func TestFontKey(t *testing.T) {
+	userID, fontID := uuid.New(), uuid.New()
+	want := "users/" + userID.String() + "/fonts/" + fontID.String()
+	if got := FontKey(userID, fontID); got != want {
+		t.Errorf("FontKey() = %s, want %s", got, want)
+	}
+}
+
+func TestTextFontIDs(t *testing.T) {
+	a, b := uuid.New(), uuid.New()
+	operations := []Operation{
+		{Operation: OperationText, Parameters: map[string]interface{}{"text": "hi", "font": a.String()}},
+		{Operation: OperationText, Parameters: map[string]interface{}{"text": "hi", "font": "go-bold"}},
+		{Operation: OperationText, Parameters: map[string]interface{}{"text": "hi"}},
+		{Operation: OperationText, Parameters: map[string]interface{}{"text": "hi", "font": a.String()}},
+		{Operation: OperationResize, Parameters: map[string]interface{}{"font": b.String()}},
+		{Operation: OperationText, Parameters: map[string]interface{}{"text": "hi", "font": b.String()}},
+	}
+
+	if got, want := TextFontIDs(operations), []uuid.UUID{a, b}; !reflect.DeepEqual(got, want) {
+		t.Errorf("TextFontIDs() = %v, want %v", got, want)
+	}
+	if got := TextFontIDs(nil); got != nil {
+		t.Errorf("TextFontIDs(nil) = %v, want nil", got)
+	}
+}
@@ -1,7 +1,11 @@
 package models
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"fmt"
+	"slices"
 	"time"
 
 	"github.com/google/uuid"
@@ -12,6 +16,7 @@ type JobStatus string
 
 const (
 	JobStatusPending    JobStatus = "pending"
+	JobStatusScheduled  JobStatus = "scheduled"
 	JobStatusQueued     JobStatus = "queued"
 	JobStatusProcessing JobStatus = "processing"
 	JobStatusCompleted  JobStatus = "completed"
@@ -19,6 +24,52 @@ const (
 	JobStatusCancelled  JobStatus = "canceled"
 )
 
+// JobStatuses lists every job status in lifecycle order
+var JobStatuses = []JobStatus{
+	JobStatusPending,
+	JobStatusScheduled,
+	JobStatusQueued,
+	JobStatusProcessing,
+	JobStatusCompleted,
+	JobStatusFailed,
+	JobStatusCancelled,
+}
+
+// IsValid reports whether s is a known job status
+func (s JobStatus) IsValid() bool {
+	return slices.Contains(JobStatuses, s)
+}
+
+// IsTerminal reports whether a job status will no longer change
+func (s JobStatus) IsTerminal() bool {
+	return s == JobStatusCompleted || s == JobStatusFailed || s == JobStatusCancelled
+}
+
+// JobPriority represents the scheduling tier a job is queued under
+type JobPriority string
+
+const (
+	PriorityInteractive JobPriority = "interactive"
+	PriorityDefault     JobPriority = "default"
+	PriorityBulk        JobPriority = "bulk"
+)
+
+// Priorities lists all job priorities from highest to lowest
+var Priorities = []JobPriority{PriorityInteractive, PriorityDefault, PriorityBulk}
+
+// ParsePriority converts a string to a JobPriority, defaulting to PriorityDefault when empty
+func ParsePriority(s string) (JobPriority, error) {
+	if s == "" {
+		return PriorityDefault, nil
+	}
+	for _, p := range Priorities {
+		if JobPriority(s) == p {
+			return p, nil
+		}
+	}
+	return "", fmt.Errorf("invalid priority: %s", s)
+}
+
 // OperationType represents the type of image processing operation
 type OperationType string
 
@@ -35,8 +86,43 @@ const (
 	OperationContrast   OperationType = "contrast"
 	OperationSaturation OperationType = "saturation"
 	OperationWatermark  OperationType = "watermark"
+	OperationText       OperationType = "text"
+
+	// OperationColorProfile chooses how ICC color profiles are handled for the whole job
+	OperationColorProfile OperationType = "color_profile"
+
+	// Canvas operations
+	OperationPad          OperationType = "pad"
+	OperationBorder       OperationType = "border"
+	OperationRoundCorners OperationType = "round_corners"
+	OperationBackground   OperationType = "background"
+	OperationFlatten      OperationType = "flatten"
+	OperationTrim         OperationType = "trim"
+
+	// Analysis operations record data about the image instead of changing it
+	OperationPHash        OperationType = "phash"
+	OperationDHash        OperationType = "dhash"
+	OperationPalette      OperationType = "palette"
+	OperationAverageColor OperationType = "average_color"
+	OperationBlurHash     OperationType = "blurhash"
+	OperationHistogram    OperationType = "histogram"
 )
 
+// AnalysisOperations lists the operations that analyze rather than change an image
+var AnalysisOperations = []OperationType{
+	OperationPHash,
+	OperationDHash,
+	OperationPalette,
+	OperationAverageColor,
+	OperationBlurHash,
+	OperationHistogram,
+}
+
+// IsAnalysis reports whether an operation records data instead of changing pixels
+func (t OperationType) IsAnalysis() bool {
+	return slices.Contains(AnalysisOperations, t)
+}
+
 // Operation represents a single image processing operation
 type Operation struct {
 	Parameters map[string]interface{} `json:"parameters,omitempty"`
@@ -45,25 +131,91 @@ type Operation struct {
 
 // Job represents an image processing job
 type Job struct {
-	CreatedAt      time.Time   `json:"created_at" db:"created_at"`
-	UpdatedAt      time.Time   `json:"updated_at" db:"updated_at"`
-	ProcessingTime *int64      `json:"processing_time_ms,omitempty" db:"processing_time_ms"`
-	StartedAt      *time.Time  `json:"started_at,omitempty" db:"started_at"`
-	CompletedAt    *time.Time  `json:"completed_at,omitempty" db:"completed_at"`
-	DeleteAt       *time.Time  `json:"delete_at,omitempty" db:"delete_at"`
-	OriginalName   string      `json:"original_name" db:"original_name"`
-	OriginalKey    string      `json:"original_key" db:"original_key"`
-	ContentType    string      `json:"content_type" db:"content_type"`
-	OperationsJSON string      `json:"-" db:"operations"`
-	Error          string      `json:"error,omitempty" db:"error"`
-	WorkerID       string      `json:"worker_id,omitempty" db:"worker_id"`
-	ProcessedKey   string      `json:"processed_key,omitempty" db:"processed_key"`
-	Status         JobStatus   `json:"status" db:"status"`
-	Operations     []Operation `json:"operations" db:"-"`
-	FileSize       int64       `json:"file_size" db:"file_size"`
-	Progress       int         `json:"progress" db:"progress"`
-	ID             uuid.UUID   `json:"id" db:"id"`
-	UserID         uuid.UUID   `json:"user_id" db:"user_id"`
+	CreatedAt         time.Time      `json:"created_at" db:"created_at"`
+	UpdatedAt         time.Time      `json:"updated_at" db:"updated_at"`
+	ProcessingTime    *int64         `json:"processing_time_ms,omitempty" db:"processing_time_ms"`
+	StartedAt         *time.Time     `json:"started_at,omitempty" db:"started_at"`
+	CompletedAt       *time.Time     `json:"completed_at,omitempty" db:"completed_at"`
+	DeleteAt          *time.Time     `json:"delete_at,omitempty" db:"delete_at"`
+	OriginalDeletedAt *time.Time     `json:"original_deleted_at,omitempty" db:"original_deleted_at"`
+	RunAt             *time.Time     `json:"run_at,omitempty" db:"run_at"`
+	AnonymousID       *uuid.UUID     `json:"-" db:"anonymous_id"`
+	BatchID           *uuid.UUID     `json:"batch_id,omitempty" db:"batch_id"`
+	ParentJobID       *uuid.UUID     `json:"parent_job_id,omitempty" db:"parent_job_id"`
+	PresetID          *uuid.UUID     `json:"preset_id,omitempty" db:"preset_id"`
+	PresetVersion     *int           `json:"preset_version,omitempty" db:"preset_version"`
+	OutputSize        *int64         `json:"output_size,omitempty" db:"output_size"`
+	CompressionRatio  *float64       `json:"compression_ratio,omitempty" db:"-"`
+	SSIM              *float64       `json:"ssim,omitempty" db:"ssim"`
+	PSNR              *float64       `json:"psnr,omitempty" db:"psnr"`
+	Analysis          *ImageAnalysis `json:"analysis,omitempty" db:"analysis"`
+	OriginalName      string         `json:"original_name" db:"original_name"`
+	OriginalKey       string         `json:"original_key" db:"original_key"`
+	OriginalSHA256    string         `json:"original_sha256,omitempty" db:"original_sha256"`
+	OperationsHash    string         `json:"-" db:"operations_sha256"`
+	ContentType       string         `json:"content_type" db:"content_type"`
+	OperationsJSON    string         `json:"-" db:"operations"`
+	Error             string         `json:"error,omitempty" db:"error"`
+	WorkerID          string         `json:"worker_id,omitempty" db:"worker_id"`
+	ProcessedKey      string         `json:"processed_key,omitempty" db:"processed_key"`
+	Status            JobStatus      `json:"status" db:"status"`
+	Priority          JobPriority    `json:"priority" db:"priority"`
+	Operations        []Operation    `json:"operations,omitempty" db:"-"`
+	FileSize          int64          `json:"file_size" db:"file_size"`
+	Progress          int            `json:"progress" db:"progress"`
+	DeleteOriginal    bool           `json:"delete_original,omitempty" db:"delete_original"`
+	ID                uuid.UUID      `json:"id" db:"id"`
+	UserID            uuid.UUID      `json:"user_id" db:"user_id"`
+}
+
+// DerivedPrefix is the storage prefix of images derived from the job's original on demand
+func (j *Job) DerivedPrefix() string {
+	return fmt.Sprintf("users/%s/derived/%s/", j.UserID.String(), j.ID.String())
+}
+
+// OutputQuality compares a processed image with its original, resized to match.
+// SSIM is 1 and PSNR (in dB) is capped at 100 for identical images.
+type OutputQuality struct {
+	SSIM float64 `json:"ssim"`
+	PSNR float64 `json:"psnr"`
+}
+
+// Quality returns the job's output quality, or nil if it wasn't measured
+func (j *Job) Quality() *OutputQuality {
+	if j.SSIM == nil || j.PSNR == nil {
+		return nil
+	}
+	return &OutputQuality{SSIM: *j.SSIM, PSNR: *j.PSNR}
+}
+
+// SetOutputSize records the size of the job's output and its compression ratio,
+// the original's size divided by the output's
+func (j *Job) SetOutputSize(size int64) {
+	j.OutputSize = &size
+	j.CompressionRatio = nil
+	if size > 0 {
+		ratio := float64(j.FileSize) / float64(size)
+		j.CompressionRatio = &ratio
+	}
+}
+
+// JobOwner identifies whose jobs a request may see: a user, or an anonymous
+// client owned by the system user
+type JobOwner struct {
+	AnonymousID *uuid.UUID
+	UserID      uuid.UUID
+}
+
+// Owns reports whether a resource submitted by the given user and anonymous
+// identity belongs to this owner
+func (o JobOwner) Owns(userID uuid.UUID, anonymousID *uuid.UUID) bool {
+	if userID != o.UserID {
+		return false
+	}
+	if o.AnonymousID == nil || anonymousID == nil {
+		return o.AnonymousID == nil && anonymousID == nil
+	}
+	return *o.AnonymousID == *anonymousID
 }
 
 // NewJob creates a new job with the given parameters
@@ -72,6 +224,7 @@ func NewJob(originalKey, originalName, contentType string, fileSize int64, opera
 	return &Job{
 		ID:           uuid.New(),
 		Status:       JobStatusPending,
+		Priority:     PriorityDefault,
 		OriginalKey:  originalKey,
 		OriginalName: originalName,
 		ContentType:  contentType,
@@ -83,6 +236,20 @@ func NewJob(originalKey, originalName, contentType string, fileSize int64, opera
 	}
 }
 
+// JobRetentionHours is how long finished jobs and their images are kept
+const JobRetentionHours = 1
+
+// OperationsDigest returns the SHA-256 of the canonical JSON encoding of operations.
+// Operations with the same digest produce the same output from the same original.
+func OperationsDigest(operations []Operation) (string, error) {
+	data, err := json.Marshal(operations)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
 // MarshalOperations serializes operations to JSON for database storage
 func (j *Job) MarshalOperations() error {
 	data, err := json.Marshal(j.Operations)
@@ -104,6 +271,7 @@ func (j *Job) UnmarshalOperations() error {
 
 // JobMessage represents a job message in the queue
 type JobMessage struct {
+	Priority   JobPriority `json:"priority,omitempty"`
 	Operations []Operation `json:"operations"`
 	JobID      uuid.UUID   `json:"job_id"`
 }
@@ -122,9 +290,29 @@ type JobListResponse struct {
 	TotalPages int    `json:"total_pages"`
 }
 
+// JobCursorResponse represents a page of jobs fetched with a cursor. The
+// cursors are opaque tokens for the pages after and before this one.
+type JobCursorResponse struct {
+	Jobs           []*Job `json:"jobs"`
+	PageSize       int    `json:"page_size"`
+	NextCursor     string `json:"next_cursor,omitempty"`
+	PrevCursor     string `json:"prev_cursor,omitempty"`
+	EstimatedTotal *int64 `json:"estimated_total,omitempty"`
+}
+
 // QueueStats represents queue statistics
 type QueueStats struct {
-	StreamLength    int64 `json:"stream_length"`
-	PendingMessages int64 `json:"pending_messages"`
-	ConsumerCount   int64 `json:"consumer_count"`
+	Priorities      map[JobPriority]*PriorityStats `json:"priorities,omitempty"`
+	StreamLength    int64                          `json:"stream_length"`
+	PendingMessages int64                          `json:"pending_messages"`
+	ConsumerCount   int64                          `json:"consumer_count"`
+	ScheduledJobs   int64                          `json:"scheduled_jobs"`
+	DeadLetters     int64                          `json:"dead_letters"`
+}
+
+// PriorityStats represents statistics for a single priority stream
+type PriorityStats struct {
+	Stream          string `json:"stream"`
+	StreamLength    int64  `json:"stream_length"`
+	PendingMessages int64  `json:"pending_messages"`
 }
@@ -14,6 +14,7 @@ func TestJobStatus_Constants(t *testing.T) {
 		want   string
 	}{
 		{JobStatusPending, "pending"},
+		{JobStatusScheduled, "scheduled"},
 		{JobStatusQueued, "queued"},
 		{JobStatusProcessing, "processing"},
 		{JobStatusCompleted, "completed"},
@@ -45,6 +46,20 @@ func TestOperationType_Constants(t *testing.T) {
 		{OperationContrast, "contrast"},
 		{OperationSaturation, "saturation"},
 		{OperationWatermark, "watermark"},
+		{OperationText, "text"},
+		{OperationColorProfile, "color_profile"},
+		{OperationPad, "pad"},
+		{OperationBorder, "border"},
+		{OperationRoundCorners, "round_corners"},
+		{OperationBackground, "background"},
+		{OperationFlatten, "flatten"},
+		{OperationTrim, "trim"},
+		{OperationPHash, "phash"},
+		{OperationDHash, "dhash"},
+		{OperationPalette, "palette"},
+		{OperationAverageColor, "average_color"},
+		{OperationBlurHash, "blurhash"},
+		{OperationHistogram, "histogram"},
 	}
 
 	for _, tt := range tests {
@@ -54,6 +69,33 @@ func TestOperationType_Constants(t *testing.T) {
 	}
 }
 
+func TestParsePriority(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    JobPriority
+		wantErr bool
+	}{
+		{"", PriorityDefault, false},
+		{"default", PriorityDefault, false},
+		{"interactive", PriorityInteractive, false},
+		{"bulk", PriorityBulk, false},
+		{"urgent", "", true},
+		{"BULK", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParsePriority(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParsePriority(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParsePriority(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
 func TestNewJob(t *testing.T) {
 	originalKey := "original/test/image.jpg"
 	originalName := "image.jpg"
@@ -77,6 +119,9 @@ func TestNewJob(t *testing.T) {
 	if job.Status != JobStatusPending {
 		t.Errorf("Status = %q, want %q", job.Status, JobStatusPending)
 	}
+	if job.Priority != PriorityDefault {
+		t.Errorf("Priority = %q, want %q", job.Priority, PriorityDefault)
+	}
 
 	// Verify fields are set correctly
 	if job.OriginalKey != originalKey {
@@ -499,3 +544,94 @@ func TestOperation_NoParameters(t *testing.T) {
 		t.Error("Parameters should be omitted when nil/empty")
 	}
 }
+
+func TestOperationsDigest(t *testing.T) {
+	digest := func(ops []Operation) string {
+		t.Helper()
+		d, err := OperationsDigest(ops)
+		if err != nil {
+			t.Fatalf("OperationsDigest() error = %v", err)
+		}
+		return d
+	}
+
+	// Parameters decoded from JSON are float64, defaults built in code may be int
+	fromJSON := []Operation{{Operation: OperationResize, Parameters: map[string]interface{}{"height": float64(50), "width": float64(100)}}}
+	fromCode := []Operation{{Operation: OperationResize, Parameters: map[string]interface{}{"width": 100, "height": 50}}}
+	if digest(fromJSON) != digest(fromCode) {
+		t.Error("equal operations should have equal digests")
+	}
+
+	if digest([]Operation{{Operation: OperationGrayscale, Parameters: map[string]interface{}{}}}) !=
+		digest([]Operation{{Operation: OperationGrayscale}}) {
+		t.Error("empty parameters should not change the digest")
+	}
+
+	reordered := []Operation{{Operation: OperationGrayscale}, {Operation: OperationBlur}}
+	if digest(reordered) == digest([]Operation{{Operation: OperationBlur}, {Operation: OperationGrayscale}}) {
+		t.Error("operation order changes the output, so it must change the digest")
+	}
+}
+
+func TestJobOwner_Owns(t *testing.T) {
+	userID := uuid.New()
+	anonID := uuid.New()
+	otherAnon := uuid.New()
+
+	user := JobOwner{UserID: userID}
+	anon := JobOwner{UserID: userID, AnonymousID: &anonID}
+
+	tests := []struct {
+		name        string
+		owner       JobOwner
+		userID      uuid.UUID
+		anonymousID *uuid.UUID
+		want        bool
+	}{
+		{"same user", user, userID, nil, true},
+		{"other user", user, uuid.New(), nil, false},
+		{"user vs anonymous resource", user, userID, &anonID, false},
+		{"same anonymous client", anon, userID, &anonID, true},
+		{"other anonymous client", anon, userID, &otherAnon, false},
+		{"anonymous vs user resource", anon, userID, nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.owner.Owns(tt.userID, tt.anonymousID); got != tt.want {
+				t.Errorf("Owns() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJob_SetOutputSize(t *testing.T) {
+	job := &Job{FileSize: 1000}
+
+	job.SetOutputSize(250)
+	if job.OutputSize == nil || *job.OutputSize != 250 {
+		t.Fatalf("OutputSize = %v, want 250", job.OutputSize)
+	}
+	if job.CompressionRatio == nil || *job.CompressionRatio != 4 {
+		t.Errorf("CompressionRatio = %v, want 4", job.CompressionRatio)
+	}
+
+	job.SetOutputSize(0)
+	if job.CompressionRatio != nil {
+		t.Errorf("CompressionRatio = %v, want nil for an empty output", *job.CompressionRatio)
+	}
+}
+
+func TestJob_Quality(t *testing.T) {
+	job := &Job{}
+	if job.Quality() != nil {
+		t.Error("Quality() should be nil when not measured")
+	}
+
+	ssim, psnr := 0.95, 38.5
+	job.SSIM, job.PSNR = &ssim, &psnr
+	quality := job.Quality()
+	if quality == nil || quality.SSIM != ssim || quality.PSNR != psnr {
+		t.Errorf("Quality() = %+v, want {%v %v}", quality, ssim, psnr)
+	}
+}
@@ -0,0 +1,132 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MaxPresetOperations bounds the length of a preset's operation pipeline
+const MaxPresetOperations = 20
+
+var (
+	ErrInvalidPresetName    = errors.New("invalid preset name (1-100 characters, alphanumeric, space, _ . and - only)")
+	ErrEmptyPreset          = errors.New("presets need at least one operation")
+	ErrTooManyPresetSteps   = fmt.Errorf("presets can have at most %d operations", MaxPresetOperations)
+	ErrPresetDescriptionLen = errors.New("preset description must be at most 500 characters")
+)
+
+var presetNameRegex = regexp.MustCompile(`^[a-zA-Z0-9_.\-][a-zA-Z0-9_. \-]{0,99}$`)
+
+// Preset is a named operation pipeline saved by a user. Shared presets can be
+// used by everyone; only the owner can change them. Each change to the
+// operations creates a new version, and older versions stay usable.
+type Preset struct {
+	CreatedAt   time.Time   `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time   `json:"updated_at" db:"updated_at"`
+	Name        string      `json:"name" db:"name"`
+	Description string      `json:"description,omitempty" db:"description"`
+	Operations  []Operation `json:"operations" db:"operations"`
+	Version     int         `json:"version" db:"version"`
+	Shared      bool        `json:"shared" db:"shared"`
+	ID          uuid.UUID   `json:"id" db:"id"`
+	UserID      uuid.UUID   `json:"user_id" db:"user_id"`
+}
+
+// VisibleTo reports whether a user may see and use the preset
+func (p *Preset) VisibleTo(userID uuid.UUID) bool {
+	return p.Shared || p.UserID == userID
+}
+
+// PresetVersion is the operation pipeline of a preset at one version
+type PresetVersion struct {
+	CreatedAt  time.Time   `json:"created_at" db:"created_at"`
+	Operations []Operation `json:"operations" db:"operations"`
+	Version    int         `json:"version" db:"version"`
+}
+
+// PresetRequest is the request body for creating or replacing a preset. On
+// update, a non-zero Version must match the current one, so concurrent edits
+// aren't lost.
+type PresetRequest struct {
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	Operations  []Operation `json:"operations"`
+	Version     int         `json:"version,omitempty"`
+	Shared      bool        `json:"shared"`
+}
+
+// Validate checks the name, description and pipeline length. Operation types
+// are checked by the API, which knows the supported ones.
+func (r *PresetRequest) Validate() error {
+	if !presetNameRegex.MatchString(r.Name) {
+		return ErrInvalidPresetName
+	}
+	if len(r.Description) > 500 {
+		return ErrPresetDescriptionLen
+	}
+	if len(r.Operations) == 0 {
+		return ErrEmptyPreset
+	}
+	if len(r.Operations) > MaxPresetOperations {
+		return ErrTooManyPresetSteps
+	}
+	return nil
+}
+
+// ApplyOverrides returns a copy of operations with parameters replaced per
+// step. Overrides are keyed by operation type, applying to every step of that
+// type, or by zero-based step index; index overrides are applied last.
+func ApplyOverrides(operations []Operation, overrides map[string]map[string]interface{}) ([]Operation, error) {
+	result := make([]Operation, len(operations))
+	for i, op := range operations {
+		result[i] = Operation{Operation: op.Operation, Parameters: maps.Clone(op.Parameters)}
+	}
+
+	indexed := make(map[int]map[string]interface{})
+	for key, params := range overrides {
+		if index, err := strconv.Atoi(key); err == nil {
+			if index < 0 || index >= len(result) {
+				return nil, fmt.Errorf("override for step %d, but the preset has %d steps", index, len(result))
+			}
+			indexed[index] = params
+			continue
+		}
+
+		matched := false
+		for i := range result {
+			if string(result[i].Operation) == key {
+				result[i].Parameters = mergeParameters(result[i].Parameters, params)
+				matched = true
+			}
+		}
+		if !matched {
+			return nil, fmt.Errorf("override for %s, but the preset has no such step", key)
+		}
+	}
+	for index, params := range indexed {
+		result[index].Parameters = mergeParameters(result[index].Parameters, params)
+	}
+
+	return result, nil
+}
+
+// mergeParameters sets the overridden parameters; null values remove a parameter
+func mergeParameters(params, overrides map[string]interface{}) map[string]interface{} {
+	if params == nil {
+		params = make(map[string]interface{}, len(overrides))
+	}
+	for name, value := range overrides {
+		if value == nil {
+			delete(params, name)
+		} else {
+			params[name] = value
+		}
+	}
+	return params
+}
@@ -0,0 +1,95 @@
+package models
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestPresetRequest_Validate(t *testing.T) {
+	ops := []Operation{{Operation: OperationGrayscale}}
+
+	tests := []struct {
+		name    string
+		req     PresetRequest
+		wantErr error
+	}{
+		{"valid", PresetRequest{Name: "web thumbs-v2.1", Operations: ops}, nil},
+		{"empty name", PresetRequest{Operations: ops}, ErrInvalidPresetName},
+		{"leading space", PresetRequest{Name: " thumbs", Operations: ops}, ErrInvalidPresetName},
+		{"invalid character", PresetRequest{Name: "thumbs/web", Operations: ops}, ErrInvalidPresetName},
+		{"long name", PresetRequest{Name: strings.Repeat("a", 101), Operations: ops}, ErrInvalidPresetName},
+		{"long description", PresetRequest{Name: "thumbs", Description: strings.Repeat("a", 501), Operations: ops}, ErrPresetDescriptionLen},
+		{"no operations", PresetRequest{Name: "thumbs"}, ErrEmptyPreset},
+		{"too many operations", PresetRequest{Name: "thumbs", Operations: make([]Operation, MaxPresetOperations+1)}, ErrTooManyPresetSteps},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.req.Validate(); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Validate() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPreset_VisibleTo(t *testing.T) {
+	owner, other := uuid.New(), uuid.New()
+
+	private := &Preset{UserID: owner}
+	if !private.VisibleTo(owner) || private.VisibleTo(other) {
+		t.Error("private preset should be visible to its owner only")
+	}
+
+	shared := &Preset{UserID: owner, Shared: true}
+	if !shared.VisibleTo(other) {
+		t.Error("shared preset should be visible to everyone")
+	}
+}
+
+func TestApplyOverrides(t *testing.T) {
+	operations := []Operation{
+		{Operation: OperationResize, Parameters: map[string]interface{}{"width": 800.0, "height": 600.0}},
+		{Operation: OperationSharpen, Parameters: map[string]interface{}{"sigma": 1.0}},
+		{Operation: OperationResize, Parameters: map[string]interface{}{"width": 400.0}},
+		{Operation: OperationGrayscale},
+	}
+
+	result, err := ApplyOverrides(operations, map[string]map[string]interface{}{
+		"resize":  {"width": 1200.0, "height": nil},
+		"2":       {"width": 300.0},
+		"3":       {"amount": 1.0},
+		"sharpen": {"sigma": 2.0},
+	})
+	if err != nil {
+		t.Fatalf("ApplyOverrides() error = %v", err)
+	}
+
+	want := []Operation{
+		{Operation: OperationResize, Parameters: map[string]interface{}{"width": 1200.0}},
+		{Operation: OperationSharpen, Parameters: map[string]interface{}{"sigma": 2.0}},
+		{Operation: OperationResize, Parameters: map[string]interface{}{"width": 300.0}},
+		{Operation: OperationGrayscale, Parameters: map[string]interface{}{"amount": 1.0}},
+	}
+	if !reflect.DeepEqual(result, want) {
+		t.Errorf("ApplyOverrides() = %v, want %v", result, want)
+	}
+
+	// The preset's own operations are left unchanged
+	if operations[0].Parameters["width"] != 800.0 || operations[0].Parameters["height"] != 600.0 {
+		t.Errorf("ApplyOverrides() modified its input: %v", operations[0].Parameters)
+	}
+}
+
+func TestApplyOverrides_NoMatchingStep(t *testing.T) {
+	operations := []Operation{{Operation: OperationGrayscale}}
+
+	for _, key := range []string{"blur", "1", "-1"} {
+		if _, err := ApplyOverrides(operations, map[string]map[string]interface{}{key: {"sigma": 1.0}}); err == nil {
+			t.Errorf("ApplyOverrides(%q) expected an error", key)
+		}
+	}
+}
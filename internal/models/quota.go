@@ -0,0 +1,72 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// Quota names reported when a limit is exceeded
+const (
+	QuotaJobsPerDay  = "jobs_per_day"
+	QuotaBytesStored = "bytes_stored"
+	QuotaActiveJobs  = "active_jobs"
+)
+
+// QuotaLimits holds the job submission limits for a user. Zero means unlimited.
+type QuotaLimits struct {
+	JobsPerDay  int   `json:"jobs_per_day"`
+	BytesStored int64 `json:"bytes_stored"`
+	ActiveJobs  int   `json:"active_jobs"`
+}
+
+// QuotaUsage holds a user's current consumption of their quota
+type QuotaUsage struct {
+	JobsToday   int   `json:"jobs_today"`
+	BytesStored int64 `json:"bytes_stored"`
+	ActiveJobs  int   `json:"active_jobs"`
+}
+
+// UsageResponse is returned by the usage endpoint
+type UsageResponse struct {
+	Limits    QuotaLimits `json:"limits"`
+	Usage     QuotaUsage  `json:"usage"`
+	ResetsAt  time.Time   `json:"resets_at"`
+	RateLimit *RateLimit  `json:"rate_limit,omitempty"`
+}
+
+// RateLimit describes the request rate limit applied to job submission
+type RateLimit struct {
+	RequestsPerMinute int `json:"requests_per_minute"`
+	Burst             int `json:"burst"`
+}
+
+// QuotaExceededError is returned when a submission would exceed a quota
+type QuotaExceededError struct {
+	Quota string
+	Limit int64
+	Used  int64
+}
+
+func (e *QuotaExceededError) Error() string {
+	return fmt.Sprintf("quota exceeded: %s (used %d of %d)", e.Quota, e.Used, e.Limit)
+}
+
+// Check reports whether the given number of new jobs with uploads totaling
+// uploadSize bytes fit within the limits
+func (l QuotaLimits) Check(usage QuotaUsage, jobs int, uploadSize int64) error {
+	if l.JobsPerDay > 0 && usage.JobsToday+jobs > l.JobsPerDay {
+		return &QuotaExceededError{Quota: QuotaJobsPerDay, Limit: int64(l.JobsPerDay), Used: int64(usage.JobsToday)}
+	}
+	if l.ActiveJobs > 0 && usage.ActiveJobs+jobs > l.ActiveJobs {
+		return &QuotaExceededError{Quota: QuotaActiveJobs, Limit: int64(l.ActiveJobs), Used: int64(usage.ActiveJobs)}
+	}
+	if l.BytesStored > 0 && usage.BytesStored+uploadSize > l.BytesStored {
+		return &QuotaExceededError{Quota: QuotaBytesStored, Limit: l.BytesStored, Used: usage.BytesStored}
+	}
+	return nil
+}
+
+// QuotaDayStart returns the start of the UTC day that daily quotas are counted from
+func QuotaDayStart(now time.Time) time.Time {
+	return now.UTC().Truncate(24 * time.Hour)
+}
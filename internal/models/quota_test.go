@@ -0,0 +1,58 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestQuotaLimits_Check(t *testing.T) {
+	limits := QuotaLimits{JobsPerDay: 10, BytesStored: 1000, ActiveJobs: 2}
+
+	tests := []struct {
+		name       string
+		limits     QuotaLimits
+		usage      QuotaUsage
+		jobs       int
+		uploadSize int64
+		wantQuota  string
+	}{
+		{"within limits", limits, QuotaUsage{JobsToday: 9, BytesStored: 500, ActiveJobs: 1}, 1, 500, ""},
+		{"daily jobs exhausted", limits, QuotaUsage{JobsToday: 10}, 1, 1, QuotaJobsPerDay},
+		{"batch exceeds daily jobs", limits, QuotaUsage{JobsToday: 5}, 6, 1, QuotaJobsPerDay},
+		{"active jobs exhausted", limits, QuotaUsage{ActiveJobs: 2}, 1, 1, QuotaActiveJobs},
+		{"upload exceeds storage", limits, QuotaUsage{BytesStored: 900}, 1, 101, QuotaBytesStored},
+		{"unlimited", QuotaLimits{}, QuotaUsage{JobsToday: 1 << 20, BytesStored: 1 << 40, ActiveJobs: 1 << 20}, 100, 1 << 30, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.limits.Check(tt.usage, tt.jobs, tt.uploadSize)
+			if tt.wantQuota == "" {
+				if err != nil {
+					t.Errorf("Check() error = %v, want nil", err)
+				}
+				return
+			}
+
+			var exceeded *QuotaExceededError
+			if !errors.As(err, &exceeded) {
+				t.Fatalf("Check() error = %v, want *QuotaExceededError", err)
+			}
+			if exceeded.Quota != tt.wantQuota {
+				t.Errorf("Quota = %q, want %q", exceeded.Quota, tt.wantQuota)
+			}
+		})
+	}
+}
+
+func TestQuotaDayStart(t *testing.T) {
+	loc := time.FixedZone("UTC+2", 2*60*60)
+	now := time.Date(2024, 3, 5, 1, 30, 0, 0, loc)
+
+	got := QuotaDayStart(now)
+	want := time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC)
+	if !got.Equal(want) {
+		t.Errorf("QuotaDayStart() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,54 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UploadStatus represents the state of a direct-to-storage upload
+type UploadStatus string
+
+const (
+	UploadStatusPending UploadStatus = "pending"
+	UploadStatusClaimed UploadStatus = "claimed"
+)
+
+// Upload tracks an object uploaded directly to storage before a job claims it.
+// The upload ID becomes the ID of the job created from it.
+type Upload struct {
+	ExpiresAt   time.Time    `json:"expires_at" db:"expires_at"`
+	CreatedAt   time.Time    `json:"created_at" db:"created_at"`
+	CompletedAt *time.Time   `json:"completed_at,omitempty" db:"completed_at"`
+	AnonymousID *uuid.UUID   `json:"-" db:"anonymous_id"`
+	ObjectKey   string       `json:"-" db:"object_key"`
+	MultipartID string       `json:"-" db:"multipart_id"`
+	Filename    string       `json:"filename" db:"filename"`
+	ContentType string       `json:"content_type" db:"content_type"`
+	Status      UploadStatus `json:"status" db:"status"`
+	Size        int64        `json:"size" db:"size"`
+	ID          uuid.UUID    `json:"id" db:"id"`
+	UserID      uuid.UUID    `json:"user_id" db:"user_id"`
+}
+
+// IncompleteKey is the storage key buffering the tail of a resumable upload
+// that is too small to become a multipart part yet
+func (u *Upload) IncompleteKey() string {
+	return "uploads/incomplete/" + u.ID.String()
+}
+
+// CreateUploadRequest is the request body for starting an upload
+type CreateUploadRequest struct {
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size"`
+}
+
+// CreateUploadResponse tells the client where and how to PUT the file
+type CreateUploadResponse struct {
+	ExpiresAt time.Time         `json:"expires_at"`
+	Headers   map[string]string `json:"headers"`
+	URL       string            `json:"url"`
+	Method    string            `json:"method"`
+	UploadID  uuid.UUID         `json:"upload_id"`
+}
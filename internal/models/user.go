@@ -14,6 +14,7 @@ var (
 	ErrInvalidUsername  = errors.New("invalid username (3-100 characters, alphanumeric and _ only)")
 	ErrPasswordTooShort = errors.New("password must be at least 8 characters")
 	ErrInvalidPassword  = errors.New("invalid password")
+	ErrUserDisabled     = errors.New("account disabled")
 )
 
 var (
@@ -32,6 +33,9 @@ type User struct {
 	CreatedAt    time.Time  `json:"created_at"`
 	UpdatedAt    time.Time  `json:"updated_at"`
 	LastLoginAt  *time.Time `json:"last_login_at,omitempty"`
+	DisabledAt   *time.Time `json:"disabled_at,omitempty"`
+	// SessionEpoch changes whenever the user's sessions are revoked
+	SessionEpoch int `json:"-"`
 }
 
 // CreateUserRequest represents the data needed to create a new user
@@ -59,11 +63,14 @@ func (r *CreateUserRequest) Validate() error {
 		return ErrInvalidUsername
 	}
 
-	// Validate password
-	if len(r.Password) < 8 {
+	return ValidatePassword(r.Password)
+}
+
+// ValidatePassword checks that a new password meets the minimum requirements
+func ValidatePassword(password string) error {
+	if len(password) < 8 {
 		return ErrPasswordTooShort
 	}
-
 	return nil
 }
 
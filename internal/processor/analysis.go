@@ -0,0 +1,401 @@
+package processor
+
+import (
+	"cmp"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/disintegration/imaging"
+
+	"github.com/timkrebs/image-processor/internal/models"
+)
+
+const (
+	// analysisMaxDimension bounds the image palettes, blurhashes and histograms are computed from
+	analysisMaxDimension = 128
+	// paletteIterations bounds the k-means refinement of a palette
+	paletteIterations = 12
+	// Default and maximum number of palette colors
+	defaultPaletteSize = 5
+	maxPaletteSize     = 16
+	// Default and maximum blurhash components per axis
+	defaultBlurHashX = 4
+	defaultBlurHashY = 3
+	maxBlurHash      = 9
+)
+
+// analyze runs an analysis operation on img and records the result in analysis
+func (p *Processor) analyze(img *image.NRGBA, op models.Operation, analysis *models.ImageAnalysis) error {
+	switch op.Operation {
+	case models.OperationPHash:
+		analysis.PHash = models.FormatImageHash(pHash(img))
+	case models.OperationDHash:
+		analysis.DHash = models.FormatImageHash(dHash(img))
+	case models.OperationAverageColor:
+		analysis.AverageColor = hexColor(averageColor(img))
+	case models.OperationPalette:
+		size := p.getIntParam(op.Parameters, "colors", defaultPaletteSize)
+		if size < 1 || size > maxPaletteSize {
+			return fmt.Errorf("colors must be between 1 and %d", maxPaletteSize)
+		}
+		analysis.Palette = palette(analysisSample(img), size)
+	case models.OperationBlurHash:
+		x := p.getIntParam(op.Parameters, "x_components", defaultBlurHashX)
+		y := p.getIntParam(op.Parameters, "y_components", defaultBlurHashY)
+		if x < 1 || x > maxBlurHash || y < 1 || y > maxBlurHash {
+			return fmt.Errorf("x_components and y_components must be between 1 and %d", maxBlurHash)
+		}
+		analysis.BlurHash = blurHash(analysisSample(img), x, y)
+	case models.OperationHistogram:
+		analysis.Histogram = histogramStats(analysisSample(img))
+	default:
+		return fmt.Errorf("unknown analysis: %s", op.Operation)
+	}
+	return nil
+}
+
+// analysisSample returns img, downscaled if it is larger than needed for analysis
+func analysisSample(img *image.NRGBA) *image.NRGBA {
+	bounds := img.Bounds()
+	if bounds.Dx() <= analysisMaxDimension && bounds.Dy() <= analysisMaxDimension {
+		return img
+	}
+	return imaging.Fit(img, analysisMaxDimension, analysisMaxDimension, imaging.Box)
+}
+
+// grayPixels returns the luma of img scaled to width x height, row by row
+func grayPixels(img *image.NRGBA, width, height int) []float64 {
+	small := imaging.Resize(imaging.Grayscale(flatten(img)), width, height, imaging.Box)
+	values := make([]float64, width*height)
+	for i := range values {
+		values[i] = float64(small.Pix[i*4])
+	}
+	return values
+}
+
+// dHash returns the difference hash of img: whether each pixel of a 9x8
+// grayscale thumbnail is brighter than its right neighbor
+func dHash(img *image.NRGBA) uint64 {
+	gray := grayPixels(img, 9, 8)
+
+	var hash uint64
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			hash <<= 1
+			if gray[y*9+x] > gray[y*9+x+1] {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
+
+// pHash returns the perceptual hash of img: whether each of the 8x8 lowest
+// frequencies of the DCT of a 32x32 grayscale thumbnail is above their median
+func pHash(img *image.NRGBA) uint64 {
+	const size, low = 32, 8
+	gray := grayPixels(img, size, size)
+
+	// Separable 2D DCT-II, only the low frequencies are needed
+	var cosines [low][size]float64
+	for u := 0; u < low; u++ {
+		for x := 0; x < size; x++ {
+			cosines[u][x] = math.Cos(float64(2*x+1) * float64(u) * math.Pi / (2 * size))
+		}
+	}
+	var rows [size][low]float64
+	for y := 0; y < size; y++ {
+		for u := 0; u < low; u++ {
+			var sum float64
+			for x := 0; x < size; x++ {
+				sum += gray[y*size+x] * cosines[u][x]
+			}
+			rows[y][u] = sum
+		}
+	}
+	coefficients := make([]float64, 0, low*low)
+	for v := 0; v < low; v++ {
+		for u := 0; u < low; u++ {
+			var sum float64
+			for y := 0; y < size; y++ {
+				sum += rows[y][u] * cosines[v][y]
+			}
+			coefficients = append(coefficients, sum)
+		}
+	}
+
+	// The DC coefficient is the mean brightness and would skew the median
+	sorted := slices.Clone(coefficients[1:])
+	slices.Sort(sorted)
+	median := (sorted[len(sorted)/2-1] + sorted[len(sorted)/2]) / 2
+
+	var hash uint64
+	for _, c := range coefficients {
+		hash <<= 1
+		if c > median {
+			hash |= 1
+		}
+	}
+	return hash
+}
+
+// averageColor returns the mean color of img, weighting pixels by opacity
+func averageColor(img *image.NRGBA) color.NRGBA {
+	var r, g, b, weight float64
+	for i := 0; i < len(img.Pix); i += 4 {
+		a := float64(img.Pix[i+3])
+		r += float64(img.Pix[i]) * a
+		g += float64(img.Pix[i+1]) * a
+		b += float64(img.Pix[i+2]) * a
+		weight += a
+	}
+	if weight == 0 {
+		return color.NRGBA{}
+	}
+	return color.NRGBA{
+		R: uint8(math.Round(r / weight)),
+		G: uint8(math.Round(g / weight)),
+		B: uint8(math.Round(b / weight)),
+		A: 255,
+	}
+}
+
+// hexColor formats a color as #rrggbb
+func hexColor(c color.NRGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+// palette returns up to size dominant colors of img by k-means clustering of
+// its opaque pixels, most common first. Clusters start at evenly spaced
+// brightness quantiles, so the result is deterministic.
+func palette(img *image.NRGBA, size int) []models.PaletteColor {
+	var pixels [][3]float64
+	for i := 0; i < len(img.Pix); i += 4 {
+		if img.Pix[i+3] < 128 {
+			continue
+		}
+		pixels = append(pixels, [3]float64{float64(img.Pix[i]), float64(img.Pix[i+1]), float64(img.Pix[i+2])})
+	}
+	if len(pixels) == 0 {
+		return nil
+	}
+	size = min(size, len(pixels))
+
+	sorted := slices.Clone(pixels)
+	slices.SortFunc(sorted, func(a, b [3]float64) int {
+		return cmp.Compare(0.299*a[0]+0.587*a[1]+0.114*a[2], 0.299*b[0]+0.587*b[1]+0.114*b[2])
+	})
+	centers := make([][3]float64, size)
+	for k := range centers {
+		centers[k] = sorted[(2*k+1)*len(sorted)/(2*size)]
+	}
+
+	assignments := make([]int, len(pixels))
+	counts := make([]int, size)
+	for iteration := 0; iteration < paletteIterations; iteration++ {
+		changed := false
+		for i, px := range pixels {
+			nearest, best := 0, math.MaxFloat64
+			for k, c := range centers {
+				d := (px[0]-c[0])*(px[0]-c[0]) + (px[1]-c[1])*(px[1]-c[1]) + (px[2]-c[2])*(px[2]-c[2])
+				if d < best {
+					nearest, best = k, d
+				}
+			}
+			if iteration == 0 || assignments[i] != nearest {
+				changed = true
+			}
+			assignments[i] = nearest
+		}
+		if !changed {
+			break
+		}
+
+		sums := make([][3]float64, size)
+		clear(counts)
+		for i, px := range pixels {
+			k := assignments[i]
+			sums[k][0] += px[0]
+			sums[k][1] += px[1]
+			sums[k][2] += px[2]
+			counts[k]++
+		}
+		for k := range centers {
+			if counts[k] > 0 {
+				n := float64(counts[k])
+				centers[k] = [3]float64{sums[k][0] / n, sums[k][1] / n, sums[k][2] / n}
+			}
+		}
+	}
+
+	colors := make([]models.PaletteColor, 0, size)
+	for k, c := range centers {
+		if counts[k] == 0 {
+			continue
+		}
+		colors = append(colors, models.PaletteColor{
+			Color:  hexColor(color.NRGBA{R: uint8(math.Round(c[0])), G: uint8(math.Round(c[1])), B: uint8(math.Round(c[2])), A: 255}),
+			Weight: math.Round(float64(counts[k])/float64(len(pixels))*10000) / 10000,
+		})
+	}
+	sort.SliceStable(colors, func(i, j int) bool { return colors[i].Weight > colors[j].Weight })
+	return colors
+}
+
+// histogramStats summarizes the red, green, blue and luma values of img's pixels
+func histogramStats(img *image.NRGBA) *models.HistogramStats {
+	var histograms [4][256]int
+	for i := 0; i < len(img.Pix); i += 4 {
+		r, g, b := img.Pix[i], img.Pix[i+1], img.Pix[i+2]
+		histograms[0][r]++
+		histograms[1][g]++
+		histograms[2][b]++
+		histograms[3][uint8(math.Round(0.299*float64(r)+0.587*float64(g)+0.114*float64(b)))]++
+	}
+
+	return &models.HistogramStats{
+		Red:   channelStats(histograms[0]),
+		Green: channelStats(histograms[1]),
+		Blue:  channelStats(histograms[2]),
+		Luma:  channelStats(histograms[3]),
+	}
+}
+
+// channelStats summarizes a histogram of 0-255 values
+func channelStats(histogram [256]int) models.ChannelStats {
+	var n, sum, sumSquares float64
+	stats := models.ChannelStats{Min: -1}
+	for v, count := range histogram {
+		if count == 0 {
+			continue
+		}
+		if stats.Min < 0 {
+			stats.Min = v
+		}
+		stats.Max = v
+		n += float64(count)
+		sum += float64(v * count)
+		sumSquares += float64(v * v * count)
+	}
+	if n == 0 {
+		return models.ChannelStats{}
+	}
+
+	var seen float64
+	for v, count := range histogram {
+		seen += float64(count)
+		if seen >= n/2 {
+			stats.Median = v
+			break
+		}
+	}
+
+	mean := sum / n
+	stats.Mean = math.Round(mean*100) / 100
+	stats.StdDev = math.Round(math.Sqrt(max(sumSquares/n-mean*mean, 0))*100) / 100
+	return stats
+}
+
+// base83 is the blurhash digit alphabet
+const base83 = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz#$%*+,-.:;=?@[]^_{|}~"
+
+// blurHash encodes img as a blurhash with x by y components
+// (https://github.com/woltapp/blurhash)
+func blurHash(img *image.NRGBA, x, y int) string {
+	img = flatten(img)
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+
+	// Pixels in linear light, so components average like light does
+	var linear [256]float64
+	for v := range linear {
+		linear[v] = srgbToLinear(v)
+	}
+
+	factors := make([][3]float64, 0, x*y)
+	for j := 0; j < y; j++ {
+		for i := 0; i < x; i++ {
+			normalization := 2.0
+			if i == 0 && j == 0 {
+				normalization = 1
+			}
+			var r, g, b float64
+			for py := 0; py < height; py++ {
+				cy := math.Cos(math.Pi * float64(j) * float64(py) / float64(height))
+				row := img.Pix[py*img.Stride:]
+				for px := 0; px < width; px++ {
+					basis := math.Cos(math.Pi*float64(i)*float64(px)/float64(width)) * cy
+					r += basis * linear[row[px*4]]
+					g += basis * linear[row[px*4+1]]
+					b += basis * linear[row[px*4+2]]
+				}
+			}
+			scale := normalization / float64(width*height)
+			factors = append(factors, [3]float64{r * scale, g * scale, b * scale})
+		}
+	}
+
+	var hash strings.Builder
+	hash.WriteString(encode83((x-1)+(y-1)*9, 1))
+
+	dc, ac := factors[0], factors[1:]
+	maximum := 1.0
+	if len(ac) > 0 {
+		var actualMax float64
+		for _, f := range ac {
+			actualMax = max(actualMax, math.Abs(f[0]), math.Abs(f[1]), math.Abs(f[2]))
+		}
+		quantisedMax := int(max(0, min(82, math.Floor(actualMax*166-0.5))))
+		maximum = float64(quantisedMax+1) / 166
+		hash.WriteString(encode83(quantisedMax, 1))
+	} else {
+		hash.WriteString(encode83(0, 1))
+	}
+
+	hash.WriteString(encode83(linearToSRGB(dc[0])<<16+linearToSRGB(dc[1])<<8+linearToSRGB(dc[2]), 4))
+	for _, f := range ac {
+		quantise := func(v float64) int {
+			return int(max(0, min(18, math.Floor(signPow(v/maximum, 0.5)*9+9.5))))
+		}
+		hash.WriteString(encode83(quantise(f[0])*19*19+quantise(f[1])*19+quantise(f[2]), 2))
+	}
+	return hash.String()
+}
+
+// encode83 returns value as length base83 digits
+func encode83(value, length int) string {
+	digits := make([]byte, length)
+	for i := length - 1; i >= 0; i-- {
+		digits[i] = base83[value%83]
+		value /= 83
+	}
+	return string(digits)
+}
+
+// srgbToLinear converts an 8-bit sRGB value to linear light in 0-1
+func srgbToLinear(value int) float64 {
+	v := float64(value) / 255
+	if v <= 0.04045 {
+		return v / 12.92
+	}
+	return math.Pow((v+0.055)/1.055, 2.4)
+}
+
+// linearToSRGB converts linear light in 0-1 to an 8-bit sRGB value
+func linearToSRGB(value float64) int {
+	v := max(0, min(1, value))
+	if v <= 0.0031308 {
+		return int(v*12.92*255 + 0.5)
+	}
+	return int((1.055*math.Pow(v, 1/2.4)-0.055)*255 + 0.5)
+}
+
+// signPow raises the magnitude of v to exp, keeping its sign
+func signPow(v, exp float64) float64 {
+	return math.Copysign(math.Pow(math.Abs(v), exp), v)
+}
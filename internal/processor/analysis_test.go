@@ -0,0 +1,190 @@
+package processor
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/disintegration/imaging"
+
+	"github.com/timkrebs/image-processor/internal/models"
+)
+
+// createSolidImage creates an image of a single color
+func createSolidImage(width, height int, c color.NRGBA) *image.NRGBA {
+	return imaging.New(width, height, c)
+}
+
+// createBlocksImage creates an image of 8x6 blocks of pseudo-random brightness
+func createBlocksImage(width, height int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			block := (x*8/width)*6 + y*6/height
+			v := uint8((block * 97) % 256)
+			img.SetNRGBA(x, y, color.NRGBA{R: v, G: v / 2, B: 255 - v, A: 255})
+		}
+	}
+	return img
+}
+
+func TestProcessor_Process_Analysis(t *testing.T) {
+	p := New()
+	img := createTestImage(100, 80)
+	data := encodeTestImage(t, img, "png")
+
+	operations := []models.Operation{
+		{Operation: models.OperationPHash},
+		{Operation: models.OperationDHash},
+		{Operation: models.OperationAverageColor},
+		{Operation: models.OperationPalette, Parameters: map[string]interface{}{"colors": float64(3)}},
+		{Operation: models.OperationBlurHash},
+		{Operation: models.OperationHistogram},
+	}
+
+	result, err := p.Process(bytes.NewReader(data), "image/png", operations)
+	if err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+	if result.Width != 100 || result.Height != 80 {
+		t.Errorf("size = %dx%d, analyses should not change the image", result.Width, result.Height)
+	}
+
+	analysis := result.Analysis
+	if analysis == nil {
+		t.Fatal("Analysis should be set")
+	}
+	if len(analysis.PHash) != 16 || len(analysis.DHash) != 16 {
+		t.Errorf("hashes = %q, %q, want 16 hex digits", analysis.PHash, analysis.DHash)
+	}
+	if len(analysis.AverageColor) != 7 || analysis.AverageColor[0] != '#' {
+		t.Errorf("AverageColor = %q, want #rrggbb", analysis.AverageColor)
+	}
+	if len(analysis.Palette) == 0 || len(analysis.Palette) > 3 {
+		t.Errorf("len(Palette) = %d, want 1-3", len(analysis.Palette))
+	}
+	if analysis.BlurHash == "" {
+		t.Error("BlurHash should be set")
+	}
+	if analysis.Histogram == nil {
+		t.Error("Histogram should be set")
+	}
+}
+
+func TestProcessor_Process_NoAnalysis(t *testing.T) {
+	p := New()
+	data := encodeTestImage(t, createTestImage(20, 20), "png")
+
+	result, err := p.Process(bytes.NewReader(data), "image/png", []models.Operation{{Operation: models.OperationGrayscale}})
+	if err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+	if result.Analysis != nil {
+		t.Errorf("Analysis = %+v, want nil without analysis operations", result.Analysis)
+	}
+}
+
+func TestProcessor_Process_AnalysisInvalidParams(t *testing.T) {
+	p := New()
+	data := encodeTestImage(t, createTestImage(20, 20), "png")
+
+	tests := []models.Operation{
+		{Operation: models.OperationPalette, Parameters: map[string]interface{}{"colors": float64(0)}},
+		{Operation: models.OperationPalette, Parameters: map[string]interface{}{"colors": float64(17)}},
+		{Operation: models.OperationBlurHash, Parameters: map[string]interface{}{"x_components": float64(10)}},
+	}
+
+	for _, op := range tests {
+		if _, err := p.Process(bytes.NewReader(data), "image/png", []models.Operation{op}); err == nil {
+			t.Errorf("Process(%v) should fail", op)
+		}
+	}
+}
+
+func TestImageHashes_NearDuplicates(t *testing.T) {
+	img := createBlocksImage(200, 150)
+	resized := imaging.Resize(img, 100, 75, imaging.Lanczos)
+	different := imaging.FlipH(imaging.FlipV(img))
+
+	for name, hash := range map[string]func(*image.NRGBA) uint64{"phash": pHash, "dhash": dHash} {
+		t.Run(name, func(t *testing.T) {
+			if d := models.HashDistance(hash(img), hash(img)); d != 0 {
+				t.Errorf("distance to itself = %d, want 0", d)
+			}
+			if d := models.HashDistance(hash(img), hash(resized)); d > models.DefaultSimilarDistance {
+				t.Errorf("distance to resized copy = %d, want <= %d", d, models.DefaultSimilarDistance)
+			}
+			if d := models.HashDistance(hash(img), hash(different)); d <= models.DefaultSimilarDistance {
+				t.Errorf("distance to flipped image = %d, want > %d", d, models.DefaultSimilarDistance)
+			}
+		})
+	}
+}
+
+func TestAverageColor(t *testing.T) {
+	img := createSolidImage(10, 10, color.NRGBA{R: 255, A: 255})
+	// Transparent pixels don't count
+	for x := 0; x < 10; x++ {
+		img.SetNRGBA(x, 0, color.NRGBA{B: 255})
+	}
+
+	if got := hexColor(averageColor(img)); got != "#ff0000" {
+		t.Errorf("averageColor() = %s, want #ff0000", got)
+	}
+}
+
+func TestPalette(t *testing.T) {
+	img := createSolidImage(10, 10, color.NRGBA{R: 255, A: 255})
+	for y := 0; y < 10; y++ {
+		for x := 0; x < 3; x++ {
+			img.SetNRGBA(x, y, color.NRGBA{B: 255, A: 255})
+		}
+	}
+
+	colors := palette(img, 2)
+	if len(colors) != 2 {
+		t.Fatalf("len(palette) = %d, want 2", len(colors))
+	}
+	if colors[0].Color != "#ff0000" || colors[0].Weight != 0.7 {
+		t.Errorf("palette[0] = %+v, want {#ff0000 0.7}", colors[0])
+	}
+	if colors[1].Color != "#0000ff" || colors[1].Weight != 0.3 {
+		t.Errorf("palette[1] = %+v, want {#0000ff 0.3}", colors[1])
+	}
+}
+
+func TestBlurHash(t *testing.T) {
+	// Size flag, quantized maximum, 4 DC digits and 2 digits per AC component
+	hash := blurHash(createSolidImage(32, 32, color.NRGBA{R: 255, G: 255, B: 255, A: 255}), 4, 3)
+	if len(hash) != 1+1+4+2*11 {
+		t.Fatalf("len(blurHash()) = %d, want 28", len(hash))
+	}
+	if hash[0] != 'L' {
+		t.Errorf("size flag = %c, want L for 4x3 components", hash[0])
+	}
+	if dc := hash[2:6]; dc != "TSUA" {
+		t.Errorf("DC = %s, want TSUA (white)", dc)
+	}
+}
+
+func TestHistogramStats(t *testing.T) {
+	stats := histogramStats(createSolidImage(8, 8, color.NRGBA{R: 200, G: 100, B: 0, A: 255}))
+
+	want := models.ChannelStats{Mean: 200, Min: 200, Max: 200, Median: 200}
+	if stats.Red != want {
+		t.Errorf("Red = %+v, want %+v", stats.Red, want)
+	}
+	if stats.Blue.Max != 0 || stats.Blue.Mean != 0 {
+		t.Errorf("Blue = %+v, want all zero", stats.Blue)
+	}
+}
+
+func TestEncode83(t *testing.T) {
+	if got := encode83(0, 2); got != "00" {
+		t.Errorf("encode83(0, 2) = %s, want 00", got)
+	}
+	if got := encode83(83*83-1, 2); got != "~~" {
+		t.Errorf("encode83(6888, 2) = %s, want ~~", got)
+	}
+}
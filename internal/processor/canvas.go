@@ -0,0 +1,193 @@
+package processor
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+
+	"github.com/disintegration/imaging"
+)
+
+const (
+	// maxCanvasDimension bounds the width and height padding and borders may grow an image to
+	maxCanvasDimension = 16384
+	// cornerSamples is the number of subpixel samples per axis that anti-alias rounded corners
+	cornerSamples = 4
+	// defaultTrimTolerance is how far a channel may differ from the border color and still be trimmed
+	defaultTrimTolerance = 10
+)
+
+// pad adds space around the image: top, right, bottom and left pixels
+// (all sets every side), then grows the canvas to at least width x height
+// with the image centered, which letterboxes it
+func (p *Processor) pad(img *image.NRGBA, params map[string]interface{}) (*image.NRGBA, error) {
+	all := p.getIntParam(params, "all", 0)
+	top := p.getIntParam(params, "top", all)
+	right := p.getIntParam(params, "right", all)
+	bottom := p.getIntParam(params, "bottom", all)
+	left := p.getIntParam(params, "left", all)
+	if top < 0 || right < 0 || bottom < 0 || left < 0 {
+		return nil, fmt.Errorf("padding must not be negative")
+	}
+
+	background, err := p.getColorParam(params, "color", color.NRGBA{})
+	if err != nil {
+		return nil, err
+	}
+
+	bounds := img.Bounds()
+	padded := image.Pt(bounds.Dx()+left+right, bounds.Dy()+top+bottom)
+	canvas := image.Pt(
+		max(padded.X, p.getIntParam(params, "width", 0)),
+		max(padded.Y, p.getIntParam(params, "height", 0)),
+	)
+
+	offset := image.Pt(left+(canvas.X-padded.X)/2, top+(canvas.Y-padded.Y)/2)
+	return extendCanvas(img, canvas, offset, background)
+}
+
+// border surrounds the image with a frame size pixels wide
+func (p *Processor) border(img *image.NRGBA, params map[string]interface{}) (*image.NRGBA, error) {
+	size := p.getIntParam(params, "size", 10)
+	if size < 0 {
+		return nil, fmt.Errorf("border size must not be negative")
+	}
+
+	frame, err := p.getColorParam(params, "color", color.NRGBA{A: 255})
+	if err != nil {
+		return nil, err
+	}
+
+	bounds := img.Bounds()
+	canvas := image.Pt(bounds.Dx()+2*size, bounds.Dy()+2*size)
+	return extendCanvas(img, canvas, image.Pt(size, size), frame)
+}
+
+// extendCanvas places img at offset on a canvas of the given size filled with background
+func extendCanvas(img *image.NRGBA, size, offset image.Point, background color.NRGBA) (*image.NRGBA, error) {
+	if size.X > maxCanvasDimension || size.Y > maxCanvasDimension {
+		return nil, fmt.Errorf("canvas of %dx%d exceeds %d pixels per side", size.X, size.Y, maxCanvasDimension)
+	}
+	return imaging.Paste(imaging.New(size.X, size.Y, background), img, offset), nil
+}
+
+// roundCorners makes the corners outside circles of the given radius
+// transparent, or with circle=true everything outside the largest centered circle
+func (p *Processor) roundCorners(img *image.NRGBA, params map[string]interface{}) (*image.NRGBA, error) {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	shortest := min(width, height)
+
+	radius := p.getIntParam(params, "radius", shortest/10)
+	if p.getBoolParam(params, "circle", false) {
+		radius = shortest / 2
+	}
+	if radius < 0 {
+		return nil, fmt.Errorf("radius must not be negative")
+	}
+	radius = min(radius, shortest/2)
+	if radius == 0 {
+		return img, nil
+	}
+
+	dst := imaging.Clone(img)
+	r := float64(radius)
+	corners := []struct{ x, y, cx, cy int }{
+		{0, 0, radius, radius},
+		{width - radius, 0, width - radius, radius},
+		{0, height - radius, radius, height - radius},
+		{width - radius, height - radius, width - radius, height - radius},
+	}
+	for _, corner := range corners {
+		for y := corner.y; y < corner.y+radius; y++ {
+			for x := corner.x; x < corner.x+radius; x++ {
+				coverage := circleCoverage(x, y, float64(corner.cx), float64(corner.cy), r)
+				if coverage == 1 {
+					continue
+				}
+				i := dst.PixOffset(x, y)
+				dst.Pix[i+3] = uint8(math.Round(float64(dst.Pix[i+3]) * coverage))
+			}
+		}
+	}
+	return dst, nil
+}
+
+// circleCoverage returns the share of the pixel at x, y that lies within r of cx, cy
+func circleCoverage(x, y int, cx, cy, r float64) float64 {
+	inside := 0
+	for sy := 0; sy < cornerSamples; sy++ {
+		for sx := 0; sx < cornerSamples; sx++ {
+			dx := float64(x) + (float64(sx)+0.5)/cornerSamples - cx
+			dy := float64(y) + (float64(sy)+0.5)/cornerSamples - cy
+			if dx*dx+dy*dy <= r*r {
+				inside++
+			}
+		}
+	}
+	return float64(inside) / (cornerSamples * cornerSamples)
+}
+
+// background composites the image onto a solid color, white by default,
+// removing its transparency
+func (p *Processor) background(img *image.NRGBA, params map[string]interface{}) (*image.NRGBA, error) {
+	fill, err := p.getColorParam(params, "color", color.NRGBA{R: 255, G: 255, B: 255, A: 255})
+	if err != nil {
+		return nil, err
+	}
+	return flattenOnto(img, fill), nil
+}
+
+// flattenOnto composites an image onto a solid color
+func flattenOnto(img image.Image, fill color.NRGBA) *image.NRGBA {
+	bounds := img.Bounds()
+	canvas := imaging.New(bounds.Dx(), bounds.Dy(), fill)
+	return imaging.Overlay(canvas, img, image.Pt(0, 0), 1)
+}
+
+// trim crops away uniform borders: the rows and columns whose pixels are all
+// within tolerance of the border color, the top-left pixel unless color is given
+func (p *Processor) trim(img *image.NRGBA, params map[string]interface{}) (*image.NRGBA, error) {
+	tolerance := p.getIntParam(params, "tolerance", defaultTrimTolerance)
+	if tolerance < 0 || tolerance > 255 {
+		return nil, fmt.Errorf("tolerance must be between 0 and 255")
+	}
+
+	bounds := img.Bounds()
+	if bounds.Empty() {
+		return img, nil
+	}
+	edge, err := p.getColorParam(params, "color", img.NRGBAAt(bounds.Min.X, bounds.Min.Y))
+	if err != nil {
+		return nil, err
+	}
+
+	var content image.Rectangle
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if !colorWithin(img.NRGBAAt(x, y), edge, tolerance) {
+				content = content.Union(image.Rect(x, y, x+1, y+1))
+			}
+		}
+	}
+
+	// A uniform image has nothing to keep, so it is left alone
+	if content.Empty() || content == bounds {
+		return img, nil
+	}
+	return imaging.Crop(img, content), nil
+}
+
+// colorWithin reports whether every channel of a is within tolerance of b's.
+// Fully transparent pixels match whatever their color channels hold.
+func colorWithin(a, b color.NRGBA, tolerance int) bool {
+	if a.A == 0 && b.A == 0 {
+		return true
+	}
+	within := func(x, y uint8) bool {
+		d := int(x) - int(y)
+		return d <= tolerance && d >= -tolerance
+	}
+	return within(a.R, b.R) && within(a.G, b.G) && within(a.B, b.B) && within(a.A, b.A)
+}
@@ -0,0 +1,203 @@
+package processor
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+
+	"github.com/disintegration/imaging"
+
+	"github.com/timkrebs/image-processor/internal/models"
+)
+
+func TestProcessor_pad(t *testing.T) {
+	p := New()
+	img := imaging.New(100, 50, color.NRGBA{R: 255, A: 255})
+
+	tests := []struct {
+		name   string
+		params map[string]interface{}
+		width  int
+		height int
+		offset image.Point
+	}{
+		{"all sides", map[string]interface{}{"all": float64(10)}, 120, 70, image.Pt(10, 10)},
+		{"single side", map[string]interface{}{"left": float64(5)}, 105, 50, image.Pt(5, 0)},
+		{"letterbox", map[string]interface{}{"width": float64(100), "height": float64(100)}, 100, 100, image.Pt(0, 25)},
+		{"canvas smaller than image", map[string]interface{}{"width": float64(10)}, 100, 50, image.Pt(0, 0)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := tt.params
+			params["color"] = "#0000ff"
+			result, err := p.pad(img, params)
+			if err != nil {
+				t.Fatalf("pad() error = %v", err)
+			}
+			if result.Bounds().Dx() != tt.width || result.Bounds().Dy() != tt.height {
+				t.Fatalf("size = %dx%d, want %dx%d", result.Bounds().Dx(), result.Bounds().Dy(), tt.width, tt.height)
+			}
+			if c := result.NRGBAAt(tt.offset.X, tt.offset.Y); c.R != 255 {
+				t.Errorf("pixel at offset = %v, want the image", c)
+			}
+			if tt.offset != (image.Point{}) {
+				if c := result.NRGBAAt(0, 0); c.B != 255 {
+					t.Errorf("corner = %v, want padding color", c)
+				}
+			}
+		})
+	}
+}
+
+func TestProcessor_pad_Invalid(t *testing.T) {
+	p := New()
+	img := createTestImage(10, 10)
+
+	for _, params := range []map[string]interface{}{
+		{"top": float64(-1)},
+		{"color": "nope", "all": float64(1)},
+		{"width": float64(maxCanvasDimension + 1)},
+	} {
+		if _, err := p.pad(img, params); err == nil {
+			t.Errorf("pad(%v) should fail", params)
+		}
+	}
+}
+
+func TestProcessor_border(t *testing.T) {
+	p := New()
+	img := imaging.New(20, 10, color.NRGBA{R: 255, G: 255, B: 255, A: 255})
+
+	result, err := p.border(img, map[string]interface{}{"size": float64(3), "color": "red"})
+	if err != nil {
+		t.Fatalf("border() error = %v", err)
+	}
+	if result.Bounds().Dx() != 26 || result.Bounds().Dy() != 16 {
+		t.Errorf("size = %dx%d, want 26x16", result.Bounds().Dx(), result.Bounds().Dy())
+	}
+	if c := result.NRGBAAt(0, 0); c != (color.NRGBA{R: 255, A: 255}) {
+		t.Errorf("frame = %v, want red", c)
+	}
+	if c := result.NRGBAAt(3, 3); c.G != 255 {
+		t.Errorf("inside = %v, want the image", c)
+	}
+}
+
+func TestProcessor_roundCorners(t *testing.T) {
+	p := New()
+	img := imaging.New(40, 40, color.NRGBA{R: 255, A: 255})
+
+	result, err := p.roundCorners(img, map[string]interface{}{"radius": float64(10)})
+	if err != nil {
+		t.Fatalf("roundCorners() error = %v", err)
+	}
+	for _, pt := range []image.Point{{0, 0}, {39, 0}, {0, 39}, {39, 39}} {
+		if a := result.NRGBAAt(pt.X, pt.Y).A; a != 0 {
+			t.Errorf("corner %v alpha = %d, want 0", pt, a)
+		}
+	}
+	if a := result.NRGBAAt(20, 20).A; a != 255 {
+		t.Errorf("center alpha = %d, want 255", a)
+	}
+	if a := result.NRGBAAt(20, 0).A; a != 255 {
+		t.Errorf("edge alpha = %d, want 255", a)
+	}
+
+	circle, err := p.roundCorners(img, map[string]interface{}{"circle": true})
+	if err != nil {
+		t.Fatalf("roundCorners() error = %v", err)
+	}
+	if a := circle.NRGBAAt(2, 2).A; a != 0 {
+		t.Errorf("circle corner alpha = %d, want 0", a)
+	}
+	if a := circle.NRGBAAt(20, 0).A; a == 0 {
+		t.Error("circle should touch the top edge")
+	}
+}
+
+func TestProcessor_background(t *testing.T) {
+	p := New()
+	img := imaging.New(10, 10, color.NRGBA{})
+	img.SetNRGBA(5, 5, color.NRGBA{R: 255, A: 255})
+
+	result, err := p.background(img, map[string]interface{}{"color": "#00ff00"})
+	if err != nil {
+		t.Fatalf("background() error = %v", err)
+	}
+	if c := result.NRGBAAt(0, 0); c != (color.NRGBA{G: 255, A: 255}) {
+		t.Errorf("transparent pixel = %v, want green", c)
+	}
+	if c := result.NRGBAAt(5, 5); c != (color.NRGBA{R: 255, A: 255}) {
+		t.Errorf("opaque pixel = %v, want red", c)
+	}
+}
+
+func TestProcessor_trim(t *testing.T) {
+	p := New()
+	img := imaging.New(50, 40, color.NRGBA{R: 250, G: 250, B: 250, A: 255})
+	for y := 10; y < 20; y++ {
+		for x := 5; x < 35; x++ {
+			img.SetNRGBA(x, y, color.NRGBA{A: 255})
+		}
+	}
+	// Within tolerance of the border, so still trimmed
+	img.SetNRGBA(45, 35, color.NRGBA{R: 245, G: 255, B: 250, A: 255})
+
+	result, err := p.trim(img, nil)
+	if err != nil {
+		t.Fatalf("trim() error = %v", err)
+	}
+	if result.Bounds().Dx() != 30 || result.Bounds().Dy() != 10 {
+		t.Errorf("size = %dx%d, want 30x10", result.Bounds().Dx(), result.Bounds().Dy())
+	}
+
+	uniform := imaging.New(10, 10, color.White)
+	result, err = p.trim(uniform, nil)
+	if err != nil {
+		t.Fatalf("trim() error = %v", err)
+	}
+	if result.Bounds().Dx() != 10 {
+		t.Error("trim() should leave uniform images alone")
+	}
+}
+
+func TestProcessor_Process_TransparentToJPEG(t *testing.T) {
+	p := New()
+	img := imaging.New(10, 10, color.NRGBA{})
+	data := encodeTestImage(t, img, "png")
+
+	result, err := p.ProcessAs(bytes.NewReader(data), "image/png", nil, "image/jpeg")
+	if err != nil {
+		t.Fatalf("ProcessAs() error = %v", err)
+	}
+	decoded, err := jpeg.Decode(bytes.NewReader(result.Data))
+	if err != nil {
+		t.Fatalf("failed to decode JPEG: %v", err)
+	}
+	if r, _, _, _ := decoded.At(5, 5).RGBA(); r>>8 < 250 {
+		t.Errorf("transparent pixel encoded with red %d, want white", r>>8)
+	}
+}
+
+func TestProcessor_Process_Flatten(t *testing.T) {
+	p := New()
+	data := encodeTestImage(t, imaging.New(10, 10, color.NRGBA{}), "png")
+
+	operations := []models.Operation{
+		{Operation: models.OperationFlatten, Parameters: map[string]interface{}{"color": "#102030"}},
+	}
+	result, err := p.ProcessAs(bytes.NewReader(data), "image/png", operations, "image/png")
+	if err != nil {
+		t.Fatalf("ProcessAs() error = %v", err)
+	}
+	decoded, _, err := image.Decode(bytes.NewReader(result.Data))
+	if err != nil {
+		t.Fatalf("failed to decode PNG: %v", err)
+	}
+	if c := color.NRGBAModel.Convert(decoded.At(0, 0)).(color.NRGBA); c != (color.NRGBA{R: 0x10, G: 0x20, B: 0x30, A: 255}) {
+		t.Errorf("pixel = %v, want #102030", c)
+	}
+}
@@ -0,0 +1,97 @@
+package processor
+
+import (
+	"fmt"
+	"image/color"
+	"strconv"
+	"strings"
+
+	"golang.org/x/image/colornames"
+)
+
+// ParseColor parses a CSS-style color: #rgb, #rgba, #rrggbb, #rrggbbaa,
+// rgb(r, g, b), rgba(r, g, b, a) with a in 0-1, a CSS color name, or
+// "transparent"
+func ParseColor(s string) (color.NRGBA, error) {
+	value := strings.ToLower(strings.TrimSpace(s))
+
+	switch {
+	case value == "transparent":
+		return color.NRGBA{}, nil
+	case strings.HasPrefix(value, "#"):
+		return parseHexColor(value[1:], s)
+	case strings.HasPrefix(value, "rgb(") || strings.HasPrefix(value, "rgba("):
+		return parseRGBColor(value, s)
+	}
+
+	if c, ok := colornames.Map[value]; ok {
+		return color.NRGBA{R: c.R, G: c.G, B: c.B, A: c.A}, nil
+	}
+	return color.NRGBA{}, fmt.Errorf("invalid color: %s", s)
+}
+
+// parseHexColor parses the digits of a hex color, expanding short forms
+func parseHexColor(digits, s string) (color.NRGBA, error) {
+	switch len(digits) {
+	case 3, 4:
+		var expanded strings.Builder
+		for _, d := range digits {
+			expanded.WriteRune(d)
+			expanded.WriteRune(d)
+		}
+		digits = expanded.String()
+	case 6, 8:
+	default:
+		return color.NRGBA{}, fmt.Errorf("invalid color: %s", s)
+	}
+	if len(digits) == 6 {
+		digits += "ff"
+	}
+
+	n, err := strconv.ParseUint(digits, 16, 32)
+	if err != nil {
+		return color.NRGBA{}, fmt.Errorf("invalid color: %s", s)
+	}
+	return color.NRGBA{R: uint8(n >> 24), G: uint8(n >> 16), B: uint8(n >> 8), A: uint8(n)}, nil
+}
+
+// parseRGBColor parses rgb(r, g, b) and rgba(r, g, b, a)
+func parseRGBColor(value, s string) (color.NRGBA, error) {
+	open, end := strings.IndexByte(value, '('), strings.LastIndexByte(value, ')')
+	if end != len(value)-1 {
+		return color.NRGBA{}, fmt.Errorf("invalid color: %s", s)
+	}
+	parts := strings.Split(value[open+1:end], ",")
+	alpha := strings.HasPrefix(value, "rgba(")
+	if (alpha && len(parts) != 4) || (!alpha && len(parts) != 3) {
+		return color.NRGBA{}, fmt.Errorf("invalid color: %s", s)
+	}
+
+	var channels [3]uint8
+	for i := range channels {
+		n, err := strconv.Atoi(strings.TrimSpace(parts[i]))
+		if err != nil || n < 0 || n > 255 {
+			return color.NRGBA{}, fmt.Errorf("invalid color: %s", s)
+		}
+		channels[i] = uint8(n)
+	}
+
+	c := color.NRGBA{R: channels[0], G: channels[1], B: channels[2], A: 255}
+	if alpha {
+		a, err := strconv.ParseFloat(strings.TrimSpace(parts[3]), 64)
+		if err != nil || a < 0 || a > 1 {
+			return color.NRGBA{}, fmt.Errorf("invalid color: %s", s)
+		}
+		c.A = uint8(a*255 + 0.5)
+	}
+	return c, nil
+}
+
+// getColorParam reads a color parameter, returning defaultVal when it is missing
+func (p *Processor) getColorParam(params map[string]interface{}, key string, defaultVal color.NRGBA) (color.NRGBA, error) {
+	value := p.getStringParam(params, key, "")
+	if value == "" {
+		return defaultVal, nil
+	}
+	return ParseColor(value)
+}
@@ -0,0 +1,43 @@
+package processor
+
+import (
+	"image/color"
+	"testing"
+)
+
+func TestParseColor(t *testing.T) {
+	tests := []struct {
+		in   string
+		want color.NRGBA
+	}{
+		{"#fff", color.NRGBA{R: 255, G: 255, B: 255, A: 255}},
+		{"#f008", color.NRGBA{R: 255, A: 0x88}},
+		{"#1E90FF", color.NRGBA{R: 0x1e, G: 0x90, B: 0xff, A: 255}},
+		{"#1e90ff80", color.NRGBA{R: 0x1e, G: 0x90, B: 0xff, A: 0x80}},
+		{"rgb(10, 20, 30)", color.NRGBA{R: 10, G: 20, B: 30, A: 255}},
+		{"rgba(10,20,30,0.5)", color.NRGBA{R: 10, G: 20, B: 30, A: 128}},
+		{"transparent", color.NRGBA{}},
+		{" White ", color.NRGBA{R: 255, G: 255, B: 255, A: 255}},
+		{"dodgerblue", color.NRGBA{R: 0x1e, G: 0x90, B: 0xff, A: 255}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseColor(tt.in)
+			if err != nil {
+				t.Fatalf("ParseColor() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseColor() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseColor_Invalid(t *testing.T) {
+	for _, in := range []string{"", "#", "#12", "#12345", "#ggg", "rgb(1,2)", "rgb(1,2,256)", "rgba(1,2,3,2)", "rgb(1,2,3", "notacolor"} {
+		if _, err := ParseColor(in); err == nil {
+			t.Errorf("ParseColor(%q) should fail", in)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package processor
+
+import (
+	"fmt"
+	"image"
+	"math"
+
+	"github.com/disintegration/imaging"
+)
+
+// Gravities name points of an image, or of an overlay placed onto one
+const (
+	GravityTopLeft     = "top-left"
+	GravityTop         = "top"
+	GravityTopRight    = "top-right"
+	GravityLeft        = "left"
+	GravityCenter      = "center"
+	GravityRight       = "right"
+	GravityBottomLeft  = "bottom-left"
+	GravityBottom      = "bottom"
+	GravityBottomRight = "bottom-right"
+)
+
+// defaultOverlayMargin insets overlays placed by gravity from the image's edges
+const defaultOverlayMargin = 10
+
+// gravities maps each gravity to where it lies along each axis, from 0 to 1
+var gravities = map[string][2]float64{
+	GravityTopLeft:     {0, 0},
+	GravityTop:         {0.5, 0},
+	GravityTopRight:    {1, 0},
+	GravityLeft:        {0, 0.5},
+	GravityCenter:      {0.5, 0.5},
+	GravityRight:       {1, 0.5},
+	GravityBottomLeft:  {0, 1},
+	GravityBottom:      {0.5, 1},
+	GravityBottomRight: {1, 1},
+}
+
+// placement is where an overlay, such as text or a watermark, goes on an
+// image: the overlay's anchor point is put at a gravity position inset by a
+// margin, or at explicit coordinates
+type placement struct {
+	at       *image.Point
+	position string
+	anchor   string
+	margin   int
+}
+
+// getPlacement reads the position, anchor, margin, x and y parameters. The
+// anchor defaults to the position, which keeps overlays inside the image.
+func (p *Processor) getPlacement(params map[string]interface{}, defaultPosition string) (placement, error) {
+	pl := placement{
+		position: p.getStringParam(params, "position", defaultPosition),
+		margin:   p.getIntParam(params, "margin", defaultOverlayMargin),
+	}
+	pl.anchor = p.getStringParam(params, "anchor", pl.position)
+
+	if _, ok := gravities[pl.position]; !ok {
+		return pl, fmt.Errorf("invalid position: %s", pl.position)
+	}
+	if _, ok := gravities[pl.anchor]; !ok {
+		return pl, fmt.Errorf("invalid anchor: %s", pl.anchor)
+	}
+	if pl.margin < 0 {
+		return pl, fmt.Errorf("margin must not be negative")
+	}
+
+	_, hasX := params["x"]
+	_, hasY := params["y"]
+	if hasX || hasY {
+		pl.at = &image.Point{X: p.getIntParam(params, "x", 0), Y: p.getIntParam(params, "y", 0)}
+	}
+	return pl, nil
+}
+
+// origin returns where the top-left corner of an overlay of the given size goes on canvas
+func (pl placement) origin(canvas image.Rectangle, size image.Point) image.Point {
+	var target image.Point
+	if pl.at != nil {
+		target = canvas.Min.Add(*pl.at)
+	} else {
+		g := gravities[pl.position]
+		inner := canvas.Inset(pl.margin)
+		target = image.Pt(inner.Min.X+scaleRound(g[0], inner.Dx()), inner.Min.Y+scaleRound(g[1], inner.Dy()))
+	}
+
+	a := gravities[pl.anchor]
+	return target.Sub(image.Pt(scaleRound(a[0], size.X), scaleRound(a[1], size.Y)))
+}
+
+// scaleRound returns f times n, rounded
+func scaleRound(f float64, n int) int {
+	return int(math.Round(f * float64(n)))
+}
+
+// getOpacity reads the opacity parameter, from 0 to 1
+func (p *Processor) getOpacity(params map[string]interface{}) (float64, error) {
+	opacity := p.getFloatParam(params, "opacity", 1)
+	if opacity < 0 || opacity > 1 {
+		return 0, fmt.Errorf("opacity must be between 0 and 1")
+	}
+	return opacity, nil
+}
+
+// composite blends overlay onto a copy of img with its top-left corner at pt,
+// scaling the overlay's alpha by opacity. Parts outside img are clipped.
+func composite(img, overlay *image.NRGBA, pt image.Point, opacity float64) *image.NRGBA {
+	return imaging.Overlay(img, overlay, pt, opacity)
+}
@@ -0,0 +1,98 @@
+package processor
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/gobolditalic"
+	"golang.org/x/image/font/gofont/goitalic"
+	"golang.org/x/image/font/gofont/gomedium"
+	"golang.org/x/image/font/gofont/gomono"
+	"golang.org/x/image/font/gofont/gomonobold"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/font/sfnt"
+)
+
+// DefaultFont is the embedded font text operations use without a font parameter
+const DefaultFont = "go"
+
+// builtinFonts are the embedded fonts, from the BSD-licensed Go font family
+var builtinFonts = map[string][]byte{
+	"go":             goregular.TTF,
+	"go-bold":        gobold.TTF,
+	"go-italic":      goitalic.TTF,
+	"go-bold-italic": gobolditalic.TTF,
+	"go-medium":      gomedium.TTF,
+	"go-mono":        gomono.TTF,
+	"go-mono-bold":   gomonobold.TTF,
+}
+
+var (
+	// parsedFonts caches the embedded fonts once parsed; fonts are safe for concurrent use
+	parsedFonts   = make(map[string]*opentype.Font)
+	parsedFontsMu sync.Mutex
+)
+
+// BuiltinFonts returns the names of the embedded fonts, sorted
+func BuiltinFonts() []string {
+	return slices.Sorted(maps.Keys(builtinFonts))
+}
+
+// IsBuiltinFont reports whether name is an embedded font
+func IsBuiltinFont(name string) bool {
+	_, ok := builtinFonts[name]
+	return ok
+}
+
+// ParseFont checks that data is a TrueType or OpenType font and returns its family name
+func ParseFont(data []byte) (string, error) {
+	f, err := opentype.Parse(data)
+	if err != nil {
+		return "", fmt.Errorf("invalid font: %w", err)
+	}
+	if f.NumGlyphs() == 0 {
+		return "", fmt.Errorf("invalid font: no glyphs")
+	}
+	name, err := f.Name(nil, sfnt.NameIDFamily)
+	if err != nil {
+		// Naming the font is up to the uploader then
+		return "", nil
+	}
+	return name, nil
+}
+
+// WithFonts returns a processor that can also use the given user fonts, keyed
+// by the name text operations refer to them by
+func (p *Processor) WithFonts(fonts map[string][]byte) *Processor {
+	clone := *p
+	clone.fonts = fonts
+	return &clone
+}
+
+// loadFont returns the user font or embedded font with the given name
+func (p *Processor) loadFont(name string) (*opentype.Font, error) {
+	if data, ok := p.fonts[name]; ok {
+		return opentype.Parse(data)
+	}
+
+	data, ok := builtinFonts[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown font: %s", name)
+	}
+
+	parsedFontsMu.Lock()
+	defer parsedFontsMu.Unlock()
+	if f, ok := parsedFonts[name]; ok {
+		return f, nil
+	}
+	f, err := opentype.Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse font %s: %w", name, err)
+	}
+	parsedFonts[name] = f
+	return f, nil
+}
@@ -0,0 +1,540 @@
+package processor
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"io"
+	"math"
+	"unicode/utf16"
+
+	"github.com/disintegration/imaging"
+
+	"github.com/timkrebs/image-processor/internal/models"
+)
+
+// Color profile modes of the color_profile operation
+const (
+	// ProfileSRGB converts images with an ICC profile to sRGB and drops the profile
+	ProfileSRGB = "srgb"
+	// ProfileEmbed converts to sRGB like ProfileSRGB and embeds an sRGB profile in the output
+	ProfileEmbed = "embed"
+	// ProfilePreserve keeps the pixels in the original's color space and embeds its profile
+	ProfilePreserve = "preserve"
+)
+
+const (
+	// maxICCProfileSize bounds the profiles read from images
+	maxICCProfileSize = 4 << 20
+	// jpegICCChunkSize is the most profile data a JPEG APP2 segment carries
+	jpegICCChunkSize = 65519
+)
+
+var (
+	errUnsupportedProfile = errors.New("unsupported ICC profile")
+
+	jpegICCPrefix = []byte("ICC_PROFILE\x00")
+)
+
+// iccProfile is an RGB matrix/TRC ICC profile, the kind Adobe RGB, Display P3
+// and most camera and display profiles are
+type iccProfile struct {
+	// toXYZ maps linear RGB to the D50 XYZ profile connection space
+	toXYZ [3][3]float64
+	// curves map each encoded channel in 0-1 to linear light
+	curves [3]func(float64) float64
+}
+
+// srgbColorants are the D50-adapted XYZ values of the sRGB primaries
+var srgbColorants = [3][3]float64{
+	{0.4361, 0.2225, 0.0139},
+	{0.3851, 0.7169, 0.0971},
+	{0.1431, 0.0606, 0.7141},
+}
+
+// xyzToSRGB maps D50 XYZ to linear sRGB, including Bradford adaptation to D65
+var xyzToSRGB = [3][3]float64{
+	{3.1338561, -1.6168667, -0.4906146},
+	{-0.9787684, 1.9161415, 0.0334540},
+	{0.0719453, -0.2289914, 1.4052427},
+}
+
+// srgbProfile is embedded in images converted with ProfileEmbed
+var srgbProfile = buildRGBProfile("sRGB", srgbColorants, iccParametricCurve(2.4, 1/1.055, 0.055/1.055, 1/12.92, 0.04045))
+
+// colorProfileMode returns the mode of the last color_profile operation, ProfileSRGB without one
+func (p *Processor) colorProfileMode(operations []models.Operation) (string, error) {
+	mode := ProfileSRGB
+	for _, op := range operations {
+		if op.Operation == models.OperationColorProfile {
+			mode = p.getStringParam(op.Parameters, "mode", ProfileSRGB)
+		}
+	}
+	switch mode {
+	case ProfileSRGB, ProfileEmbed, ProfilePreserve:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("invalid color profile mode: %s", mode)
+	}
+}
+
+// decode decodes an image to NRGBA along with the ICC profile embedded in it, if any
+func decode(r io.Reader) (*image.NRGBA, string, []byte, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", nil, err
+	}
+	return imaging.Clone(img), format, extractICCProfile(data, format), nil
+}
+
+// convertToSRGB converts img in place from the color space of an ICC profile
+// to sRGB. Images without a profile are taken to be sRGB already, and profiles
+// other than RGB matrix/TRC ones, such as CMYK or LUT-based profiles, are left
+// alone, as they were before profiles were read.
+func convertToSRGB(img *image.NRGBA, data []byte) {
+	if data == nil {
+		return
+	}
+	profile, err := parseICCProfile(data)
+	if err != nil || profile.isSRGB() {
+		return
+	}
+	profile.toSRGB(img)
+}
+
+// extractICCProfile returns the ICC profile embedded in encoded image data, or nil
+func extractICCProfile(data []byte, format string) []byte {
+	switch format {
+	case "jpeg":
+		return jpegICCProfile(data)
+	case "png":
+		return pngICCProfile(data)
+	default:
+		return nil
+	}
+}
+
+// jpegICCProfile reassembles a profile split across APP2 segments
+func jpegICCProfile(data []byte) []byte {
+	var chunks [][]byte
+	for i := 2; i+4 <= len(data); {
+		if data[i] != 0xff {
+			return nil
+		}
+		marker := data[i+1]
+		if marker == 0xff {
+			// Fill byte
+			i++
+			continue
+		}
+		if marker == 0xda || marker == 0xd9 {
+			// Start of scan: all metadata comes before it
+			break
+		}
+
+		end := i + 2 + int(binary.BigEndian.Uint16(data[i+2:]))
+		if end < i+4 || end > len(data) {
+			return nil
+		}
+		segment := data[i+4 : end]
+		if marker == 0xe2 && len(segment) > len(jpegICCPrefix)+2 && bytes.HasPrefix(segment, jpegICCPrefix) {
+			seq, count := int(segment[12]), int(segment[13])
+			if chunks == nil {
+				chunks = make([][]byte, count)
+			}
+			if seq < 1 || seq > len(chunks) || count != len(chunks) {
+				return nil
+			}
+			chunks[seq-1] = segment[14:]
+		}
+		i = end
+	}
+
+	var profile []byte
+	for _, chunk := range chunks {
+		if chunk == nil {
+			return nil
+		}
+		profile = append(profile, chunk...)
+	}
+	return profile
+}
+
+// pngICCProfile decompresses the profile in a PNG's iCCP chunk
+func pngICCProfile(data []byte) []byte {
+	for i := 8; i+8 <= len(data); {
+		length := int(binary.BigEndian.Uint32(data[i:]))
+		chunk := string(data[i+4 : i+8])
+		end := i + 12 + length
+		if length < 0 || end > len(data) {
+			return nil
+		}
+
+		switch chunk {
+		case "iCCP":
+			// Profile name, null separator, compression method (always zlib), profile
+			body := data[i+8 : i+8+length]
+			sep := bytes.IndexByte(body, 0)
+			if sep < 0 || sep+2 > len(body) || body[sep+1] != 0 {
+				return nil
+			}
+			zr, err := zlib.NewReader(bytes.NewReader(body[sep+2:]))
+			if err != nil {
+				return nil
+			}
+			defer zr.Close()
+			profile, err := io.ReadAll(io.LimitReader(zr, maxICCProfileSize))
+			if err != nil {
+				return nil
+			}
+			return profile
+		case "IDAT":
+			return nil
+		}
+		i = end
+	}
+	return nil
+}
+
+// embedICCProfile returns encoded image data carrying an ICC profile. GIF
+// has no standard place for one, so GIF data is returned unchanged.
+func embedICCProfile(data []byte, contentType string, profile []byte) ([]byte, error) {
+	if len(profile) > maxICCProfileSize {
+		return nil, fmt.Errorf("ICC profile of %d bytes is too large", len(profile))
+	}
+
+	switch contentType {
+	case "image/jpeg":
+		if len(data) < 2 {
+			return nil, fmt.Errorf("invalid JPEG data")
+		}
+		count := (len(profile) + jpegICCChunkSize - 1) / jpegICCChunkSize
+		out := make([]byte, 0, len(data)+len(profile)+count*18)
+		out = append(out, data[:2]...)
+		for seq := 1; seq <= count; seq++ {
+			chunk := profile[(seq-1)*jpegICCChunkSize : min(seq*jpegICCChunkSize, len(profile))]
+			out = append(out, 0xff, 0xe2)
+			out = binary.BigEndian.AppendUint16(out, uint16(2+len(jpegICCPrefix)+2+len(chunk)))
+			out = append(out, jpegICCPrefix...)
+			out = append(out, byte(seq), byte(count))
+			out = append(out, chunk...)
+		}
+		return append(out, data[2:]...), nil
+	case "image/png":
+		// The iCCP chunk has to come before the image data, so it goes right after IHDR
+		const ihdrEnd = 8 + 12 + 13
+		if len(data) < ihdrEnd || string(data[12:16]) != "IHDR" {
+			return nil, fmt.Errorf("invalid PNG data")
+		}
+		var compressed bytes.Buffer
+		zw := zlib.NewWriter(&compressed)
+		if _, err := zw.Write(profile); err != nil {
+			return nil, err
+		}
+		if err := zw.Close(); err != nil {
+			return nil, err
+		}
+
+		chunk := append([]byte("iCCP"), "ICC Profile\x00\x00"...)
+		chunk = append(chunk, compressed.Bytes()...)
+		out := make([]byte, 0, len(data)+len(chunk)+8)
+		out = append(out, data[:ihdrEnd]...)
+		out = binary.BigEndian.AppendUint32(out, uint32(len(chunk)-4))
+		out = append(out, chunk...)
+		out = binary.BigEndian.AppendUint32(out, crc32.ChecksumIEEE(chunk))
+		return append(out, data[ihdrEnd:]...), nil
+	default:
+		return data, nil
+	}
+}
+
+// parseICCProfile reads the colorants and tone curves of an RGB matrix/TRC profile
+func parseICCProfile(data []byte) (*iccProfile, error) {
+	if len(data) < 132 || string(data[36:40]) != "acsp" {
+		return nil, fmt.Errorf("invalid ICC profile")
+	}
+	if string(data[16:20]) != "RGB " || string(data[20:24]) != "XYZ " {
+		return nil, errUnsupportedProfile
+	}
+
+	tags := make(map[string][]byte)
+	count := int(binary.BigEndian.Uint32(data[128:]))
+	for i := 0; i < count; i++ {
+		entry := 132 + 12*i
+		if entry+12 > len(data) {
+			return nil, fmt.Errorf("invalid ICC profile")
+		}
+		offset := int(binary.BigEndian.Uint32(data[entry+4:]))
+		size := int(binary.BigEndian.Uint32(data[entry+8:]))
+		if offset < 0 || size < 0 || offset+size > len(data) || offset+size < offset {
+			return nil, fmt.Errorf("invalid ICC profile")
+		}
+		tags[string(data[entry:entry+4])] = data[offset : offset+size]
+	}
+
+	profile := &iccProfile{}
+	for channel, prefix := range []string{"r", "g", "b"} {
+		xyz, err := parseICCXYZ(tags[prefix+"XYZ"])
+		if err != nil {
+			return nil, err
+		}
+		for row := range xyz {
+			profile.toXYZ[row][channel] = xyz[row]
+		}
+		if profile.curves[channel], err = parseICCCurve(tags[prefix+"TRC"]); err != nil {
+			return nil, err
+		}
+	}
+	return profile, nil
+}
+
+// parseICCXYZ reads an XYZType tag
+func parseICCXYZ(tag []byte) ([3]float64, error) {
+	var xyz [3]float64
+	if len(tag) < 20 || string(tag[:4]) != "XYZ " {
+		return xyz, errUnsupportedProfile
+	}
+	for i := range xyz {
+		xyz[i] = s15Fixed16(tag[8+4*i:])
+	}
+	return xyz, nil
+}
+
+// parseICCCurve reads a curveType or parametricCurveType tag as a function
+// from an encoded value to linear light
+func parseICCCurve(tag []byte) (func(float64) float64, error) {
+	if len(tag) < 12 {
+		return nil, errUnsupportedProfile
+	}
+
+	switch string(tag[:4]) {
+	case "curv":
+		n := int(binary.BigEndian.Uint32(tag[8:]))
+		switch {
+		case n == 0:
+			return func(v float64) float64 { return v }, nil
+		case n == 1 && len(tag) >= 14:
+			gamma := float64(binary.BigEndian.Uint16(tag[12:])) / 256
+			return func(v float64) float64 { return math.Pow(v, gamma) }, nil
+		case len(tag) >= 12+2*n:
+			table := make([]float64, n)
+			for i := range table {
+				table[i] = float64(binary.BigEndian.Uint16(tag[12+2*i:])) / 65535
+			}
+			return func(v float64) float64 {
+				x := v * float64(n-1)
+				i := min(int(x), n-2)
+				return table[i] + (table[i+1]-table[i])*(x-float64(i))
+			}, nil
+		}
+	case "para":
+		// Function types 0 to 4 take 1, 3, 4, 5 and 7 parameters
+		functionType := int(binary.BigEndian.Uint16(tag[8:]))
+		counts := []int{1, 3, 4, 5, 7}
+		if functionType >= len(counts) || len(tag) < 12+4*counts[functionType] {
+			break
+		}
+		var g [7]float64
+		for i := 0; i < counts[functionType]; i++ {
+			g[i] = s15Fixed16(tag[12+4*i:])
+		}
+		gamma, a, b, c, d, e, f := g[0], g[1], g[2], g[3], g[4], g[5], g[6]
+		switch functionType {
+		case 0:
+			return func(v float64) float64 { return math.Pow(v, gamma) }, nil
+		case 1:
+			return func(v float64) float64 {
+				if v >= -b/a {
+					return math.Pow(a*v+b, gamma)
+				}
+				return 0
+			}, nil
+		case 2:
+			return func(v float64) float64 {
+				if v >= -b/a {
+					return math.Pow(a*v+b, gamma) + c
+				}
+				return c
+			}, nil
+		case 3:
+			return func(v float64) float64 {
+				if v >= d {
+					return math.Pow(a*v+b, gamma)
+				}
+				return c * v
+			}, nil
+		case 4:
+			return func(v float64) float64 {
+				if v >= d {
+					return math.Pow(a*v+b, gamma) + e
+				}
+				return c*v + f
+			}, nil
+		}
+	}
+	return nil, errUnsupportedProfile
+}
+
+// s15Fixed16 reads a signed 15.16 fixed point number
+func s15Fixed16(b []byte) float64 {
+	return float64(int32(binary.BigEndian.Uint32(b))) / 65536
+}
+
+// isSRGB reports whether the profile describes sRGB closely enough that
+// converting would only add rounding errors
+func (prof *iccProfile) isSRGB() bool {
+	m := prof.srgbMatrix()
+	for row := range m {
+		for col := range m[row] {
+			identity := 0.0
+			if row == col {
+				identity = 1
+			}
+			if math.Abs(m[row][col]-identity) > 0.01 {
+				return false
+			}
+		}
+	}
+	for _, curve := range prof.curves {
+		for v := 0; v <= 255; v += 15 {
+			if math.Abs(curve(float64(v)/255)-srgbToLinear(v)) > 0.002 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// srgbMatrix maps the profile's linear RGB to linear sRGB
+func (prof *iccProfile) srgbMatrix() [3][3]float64 {
+	var m [3][3]float64
+	for row := range m {
+		for col := range m[row] {
+			for k := range 3 {
+				m[row][col] += xyzToSRGB[row][k] * prof.toXYZ[k][col]
+			}
+		}
+	}
+	return m
+}
+
+// toSRGB converts img in place from the profile's color space to sRGB,
+// clipping colors outside the sRGB gamut
+func (prof *iccProfile) toSRGB(img *image.NRGBA) {
+	var linear [3][256]float64
+	for channel, curve := range prof.curves {
+		for v := range linear[channel] {
+			linear[channel][v] = curve(float64(v) / 255)
+		}
+	}
+	m := prof.srgbMatrix()
+
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		row := img.Pix[img.PixOffset(bounds.Min.X, y):img.PixOffset(bounds.Max.X, y)]
+		for i := 0; i < len(row); i += 4 {
+			r, g, b := linear[0][row[i]], linear[1][row[i+1]], linear[2][row[i+2]]
+			row[i] = encodeLinear(m[0][0]*r + m[0][1]*g + m[0][2]*b)
+			row[i+1] = encodeLinear(m[1][0]*r + m[1][1]*g + m[1][2]*b)
+			row[i+2] = encodeLinear(m[2][0]*r + m[2][1]*g + m[2][2]*b)
+		}
+	}
+}
+
+// buildRGBProfile encodes an ICC v4 display profile with the given D50
+// colorants, one per row, and the same tone curve for every channel
+func buildRGBProfile(description string, colorants [3][3]float64, curve []byte) []byte {
+	tags := []struct {
+		signature string
+		data      []byte
+	}{
+		{"desc", iccText(description)},
+		{"cprt", iccText("No copyright, use freely")},
+		{"wtpt", iccXYZ(0.9642, 1, 0.8249)},
+		// Bradford adaptation from the D65 display white to D50
+		{"chad", iccNumbers("sf32", 1.0479, 0.0229, -0.0502, 0.0296, 0.9904, -0.0171, -0.0092, 0.0151, 0.7519)},
+		{"rXYZ", iccXYZ(colorants[0][0], colorants[0][1], colorants[0][2])},
+		{"gXYZ", iccXYZ(colorants[1][0], colorants[1][1], colorants[1][2])},
+		{"bXYZ", iccXYZ(colorants[2][0], colorants[2][1], colorants[2][2])},
+		{"rTRC", curve},
+		{"gTRC", curve},
+		{"bTRC", curve},
+	}
+
+	header := make([]byte, 128)
+	copy(header[8:], []byte{4, 0x30, 0, 0})
+	copy(header[12:], "mntrRGB XYZ ")
+	for i, v := range []uint16{2025, 1, 1} {
+		binary.BigEndian.PutUint16(header[24+2*i:], v)
+	}
+	copy(header[36:], "acsp")
+	copy(header[68:], iccXYZ(0.9642, 1, 0.8249)[8:])
+
+	// Tags with the same data, like the tone curves, share it
+	table := binary.BigEndian.AppendUint32(nil, uint32(len(tags)))
+	var body []byte
+	offsets := make(map[string]int)
+	start := len(header) + 4 + 12*len(tags)
+	for _, tag := range tags {
+		offset, ok := offsets[string(tag.data)]
+		if !ok {
+			offset = start + len(body)
+			offsets[string(tag.data)] = offset
+			body = append(body, tag.data...)
+			for len(body)%4 != 0 {
+				body = append(body, 0)
+			}
+		}
+		table = append(table, tag.signature...)
+		table = binary.BigEndian.AppendUint32(table, uint32(offset))
+		table = binary.BigEndian.AppendUint32(table, uint32(len(tag.data)))
+	}
+
+	profile := append(append(header, table...), body...)
+	binary.BigEndian.PutUint32(profile, uint32(len(profile)))
+	return profile
+}
+
+// iccNumbers encodes a tag of the given type holding s15Fixed16 numbers
+func iccNumbers(tagType string, values ...float64) []byte {
+	tag := append([]byte(tagType), 0, 0, 0, 0)
+	for _, v := range values {
+		tag = binary.BigEndian.AppendUint32(tag, uint32(int32(math.Round(v*65536))))
+	}
+	return tag
+}
+
+// iccXYZ encodes an XYZType tag
+func iccXYZ(x, y, z float64) []byte {
+	return iccNumbers("XYZ ", x, y, z)
+}
+
+// iccParametricCurve encodes a type 3 parametricCurveType tag, the form of the sRGB curve
+func iccParametricCurve(gamma, a, b, c, d float64) []byte {
+	tag := iccNumbers("para", gamma, a, b, c, d)
+	// The function type and reserved field come before the parameters
+	return append(append(tag[:8:8], 0, 3, 0, 0), tag[8:]...)
+}
+
+// iccText encodes a multiLocalizedUnicodeType tag with a single en-US string
+func iccText(s string) []byte {
+	text := utf16.Encode([]rune(s))
+	tag := append([]byte("mluc"), 0, 0, 0, 0)
+	tag = binary.BigEndian.AppendUint32(tag, 1)
+	tag = binary.BigEndian.AppendUint32(tag, 12)
+	tag = append(tag, "enUS"...)
+	tag = binary.BigEndian.AppendUint32(tag, uint32(2*len(text)))
+	tag = binary.BigEndian.AppendUint32(tag, 28)
+	for _, c := range text {
+		tag = binary.BigEndian.AppendUint16(tag, c)
+	}
+	return tag
+}
@@ -0,0 +1,165 @@
+package processor
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+
+	"github.com/timkrebs/image-processor/internal/models"
+)
+
+// adobeRGBProfile is a matrix/TRC Adobe RGB (1998) profile with a gamma 2.2 curve
+var adobeRGBProfile = buildRGBProfile("Adobe RGB (1998)", [3][3]float64{
+	{0.6097, 0.3111, 0.0195},
+	{0.2053, 0.6257, 0.0609},
+	{0.1492, 0.0632, 0.7446},
+}, iccGammaCurve(2.2))
+
+// iccGammaCurve encodes a curveType tag with a single gamma value
+func iccGammaCurve(gamma float64) []byte {
+	tag := append([]byte("curv"), 0, 0, 0, 0, 0, 0, 0, 1)
+	return binary.BigEndian.AppendUint16(tag, uint16(gamma*256+0.5))
+}
+
+// encodeWithProfile encodes img in the given format with an embedded ICC profile
+func encodeWithProfile(t *testing.T, img image.Image, format string, profile []byte) []byte {
+	t.Helper()
+	data, err := embedICCProfile(encodeTestImage(t, img, format), "image/"+format, profile)
+	if err != nil {
+		t.Fatalf("embedICCProfile() error = %v", err)
+	}
+	return data
+}
+
+func TestParseICCProfile(t *testing.T) {
+	srgb, err := parseICCProfile(srgbProfile)
+	if err != nil {
+		t.Fatalf("parseICCProfile(sRGB) error = %v", err)
+	}
+	if !srgb.isSRGB() {
+		t.Error("the sRGB profile should be recognized as sRGB")
+	}
+
+	adobe, err := parseICCProfile(adobeRGBProfile)
+	if err != nil {
+		t.Fatalf("parseICCProfile(Adobe RGB) error = %v", err)
+	}
+	if adobe.isSRGB() {
+		t.Error("Adobe RGB should not be recognized as sRGB")
+	}
+	// Both profiles share the D65 white, so white maps to white
+	m := adobe.srgbMatrix()
+	for row := range m {
+		if sum := m[row][0] + m[row][1] + m[row][2]; sum < 0.99 || sum > 1.01 {
+			t.Errorf("row %d of the matrix sums to %f, want 1", row, sum)
+		}
+	}
+
+	cmyk := bytes.Clone(srgbProfile)
+	copy(cmyk[16:], "CMYK")
+	if _, err := parseICCProfile(cmyk); err != errUnsupportedProfile {
+		t.Errorf("parseICCProfile(CMYK) error = %v, want %v", err, errUnsupportedProfile)
+	}
+	if _, err := parseICCProfile([]byte("not a profile")); err == nil {
+		t.Error("parseICCProfile() should reject invalid data")
+	}
+}
+
+func TestEmbedICCProfile_RoundTrip(t *testing.T) {
+	img := createTestImage(16, 16)
+	// Larger than one JPEG segment, so it has to be split
+	large := append(bytes.Clone(adobeRGBProfile), make([]byte, 2*jpegICCChunkSize)...)
+
+	for _, format := range []string{"jpeg", "png"} {
+		for _, profile := range [][]byte{srgbProfile, large} {
+			data := encodeWithProfile(t, img, format, profile)
+			if _, _, err := image.Decode(bytes.NewReader(data)); err != nil {
+				t.Fatalf("%s with a profile no longer decodes: %v", format, err)
+			}
+			if got := extractICCProfile(data, format); !bytes.Equal(got, profile) {
+				t.Errorf("extractICCProfile(%s) returned %d bytes, want %d", format, len(got), len(profile))
+			}
+		}
+		if got := extractICCProfile(encodeTestImage(t, img, format), format); got != nil {
+			t.Errorf("extractICCProfile(%s) = %d bytes for an image without a profile", format, len(got))
+		}
+	}
+}
+
+func TestProcessor_Process_ColorProfile(t *testing.T) {
+	p := New()
+	src := color.NRGBA{R: 60, G: 180, B: 60, A: 255}
+	data := encodeWithProfile(t, createSolidImage(8, 8, src), "png", adobeRGBProfile)
+
+	process := func(mode string) (color.NRGBA, []byte) {
+		t.Helper()
+		var operations []models.Operation
+		if mode != "" {
+			operations = []models.Operation{
+				{Operation: models.OperationColorProfile, Parameters: map[string]interface{}{"mode": mode}},
+			}
+		}
+		result, err := p.ProcessAs(bytes.NewReader(data), "image/png", operations, "image/png")
+		if err != nil {
+			t.Fatalf("ProcessAs(%q) error = %v", mode, err)
+		}
+		img, _, err := image.Decode(bytes.NewReader(result.Data))
+		if err != nil {
+			t.Fatalf("failed to decode output: %v", err)
+		}
+		return color.NRGBAModel.Convert(img.At(4, 4)).(color.NRGBA), extractICCProfile(result.Data, "png")
+	}
+
+	// Adobe RGB's wider gamut makes the same values a more saturated green in sRGB
+	converted, profile := process("")
+	if converted.R >= src.R/2 || converted.G < src.G {
+		t.Errorf("converted pixel = %v, want a more saturated green than %v", converted, src)
+	}
+	if profile != nil {
+		t.Error("converting by default should not embed a profile")
+	}
+
+	embedded, profile := process(ProfileEmbed)
+	if embedded != converted {
+		t.Errorf("embed pixel = %v, want %v", embedded, converted)
+	}
+	if !bytes.Equal(profile, srgbProfile) {
+		t.Error("embed should embed the sRGB profile")
+	}
+
+	preserved, profile := process(ProfilePreserve)
+	if preserved != src {
+		t.Errorf("preserve pixel = %v, want %v", preserved, src)
+	}
+	if !bytes.Equal(profile, adobeRGBProfile) {
+		t.Error("preserve should embed the original profile")
+	}
+
+	operations := []models.Operation{
+		{Operation: models.OperationColorProfile, Parameters: map[string]interface{}{"mode": "cmyk"}},
+	}
+	if _, err := p.Process(bytes.NewReader(data), "image/png", operations); err == nil {
+		t.Error("Process() should reject an invalid color profile mode")
+	}
+}
+
+func TestProcessor_Process_ColorProfileJPEG(t *testing.T) {
+	p := New()
+	src := color.NRGBA{R: 60, G: 180, B: 60, A: 255}
+	data := encodeWithProfile(t, createSolidImage(16, 16, src), "jpeg", adobeRGBProfile)
+
+	result, err := p.Process(bytes.NewReader(data), "image/jpeg", nil)
+	if err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+	img, err := jpeg.Decode(bytes.NewReader(result.Data))
+	if err != nil {
+		t.Fatalf("failed to decode output: %v", err)
+	}
+	if got := color.NRGBAModel.Convert(img.At(8, 8)).(color.NRGBA); got.R >= src.R/2 {
+		t.Errorf("pixel = %v, want converted from Adobe RGB", got)
+	}
+}
@@ -4,18 +4,20 @@ import (
 	"bytes"
 	"fmt"
 	"image"
+	"image/gif"
 	"image/jpeg"
 	"image/png"
 	"io"
 	"strings"
 
-	"github.com/disintegration/imaging"
-
 	"github.com/timkrebs/image-processor/internal/models"
 )
 
 // Processor handles image processing operations
-type Processor struct{}
+type Processor struct {
+	// fonts are user fonts text operations may use besides the embedded ones
+	fonts map[string][]byte
+}
 
 // New creates a new image processor
 func New() *Processor {
@@ -24,6 +26,8 @@ func New() *Processor {
 
 // ProcessResult contains the processed image and metadata
 type ProcessResult struct {
+	// Analysis holds the results of analysis operations, nil without any
+	Analysis    *models.ImageAnalysis
 	ContentType string
 	Data        []byte
 	Width       int
@@ -32,17 +36,44 @@ type ProcessResult struct {
 
 // Process applies the given operations to an image
 func (p *Processor) Process(reader io.Reader, contentType string, operations []models.Operation) (*ProcessResult, error) {
+	return p.ProcessAs(reader, contentType, operations, "")
+}
+
+// ProcessAs is Process with an explicit output content type.
+// An empty outputType keeps PNG input as PNG and encodes everything else as JPEG.
+func (p *Processor) ProcessAs(reader io.Reader, contentType string, operations []models.Operation, outputType string) (*ProcessResult, error) {
+	mode, err := p.colorProfileMode(operations)
+	if err != nil {
+		return nil, err
+	}
+
 	// Decode the image
-	img, format, err := image.Decode(reader)
+	nrgba, format, profile, err := decode(reader)
 	if err != nil {
 		return nil, fmt.Errorf("failed to decode image: %w", err)
 	}
 
-	// Convert to NRGBA for processing
-	nrgba := imaging.Clone(img)
+	// Operations, analyses and outputs without a profile all assume sRGB
+	if mode != ProfilePreserve {
+		convertToSRGB(nrgba, profile)
+	}
 
-	// Apply each operation
+	// Apply each operation; analyses see the image as the operations before them left it
+	var analysis *models.ImageAnalysis
 	for _, op := range operations {
+		if op.Operation == models.OperationColorProfile {
+			continue
+		}
+		if op.Operation.IsAnalysis() {
+			if analysis == nil {
+				analysis = &models.ImageAnalysis{}
+			}
+			if err := p.analyze(nrgba, op, analysis); err != nil {
+				return nil, fmt.Errorf("failed to apply operation %s: %w", op.Operation, err)
+			}
+			continue
+		}
+
 		nrgba, err = p.applyOperation(nrgba, op)
 		if err != nil {
 			return nil, fmt.Errorf("failed to apply operation %s: %w", op.Operation, err)
@@ -54,21 +85,44 @@ func (p *Processor) Process(reader io.Reader, contentType string, operations []m
 	var outputContentType string
 
 	switch {
-	case strings.Contains(contentType, "png"), format == "png":
+	case outputType == "image/gif":
+		if err := gif.Encode(&buf, nrgba, nil); err != nil {
+			return nil, fmt.Errorf("failed to encode GIF: %w", err)
+		}
+		outputContentType = "image/gif"
+	case outputType == "image/png", outputType == "" && (strings.Contains(contentType, "png") || format == "png"):
 		if err := png.Encode(&buf, nrgba); err != nil {
 			return nil, fmt.Errorf("failed to encode PNG: %w", err)
 		}
 		outputContentType = "image/png"
-	default:
+	case outputType == "", outputType == "image/jpeg":
+		// JPEG has no alpha channel; unflattened transparency would turn black
+		if !nrgba.Opaque() {
+			nrgba = flatten(nrgba)
+		}
 		if err := jpeg.Encode(&buf, nrgba, &jpeg.Options{Quality: 90}); err != nil {
 			return nil, fmt.Errorf("failed to encode JPEG: %w", err)
 		}
 		outputContentType = "image/jpeg"
+	default:
+		return nil, fmt.Errorf("unsupported output type: %s", outputType)
+	}
+
+	data := buf.Bytes()
+	switch {
+	case mode == ProfileEmbed:
+		data, err = embedICCProfile(data, outputContentType, srgbProfile)
+	case mode == ProfilePreserve && profile != nil:
+		data, err = embedICCProfile(data, outputContentType, profile)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed ICC profile: %w", err)
 	}
 
 	bounds := nrgba.Bounds()
 	return &ProcessResult{
-		Data:        buf.Bytes(),
+		Analysis:    analysis,
+		Data:        data,
 		ContentType: outputContentType,
 		Width:       bounds.Dx(),
 		Height:      bounds.Dy(),
@@ -99,6 +153,18 @@ func (p *Processor) applyOperation(img *image.NRGBA, op models.Operation) (*imag
 		return p.contrast(img, op.Parameters)
 	case models.OperationSaturation:
 		return p.saturation(img, op.Parameters)
+	case models.OperationPad:
+		return p.pad(img, op.Parameters)
+	case models.OperationBorder:
+		return p.border(img, op.Parameters)
+	case models.OperationRoundCorners:
+		return p.roundCorners(img, op.Parameters)
+	case models.OperationBackground, models.OperationFlatten:
+		return p.background(img, op.Parameters)
+	case models.OperationTrim:
+		return p.trim(img, op.Parameters)
+	case models.OperationText:
+		return p.text(img, op.Parameters)
 	default:
 		return nil, fmt.Errorf("unknown operation: %s", op.Operation)
 	}
@@ -128,6 +194,15 @@ func (p *Processor) getFloatParam(params map[string]interface{}, key string, def
 	return defaultVal
 }
 
+func (p *Processor) getStringParam(params map[string]interface{}, key, defaultVal string) string {
+	if v, ok := params[key]; ok {
+		if val, ok := v.(string); ok {
+			return val
+		}
+	}
+	return defaultVal
+}
+
 func (p *Processor) getBoolParam(params map[string]interface{}, key string, defaultVal bool) bool {
 	if v, ok := params[key]; ok {
 		if val, ok := v.(bool); ok {
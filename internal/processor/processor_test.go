@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"image"
 	"image/color"
+	_ "image/gif"
 	"image/jpeg"
 	"image/png"
 	"testing"
@@ -540,6 +541,131 @@ func TestProcessor_resize_PreserveAspectRatio(t *testing.T) {
 	}
 }
 
+func TestProcessor_resize_Fit(t *testing.T) {
+	p := New()
+	img := createTestImage(200, 100)
+
+	tests := []struct {
+		fit        string
+		wantWidth  int
+		wantHeight int
+	}{
+		{FitFill, 50, 50},
+		{FitContain, 50, 25},
+		{FitCover, 50, 50},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fit, func(t *testing.T) {
+			result, err := p.resize(img, map[string]interface{}{"width": 50, "height": 50, "fit": tt.fit})
+			if err != nil {
+				t.Fatalf("resize() error = %v", err)
+			}
+
+			bounds := result.Bounds()
+			if bounds.Dx() != tt.wantWidth || bounds.Dy() != tt.wantHeight {
+				t.Errorf("resize(%s) = %dx%d, want %dx%d", tt.fit, bounds.Dx(), bounds.Dy(), tt.wantWidth, tt.wantHeight)
+			}
+		})
+	}
+
+	if _, err := p.resize(img, map[string]interface{}{"width": 50, "height": 50, "fit": "squash"}); err == nil {
+		t.Error("resize() should reject an unknown fit")
+	}
+}
+
+func TestProcessor_resize_Linear(t *testing.T) {
+	p := New()
+
+	// Alternating black and white columns average to 50% linear light, which
+	// is much brighter than the 50% sRGB value averaging gamma-encoded pixels gives
+	stripes := image.NewNRGBA(image.Rect(0, 0, 64, 16))
+	for y := 0; y < 16; y++ {
+		for x := 0; x < 64; x += 2 {
+			stripes.SetNRGBA(x, y, color.NRGBA{R: 255, G: 255, B: 255, A: 255})
+			stripes.SetNRGBA(x+1, y, color.NRGBA{A: 255})
+		}
+	}
+
+	gamma, err := p.resize(stripes, map[string]interface{}{"width": 8, "height": 2})
+	if err != nil {
+		t.Fatalf("resize() error = %v", err)
+	}
+	linear, err := p.resize(stripes, map[string]interface{}{"width": 8, "height": 2, "linear": true})
+	if err != nil {
+		t.Fatalf("resize() error = %v", err)
+	}
+	if g := gamma.NRGBAAt(4, 1).R; g > 140 {
+		t.Errorf("sRGB resize = %d, want about 128", g)
+	}
+	if l := linear.NRGBAAt(4, 1).R; l < 180 || l > 195 {
+		t.Errorf("linear resize = %d, want about 188", l)
+	}
+}
+
+func TestProcessor_resize_LinearFit(t *testing.T) {
+	p := New()
+	img := createTestImage(200, 100)
+
+	tests := []struct {
+		params     map[string]interface{}
+		wantWidth  int
+		wantHeight int
+	}{
+		{map[string]interface{}{"width": 50, "height": 50, "fit": FitFill}, 50, 50},
+		{map[string]interface{}{"width": 50, "height": 50, "fit": FitContain}, 50, 25},
+		{map[string]interface{}{"width": 500, "height": 500, "fit": FitContain}, 200, 100},
+		{map[string]interface{}{"width": 50, "height": 50, "fit": FitCover}, 50, 50},
+		{map[string]interface{}{"width": 100}, 100, 50},
+		{map[string]interface{}{"height": 20}, 40, 20},
+	}
+
+	for _, tt := range tests {
+		tt.params["linear"] = true
+		result, err := p.resize(img, tt.params)
+		if err != nil {
+			t.Fatalf("resize(%v) error = %v", tt.params, err)
+		}
+		if bounds := result.Bounds(); bounds.Dx() != tt.wantWidth || bounds.Dy() != tt.wantHeight {
+			t.Errorf("resize(%v) = %dx%d, want %dx%d", tt.params, bounds.Dx(), bounds.Dy(), tt.wantWidth, tt.wantHeight)
+		}
+	}
+
+	thumb, err := p.thumbnail(img, map[string]interface{}{"size": 30, "linear": true})
+	if err != nil {
+		t.Fatalf("thumbnail() error = %v", err)
+	}
+	if bounds := thumb.Bounds(); bounds.Dx() != 30 || bounds.Dy() != 30 {
+		t.Errorf("thumbnail() = %dx%d, want 30x30", bounds.Dx(), bounds.Dy())
+	}
+
+	if _, err := p.resize(img, map[string]interface{}{"width": 50, "height": 50, "fit": "squash", "linear": true}); err == nil {
+		t.Error("resize() should reject an unknown fit")
+	}
+}
+
+func TestProcessor_ProcessAs(t *testing.T) {
+	p := New()
+	data := encodeTestImage(t, createTestImage(20, 20), "jpeg")
+
+	for _, outputType := range []string{"image/png", "image/gif", "image/jpeg"} {
+		result, err := p.ProcessAs(bytes.NewReader(data), "image/jpeg", nil, outputType)
+		if err != nil {
+			t.Fatalf("ProcessAs(%s) error = %v", outputType, err)
+		}
+		if result.ContentType != outputType {
+			t.Errorf("ContentType = %s, want %s", result.ContentType, outputType)
+		}
+		if _, _, err := image.Decode(bytes.NewReader(result.Data)); err != nil {
+			t.Errorf("ProcessAs(%s) produced undecodable output: %v", outputType, err)
+		}
+	}
+
+	if _, err := p.ProcessAs(bytes.NewReader(data), "image/jpeg", nil, "image/webp"); err == nil {
+		t.Error("ProcessAs() should reject unsupported output types")
+	}
+}
+
 func TestProcessor_thumbnail_DefaultSize(t *testing.T) {
 	p := New()
 	img := createTestImage(200, 200)
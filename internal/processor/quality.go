@@ -0,0 +1,213 @@
+package processor
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+
+	"github.com/disintegration/imaging"
+
+	"github.com/timkrebs/image-processor/internal/models"
+)
+
+// Comparison image modes
+const (
+	// CompareSideBySide places the original, resized to match, left of the output
+	CompareSideBySide = "side-by-side"
+	// CompareDiff shows the amplified per-pixel difference between original and output
+	CompareDiff = "diff"
+)
+
+const (
+	// maxPSNR is reported for identical images, whose PSNR is infinite
+	maxPSNR = 100
+	// ssimWindow and ssimStep size and space the windows SSIM is averaged over
+	ssimWindow = 8
+	ssimStep   = 4
+	// diffGain amplifies differences so compression artifacts are visible
+	diffGain = 4
+	// compareGap separates the two halves of a side-by-side comparison
+	compareGap = 8
+	// compareMaxDimension bounds the size of comparison images
+	compareMaxDimension = 2048
+)
+
+// MeasureQuality compares a processed image with its original, which is
+// resized to the output's dimensions first. Both are decoded from their
+// encoded form, so compression artifacts count.
+func (p *Processor) MeasureQuality(original, output io.Reader) (*models.OutputQuality, error) {
+	a, b, err := decodePair(original, output)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.OutputQuality{
+		SSIM: ssim(a, b),
+		PSNR: psnr(a, b),
+	}, nil
+}
+
+// Compare renders a PNG comparing a processed image with its original in the given mode
+func (p *Processor) Compare(original, output io.Reader, mode string) (*ProcessResult, error) {
+	a, b, err := decodePair(original, output)
+	if err != nil {
+		return nil, err
+	}
+
+	var img *image.NRGBA
+	switch mode {
+	case CompareSideBySide:
+		bounds := b.Bounds()
+		img = imaging.New(2*bounds.Dx()+compareGap, bounds.Dy(), color.White)
+		img = imaging.Paste(img, a, image.Pt(0, 0))
+		img = imaging.Paste(img, b, image.Pt(bounds.Dx()+compareGap, 0))
+	case CompareDiff:
+		img = difference(a, b)
+	default:
+		return nil, fmt.Errorf("invalid comparison mode: %s", mode)
+	}
+
+	if bounds := img.Bounds(); bounds.Dx() > compareMaxDimension || bounds.Dy() > compareMaxDimension {
+		img = imaging.Fit(img, compareMaxDimension, compareMaxDimension, imaging.Lanczos)
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, fmt.Errorf("failed to encode PNG: %w", err)
+	}
+
+	bounds := img.Bounds()
+	return &ProcessResult{
+		Data:        buf.Bytes(),
+		ContentType: "image/png",
+		Width:       bounds.Dx(),
+		Height:      bounds.Dy(),
+	}, nil
+}
+
+// decodePair decodes an original and its output, returning both in sRGB
+// and flattened onto white at the output's dimensions
+func decodePair(original, output io.Reader) (*image.NRGBA, *image.NRGBA, error) {
+	originalImg, _, originalProfile, err := decode(original)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to decode original: %w", err)
+	}
+	outputImg, _, outputProfile, err := decode(output)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to decode output: %w", err)
+	}
+	convertToSRGB(originalImg, originalProfile)
+	convertToSRGB(outputImg, outputProfile)
+
+	b := flatten(outputImg)
+	bounds := b.Bounds()
+	a := flatten(originalImg)
+	if a.Bounds().Size() != bounds.Size() {
+		a = imaging.Resize(a, bounds.Dx(), bounds.Dy(), imaging.Lanczos)
+	}
+	return a, b, nil
+}
+
+// flatten composites an image onto white, so transparency compares like the
+// background it is usually shown on
+func flatten(img image.Image) *image.NRGBA {
+	return flattenOnto(img, color.NRGBA{R: 255, G: 255, B: 255, A: 255})
+}
+
+// psnr returns the peak signal-to-noise ratio in dB over the RGB channels of
+// two images of the same size
+func psnr(a, b *image.NRGBA) float64 {
+	var sum float64
+	var n int
+	for i := 0; i < len(a.Pix); i += 4 {
+		for c := 0; c < 3; c++ {
+			d := float64(a.Pix[i+c]) - float64(b.Pix[i+c])
+			sum += d * d
+			n++
+		}
+	}
+	if n == 0 || sum == 0 {
+		return maxPSNR
+	}
+
+	mse := sum / float64(n)
+	return math.Min(10*math.Log10(255*255/mse), maxPSNR)
+}
+
+// ssim returns the mean structural similarity of the luma of two images of
+// the same size, over overlapping square windows
+func ssim(a, b *image.NRGBA) float64 {
+	const (
+		c1 = (0.01 * 255) * (0.01 * 255)
+		c2 = (0.03 * 255) * (0.03 * 255)
+	)
+
+	width, height := a.Bounds().Dx(), a.Bounds().Dy()
+	la, lb := luma(a), luma(b)
+
+	// Images smaller than a window are compared as a single window
+	window := min(ssimWindow, width, height)
+	if window == 0 {
+		return 1
+	}
+
+	var total float64
+	var windows int
+	for y := 0; y+window <= height; y += ssimStep {
+		for x := 0; x+window <= width; x += ssimStep {
+			var sumA, sumB, sumAA, sumBB, sumAB float64
+			for wy := y; wy < y+window; wy++ {
+				row := wy * width
+				for wx := x; wx < x+window; wx++ {
+					va, vb := la[row+wx], lb[row+wx]
+					sumA += va
+					sumB += vb
+					sumAA += va * va
+					sumBB += vb * vb
+					sumAB += va * vb
+				}
+			}
+
+			n := float64(window * window)
+			meanA, meanB := sumA/n, sumB/n
+			varA := sumAA/n - meanA*meanA
+			varB := sumBB/n - meanB*meanB
+			cov := sumAB/n - meanA*meanB
+
+			total += ((2*meanA*meanB + c1) * (2*cov + c2)) /
+				((meanA*meanA + meanB*meanB + c1) * (varA + varB + c2))
+			windows++
+		}
+	}
+	return total / float64(windows)
+}
+
+// luma returns the Rec. 601 luma of each pixel, row by row
+func luma(img *image.NRGBA) []float64 {
+	values := make([]float64, len(img.Pix)/4)
+	for i := range values {
+		r, g, b := img.Pix[i*4], img.Pix[i*4+1], img.Pix[i*4+2]
+		values[i] = 0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)
+	}
+	return values
+}
+
+// difference returns the amplified absolute difference of two images of the same size
+func difference(a, b *image.NRGBA) *image.NRGBA {
+	diff := image.NewNRGBA(a.Bounds())
+	for i := 0; i < len(a.Pix); i += 4 {
+		for c := 0; c < 3; c++ {
+			d := int(a.Pix[i+c]) - int(b.Pix[i+c])
+			if d < 0 {
+				d = -d
+			}
+			diff.Pix[i+c] = uint8(min(d*diffGain, 255))
+		}
+		diff.Pix[i+3] = 255
+	}
+	return diff
+}
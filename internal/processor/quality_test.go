@@ -0,0 +1,136 @@
+package processor
+
+import (
+	"bytes"
+	"image/png"
+	"testing"
+
+	"github.com/timkrebs/image-processor/internal/models"
+)
+
+func TestProcessor_MeasureQuality_Identical(t *testing.T) {
+	p := New()
+	data := encodeTestImage(t, createTestImage(64, 48), "png")
+
+	quality, err := p.MeasureQuality(bytes.NewReader(data), bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("MeasureQuality() error = %v", err)
+	}
+	if quality.SSIM < 0.9999 {
+		t.Errorf("SSIM = %f, want 1", quality.SSIM)
+	}
+	if quality.PSNR != maxPSNR {
+		t.Errorf("PSNR = %f, want %d", quality.PSNR, maxPSNR)
+	}
+}
+
+func TestProcessor_MeasureQuality_Degraded(t *testing.T) {
+	p := New()
+	original := encodeTestImage(t, createTestImage(64, 48), "png")
+
+	light, err := p.Process(bytes.NewReader(original), "image/png", nil)
+	if err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+	heavy, err := p.Process(bytes.NewReader(original), "image/png", blurOps(5))
+	if err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+
+	lightQuality, err := p.MeasureQuality(bytes.NewReader(original), bytes.NewReader(light.Data))
+	if err != nil {
+		t.Fatalf("MeasureQuality() error = %v", err)
+	}
+	heavyQuality, err := p.MeasureQuality(bytes.NewReader(original), bytes.NewReader(heavy.Data))
+	if err != nil {
+		t.Fatalf("MeasureQuality() error = %v", err)
+	}
+
+	if heavyQuality.SSIM >= lightQuality.SSIM {
+		t.Errorf("blurred SSIM %f should be below %f", heavyQuality.SSIM, lightQuality.SSIM)
+	}
+	if heavyQuality.PSNR >= lightQuality.PSNR {
+		t.Errorf("blurred PSNR %f should be below %f", heavyQuality.PSNR, lightQuality.PSNR)
+	}
+}
+
+func TestProcessor_MeasureQuality_ResizesOriginal(t *testing.T) {
+	p := New()
+	original := encodeTestImage(t, createTestImage(200, 100), "png")
+	output := encodeTestImage(t, createTestImage(50, 25), "png")
+
+	quality, err := p.MeasureQuality(bytes.NewReader(original), bytes.NewReader(output))
+	if err != nil {
+		t.Fatalf("MeasureQuality() error = %v", err)
+	}
+	if quality.SSIM < 0.9 {
+		t.Errorf("SSIM = %f, want close to 1 for a downscaled gradient", quality.SSIM)
+	}
+}
+
+func TestProcessor_MeasureQuality_InvalidImage(t *testing.T) {
+	p := New()
+	data := encodeTestImage(t, createTestImage(10, 10), "png")
+
+	if _, err := p.MeasureQuality(bytes.NewReader([]byte("not an image")), bytes.NewReader(data)); err == nil {
+		t.Error("MeasureQuality() should fail for an invalid original")
+	}
+	if _, err := p.MeasureQuality(bytes.NewReader(data), bytes.NewReader([]byte("not an image"))); err == nil {
+		t.Error("MeasureQuality() should fail for an invalid output")
+	}
+}
+
+func TestProcessor_Compare(t *testing.T) {
+	p := New()
+	original := encodeTestImage(t, createTestImage(80, 60), "png")
+	output := encodeTestImage(t, createTestImage(40, 30), "png")
+
+	tests := []struct {
+		mode   string
+		width  int
+		height int
+	}{
+		{CompareSideBySide, 2*40 + compareGap, 30},
+		{CompareDiff, 40, 30},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			result, err := p.Compare(bytes.NewReader(original), bytes.NewReader(output), tt.mode)
+			if err != nil {
+				t.Fatalf("Compare() error = %v", err)
+			}
+			if result.ContentType != "image/png" {
+				t.Errorf("ContentType = %s, want image/png", result.ContentType)
+			}
+			if result.Width != tt.width || result.Height != tt.height {
+				t.Errorf("size = %dx%d, want %dx%d", result.Width, result.Height, tt.width, tt.height)
+			}
+			if _, err := png.Decode(bytes.NewReader(result.Data)); err != nil {
+				t.Errorf("result is not a PNG: %v", err)
+			}
+		})
+	}
+
+	if _, err := p.Compare(bytes.NewReader(original), bytes.NewReader(output), "overlay"); err == nil {
+		t.Error("Compare() should fail for an unknown mode")
+	}
+}
+
+func TestDifference_Identical(t *testing.T) {
+	img := createTestImage(16, 16)
+	diff := difference(img, img)
+
+	for y := 0; y < 16; y++ {
+		for x := 0; x < 16; x++ {
+			if c := diff.NRGBAAt(x, y); c.R != 0 || c.G != 0 || c.B != 0 || c.A != 255 {
+				t.Fatalf("pixel (%d,%d) = %v, want opaque black", x, y, c)
+			}
+		}
+	}
+}
+
+// blurOps returns a blur of the given sigma
+func blurOps(sigma float64) []models.Operation {
+	return []models.Operation{{Operation: models.OperationBlur, Parameters: map[string]interface{}{"sigma": sigma}}}
+}
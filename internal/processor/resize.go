@@ -1,28 +1,61 @@
 package processor
 
 import (
+	"fmt"
 	"image"
 
 	"github.com/disintegration/imaging"
 )
 
+// Resize fit modes
+const (
+	// FitFill stretches the image to exactly the given dimensions
+	FitFill = "fill"
+	// FitContain scales the image down to fit inside the dimensions, keeping its aspect ratio
+	FitContain = "contain"
+	// FitCover scales and center-crops the image to cover the dimensions
+	FitCover = "cover"
+)
+
 // resize scales the image to the specified dimensions
 func (p *Processor) resize(img *image.NRGBA, params map[string]interface{}) (*image.NRGBA, error) {
 	width := p.getIntParam(params, "width", 0)
 	height := p.getIntParam(params, "height", 0)
+	fit := p.getStringParam(params, "fit", FitFill)
 
 	// If both are 0, return the original
 	if width == 0 && height == 0 {
 		return img, nil
 	}
 
+	// With a single dimension every fit preserves the aspect ratio
+	if width == 0 || height == 0 {
+		fit = FitFill
+	}
+
+	if p.getBoolParam(params, "linear", false) {
+		return resizeLinear(img, width, height, fit)
+	}
+
 	// Use Lanczos resampling for high quality
-	return imaging.Resize(img, width, height, imaging.Lanczos), nil
+	switch fit {
+	case FitFill:
+		return imaging.Resize(img, width, height, imaging.Lanczos), nil
+	case FitContain:
+		return imaging.Fit(img, width, height, imaging.Lanczos), nil
+	case FitCover:
+		return imaging.Fill(img, width, height, imaging.Center, imaging.Lanczos), nil
+	default:
+		return nil, fmt.Errorf("invalid fit: %s", fit)
+	}
 }
 
 // thumbnail creates a square thumbnail of the specified size
 func (p *Processor) thumbnail(img *image.NRGBA, params map[string]interface{}) (*image.NRGBA, error) {
 	size := p.getIntParam(params, "size", 150)
+	if p.getBoolParam(params, "linear", false) {
+		return resizeLinear(img, size, size, FitCover)
+	}
 
 	// Crop and resize to a square thumbnail
 	return imaging.Thumbnail(img, size, size, imaging.Lanczos), nil
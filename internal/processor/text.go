@@ -0,0 +1,317 @@
+package processor
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/disintegration/imaging"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+// Text alignments of the lines within a text block
+const (
+	AlignLeft   = "left"
+	AlignCenter = "center"
+	AlignRight  = "right"
+)
+
+const (
+	// defaultTextSize is the default font size in pixels
+	defaultTextSize = 32
+	// maxTextSize bounds the font size in pixels
+	maxTextSize = 1000
+	// maxTextLength bounds the characters a text operation draws
+	maxTextLength = 2000
+	// maxStrokeWidth bounds the outline drawn around text, in pixels
+	maxStrokeWidth = 32
+	// maxShadowBlur bounds the standard deviation of the shadow's blur
+	maxShadowBlur = 50
+	// maxShadowOffset bounds how far the shadow is offset along each axis, in pixels
+	maxShadowOffset = 1000
+	// maxTextPadding bounds the padding of the background box, in pixels
+	maxTextPadding = 1000
+)
+
+// textStyle is how a block of text is drawn
+type textStyle struct {
+	fill         color.NRGBA
+	stroke       color.NRGBA
+	shadow       color.NRGBA
+	background   color.NRGBA
+	align        string
+	shadowOffset image.Point
+	shadowBlur   float64
+	lineSpacing  float64
+	strokeWidth  int
+	padding      int
+}
+
+// text draws a caption onto the image. Lines longer than max_width, or than
+// the image less its margins, wrap at spaces.
+func (p *Processor) text(img *image.NRGBA, params map[string]interface{}) (*image.NRGBA, error) {
+	content := strings.ReplaceAll(p.getStringParam(params, "text", ""), "\r\n", "\n")
+	if strings.TrimSpace(content) == "" {
+		return nil, fmt.Errorf("text is required")
+	}
+	if utf8.RuneCountInString(content) > maxTextLength {
+		return nil, fmt.Errorf("text must be at most %d characters", maxTextLength)
+	}
+
+	size := p.getFloatParam(params, "size", defaultTextSize)
+	if size <= 0 || size > maxTextSize {
+		return nil, fmt.Errorf("size must be between 0 and %d", maxTextSize)
+	}
+	f, err := p.loadFont(p.getStringParam(params, "font", DefaultFont))
+	if err != nil {
+		return nil, err
+	}
+
+	style, err := p.getTextStyle(params)
+	if err != nil {
+		return nil, err
+	}
+	pl, err := p.getPlacement(params, GravityBottom)
+	if err != nil {
+		return nil, err
+	}
+	opacity, err := p.getOpacity(params)
+	if err != nil {
+		return nil, err
+	}
+
+	maxWidth := p.getIntParam(params, "max_width", 0)
+	if maxWidth <= 0 {
+		maxWidth = img.Bounds().Dx() - 2*pl.margin
+	}
+	maxWidth = max(1, maxWidth-2*(style.padding+style.strokeWidth))
+
+	face, err := opentype.NewFace(f, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingNone})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load font: %w", err)
+	}
+	defer face.Close()
+
+	layer, box, err := renderText(face, wrapText(face, content, maxWidth), style)
+	if err != nil {
+		return nil, err
+	}
+	at := pl.origin(img.Bounds(), box.Size()).Sub(box.Min)
+	return composite(img, layer, at, opacity), nil
+}
+
+// getTextStyle reads the parameters styling text
+func (p *Processor) getTextStyle(params map[string]interface{}) (textStyle, error) {
+	style := textStyle{
+		align:        p.getStringParam(params, "align", AlignLeft),
+		lineSpacing:  p.getFloatParam(params, "line_spacing", 1.2),
+		strokeWidth:  p.getIntParam(params, "stroke_width", 0),
+		shadowOffset: image.Pt(p.getIntParam(params, "shadow_x", 0), p.getIntParam(params, "shadow_y", 0)),
+		shadowBlur:   p.getFloatParam(params, "shadow_blur", 0),
+	}
+
+	switch style.align {
+	case AlignLeft, AlignCenter, AlignRight:
+	default:
+		return style, fmt.Errorf("invalid align: %s", style.align)
+	}
+	if style.lineSpacing <= 0 || style.lineSpacing > 5 {
+		return style, fmt.Errorf("line_spacing must be between 0 and 5")
+	}
+	if style.strokeWidth < 0 || style.strokeWidth > maxStrokeWidth {
+		return style, fmt.Errorf("stroke_width must be between 0 and %d", maxStrokeWidth)
+	}
+	if style.shadowBlur < 0 || style.shadowBlur > maxShadowBlur {
+		return style, fmt.Errorf("shadow_blur must be between 0 and %d", maxShadowBlur)
+	}
+	if abs(style.shadowOffset.X) > maxShadowOffset || abs(style.shadowOffset.Y) > maxShadowOffset {
+		return style, fmt.Errorf("shadow_x and shadow_y must be between -%d and %d", maxShadowOffset, maxShadowOffset)
+	}
+
+	colors := []struct {
+		key        string
+		dst        *color.NRGBA
+		defaultVal color.NRGBA
+	}{
+		{"color", &style.fill, color.NRGBA{R: 255, G: 255, B: 255, A: 255}},
+		{"stroke_color", &style.stroke, color.NRGBA{A: 255}},
+		{"shadow_color", &style.shadow, color.NRGBA{A: 153}},
+		{"background", &style.background, color.NRGBA{}},
+	}
+	for _, c := range colors {
+		value, err := p.getColorParam(params, c.key, c.defaultVal)
+		if err != nil {
+			return style, err
+		}
+		*c.dst = value
+	}
+
+	// Padding only makes sense around a background box
+	if style.background.A > 0 {
+		style.padding = p.getIntParam(params, "padding", 8)
+		if style.padding < 0 || style.padding > maxTextPadding {
+			return style, fmt.Errorf("padding must be between 0 and %d", maxTextPadding)
+		}
+	}
+	return style, nil
+}
+
+// wrapText breaks text into lines no wider than maxWidth, at spaces where it
+// can. Newlines always break, and words too long for a line break anywhere.
+func wrapText(face font.Face, text string, maxWidth int) []string {
+	fits := func(s string) bool {
+		return font.MeasureString(face, s).Ceil() <= maxWidth
+	}
+
+	var lines []string
+	for _, paragraph := range strings.Split(text, "\n") {
+		line := ""
+		for _, word := range strings.Fields(paragraph) {
+			if line != "" {
+				if candidate := line + " " + word; fits(candidate) {
+					line = candidate
+					continue
+				}
+				lines = append(lines, line)
+			}
+			for !fits(word) {
+				cut := fitPrefix(face, word, maxWidth)
+				if cut == len(word) {
+					break
+				}
+				lines = append(lines, word[:cut])
+				word = word[cut:]
+			}
+			line = word
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// fitPrefix returns the length of the longest prefix of s no wider than
+// maxWidth, but at least one character
+func fitPrefix(face font.Face, s string, maxWidth int) int {
+	end := 0
+	for i, r := range s {
+		next := i + utf8.RuneLen(r)
+		if end > 0 && font.MeasureString(face, s[:next]).Ceil() > maxWidth {
+			break
+		}
+		end = next
+	}
+	return end
+}
+
+// renderText draws lines of text onto a transparent layer, returning it with
+// the box the text and its background take up. The shadow may extend past the box.
+// Layers larger than maxCanvasDimension on either side are rejected.
+func renderText(face font.Face, lines []string, style textStyle) (*image.NRGBA, image.Rectangle, error) {
+	metrics := face.Metrics()
+	ascent, descent := metrics.Ascent.Ceil(), metrics.Descent.Ceil()
+	lineHeight := max(1, int(math.Round(float64(metrics.Height)/64*style.lineSpacing)))
+
+	widths := make([]int, len(lines))
+	textWidth := 0
+	for i, line := range lines {
+		widths[i] = font.MeasureString(face, line).Ceil()
+		textWidth = max(textWidth, widths[i])
+	}
+	textHeight := ascent + descent + (len(lines)-1)*lineHeight
+
+	inset := style.padding + style.strokeWidth
+	box := image.Rect(0, 0, textWidth+2*inset, textHeight+2*inset)
+	bounds := box
+	hasShadow := style.shadowOffset != (image.Point{}) || style.shadowBlur > 0
+	if hasShadow {
+		spread := int(math.Ceil(3 * style.shadowBlur))
+		bounds = bounds.Union(box.Add(style.shadowOffset).Inset(-spread))
+	}
+	if bounds.Dx() > maxCanvasDimension || bounds.Dy() > maxCanvasDimension {
+		return nil, image.Rectangle{}, fmt.Errorf("text of %dx%d exceeds %d pixels per side", bounds.Dx(), bounds.Dy(), maxCanvasDimension)
+	}
+	// Keep the layer's origin at 0, 0
+	box = box.Sub(bounds.Min)
+	bounds = bounds.Sub(bounds.Min)
+
+	glyphs := image.NewAlpha(bounds)
+	drawer := font.Drawer{Dst: glyphs, Src: image.Opaque, Face: face}
+	for i, line := range lines {
+		x := box.Min.X + inset
+		switch style.align {
+		case AlignCenter:
+			x += (textWidth - widths[i]) / 2
+		case AlignRight:
+			x += textWidth - widths[i]
+		}
+		drawer.Dot = fixed.P(x, box.Min.Y+inset+ascent+i*lineHeight)
+		drawer.DrawString(line)
+	}
+
+	outline := glyphs
+	if style.strokeWidth > 0 {
+		outline = dilate(glyphs, style.strokeWidth)
+	}
+
+	layer := image.NewNRGBA(bounds)
+	if style.background.A > 0 {
+		draw.Draw(layer, box, image.NewUniform(style.background), image.Point{}, draw.Src)
+	}
+	if hasShadow && style.shadow.A > 0 {
+		shadow := image.NewNRGBA(bounds)
+		draw.DrawMask(shadow, bounds, image.NewUniform(style.shadow), image.Point{}, outline, image.Point{}.Sub(style.shadowOffset), draw.Src)
+		if style.shadowBlur > 0 {
+			shadow = imaging.Blur(shadow, style.shadowBlur)
+		}
+		draw.Draw(layer, bounds, shadow, image.Point{}, draw.Over)
+	}
+	if style.strokeWidth > 0 && style.stroke.A > 0 {
+		draw.DrawMask(layer, bounds, image.NewUniform(style.stroke), image.Point{}, outline, image.Point{}, draw.Over)
+	}
+	draw.DrawMask(layer, bounds, image.NewUniform(style.fill), image.Point{}, glyphs, image.Point{}, draw.Over)
+	return layer, box, nil
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// dilate grows the shapes of a mask by radius pixels in every direction,
+// which outlines text when the original is drawn over the result
+func dilate(mask *image.Alpha, radius int) *image.Alpha {
+	var disk []image.Point
+	for dy := -radius; dy <= radius; dy++ {
+		for dx := -radius; dx <= radius; dx++ {
+			if dx*dx+dy*dy <= radius*radius {
+				disk = append(disk, image.Pt(dx, dy))
+			}
+		}
+	}
+
+	bounds := mask.Bounds()
+	out := image.NewAlpha(bounds)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			a := mask.AlphaAt(x, y).A
+			if a == 0 {
+				continue
+			}
+			for _, d := range disk {
+				pt := image.Pt(x+d.X, y+d.Y)
+				if pt.In(bounds) && out.AlphaAt(pt.X, pt.Y).A < a {
+					out.SetAlpha(pt.X, pt.Y, color.Alpha{A: a})
+				}
+			}
+		}
+	}
+	return out
+}
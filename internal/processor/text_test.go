@@ -0,0 +1,265 @@
+package processor
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+
+	"github.com/disintegration/imaging"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+
+	"github.com/timkrebs/image-processor/internal/models"
+)
+
+// textBounds returns the bounds of the pixels of img that differ from the background
+func textBounds(img *image.NRGBA, background color.NRGBA) image.Rectangle {
+	var r image.Rectangle
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if img.NRGBAAt(x, y) != background {
+				r = r.Union(image.Rect(x, y, x+1, y+1))
+			}
+		}
+	}
+	return r
+}
+
+func TestProcessor_text(t *testing.T) {
+	p := New()
+	black := color.NRGBA{A: 255}
+	img := imaging.New(200, 100, black)
+
+	result, err := p.text(img, map[string]interface{}{"text": "Hi", "position": "top-left", "margin": float64(5)})
+	if err != nil {
+		t.Fatalf("text() error = %v", err)
+	}
+	drawn := textBounds(result, black)
+	if drawn.Empty() {
+		t.Fatal("text() drew nothing")
+	}
+	if drawn.Min.X < 5 || drawn.Min.Y < 5 || drawn.Max.X > 100 || drawn.Max.Y > 50 {
+		t.Errorf("text drawn at %v, want near the top-left corner", drawn)
+	}
+	if img.NRGBAAt(drawn.Min.X, drawn.Min.Y) != black {
+		t.Error("text() should not change the input image")
+	}
+
+	bottomRight, err := p.text(img, map[string]interface{}{"text": "Hi", "position": "bottom-right", "margin": float64(0)})
+	if err != nil {
+		t.Fatalf("text() error = %v", err)
+	}
+	if drawn := textBounds(bottomRight, black); drawn.Max.X < 190 || drawn.Max.Y < 85 {
+		t.Errorf("text drawn at %v, want in the bottom-right corner", drawn)
+	}
+}
+
+func TestProcessor_text_Style(t *testing.T) {
+	p := New()
+	black := color.NRGBA{A: 255}
+	img := imaging.New(200, 100, black)
+	base := map[string]interface{}{"text": "Hi", "position": "center", "color": "#ff0000"}
+
+	with := func(extra map[string]interface{}) *image.NRGBA {
+		t.Helper()
+		params := map[string]interface{}{}
+		for k, v := range base {
+			params[k] = v
+		}
+		for k, v := range extra {
+			params[k] = v
+		}
+		result, err := p.text(img, params)
+		if err != nil {
+			t.Fatalf("text(%v) error = %v", extra, err)
+		}
+		return result
+	}
+
+	plain := textBounds(with(nil), black)
+	stroked := textBounds(with(map[string]interface{}{"stroke_width": float64(4), "stroke_color": "white"}), black)
+	if stroked.Dx() <= plain.Dx() || stroked.Dy() <= plain.Dy() {
+		t.Errorf("stroked text %v should be larger than %v", stroked, plain)
+	}
+
+	shadowed := with(map[string]interface{}{"shadow_x": float64(10), "shadow_y": float64(10), "shadow_color": "blue"})
+	if drawn := textBounds(shadowed, black); drawn.Max.X < plain.Max.X+8 || drawn.Max.Y < plain.Max.Y+8 {
+		t.Errorf("shadowed text %v should extend past %v", drawn, plain)
+	}
+
+	boxed := with(map[string]interface{}{"background": "#00ff00", "padding": float64(10)})
+	drawn := textBounds(boxed, black)
+	if c := boxed.NRGBAAt(drawn.Min.X, drawn.Min.Y); c != (color.NRGBA{G: 255, A: 255}) {
+		t.Errorf("box corner = %v, want the background color", c)
+	}
+	if drawn.Dx() < plain.Dx()+20 {
+		t.Errorf("box %v should pad text %v", drawn, plain)
+	}
+
+	faded := with(map[string]interface{}{"background": "#00ff00", "opacity": 0.5})
+	drawn = textBounds(faded, black)
+	if c := faded.NRGBAAt(drawn.Min.X, drawn.Min.Y); c.G < 120 || c.G > 135 {
+		t.Errorf("half-opaque box = %v, want half green", c)
+	}
+}
+
+func TestProcessor_text_Invalid(t *testing.T) {
+	p := New()
+	img := createTestImage(50, 50)
+
+	for _, params := range []map[string]interface{}{
+		{},
+		{"text": "  "},
+		{"text": strings.Repeat("x", maxTextLength+1)},
+		{"text": "hi", "size": float64(0)},
+		{"text": "hi", "font": "comic-sans"},
+		{"text": "hi", "color": "nope"},
+		{"text": "hi", "position": "middle"},
+		{"text": "hi", "anchor": "middle"},
+		{"text": "hi", "align": "justify"},
+		{"text": "hi", "stroke_width": float64(maxStrokeWidth + 1)},
+		{"text": "hi", "opacity": 1.5},
+		{"text": "hi", "margin": float64(-1)},
+	} {
+		if _, err := p.text(img, params); err == nil {
+			t.Errorf("text(%v) should fail", params)
+		}
+	}
+}
+
+func TestProcessor_text_Oversized(t *testing.T) {
+	p := New()
+	data := encodeTestImage(t, createTestImage(50, 50), "png")
+
+	for _, params := range []map[string]interface{}{
+		{"text": "a", "background": "red", "padding": 5e9},
+		{"text": "a", "background": "red", "padding": float64(maxTextPadding + 1)},
+		{"text": "a", "shadow_x": 5e9},
+		{"text": "a", "shadow_y": float64(-maxShadowOffset - 1)},
+		// Within every bound, but too tall a layer
+		{"text": strings.Repeat("a\n", 999), "size": float64(maxTextSize), "line_spacing": float64(5)},
+	} {
+		ops := []models.Operation{{Operation: models.OperationText, Parameters: params}}
+		if _, err := p.Process(bytes.NewReader(data), "image/png", ops); err == nil {
+			t.Errorf("Process() with %v should fail", params)
+		}
+	}
+}
+
+func TestProcessor_text_UserFont(t *testing.T) {
+	img := createTestImage(100, 50)
+	params := map[string]interface{}{"text": "hi", "font": "my-font"}
+
+	if _, err := New().text(img, params); err == nil {
+		t.Error("text() should reject fonts the processor wasn't given")
+	}
+	p := New().WithFonts(map[string][]byte{"my-font": goregular.TTF})
+	if _, err := p.text(img, params); err != nil {
+		t.Errorf("text() error = %v", err)
+	}
+}
+
+func TestWrapText(t *testing.T) {
+	f, err := opentype.Parse(goregular.TTF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	face, err := opentype.NewFace(f, &opentype.FaceOptions{Size: 20, DPI: 72})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer face.Close()
+
+	width := font.MeasureString(face, "hello world").Ceil()
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"hello world", []string{"hello world"}},
+		{"hello world again", []string{"hello world", "again"}},
+		{"hello\n\nworld", []string{"hello", "", "world"}},
+		{"  hello   world  ", []string{"hello world"}},
+	}
+
+	for _, tt := range tests {
+		got := wrapText(face, tt.text, width)
+		if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+			t.Errorf("wrapText(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+
+	for _, text := range []string{strings.Repeat("word ", 50), strings.Repeat("unbreakable", 5)} {
+		lines := wrapText(face, text, width)
+		if len(lines) < 2 {
+			t.Errorf("wrapText(%q) = %q, want several lines", text, lines)
+		}
+		for _, line := range lines {
+			if w := font.MeasureString(face, line).Ceil(); w > width {
+				t.Errorf("line %q is %dpx wide, want at most %d", line, w, width)
+			}
+		}
+	}
+	if got := wrapText(face, strings.Repeat("unbreakable", 5), width); strings.Join(got, "") != strings.Repeat("unbreakable", 5) {
+		t.Errorf("breaking a long word lost characters: %q", got)
+	}
+}
+
+func TestPlacement_origin(t *testing.T) {
+	canvas := image.Rect(0, 0, 100, 50)
+	size := image.Pt(20, 10)
+
+	tests := []struct {
+		pl   placement
+		want image.Point
+	}{
+		{placement{position: GravityTopLeft, anchor: GravityTopLeft, margin: 5}, image.Pt(5, 5)},
+		{placement{position: GravityBottomRight, anchor: GravityBottomRight, margin: 5}, image.Pt(75, 35)},
+		{placement{position: GravityCenter, anchor: GravityCenter}, image.Pt(40, 20)},
+		{placement{position: GravityCenter, anchor: GravityTopLeft}, image.Pt(50, 25)},
+		{placement{at: &image.Point{X: 30, Y: 40}, anchor: GravityBottom}, image.Pt(20, 30)},
+	}
+
+	for _, tt := range tests {
+		if got := tt.pl.origin(canvas, size); got != tt.want {
+			t.Errorf("origin(%+v) = %v, want %v", tt.pl, got, tt.want)
+		}
+	}
+}
+
+func TestProcessor_Process_Text(t *testing.T) {
+	p := New()
+	data := encodeTestImage(t, createSolidImage(120, 60, color.NRGBA{A: 255}), "png")
+
+	operations := []models.Operation{
+		{Operation: models.OperationText, Parameters: map[string]interface{}{"text": "Sale", "size": float64(24)}},
+	}
+	result, err := p.Process(bytes.NewReader(data), "image/png", operations)
+	if err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+	if result.Width != 120 || result.Height != 60 {
+		t.Errorf("size = %dx%d, want 120x60", result.Width, result.Height)
+	}
+}
+
+func TestParseFont(t *testing.T) {
+	name, err := ParseFont(goregular.TTF)
+	if err != nil {
+		t.Fatalf("ParseFont() error = %v", err)
+	}
+	if name != "Go" {
+		t.Errorf("ParseFont() = %q, want Go", name)
+	}
+	if _, err := ParseFont([]byte("not a font")); err == nil {
+		t.Error("ParseFont() should reject invalid data")
+	}
+	for _, name := range BuiltinFonts() {
+		if _, err := New().loadFont(name); err != nil {
+			t.Errorf("loadFont(%s) error = %v", name, err)
+		}
+	}
+}
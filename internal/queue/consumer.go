@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"sync"
 	"time"
 
 	"github.com/redis/go-redis/v9"
@@ -16,18 +17,29 @@ import (
 // ErrNoMessages is returned when no messages are available in the stream
 var ErrNoMessages = errors.New("no messages available")
 
-// Consumer reads jobs from the Redis stream
+// DefaultWeights are the relative read shares of each priority when all streams have work
+var DefaultWeights = map[models.JobPriority]int{
+	models.PriorityInteractive: 6,
+	models.PriorityDefault:     3,
+	models.PriorityBulk:        1,
+}
+
+// Consumer reads jobs from the Redis priority streams
 type Consumer struct {
 	client        *redis.Client
 	logger        *slog.Logger
+	weights       map[models.JobPriority]int
+	credits       map[models.JobPriority]int
 	streamName    string
 	consumerGroup string
 	consumerName  string
 	pollTimeout   time.Duration
+	mu            sync.Mutex
 }
 
 // ConsumerConfig holds consumer configuration
 type ConsumerConfig struct {
+	Weights       map[models.JobPriority]int
 	StreamName    string
 	ConsumerGroup string
 	ConsumerName  string
@@ -36,58 +48,101 @@ type ConsumerConfig struct {
 
 // NewConsumer creates a new queue consumer
 func NewConsumer(client *redis.Client, cfg ConsumerConfig, logger *slog.Logger) *Consumer {
+	weights := make(map[models.JobPriority]int, len(models.Priorities))
+	for _, priority := range models.Priorities {
+		weight, ok := cfg.Weights[priority]
+		if !ok || weight < 1 {
+			// Every tier keeps a minimum share so bulk work never starves
+			weight = DefaultWeights[priority]
+		}
+		weights[priority] = weight
+	}
+
 	return &Consumer{
 		client:        client,
 		streamName:    cfg.StreamName,
 		consumerGroup: cfg.ConsumerGroup,
 		consumerName:  cfg.ConsumerName,
 		pollTimeout:   cfg.PollTimeout,
+		weights:       weights,
+		credits:       make(map[models.JobPriority]int, len(models.Priorities)),
 		logger:        logger,
 	}
 }
 
-// EnsureGroup creates the consumer group if it doesn't exist
+// EnsureGroup creates the consumer group on every priority stream if it doesn't exist
 func (c *Consumer) EnsureGroup(ctx context.Context) error {
-	err := c.client.XGroupCreateMkStream(ctx, c.streamName, c.consumerGroup, "0").Err()
-	if err != nil && err.Error() != "BUSYGROUP Consumer Group name already exists" {
-		return fmt.Errorf("failed to create consumer group: %w", err)
+	for _, priority := range models.Priorities {
+		stream := StreamName(c.streamName, priority)
+		err := c.client.XGroupCreateMkStream(ctx, stream, c.consumerGroup, "0").Err()
+		if err != nil && err.Error() != "BUSYGROUP Consumer Group name already exists" {
+			return fmt.Errorf("failed to create consumer group on %s: %w", stream, err)
+		}
 	}
 	return nil
 }
 
 // Message represents a message from the queue
 type Message struct {
-	ID   string
-	Job  *models.JobMessage
-	Data string
+	ID       string
+	Stream   string
+	Priority models.JobPriority
+	Job      *models.JobMessage
+	Data     string
 }
 
-// Consume reads messages from the queue
+// Consume reads the next message, preferring higher priorities by weight
 func (c *Consumer) Consume(ctx context.Context) (*Message, error) {
 	// First, try to read pending messages (messages that were read but not acknowledged)
-	pendingMessages, err := c.client.XReadGroup(ctx, &redis.XReadGroupArgs{
-		Group:    c.consumerGroup,
-		Consumer: c.consumerName,
-		Streams:  []string{c.streamName, "0"},
-		Count:    1,
-		Block:    0, // Non-blocking for pending
-	}).Result()
-	if err != nil && err != redis.Nil {
+	msg, err := c.readPending(ctx)
+	if err != nil || msg != nil {
+		return msg, err
+	}
+
+	// No pending messages, try each stream without blocking in weighted order
+	for _, priority := range c.nextOrder() {
+		msg, err := c.read(ctx, []models.JobPriority{priority}, ">", -1)
+		if err != nil && !errors.Is(err, ErrNoMessages) {
+			return nil, err
+		}
+		if msg != nil {
+			return msg, nil
+		}
+	}
+
+	// All streams are empty, block until any of them receives a message
+	return c.read(ctx, models.Priorities, ">", c.pollTimeout)
+}
+
+// readPending returns the first pending message across streams in priority order
+func (c *Consumer) readPending(ctx context.Context) (*Message, error) {
+	msg, err := c.read(ctx, models.Priorities, "0", 0) // Non-blocking for pending
+	if errors.Is(err, ErrNoMessages) {
+		return nil, nil
+	}
+	if err != nil {
 		return nil, fmt.Errorf("failed to read pending messages: %w", err)
 	}
+	return msg, nil
+}
 
-	// Check if we got pending messages
-	if len(pendingMessages) > 0 && len(pendingMessages[0].Messages) > 0 {
-		return c.parseMessage(pendingMessages[0].Messages[0])
+// read issues a single XREADGROUP over the given priority streams and returns
+// the first message found, scanning the results in the order given
+func (c *Consumer) read(ctx context.Context, priorities []models.JobPriority, id string, block time.Duration) (*Message, error) {
+	streams := make([]string, 0, len(priorities)*2)
+	for _, priority := range priorities {
+		streams = append(streams, StreamName(c.streamName, priority))
+	}
+	for range priorities {
+		streams = append(streams, id)
 	}
 
-	// No pending messages, read new messages
-	streams, err := c.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+	result, err := c.client.XReadGroup(ctx, &redis.XReadGroupArgs{
 		Group:    c.consumerGroup,
 		Consumer: c.consumerName,
-		Streams:  []string{c.streamName, ">"},
+		Streams:  streams,
 		Count:    1,
-		Block:    c.pollTimeout,
+		Block:    block,
 	}).Result()
 	if err != nil {
 		if err == redis.Nil {
@@ -96,14 +151,57 @@ func (c *Consumer) Consume(ctx context.Context) (*Message, error) {
 		return nil, fmt.Errorf("failed to read from stream: %w", err)
 	}
 
-	if len(streams) == 0 || len(streams[0].Messages) == 0 {
-		return nil, ErrNoMessages
+	for _, priority := range priorities {
+		stream := StreamName(c.streamName, priority)
+		for _, s := range result {
+			if s.Stream == stream && len(s.Messages) > 0 {
+				msg, err := c.parseMessage(stream, priority, s.Messages[0])
+				if err != nil {
+					// A malformed message would be redelivered forever, so set it aside
+					data, _ := s.Messages[0].Values["data"].(string)
+					poison := &Message{ID: s.Messages[0].ID, Stream: stream, Priority: priority, Data: data}
+					if dlqErr := c.DeadLetter(ctx, poison, err.Error()); dlqErr != nil {
+						return nil, dlqErr
+					}
+					c.logger.Warn("moved malformed message to dead-letter stream", "stream", stream, "id", poison.ID, "error", err)
+					return nil, ErrNoMessages
+				}
+				return msg, nil
+			}
+		}
+	}
+
+	return nil, ErrNoMessages
+}
+
+// nextOrder returns the priorities to poll, led by the next pick of a smooth
+// weighted round-robin and followed by the rest from highest to lowest
+func (c *Consumer) nextOrder() []models.JobPriority {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	total := 0
+	var best models.JobPriority
+	for _, priority := range models.Priorities {
+		c.credits[priority] += c.weights[priority]
+		total += c.weights[priority]
+		if best == "" || c.credits[priority] > c.credits[best] {
+			best = priority
+		}
 	}
+	c.credits[best] -= total
 
-	return c.parseMessage(streams[0].Messages[0])
+	order := make([]models.JobPriority, 0, len(models.Priorities))
+	order = append(order, best)
+	for _, priority := range models.Priorities {
+		if priority != best {
+			order = append(order, priority)
+		}
+	}
+	return order
 }
 
-func (c *Consumer) parseMessage(redisMsg redis.XMessage) (*Message, error) {
+func (c *Consumer) parseMessage(stream string, priority models.JobPriority, redisMsg redis.XMessage) (*Message, error) {
 	data, ok := redisMsg.Values["data"].(string)
 	if !ok {
 		return nil, fmt.Errorf("invalid message format: missing data field")
@@ -115,26 +213,36 @@ func (c *Consumer) parseMessage(redisMsg redis.XMessage) (*Message, error) {
 	}
 
 	return &Message{
-		ID:   redisMsg.ID,
-		Job:  &jobMsg,
-		Data: data,
+		ID:       redisMsg.ID,
+		Stream:   stream,
+		Priority: priority,
+		Job:      &jobMsg,
+		Data:     data,
 	}, nil
 }
 
-// Acknowledge marks a message as processed
-func (c *Consumer) Acknowledge(ctx context.Context, messageID string) error {
-	_, err := c.client.XAck(ctx, c.streamName, c.consumerGroup, messageID).Result()
+// Acknowledge marks a message as processed on the stream it was read from
+func (c *Consumer) Acknowledge(ctx context.Context, msg *Message) error {
+	stream := msg.Stream
+	if stream == "" {
+		stream = c.streamName
+	}
+	_, err := c.client.XAck(ctx, stream, c.consumerGroup, msg.ID).Result()
 	if err != nil {
 		return fmt.Errorf("failed to acknowledge message: %w", err)
 	}
 	return nil
 }
 
-// GetPendingCount returns the number of pending messages in the consumer group
+// GetPendingCount returns the number of pending messages across all priority streams
 func (c *Consumer) GetPendingCount(ctx context.Context) (int64, error) {
-	pending, err := c.client.XPending(ctx, c.streamName, c.consumerGroup).Result()
-	if err != nil {
-		return 0, err
+	var total int64
+	for _, priority := range models.Priorities {
+		pending, err := c.client.XPending(ctx, StreamName(c.streamName, priority), c.consumerGroup).Result()
+		if err != nil {
+			return 0, err
+		}
+		total += pending.Count
 	}
-	return pending.Count, nil
+	return total, nil
 }
@@ -0,0 +1,229 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/timkrebs/image-processor/internal/models"
+)
+
+// DeadLetterStream returns the stream holding messages that could not be processed
+func DeadLetterStream(base string) string {
+	return base + ":dlq"
+}
+
+// DeadLetter is a message that was moved to the dead-letter stream
+type DeadLetter struct {
+	ID       string             `json:"id"`
+	Stream   string             `json:"stream"`
+	Reason   string             `json:"reason"`
+	FailedAt time.Time          `json:"failed_at"`
+	Data     string             `json:"data"`
+	Job      *models.JobMessage `json:"job,omitempty"` // nil if Data is not a job message
+}
+
+// DeadLetter moves a message to the dead-letter stream and acknowledges it, so it
+// is kept for inspection and replay instead of being redelivered
+func (c *Consumer) DeadLetter(ctx context.Context, msg *Message, reason string) error {
+	stream := msg.Stream
+	if stream == "" {
+		stream = c.streamName
+	}
+
+	pipe := c.client.TxPipeline()
+	pipe.XAdd(ctx, &redis.XAddArgs{
+		Stream: DeadLetterStream(c.streamName),
+		Values: map[string]interface{}{
+			"data":      msg.Data,
+			"stream":    stream,
+			"reason":    reason,
+			"failed_at": time.Now().UTC().Format(time.RFC3339),
+		},
+	})
+	pipe.XAck(ctx, stream, c.consumerGroup, msg.ID)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to dead-letter message: %w", err)
+	}
+	return nil
+}
+
+// Drain moves every message that has not been delivered to a worker yet to the
+// dead-letter stream, from where it can be replayed. It returns the number of
+// messages moved.
+func (c *Consumer) Drain(ctx context.Context, reason string) (int, error) {
+	drained := 0
+	for {
+		msg, err := c.read(ctx, models.Priorities, ">", -1)
+		if errors.Is(err, ErrNoMessages) {
+			break
+		}
+		if err != nil {
+			return drained, err
+		}
+		if err := c.DeadLetter(ctx, msg, reason); err != nil {
+			return drained, err
+		}
+		drained++
+	}
+
+	// Nothing is left pending for the draining consumer, so drop it from the groups
+	for _, priority := range models.Priorities {
+		stream := StreamName(c.streamName, priority)
+		if err := c.client.XGroupDelConsumer(ctx, stream, c.consumerGroup, c.consumerName).Err(); err != nil {
+			return drained, fmt.Errorf("failed to remove consumer from %s: %w", stream, err)
+		}
+	}
+
+	return drained, nil
+}
+
+// DeadLetters returns up to count dead-lettered messages, oldest first
+func (p *Producer) DeadLetters(ctx context.Context, count int64) ([]DeadLetter, error) {
+	messages, err := p.client.XRangeN(ctx, DeadLetterStream(p.streamName), "-", "+", count).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read dead letters: %w", err)
+	}
+
+	letters := make([]DeadLetter, 0, len(messages))
+	for _, m := range messages {
+		letter := DeadLetter{ID: m.ID}
+		letter.Data, _ = m.Values["data"].(string)
+		letter.Stream, _ = m.Values["stream"].(string)
+		letter.Reason, _ = m.Values["reason"].(string)
+		if failedAt, ok := m.Values["failed_at"].(string); ok {
+			letter.FailedAt, _ = time.Parse(time.RFC3339, failedAt)
+		}
+
+		var job models.JobMessage
+		if err := json.Unmarshal([]byte(letter.Data), &job); err == nil {
+			letter.Job = &job
+		}
+		letters = append(letters, letter)
+	}
+
+	return letters, nil
+}
+
+// RemoveDeadLetter deletes a message from the dead-letter stream
+func (p *Producer) RemoveDeadLetter(ctx context.Context, id string) error {
+	if err := p.client.XDel(ctx, DeadLetterStream(p.streamName), id).Err(); err != nil {
+		return fmt.Errorf("failed to remove dead letter: %w", err)
+	}
+	return nil
+}
+
+// Trim removes messages from the priority streams that every consumer group has
+// already acknowledged. Undelivered and pending messages are kept. It returns
+// the number of messages removed.
+func (p *Producer) Trim(ctx context.Context) (int64, error) {
+	var trimmed int64
+	for _, priority := range models.Priorities {
+		stream := StreamName(p.streamName, priority)
+
+		minID, err := p.acknowledgedBefore(ctx, stream)
+		if err != nil {
+			return trimmed, err
+		}
+		if minID == "" {
+			continue
+		}
+
+		n, err := p.client.XTrimMinID(ctx, stream, minID).Result()
+		if err != nil {
+			return trimmed, fmt.Errorf("failed to trim %s: %w", stream, err)
+		}
+		trimmed += n
+	}
+	return trimmed, nil
+}
+
+// acknowledgedBefore returns the lowest message ID on stream that some consumer
+// group still needs, or "" if nothing on the stream can be trimmed
+func (p *Producer) acknowledgedBefore(ctx context.Context, stream string) (string, error) {
+	length, err := p.client.XLen(ctx, stream).Result()
+	if err != nil {
+		return "", fmt.Errorf("failed to get stream length: %w", err)
+	}
+	if length == 0 {
+		return "", nil
+	}
+
+	groups, err := p.client.XInfoGroups(ctx, stream).Result()
+	if err != nil {
+		return "", fmt.Errorf("failed to get consumer groups of %s: %w", stream, err)
+	}
+	if len(groups) == 0 {
+		// Without a group nobody has consumed anything yet
+		return "", nil
+	}
+
+	minID := ""
+	for _, group := range groups {
+		needed := nextStreamID(group.LastDeliveredID)
+		if group.Pending > 0 {
+			pending, err := p.client.XPending(ctx, stream, group.Name).Result()
+			if err != nil {
+				return "", fmt.Errorf("failed to get pending messages of %s: %w", stream, err)
+			}
+			needed = pending.Lower
+		}
+		if minID == "" || compareStreamIDs(needed, minID) < 0 {
+			minID = needed
+		}
+	}
+	return minID, nil
+}
+
+// nextStreamID returns the smallest stream ID greater than id
+func nextStreamID(id string) string {
+	ms, seq, ok := parseStreamID(id)
+	if !ok {
+		return id
+	}
+	if seq == ^uint64(0) {
+		return strconv.FormatUint(ms+1, 10) + "-0"
+	}
+	return strconv.FormatUint(ms, 10) + "-" + strconv.FormatUint(seq+1, 10)
+}
+
+// compareStreamIDs orders two stream IDs like strings.Compare
+func compareStreamIDs(a, b string) int {
+	ams, aseq, _ := parseStreamID(a)
+	bms, bseq, _ := parseStreamID(b)
+	switch {
+	case ams != bms:
+		if ams < bms {
+			return -1
+		}
+		return 1
+	case aseq != bseq:
+		if aseq < bseq {
+			return -1
+		}
+		return 1
+	}
+	return 0
+}
+
+func parseStreamID(id string) (uint64, uint64, bool) {
+	msPart, seqPart, _ := strings.Cut(id, "-")
+	ms, err := strconv.ParseUint(msPart, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	if seqPart == "" {
+		return ms, 0, true
+	}
+	seq, err := strconv.ParseUint(seqPart, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	return ms, seq, true
+}
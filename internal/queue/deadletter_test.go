@@ -0,0 +1,192 @@
+package queue
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/timkrebs/image-processor/internal/models"
+)
+
+func TestNextStreamID(t *testing.T) {
+	tests := []struct {
+		id   string
+		want string
+	}{
+		{"0-0", "0-1"},
+		{"1700000000000-5", "1700000000000-6"},
+		{"1700000000000-18446744073709551615", "1700000000001-0"},
+		{"1700000000000", "1700000000000-1"},
+	}
+
+	for _, tt := range tests {
+		if got := nextStreamID(tt.id); got != tt.want {
+			t.Errorf("nextStreamID(%q) = %q, want %q", tt.id, got, tt.want)
+		}
+	}
+}
+
+func TestCompareStreamIDs(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1-0", "1-0", 0},
+		{"1-0", "1-1", -1},
+		{"2-0", "1-9", 1},
+		{"10-0", "9-0", 1},
+	}
+
+	for _, tt := range tests {
+		if got := compareStreamIDs(tt.a, tt.b); got != tt.want {
+			t.Errorf("compareStreamIDs(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestDeadLetter_DrainAndTrim(t *testing.T) {
+	client := getTestRedisClient(t)
+	if client == nil {
+		return
+	}
+	defer client.Close()
+
+	ctx := context.Background()
+	streamName := "test-dlq-" + uuid.New().String()[:8]
+	defer cleanupStream(t, client, streamName)
+	defer client.Del(ctx, DeadLetterStream(streamName))
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	producer := NewProducer(client, streamName)
+	worker := NewConsumer(client, ConsumerConfig{
+		StreamName:    streamName,
+		ConsumerGroup: "test-group",
+		ConsumerName:  "test-consumer",
+		PollTimeout:   100 * time.Millisecond,
+	}, logger)
+	if err := worker.EnsureGroup(ctx); err != nil {
+		t.Fatalf("EnsureGroup() error = %v", err)
+	}
+
+	// One message is processed and acknowledged, one fails, one is never read
+	for range 3 {
+		if err := producer.Enqueue(ctx, &models.JobMessage{JobID: uuid.New()}); err != nil {
+			t.Fatalf("Enqueue() error = %v", err)
+		}
+	}
+	done, err := worker.Consume(ctx)
+	if err != nil {
+		t.Fatalf("Consume() error = %v", err)
+	}
+	if err := worker.Acknowledge(ctx, done); err != nil {
+		t.Fatalf("Acknowledge() error = %v", err)
+	}
+	failed, err := worker.Consume(ctx)
+	if err != nil {
+		t.Fatalf("Consume() error = %v", err)
+	}
+	if err := worker.DeadLetter(ctx, failed, "boom"); err != nil {
+		t.Fatalf("DeadLetter() error = %v", err)
+	}
+
+	trimmed, err := producer.Trim(ctx)
+	if err != nil {
+		t.Fatalf("Trim() error = %v", err)
+	}
+	if trimmed != 2 {
+		t.Errorf("Trim() = %d, want 2", trimmed)
+	}
+
+	drainer := NewConsumer(client, ConsumerConfig{
+		StreamName:    streamName,
+		ConsumerGroup: "test-group",
+		ConsumerName:  "test-drain",
+	}, logger)
+	drained, err := drainer.Drain(ctx, "drained")
+	if err != nil {
+		t.Fatalf("Drain() error = %v", err)
+	}
+	if drained != 1 {
+		t.Errorf("Drain() = %d, want 1", drained)
+	}
+
+	letters, err := producer.DeadLetters(ctx, 10)
+	if err != nil {
+		t.Fatalf("DeadLetters() error = %v", err)
+	}
+	if len(letters) != 2 {
+		t.Fatalf("DeadLetters() returned %d letters, want 2", len(letters))
+	}
+	if letters[0].Reason != "boom" || letters[0].Job == nil || letters[0].Job.JobID != failed.Job.JobID {
+		t.Errorf("first dead letter = %+v, want the failed job", letters[0])
+	}
+	if letters[1].Reason != "drained" || letters[1].Stream != streamName {
+		t.Errorf("second dead letter = %+v, want the drained job", letters[1])
+	}
+
+	if err := producer.RemoveDeadLetter(ctx, letters[0].ID); err != nil {
+		t.Fatalf("RemoveDeadLetter() error = %v", err)
+	}
+	stats, err := producer.GetStats(ctx, "test-group")
+	if err != nil {
+		t.Fatalf("GetStats() error = %v", err)
+	}
+	if stats.DeadLetters != 1 {
+		t.Errorf("DeadLetters = %d, want 1", stats.DeadLetters)
+	}
+}
+
+func TestConsumer_DeadLettersMalformedMessages(t *testing.T) {
+	client := getTestRedisClient(t)
+	if client == nil {
+		return
+	}
+	defer client.Close()
+
+	ctx := context.Background()
+	streamName := "test-poison-" + uuid.New().String()[:8]
+	defer cleanupStream(t, client, streamName)
+	defer client.Del(ctx, DeadLetterStream(streamName))
+
+	consumer := NewConsumer(client, ConsumerConfig{
+		StreamName:    streamName,
+		ConsumerGroup: "test-group",
+		ConsumerName:  "test-consumer",
+		PollTimeout:   100 * time.Millisecond,
+	}, slog.New(slog.NewTextHandler(os.Stdout, nil)))
+	if err := consumer.EnsureGroup(ctx); err != nil {
+		t.Fatalf("EnsureGroup() error = %v", err)
+	}
+
+	if err := client.XAdd(ctx, &redis.XAddArgs{
+		Stream: streamName,
+		Values: map[string]interface{}{"data": "not json"},
+	}).Err(); err != nil {
+		t.Fatalf("XAdd() error = %v", err)
+	}
+
+	if _, err := consumer.Consume(ctx); err != ErrNoMessages {
+		t.Fatalf("Consume() error = %v, want ErrNoMessages", err)
+	}
+
+	pending, err := consumer.GetPendingCount(ctx)
+	if err != nil {
+		t.Fatalf("GetPendingCount() error = %v", err)
+	}
+	if pending != 0 {
+		t.Errorf("PendingCount = %d, want 0", pending)
+	}
+
+	letters, err := NewProducer(client, streamName).DeadLetters(ctx, 10)
+	if err != nil {
+		t.Fatalf("DeadLetters() error = %v", err)
+	}
+	if len(letters) != 1 || letters[0].Data != "not json" || letters[0].Job != nil {
+		t.Errorf("DeadLetters() = %+v, want the malformed message", letters)
+	}
+}
@@ -7,12 +7,23 @@ import (
 
 	"github.com/redis/go-redis/v9"
 
+	"github.com/timkrebs/image-processor/internal/metrics"
 	"github.com/timkrebs/image-processor/internal/models"
 )
 
+// StreamName returns the stream that jobs of the given priority are published to.
+// Default priority jobs use the base stream so existing deployments keep working.
+func StreamName(base string, priority models.JobPriority) string {
+	if priority == "" || priority == models.PriorityDefault {
+		return base
+	}
+	return base + ":" + string(priority)
+}
+
 // Producer publishes jobs to the Redis stream
 type Producer struct {
 	client     *redis.Client
+	metrics    *metrics.QueueMetrics
 	streamName string
 }
 
@@ -24,7 +35,12 @@ func NewProducer(client *redis.Client, streamName string) *Producer {
 	}
 }
 
-// Enqueue adds a job to the queue
+// SetMetrics injects metrics collectors into the producer
+func (p *Producer) SetMetrics(m *metrics.QueueMetrics) {
+	p.metrics = m
+}
+
+// Enqueue adds a job to the stream matching its priority
 func (p *Producer) Enqueue(ctx context.Context, msg *models.JobMessage) error {
 	data, err := json.Marshal(msg)
 	if err != nil {
@@ -32,7 +48,7 @@ func (p *Producer) Enqueue(ctx context.Context, msg *models.JobMessage) error {
 	}
 
 	_, err = p.client.XAdd(ctx, &redis.XAddArgs{
-		Stream: p.streamName,
+		Stream: StreamName(p.streamName, msg.Priority),
 		Values: map[string]interface{}{
 			"data": string(data),
 		},
@@ -41,34 +57,71 @@ func (p *Producer) Enqueue(ctx context.Context, msg *models.JobMessage) error {
 		return fmt.Errorf("failed to add to stream: %w", err)
 	}
 
+	if p.metrics != nil {
+		p.metrics.MessagesProduced.Inc()
+	}
+
 	return nil
 }
 
-// GetStreamLength returns the current length of the stream
+// GetStreamLength returns the combined length of all priority streams
 func (p *Producer) GetStreamLength(ctx context.Context) (int64, error) {
-	return p.client.XLen(ctx, p.streamName).Result()
+	var total int64
+	for _, priority := range models.Priorities {
+		length, err := p.client.XLen(ctx, StreamName(p.streamName, priority)).Result()
+		if err != nil {
+			return 0, err
+		}
+		total += length
+	}
+	return total, nil
 }
 
-// GetStats returns queue statistics
+// GetStats returns queue statistics, broken down by priority
 func (p *Producer) GetStats(ctx context.Context, consumerGroup string) (*models.QueueStats, error) {
-	stats := &models.QueueStats{}
+	stats := &models.QueueStats{
+		Priorities: make(map[models.JobPriority]*models.PriorityStats, len(models.Priorities)),
+	}
 
-	// Get stream length
-	length, err := p.client.XLen(ctx, p.streamName).Result()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get stream length: %w", err)
+	for _, priority := range models.Priorities {
+		stream := StreamName(p.streamName, priority)
+		ps := &models.PriorityStats{Stream: stream}
+
+		// Get stream length
+		length, err := p.client.XLen(ctx, stream).Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get stream length: %w", err)
+		}
+		ps.StreamLength = length
+
+		// Get pending messages info
+		pending, err := p.client.XPending(ctx, stream, consumerGroup).Result()
+		if err == nil {
+			ps.PendingMessages = pending.Count
+			// Every worker reads all streams, so report the largest consumer set
+			if consumers := int64(len(pending.Consumers)); consumers > stats.ConsumerCount {
+				stats.ConsumerCount = consumers
+			}
+		}
+		// Otherwise the group might not exist yet
+
+		stats.StreamLength += ps.StreamLength
+		stats.PendingMessages += ps.PendingMessages
+		stats.Priorities[priority] = ps
+
+		if p.metrics != nil {
+			p.metrics.DepthByPriority.WithLabelValues(string(priority)).Set(float64(ps.StreamLength))
+		}
 	}
-	stats.StreamLength = length
 
-	// Get pending messages info
-	pending, err := p.client.XPending(ctx, p.streamName, consumerGroup).Result()
+	deadLetters, err := p.client.XLen(ctx, DeadLetterStream(p.streamName)).Result()
 	if err != nil {
-		// Group might not exist yet
-		stats.PendingMessages = 0
-		stats.ConsumerCount = 0
-	} else {
-		stats.PendingMessages = pending.Count
-		stats.ConsumerCount = int64(len(pending.Consumers))
+		return nil, fmt.Errorf("failed to get dead-letter stream length: %w", err)
+	}
+	stats.DeadLetters = deadLetters
+
+	if p.metrics != nil {
+		p.metrics.Depth.Set(float64(stats.StreamLength))
 	}
 
 	return stats, nil
@@ -39,11 +39,13 @@ func getTestRedisClient(t *testing.T) *redis.Client {
 	return client
 }
 
-// cleanupStream deletes the test stream
+// cleanupStream deletes the test stream and its priority streams
 func cleanupStream(t *testing.T, client *redis.Client, streamName string) {
 	t.Helper()
 	ctx := context.Background()
-	client.Del(ctx, streamName)
+	for _, priority := range models.Priorities {
+		client.Del(ctx, StreamName(streamName, priority))
+	}
 }
 
 func TestNewProducer(t *testing.T) {
@@ -89,6 +91,81 @@ func TestNewConsumer(t *testing.T) {
 	}
 }
 
+func TestStreamName(t *testing.T) {
+	tests := []struct {
+		priority models.JobPriority
+		want     string
+	}{
+		{"", "image-jobs"},
+		{models.PriorityDefault, "image-jobs"},
+		{models.PriorityInteractive, "image-jobs:interactive"},
+		{models.PriorityBulk, "image-jobs:bulk"},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.priority), func(t *testing.T) {
+			if got := StreamName("image-jobs", tt.priority); got != tt.want {
+				t.Errorf("StreamName(%q) = %q, want %q", tt.priority, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewConsumer_DefaultWeights(t *testing.T) {
+	client := redis.NewClient(&redis.Options{Addr: "localhost:6379"})
+	defer client.Close()
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	consumer := NewConsumer(client, ConsumerConfig{
+		StreamName: "test-stream",
+		Weights:    map[models.JobPriority]int{models.PriorityBulk: 0, models.PriorityInteractive: 10},
+	}, logger)
+
+	if consumer.weights[models.PriorityInteractive] != 10 {
+		t.Errorf("interactive weight = %d, want 10", consumer.weights[models.PriorityInteractive])
+	}
+	if consumer.weights[models.PriorityDefault] != DefaultWeights[models.PriorityDefault] {
+		t.Errorf("default weight = %d, want %d", consumer.weights[models.PriorityDefault], DefaultWeights[models.PriorityDefault])
+	}
+	if consumer.weights[models.PriorityBulk] != DefaultWeights[models.PriorityBulk] {
+		t.Errorf("bulk weight = %d, want %d", consumer.weights[models.PriorityBulk], DefaultWeights[models.PriorityBulk])
+	}
+}
+
+func TestConsumer_NextOrder_Weighted(t *testing.T) {
+	client := redis.NewClient(&redis.Options{Addr: "localhost:6379"})
+	defer client.Close()
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	consumer := NewConsumer(client, ConsumerConfig{
+		StreamName: "test-stream",
+		Weights: map[models.JobPriority]int{
+			models.PriorityInteractive: 6,
+			models.PriorityDefault:     3,
+			models.PriorityBulk:        1,
+		},
+	}, logger)
+
+	counts := make(map[models.JobPriority]int)
+	for i := 0; i < 100; i++ {
+		order := consumer.nextOrder()
+		if len(order) != len(models.Priorities) {
+			t.Fatalf("len(order) = %d, want %d", len(order), len(models.Priorities))
+		}
+		counts[order[0]]++
+	}
+
+	if counts[models.PriorityInteractive] != 60 {
+		t.Errorf("interactive picks = %d, want 60", counts[models.PriorityInteractive])
+	}
+	if counts[models.PriorityDefault] != 30 {
+		t.Errorf("default picks = %d, want 30", counts[models.PriorityDefault])
+	}
+	if counts[models.PriorityBulk] != 10 {
+		t.Errorf("bulk picks = %d, want 10", counts[models.PriorityBulk])
+	}
+}
+
 func TestProducer_Enqueue(t *testing.T) {
 	client := getTestRedisClient(t)
 	if client == nil {
@@ -364,7 +441,7 @@ func TestConsumer_Acknowledge(t *testing.T) {
 	}
 
 	// Acknowledge the message
-	err = consumer.Acknowledge(context.Background(), msg.ID)
+	err = consumer.Acknowledge(context.Background(), msg)
 	if err != nil {
 		t.Fatalf("Acknowledge() error = %v", err)
 	}
@@ -553,7 +630,7 @@ func TestProducerConsumer_Integration(t *testing.T) {
 		receivedIDs[msg.Job.JobID] = true
 
 		// Acknowledge
-		if err := consumer.Acknowledge(context.Background(), msg.ID); err != nil {
+		if err := consumer.Acknowledge(context.Background(), msg); err != nil {
 			t.Fatalf("Acknowledge() error = %v", err)
 		}
 	}
@@ -574,3 +651,74 @@ func TestProducerConsumer_Integration(t *testing.T) {
 		t.Errorf("PendingCount = %d, want 0", pending)
 	}
 }
+
+func TestScheduler_DispatchDue(t *testing.T) {
+	client := getTestRedisClient(t)
+	if client == nil {
+		return
+	}
+	defer client.Close()
+
+	streamName := "test-scheduler-" + uuid.New().String()[:8]
+	defer cleanupStream(t, client, streamName)
+	defer client.Del(context.Background(), streamName+":scheduled", streamName+":scheduled:data")
+
+	ctx := context.Background()
+	producer := NewProducer(client, streamName)
+	scheduler := NewScheduler(client, producer, streamName)
+	now := time.Now()
+
+	due := &models.JobMessage{JobID: uuid.New(), Priority: models.PriorityBulk}
+	later := &models.JobMessage{JobID: uuid.New()}
+	canceled := &models.JobMessage{JobID: uuid.New()}
+
+	if err := scheduler.Schedule(ctx, due, now.Add(-time.Second)); err != nil {
+		t.Fatalf("Schedule() error = %v", err)
+	}
+	if err := scheduler.Schedule(ctx, later, now.Add(time.Hour)); err != nil {
+		t.Fatalf("Schedule() error = %v", err)
+	}
+	if err := scheduler.Schedule(ctx, canceled, now.Add(-time.Second)); err != nil {
+		t.Fatalf("Schedule() error = %v", err)
+	}
+
+	removed, err := scheduler.Cancel(ctx, canceled.JobID)
+	if err != nil {
+		t.Fatalf("Cancel() error = %v", err)
+	}
+	if !removed {
+		t.Error("Cancel() = false, want true for a scheduled job")
+	}
+
+	dispatched, err := scheduler.DispatchDue(ctx, now, 10)
+	if err != nil {
+		t.Fatalf("DispatchDue() error = %v", err)
+	}
+	if len(dispatched) != 1 || dispatched[0].JobID != due.JobID {
+		t.Fatalf("DispatchDue() = %+v, want only the due job", dispatched)
+	}
+
+	length, err := client.XLen(ctx, StreamName(streamName, models.PriorityBulk)).Result()
+	if err != nil {
+		t.Fatalf("XLen() error = %v", err)
+	}
+	if length != 1 {
+		t.Errorf("bulk stream length = %d, want 1", length)
+	}
+
+	count, err := scheduler.Count(ctx)
+	if err != nil {
+		t.Fatalf("Count() error = %v", err)
+	}
+	if count != 1 {
+		t.Errorf("Count() = %d, want 1", count)
+	}
+
+	removed, err = scheduler.Cancel(ctx, due.JobID)
+	if err != nil {
+		t.Fatalf("Cancel() error = %v", err)
+	}
+	if removed {
+		t.Error("Cancel() = true, want false for an already dispatched job")
+	}
+}
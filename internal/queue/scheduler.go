@@ -0,0 +1,145 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/timkrebs/image-processor/internal/models"
+)
+
+// errNotClaimed is returned by dispatch when another dispatcher or a
+// cancellation removed the job first
+var errNotClaimed = errors.New("scheduled job not claimed")
+
+// Scheduler holds jobs that should run in the future in a Redis sorted set
+// scored by their due time, and moves them onto the streams once due
+type Scheduler struct {
+	client   *redis.Client
+	producer *Producer
+	setKey   string
+	dataKey  string
+}
+
+// NewScheduler creates a scheduler that dispatches due jobs through the producer
+func NewScheduler(client *redis.Client, producer *Producer, streamName string) *Scheduler {
+	return &Scheduler{
+		client:   client,
+		producer: producer,
+		setKey:   streamName + ":scheduled",
+		dataKey:  streamName + ":scheduled:data",
+	}
+}
+
+// Schedule stores a job message to be enqueued at runAt
+func (s *Scheduler) Schedule(ctx context.Context, msg *models.JobMessage, runAt time.Time) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal job message: %w", err)
+	}
+
+	member := msg.JobID.String()
+	pipe := s.client.TxPipeline()
+	pipe.HSet(ctx, s.dataKey, member, string(data))
+	pipe.ZAdd(ctx, s.setKey, redis.Z{Score: float64(runAt.UnixMilli()), Member: member})
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to schedule job: %w", err)
+	}
+
+	return nil
+}
+
+// Cancel removes a scheduled job. It reports false if the job was not scheduled,
+// for example because it has already been dispatched.
+func (s *Scheduler) Cancel(ctx context.Context, jobID uuid.UUID) (bool, error) {
+	member := jobID.String()
+	removed, err := s.client.ZRem(ctx, s.setKey, member).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to cancel scheduled job: %w", err)
+	}
+	if err := s.client.HDel(ctx, s.dataKey, member).Err(); err != nil {
+		return false, fmt.Errorf("failed to remove scheduled job data: %w", err)
+	}
+	return removed > 0, nil
+}
+
+// DispatchDue enqueues up to limit jobs whose due time is at or before now and
+// returns the dispatched messages. Each entry is claimed with ZREM before it is
+// enqueued, so several dispatchers can run concurrently without duplicates.
+func (s *Scheduler) DispatchDue(ctx context.Context, now time.Time, limit int) ([]*models.JobMessage, error) {
+	members, err := s.client.ZRangeByScore(ctx, s.setKey, &redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   strconv.FormatInt(now.UnixMilli(), 10),
+		Count: int64(limit),
+	}).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read due jobs: %w", err)
+	}
+
+	var dispatched []*models.JobMessage
+	for _, member := range members {
+		msg, err := s.dispatch(ctx, member, now)
+		if errors.Is(err, errNotClaimed) {
+			continue
+		}
+		if msg != nil {
+			dispatched = append(dispatched, msg)
+		}
+		if err != nil {
+			return dispatched, err
+		}
+	}
+
+	return dispatched, nil
+}
+
+// dispatch claims and enqueues a single scheduled job
+func (s *Scheduler) dispatch(ctx context.Context, member string, now time.Time) (*models.JobMessage, error) {
+	claimed, err := s.client.ZRem(ctx, s.setKey, member).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim scheduled job: %w", err)
+	}
+	if claimed == 0 {
+		return nil, errNotClaimed
+	}
+
+	data, err := s.client.HGet(ctx, s.dataKey, member).Result()
+	if err == redis.Nil {
+		// Canceled between the range read and the claim
+		return nil, errNotClaimed
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read scheduled job: %w", err)
+	}
+
+	var msg models.JobMessage
+	if err := json.Unmarshal([]byte(data), &msg); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal scheduled job: %w", err)
+	}
+
+	if err := s.producer.Enqueue(ctx, &msg); err != nil {
+		// Put the job back so the next tick retries it
+		if scheduleErr := s.Schedule(ctx, &msg, now); scheduleErr != nil {
+			return nil, fmt.Errorf("failed to enqueue scheduled job: %w (and failed to reschedule: %v)", err, scheduleErr)
+		}
+		return nil, fmt.Errorf("failed to enqueue scheduled job: %w", err)
+	}
+
+	if err := s.client.HDel(ctx, s.dataKey, member).Err(); err != nil {
+		// The job is already on the stream, so report it as dispatched
+		return &msg, fmt.Errorf("failed to remove scheduled job data: %w", err)
+	}
+
+	return &msg, nil
+}
+
+// Count returns the number of jobs waiting to be dispatched
+func (s *Scheduler) Count(ctx context.Context) (int64, error) {
+	return s.client.ZCard(ctx, s.setKey).Result()
+}
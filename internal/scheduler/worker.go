@@ -0,0 +1,84 @@
+package scheduler
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/timkrebs/image-processor/internal/database"
+	"github.com/timkrebs/image-processor/internal/queue"
+)
+
+// Worker periodically moves due scheduled jobs onto the queue
+type Worker struct {
+	jobRepo   *database.JobRepository
+	scheduler *queue.Scheduler
+	logger    *slog.Logger
+	interval  time.Duration
+	batchSize int
+}
+
+// Config holds scheduler worker configuration
+type Config struct {
+	Interval  time.Duration
+	BatchSize int
+}
+
+// NewWorker creates a new scheduler worker
+func NewWorker(jobRepo *database.JobRepository, scheduler *queue.Scheduler, cfg Config, logger *slog.Logger) *Worker {
+	if cfg.Interval == 0 {
+		cfg.Interval = time.Second
+	}
+	if cfg.BatchSize == 0 {
+		cfg.BatchSize = 100
+	}
+
+	return &Worker{
+		jobRepo:   jobRepo,
+		scheduler: scheduler,
+		logger:    logger,
+		interval:  cfg.Interval,
+		batchSize: cfg.BatchSize,
+	}
+}
+
+// Start runs the dispatch loop until the context is canceled
+func (w *Worker) Start(ctx context.Context) {
+	w.logger.Info("scheduler worker started", "interval", w.interval, "batch_size", w.batchSize)
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			w.logger.Info("scheduler worker stopped")
+			return
+		case <-ticker.C:
+			if err := w.dispatch(ctx); err != nil {
+				w.logger.Error("dispatch failed", "error", err)
+			}
+		}
+	}
+}
+
+// dispatch performs a single dispatch cycle, draining all due jobs in batches
+func (w *Worker) dispatch(ctx context.Context) error {
+	for {
+		dispatched, err := w.scheduler.DispatchDue(ctx, time.Now(), w.batchSize)
+
+		for _, msg := range dispatched {
+			w.logger.Info("dispatched scheduled job", "job_id", msg.JobID, "priority", msg.Priority)
+			if markErr := w.jobRepo.MarkQueued(ctx, msg.JobID); markErr != nil {
+				w.logger.Error("failed to mark job queued", "job_id", msg.JobID, "error", markErr)
+			}
+		}
+
+		if err != nil {
+			return err
+		}
+		if len(dispatched) < w.batchSize {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/timkrebs/image-processor/internal/metrics"
+)
+
+// Backend types
+const (
+	BackendMinIO  = "minio"
+	BackendLocal  = "local"
+	BackendMemory = "memory"
+)
+
+var (
+	// ErrNotFound is returned when an object does not exist
+	ErrNotFound = errors.New("object not found")
+	// ErrUploadNotFound is returned when a multipart upload does not exist (any more)
+	ErrUploadNotFound = errors.New("multipart upload not found")
+	// ErrNotSupported is returned for operations a backend cannot perform
+	ErrNotSupported = errors.New("not supported by storage backend")
+)
+
+// Backend stores originals and processed images as objects addressed by key
+type Backend interface {
+	// Upload stores size bytes from reader under key
+	Upload(ctx context.Context, key string, reader io.Reader, size int64, contentType string) error
+	// Download opens an object for reading
+	Download(ctx context.Context, key string) (io.ReadSeekCloser, error)
+	// Delete removes an object; deleting a missing object is not an error
+	Delete(ctx context.Context, key string) error
+	// DeleteMany removes objects with as few requests as the backend allows
+	DeleteMany(ctx context.Context, keys []string) error
+	// Stat returns object metadata
+	Stat(ctx context.Context, key string) (*ObjectInfo, error)
+	// Exists reports whether an object exists
+	Exists(ctx context.Context, key string) (bool, error)
+	// Copy copies an object within the backend
+	Copy(ctx context.Context, srcKey, dstKey string) error
+	// Presign returns a URL clients can use to GET or PUT an object directly,
+	// or ErrNotSupported if the backend is not reachable by clients
+	Presign(ctx context.Context, method, key string, expiry time.Duration, opts PresignOptions) (string, error)
+	// List calls fn for every object whose key starts with prefix, in key order
+	List(ctx context.Context, prefix string, fn func(ObjectInfo) error) error
+	// Health checks that the backend is usable
+	Health(ctx context.Context) error
+}
+
+// MultipartBackend is a Backend that can assemble an object from separately uploaded parts
+type MultipartBackend interface {
+	Backend
+	// NewMultipartUpload starts a multipart upload and returns its upload ID
+	NewMultipartUpload(ctx context.Context, key, contentType string) (string, error)
+	// PutPart uploads one part of a multipart upload
+	PutPart(ctx context.Context, key, uploadID string, number int, reader io.Reader, size int64) error
+	// ListParts returns the uploaded parts of a multipart upload in order
+	ListParts(ctx context.Context, key, uploadID string) ([]Part, error)
+	// CompleteMultipartUpload assembles the parts into the final object
+	CompleteMultipartUpload(ctx context.Context, key, uploadID string, parts []Part) error
+	// AbortMultipartUpload discards a multipart upload and its parts; aborting a missing upload is not an error
+	AbortMultipartUpload(ctx context.Context, key, uploadID string) error
+}
+
+// ObjectInfo describes a stored object
+type ObjectInfo struct {
+	LastModified time.Time
+	Key          string
+	ContentType  string
+	ETag         string
+	Size         int64
+}
+
+// MinPartSize is the smallest size S3 accepts for any multipart part but the last
+const MinPartSize = 5 << 20 // 5MB
+
+// Part describes an uploaded part of a multipart upload
+type Part struct {
+	ETag   string
+	Number int
+	Size   int64
+}
+
+// PresignOptions customizes presigned URLs
+type PresignOptions struct {
+	// ContentType is the Content-Type a presigned PUT must send
+	ContentType string
+	// CacheControl and ContentDisposition override the headers of a presigned GET response
+	CacheControl       string
+	ContentDisposition string
+}
+
+// Options selects and configures a backend
+type Options struct {
+	Metrics   *metrics.StorageMetrics
+	Type      string
+	LocalPath string
+	MinIO     Config
+}
+
+// Open creates the configured backend and prepares it for use
+func Open(ctx context.Context, opts Options) (Backend, error) {
+	switch opts.Type {
+	case BackendMinIO, "":
+		s, err := New(opts.MinIO)
+		if err != nil {
+			return nil, err
+		}
+		s.SetMetrics(opts.Metrics)
+		if err := s.EnsureBucket(ctx); err != nil {
+			return nil, err
+		}
+		return s, nil
+	case BackendLocal, BackendMemory:
+		if opts.MinIO.Encryption != EncryptionNone {
+			return nil, fmt.Errorf("storage encryption requires the %s backend", BackendMinIO)
+		}
+		if opts.Type == BackendLocal {
+			return NewLocal(opts.LocalPath)
+		}
+		return NewMemory(), nil
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", opts.Type)
+	}
+}
+
+// DeletePrefix deletes every object whose key starts with prefix
+func DeletePrefix(ctx context.Context, b Backend, prefix string) error {
+	var keys []string
+	err := b.List(ctx, prefix, func(info ObjectInfo) error {
+		keys = append(keys, info.Key)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return b.DeleteMany(ctx, keys)
+}
@@ -0,0 +1,206 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testBackends returns the backends that can run without external services
+func testBackends(t *testing.T) map[string]MultipartBackend {
+	local, err := NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocal() error = %v", err)
+	}
+	return map[string]MultipartBackend{
+		BackendLocal:  local,
+		BackendMemory: NewMemory(),
+	}
+}
+
+func TestBackend_Objects(t *testing.T) {
+	ctx := context.Background()
+
+	for name, b := range testBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			if err := b.Health(ctx); err != nil {
+				t.Fatalf("Health() error = %v", err)
+			}
+
+			data := []byte("hello image")
+			if err := b.Upload(ctx, "users/a/original/1/cat.png", bytes.NewReader(data), int64(len(data)), "image/png"); err != nil {
+				t.Fatalf("Upload() error = %v", err)
+			}
+
+			info, err := b.Stat(ctx, "users/a/original/1/cat.png")
+			if err != nil {
+				t.Fatalf("Stat() error = %v", err)
+			}
+			if info.Size != int64(len(data)) || info.ContentType != "image/png" || info.ETag == "" {
+				t.Errorf("Stat() = %+v", info)
+			}
+
+			reader, err := b.Download(ctx, "users/a/original/1/cat.png")
+			if err != nil {
+				t.Fatalf("Download() error = %v", err)
+			}
+			if _, err := reader.Seek(6, io.SeekStart); err != nil {
+				t.Fatalf("Seek() error = %v", err)
+			}
+			got, _ := io.ReadAll(reader)
+			reader.Close()
+			if string(got) != "image" {
+				t.Errorf("Download() after seek = %q, want %q", got, "image")
+			}
+
+			if err := b.Copy(ctx, "users/a/original/1/cat.png", "users/a/processed/1/cat.png"); err != nil {
+				t.Fatalf("Copy() error = %v", err)
+			}
+			if err := b.Upload(ctx, "users/ab/original/2/dog.png", strings.NewReader("x"), 1, "image/png"); err != nil {
+				t.Fatalf("Upload() error = %v", err)
+			}
+
+			var keys []string
+			err = b.List(ctx, "users/a/", func(info ObjectInfo) error {
+				keys = append(keys, info.Key)
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("List() error = %v", err)
+			}
+			want := []string{"users/a/original/1/cat.png", "users/a/processed/1/cat.png"}
+			if !reflect.DeepEqual(keys, want) {
+				t.Errorf("List() = %v, want %v", keys, want)
+			}
+
+			if err := DeletePrefix(ctx, b, "users/a/"); err != nil {
+				t.Fatalf("DeletePrefix() error = %v", err)
+			}
+			if ok, err := b.Exists(ctx, "users/a/original/1/cat.png"); ok || err != nil {
+				t.Errorf("Exists() after delete = %v, %v", ok, err)
+			}
+			if ok, err := b.Exists(ctx, "users/ab/original/2/dog.png"); !ok || err != nil {
+				t.Errorf("Exists() outside prefix = %v, %v", ok, err)
+			}
+
+			// Deleting again is not an error
+			if err := b.Delete(ctx, "users/a/original/1/cat.png"); err != nil {
+				t.Errorf("Delete() of missing object error = %v", err)
+			}
+
+			if err := b.DeleteMany(ctx, []string{"users/ab/original/2/dog.png", "missing"}); err != nil {
+				t.Errorf("DeleteMany() error = %v", err)
+			}
+			if ok, _ := b.Exists(ctx, "users/ab/original/2/dog.png"); ok {
+				t.Error("DeleteMany() should delete every key")
+			}
+		})
+	}
+}
+
+func TestBackend_NotFound(t *testing.T) {
+	ctx := context.Background()
+
+	for name, b := range testBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			if _, err := b.Download(ctx, "missing"); !IsNotFound(err) {
+				t.Errorf("Download() error = %v, want not found", err)
+			}
+			if _, err := b.Stat(ctx, "missing"); !IsNotFound(err) {
+				t.Errorf("Stat() error = %v, want not found", err)
+			}
+			if _, err := b.Presign(ctx, http.MethodGet, "missing", time.Minute, PresignOptions{}); !errors.Is(err, ErrNotSupported) {
+				t.Errorf("Presign() error = %v, want ErrNotSupported", err)
+			}
+		})
+	}
+}
+
+func TestBackend_SizeMismatch(t *testing.T) {
+	ctx := context.Background()
+
+	for name, b := range testBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			if err := b.Upload(ctx, "short", strings.NewReader("abc"), 10, "text/plain"); err == nil {
+				t.Error("Upload() should fail when fewer bytes than size are read")
+			}
+			if ok, _ := b.Exists(ctx, "short"); ok {
+				t.Error("truncated upload should not be stored")
+			}
+		})
+	}
+}
+
+func TestBackend_Multipart(t *testing.T) {
+	ctx := context.Background()
+
+	for name, b := range testBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			uploadID, err := b.NewMultipartUpload(ctx, "big.bin", "application/octet-stream")
+			if err != nil {
+				t.Fatalf("NewMultipartUpload() error = %v", err)
+			}
+
+			if err := b.PutPart(ctx, "big.bin", uploadID, 2, strings.NewReader("world"), 5); err != nil {
+				t.Fatalf("PutPart(2) error = %v", err)
+			}
+			if err := b.PutPart(ctx, "big.bin", uploadID, 1, strings.NewReader("hello "), 6); err != nil {
+				t.Fatalf("PutPart(1) error = %v", err)
+			}
+
+			parts, err := b.ListParts(ctx, "big.bin", uploadID)
+			if err != nil {
+				t.Fatalf("ListParts() error = %v", err)
+			}
+			if len(parts) != 2 || parts[0].Number != 1 || parts[1].Size != 5 {
+				t.Fatalf("ListParts() = %+v", parts)
+			}
+
+			if err := b.CompleteMultipartUpload(ctx, "big.bin", uploadID, parts); err != nil {
+				t.Fatalf("CompleteMultipartUpload() error = %v", err)
+			}
+
+			reader, err := b.Download(ctx, "big.bin")
+			if err != nil {
+				t.Fatalf("Download() error = %v", err)
+			}
+			got, _ := io.ReadAll(reader)
+			reader.Close()
+			if string(got) != "hello world" {
+				t.Errorf("assembled object = %q, want %q", got, "hello world")
+			}
+
+			if _, err := b.ListParts(ctx, "big.bin", uploadID); !IsNoSuchUpload(err) {
+				t.Errorf("ListParts() after completion error = %v, want no such upload", err)
+			}
+			if err := b.AbortMultipartUpload(ctx, "big.bin", uploadID); err != nil {
+				t.Errorf("AbortMultipartUpload() of finished upload error = %v", err)
+			}
+		})
+	}
+}
+
+func TestLocal_RejectsEscapingKeys(t *testing.T) {
+	local, err := NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocal() error = %v", err)
+	}
+
+	for _, key := range []string{"", "../etc/passwd", "a/../../b", "/abs", "a//b", "dir/"} {
+		if err := local.Upload(context.Background(), key, strings.NewReader("x"), 1, "text/plain"); err == nil {
+			t.Errorf("Upload(%q) should be rejected", key)
+		}
+	}
+}
+
+func TestOpen_UnknownBackend(t *testing.T) {
+	if _, err := Open(context.Background(), Options{Type: "ftp"}); err == nil {
+		t.Error("Open() should reject unknown backends")
+	}
+}
@@ -0,0 +1,140 @@
+package storage
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/encrypt"
+)
+
+// Server-side encryption modes
+const (
+	EncryptionNone  = ""
+	EncryptionSSES3 = "sse-s3"
+	EncryptionSSEC  = "sse-c"
+)
+
+// masterKeySize is the size of SSE-C master keys and of the keys derived from them
+const masterKeySize = 32
+
+// keyring decides how objects are encrypted.
+// With SSE-C every user gets their own key, derived from a master key, so
+// that a leaked object key only exposes one user's images. The first master
+// key encrypts new objects; the others only decrypt objects written before a
+// rotation.
+type keyring struct {
+	mode       string
+	masterKeys [][]byte
+}
+
+// newKeyring validates the encryption mode and decodes the base64 master keys, newest first
+func newKeyring(mode string, keys []string) (*keyring, error) {
+	k := &keyring{mode: mode}
+
+	switch mode {
+	case EncryptionNone, EncryptionSSES3:
+		return k, nil
+	case EncryptionSSEC:
+	default:
+		return nil, fmt.Errorf("unknown storage encryption %q", mode)
+	}
+
+	for i, encoded := range keys {
+		key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+		if err != nil {
+			return nil, fmt.Errorf("encryption key %d is not valid base64: %w", i+1, err)
+		}
+		if len(key) != masterKeySize {
+			return nil, fmt.Errorf("encryption key %d must be %d bytes, got %d", i+1, masterKeySize, len(key))
+		}
+		k.masterKeys = append(k.masterKeys, key)
+	}
+	if len(k.masterKeys) == 0 {
+		return nil, fmt.Errorf("%s encryption requires at least one encryption key", mode)
+	}
+	return k, nil
+}
+
+// write returns the encryption for storing a new object at objectKey
+func (k *keyring) write(objectKey string) encrypt.ServerSide {
+	switch k.mode {
+	case EncryptionSSES3:
+		return encrypt.NewSSE()
+	case EncryptionSSEC:
+		return k.customerKey(0, objectKey)
+	default:
+		return nil
+	}
+}
+
+// candidates returns the encryptions an existing object may have been
+// written with, newest first. SSE-S3 objects are read like plain ones.
+// With SSE-C, objects stored before encryption was enabled stay readable.
+func (k *keyring) candidates(objectKey string) []encrypt.ServerSide {
+	if k.mode != EncryptionSSEC {
+		return []encrypt.ServerSide{nil}
+	}
+
+	candidates := make([]encrypt.ServerSide, 0, len(k.masterKeys)+1)
+	for i := range k.masterKeys {
+		candidates = append(candidates, k.customerKey(i, objectKey))
+	}
+	return append(candidates, nil)
+}
+
+// presignable reports whether clients can read and write objects without our keys
+func (k *keyring) presignable() bool {
+	return k.mode != EncryptionSSEC
+}
+
+// current reports whether an object read with candidate i is already stored
+// the way write would store it
+func (k *keyring) current(i int, info minio.ObjectInfo) bool {
+	switch k.mode {
+	case EncryptionSSES3:
+		return info.Metadata.Get(encrypt.SseGenericHeader) != ""
+	case EncryptionSSEC:
+		return i == 0
+	default:
+		return true
+	}
+}
+
+// customerKey derives the SSE-C key of objectKey's user from master key i
+func (k *keyring) customerKey(i int, objectKey string) encrypt.ServerSide {
+	mac := hmac.New(sha256.New, k.masterKeys[i])
+	mac.Write([]byte("image-processor/sse-c/" + keyScope(objectKey)))
+
+	sse, err := encrypt.NewSSEC(mac.Sum(nil))
+	if err != nil {
+		// NewSSEC only rejects keys that are not 32 bytes long
+		panic(err)
+	}
+	return sse
+}
+
+// keyScope returns the part of an object key that selects its encryption key:
+// the owning user for keys under users/<id>/, and a shared scope otherwise
+func keyScope(objectKey string) string {
+	rest, ok := strings.CutPrefix(objectKey, "users/")
+	if !ok {
+		return ""
+	}
+	user, _, ok := strings.Cut(rest, "/")
+	if !ok || user == "" {
+		return ""
+	}
+	return "users/" + user
+}
+
+// isKeyMismatch reports whether err means an object cannot be read with the
+// encryption it was requested with, so another candidate should be tried
+func isKeyMismatch(err error) bool {
+	resp := minio.ToErrorResponse(err)
+	return resp.StatusCode == http.StatusBadRequest || resp.StatusCode == http.StatusForbidden
+}
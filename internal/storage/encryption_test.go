@@ -0,0 +1,131 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/base64"
+	"net/http"
+	"testing"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/encrypt"
+)
+
+func testMasterKey(b byte) string {
+	return base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{b}, masterKeySize))
+}
+
+// customerKeyHeader returns the key an SSE-C encryption would send
+func customerKeyHeader(sse encrypt.ServerSide) string {
+	h := http.Header{}
+	sse.Marshal(h)
+	return h.Get(encrypt.SseCustomerKey)
+}
+
+func TestNewKeyring(t *testing.T) {
+	tests := []struct {
+		name    string
+		mode    string
+		keys    []string
+		wantErr bool
+	}{
+		{"none", EncryptionNone, nil, false},
+		{"sse-s3", EncryptionSSES3, nil, false},
+		{"sse-c", EncryptionSSEC, []string{testMasterKey(1), testMasterKey(2)}, false},
+		{"sse-c without keys", EncryptionSSEC, nil, true},
+		{"short key", EncryptionSSEC, []string{base64.StdEncoding.EncodeToString([]byte("short"))}, true},
+		{"invalid base64", EncryptionSSEC, []string{"not base64!"}, true},
+		{"unknown mode", "rot13", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newKeyring(tt.mode, tt.keys)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("newKeyring() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestKeyring_SSEC(t *testing.T) {
+	k, err := newKeyring(EncryptionSSEC, []string{testMasterKey(1), testMasterKey(2)})
+	if err != nil {
+		t.Fatalf("newKeyring() error = %v", err)
+	}
+
+	original := customerKeyHeader(k.write("users/alice/original/1/a.png"))
+	if got := customerKeyHeader(k.write("users/alice/processed/1/a.png")); got != original {
+		t.Error("objects of the same user should share a key")
+	}
+	if got := customerKeyHeader(k.write("users/bob/original/2/b.png")); got == original {
+		t.Error("different users should get different keys")
+	}
+
+	candidates := k.candidates("users/alice/original/1/a.png")
+	if len(candidates) != 3 {
+		t.Fatalf("candidates() = %d, want both master keys and plaintext", len(candidates))
+	}
+	if customerKeyHeader(candidates[0]) != original {
+		t.Error("the current key should be tried first")
+	}
+	if customerKeyHeader(candidates[1]) == original {
+		t.Error("a retired master key should derive a different key")
+	}
+	if candidates[2] != nil {
+		t.Error("unencrypted objects should be tried last")
+	}
+
+	if k.presignable() {
+		t.Error("SSE-C objects should not be presignable")
+	}
+	if !k.current(0, minio.ObjectInfo{}) || k.current(1, minio.ObjectInfo{}) {
+		t.Error("only objects readable with the first key should be current")
+	}
+}
+
+func TestKeyring_SSES3(t *testing.T) {
+	k, err := newKeyring(EncryptionSSES3, nil)
+	if err != nil {
+		t.Fatalf("newKeyring() error = %v", err)
+	}
+
+	if sse := k.write("users/alice/a.png"); sse == nil || sse.Type() != encrypt.S3 {
+		t.Errorf("write() = %v, want SSE-S3", sse)
+	}
+	if candidates := k.candidates("users/alice/a.png"); len(candidates) != 1 || candidates[0] != nil {
+		t.Error("SSE-S3 objects should be read without encryption headers")
+	}
+	if !k.presignable() {
+		t.Error("SSE-S3 objects should be presignable")
+	}
+
+	encrypted := minio.ObjectInfo{Metadata: http.Header{}}
+	encrypted.Metadata.Set(encrypt.SseGenericHeader, "AES256")
+	if !k.current(0, encrypted) || k.current(0, minio.ObjectInfo{}) {
+		t.Error("only objects with SSE-S3 metadata should be current")
+	}
+}
+
+func TestKeyScope(t *testing.T) {
+	tests := map[string]string{
+		"users/alice/original/1/a.png": "users/alice",
+		"users/alice":                  "",
+		"users//a.png":                 "",
+		"uploads/incomplete/1":         "",
+	}
+	for key, want := range tests {
+		if got := keyScope(key); got != want {
+			t.Errorf("keyScope(%q) = %q, want %q", key, got, want)
+		}
+	}
+}
+
+func TestOpen_EncryptionRequiresMinIO(t *testing.T) {
+	_, err := Open(t.Context(), Options{
+		Type:  BackendMemory,
+		MinIO: Config{Encryption: EncryptionSSES3},
+	})
+	if err == nil {
+		t.Error("Open() should reject encryption for backends without it")
+	}
+}
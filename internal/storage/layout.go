@@ -0,0 +1,142 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/lifecycle"
+)
+
+// Lifecycle rules managed by EnsureBucket; other rules on the buckets are kept
+const (
+	originalsRuleID = "image-processor-originals"
+	processedRuleID = "image-processor-processed"
+)
+
+// location is where one class of objects is stored in MinIO
+type location struct {
+	bucket     string
+	prefix     string
+	expiryDays int
+}
+
+// IsProcessedKey reports whether key holds an output of processing rather than an
+// uploaded original: users/<user>/processed/... and users/<user>/derived/...
+func IsProcessedKey(key string) bool {
+	rest, ok := strings.CutPrefix(key, "users/")
+	if !ok {
+		return false
+	}
+	_, rest, _ = strings.Cut(rest, "/")
+	return strings.HasPrefix(rest, "processed/") || strings.HasPrefix(rest, "derived/")
+}
+
+// newLayout validates where originals and processed outputs are stored
+func newLayout(cfg Config) (location, location, error) {
+	originals := location{bucket: cfg.Bucket, prefix: dirPrefix(cfg.OriginalsPrefix), expiryDays: cfg.OriginalsExpiryDays}
+	processed := location{bucket: cfg.ProcessedBucket, prefix: dirPrefix(cfg.ProcessedPrefix), expiryDays: cfg.ProcessedExpiryDays}
+	if processed.bucket == "" {
+		processed.bucket = originals.bucket
+	}
+
+	if originals.expiryDays < 0 || processed.expiryDays < 0 {
+		return location{}, location{}, fmt.Errorf("object expiry days must not be negative")
+	}
+
+	// A lifecycle rule covers everything under its prefix, so the rules of
+	// nested locations would expire each other's objects
+	if originals.bucket == processed.bucket && originals.expiryDays != processed.expiryDays &&
+		(strings.HasPrefix(originals.prefix, processed.prefix) || strings.HasPrefix(processed.prefix, originals.prefix)) {
+		return location{}, location{}, fmt.Errorf("originals and processed outputs share a bucket and prefix, so their expiry must be equal")
+	}
+
+	return originals, processed, nil
+}
+
+// dirPrefix returns prefix with a trailing slash, unless it is empty
+func dirPrefix(prefix string) string {
+	if prefix == "" || strings.HasSuffix(prefix, "/") {
+		return prefix
+	}
+	return prefix + "/"
+}
+
+// location returns where the object with key is stored
+func (s *Storage) location(key string) location {
+	if IsProcessedKey(key) {
+		return s.processed
+	}
+	return s.originals
+}
+
+// locate returns the bucket and object name of key
+func (s *Storage) locate(key string) (string, string) {
+	loc := s.location(key)
+	return loc.bucket, loc.prefix + key
+}
+
+// locations returns the distinct locations objects are stored in
+func (s *Storage) locations() []location {
+	if s.processed.bucket == s.originals.bucket && s.processed.prefix == s.originals.prefix {
+		return []location{s.originals}
+	}
+	return []location{s.originals, s.processed}
+}
+
+// buckets returns the distinct buckets objects are stored in
+func (s *Storage) buckets() []string {
+	if s.processed.bucket == s.originals.bucket {
+		return []string{s.originals.bucket}
+	}
+	return []string{s.originals.bucket, s.processed.bucket}
+}
+
+// lifecycleRules returns the expiry rules EnsureBucket maintains, by bucket.
+// Buckets whose rules were all disabled get an empty list.
+func (s *Storage) lifecycleRules() map[string][]lifecycle.Rule {
+	rules := make(map[string][]lifecycle.Rule)
+	for _, bucket := range s.buckets() {
+		rules[bucket] = nil
+	}
+
+	ids := []string{originalsRuleID, processedRuleID}
+	for i, loc := range s.locations() {
+		if loc.expiryDays == 0 {
+			continue
+		}
+		rules[loc.bucket] = append(rules[loc.bucket], lifecycle.Rule{
+			ID:         ids[i],
+			Status:     "Enabled",
+			RuleFilter: lifecycle.Filter{Prefix: loc.prefix},
+			Expiration: lifecycle.Expiration{Days: lifecycle.ExpirationDays(loc.expiryDays)},
+		})
+	}
+	return rules
+}
+
+// configureLifecycle replaces the rules EnsureBucket manages on bucket with rules
+func (s *Storage) configureLifecycle(ctx context.Context, bucket string, rules []lifecycle.Rule) error {
+	config, err := s.client.GetBucketLifecycle(ctx, bucket)
+	if minio.ToErrorResponse(err).Code == "NoSuchLifecycleConfiguration" {
+		config, err = lifecycle.NewConfiguration(), nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get bucket lifecycle: %w", err)
+	}
+
+	managed := func(rule lifecycle.Rule) bool {
+		return rule.ID == originalsRuleID || rule.ID == processedRuleID
+	}
+	if len(rules) == 0 && !slices.ContainsFunc(config.Rules, managed) {
+		return nil
+	}
+
+	config.Rules = append(slices.DeleteFunc(config.Rules, managed), rules...)
+	if err := s.client.SetBucketLifecycle(ctx, bucket, config); err != nil {
+		return fmt.Errorf("failed to set bucket lifecycle: %w", err)
+	}
+	return nil
+}
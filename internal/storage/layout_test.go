@@ -0,0 +1,80 @@
+package storage
+
+import (
+	"testing"
+)
+
+func TestIsProcessedKey(t *testing.T) {
+	tests := map[string]bool{
+		"users/a/processed/1/cat.png":  true,
+		"users/a/derived/1/ab.webp":    true,
+		"users/a/original/1/cat.png":   false,
+		"users/a/blobs/sha256/abc":     false,
+		"users/a/staging/1":            false,
+		"uploads/incomplete/1":         false,
+		"processed/users/a/original/1": false,
+	}
+	for key, want := range tests {
+		if got := IsProcessedKey(key); got != want {
+			t.Errorf("IsProcessedKey(%q) = %v, want %v", key, got, want)
+		}
+	}
+}
+
+func TestNewLayout(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr bool
+	}{
+		{"single bucket", Config{Bucket: "images"}, false},
+		{"single bucket with equal expiry", Config{Bucket: "images", OriginalsExpiryDays: 2, ProcessedExpiryDays: 2}, false},
+		{"overlapping prefixes with different expiry", Config{Bucket: "images", ProcessedPrefix: "processed", OriginalsExpiryDays: 2, ProcessedExpiryDays: 7}, true},
+		{"separate prefixes", Config{Bucket: "images", OriginalsPrefix: "originals", ProcessedPrefix: "processed", OriginalsExpiryDays: 2, ProcessedExpiryDays: 7}, false},
+		{"separate buckets", Config{Bucket: "images", ProcessedBucket: "results", OriginalsExpiryDays: 2, ProcessedExpiryDays: 7}, false},
+		{"negative expiry", Config{Bucket: "images", OriginalsExpiryDays: -1}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := newLayout(tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("newLayout() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestStorage_Locate(t *testing.T) {
+	s, err := New(Config{
+		Endpoint:            "localhost:9000",
+		Bucket:              "images",
+		ProcessedBucket:     "results",
+		OriginalsPrefix:     "originals",
+		OriginalsExpiryDays: 2,
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	bucket, object := s.locate("users/a/original/1/cat.png")
+	if bucket != "images" || object != "originals/users/a/original/1/cat.png" {
+		t.Errorf("locate(original) = %s, %s", bucket, object)
+	}
+	bucket, object = s.locate("users/a/processed/1/cat.png")
+	if bucket != "results" || object != "users/a/processed/1/cat.png" {
+		t.Errorf("locate(processed) = %s, %s", bucket, object)
+	}
+
+	if got := s.buckets(); len(got) != 2 {
+		t.Errorf("buckets() = %v, want both buckets", got)
+	}
+
+	rules := s.lifecycleRules()
+	if len(rules["images"]) != 1 || rules["images"][0].ID != originalsRuleID || rules["images"][0].RuleFilter.Prefix != "originals/" {
+		t.Errorf("lifecycleRules()[images] = %+v, want the originals rule", rules["images"])
+	}
+	if r, ok := rules["results"]; !ok || len(r) != 0 {
+		t.Errorf("lifecycleRules()[results] = %+v, want no rules so stale ones are removed", r)
+	}
+}
@@ -0,0 +1,431 @@
+package storage
+
+import (
+	"context"
+	"crypto/md5" //nolint:gosec // ETags are S3-style content checksums, not security
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// tempPrefix marks files still being written, which are never visible as objects
+const tempPrefix = ".tmp-"
+
+// Local is a backend storing objects as files under a root directory,
+// for development and single-node installs
+type Local struct {
+	root string
+}
+
+// localMeta is the metadata stored next to each object and multipart upload
+type localMeta struct {
+	Key         string `json:"key,omitempty"`
+	ContentType string `json:"content_type"`
+	ETag        string `json:"etag,omitempty"`
+}
+
+// NewLocal creates a filesystem backend rooted at root, creating it if needed
+func NewLocal(root string) (*Local, error) {
+	if root == "" {
+		return nil, errors.New("local storage path is required")
+	}
+
+	l := &Local{root: root}
+	for _, dir := range []string{l.objectsDir(), l.metaDir(), l.multipartDir()} {
+		if err := os.MkdirAll(dir, 0o750); err != nil {
+			return nil, fmt.Errorf("failed to create storage directory: %w", err)
+		}
+	}
+	return l, nil
+}
+
+func (l *Local) objectsDir() string   { return filepath.Join(l.root, "objects") }
+func (l *Local) metaDir() string      { return filepath.Join(l.root, "meta") }
+func (l *Local) multipartDir() string { return filepath.Join(l.root, "multipart") }
+
+// paths returns the data and metadata file of a key, rejecting keys that
+// would escape the root directory
+func (l *Local) paths(key string) (string, string, error) {
+	if key == "" || path.Clean("/"+key) != "/"+key || strings.HasPrefix(path.Base(key), tempPrefix) {
+		return "", "", fmt.Errorf("invalid object key %q", key)
+	}
+	rel := filepath.FromSlash(key)
+	return filepath.Join(l.objectsDir(), rel), filepath.Join(l.metaDir(), rel+".json"), nil
+}
+
+// Upload stores size bytes from reader under key
+func (l *Local) Upload(ctx context.Context, key string, reader io.Reader, size int64, contentType string) error {
+	dataPath, metaPath, err := l.paths(key)
+	if err != nil {
+		return err
+	}
+
+	hasher := md5.New() //nolint:gosec // see import
+	written, err := writeFileAtomic(dataPath, io.TeeReader(reader, hasher))
+	if err != nil {
+		return fmt.Errorf("failed to upload object: %w", err)
+	}
+	if size >= 0 && written != size {
+		os.Remove(dataPath)
+		return fmt.Errorf("failed to upload object: got %d bytes, want %d", written, size)
+	}
+
+	return l.writeMeta(metaPath, localMeta{ContentType: contentType, ETag: hex.EncodeToString(hasher.Sum(nil))})
+}
+
+// Download opens an object for reading
+func (l *Local) Download(ctx context.Context, key string) (io.ReadSeekCloser, error) {
+	dataPath, _, err := l.paths(key)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(dataPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("failed to get object %s: %w", key, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get object: %w", err)
+	}
+	return file, nil
+}
+
+// Delete removes an object and any directories it leaves empty
+func (l *Local) Delete(ctx context.Context, key string) error {
+	dataPath, metaPath, err := l.paths(key)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range []string{dataPath, metaPath} {
+		if err := os.Remove(p); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("failed to delete object: %w", err)
+		}
+	}
+
+	removeEmptyParents(filepath.Dir(dataPath), l.objectsDir())
+	removeEmptyParents(filepath.Dir(metaPath), l.metaDir())
+	return nil
+}
+
+// DeleteMany removes objects one by one; there is nothing to batch on a filesystem
+func (l *Local) DeleteMany(ctx context.Context, keys []string) error {
+	for _, key := range keys {
+		if err := l.Delete(ctx, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Stat returns object metadata
+func (l *Local) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	dataPath, metaPath, err := l.paths(key)
+	if err != nil {
+		return nil, err
+	}
+
+	fi, err := os.Stat(dataPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("failed to stat object %s: %w", key, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat object: %w", err)
+	}
+
+	meta := l.readMeta(metaPath)
+	return &ObjectInfo{
+		Key:          key,
+		Size:         fi.Size(),
+		ContentType:  meta.ContentType,
+		ETag:         meta.ETag,
+		LastModified: fi.ModTime().UTC(),
+	}, nil
+}
+
+// Exists reports whether an object exists
+func (l *Local) Exists(ctx context.Context, key string) (bool, error) {
+	_, err := l.Stat(ctx, key)
+	if IsNotFound(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Copy copies an object
+func (l *Local) Copy(ctx context.Context, srcKey, dstKey string) error {
+	info, err := l.Stat(ctx, srcKey)
+	if err != nil {
+		return fmt.Errorf("failed to copy object: %w", err)
+	}
+
+	reader, err := l.Download(ctx, srcKey)
+	if err != nil {
+		return fmt.Errorf("failed to copy object: %w", err)
+	}
+	defer reader.Close()
+
+	return l.Upload(ctx, dstKey, reader, info.Size, info.ContentType)
+}
+
+// Presign is not supported: files are only reachable through the API
+func (l *Local) Presign(ctx context.Context, method, key string, expiry time.Duration, opts PresignOptions) (string, error) {
+	return "", fmt.Errorf("cannot presign %s: %w", method, ErrNotSupported)
+}
+
+// List calls fn for every object whose key starts with prefix, in key order
+func (l *Local) List(ctx context.Context, prefix string, fn func(ObjectInfo) error) error {
+	// Only walk the directory the prefix points into
+	start := filepath.Join(l.objectsDir(), filepath.FromSlash(prefix[:strings.LastIndex(prefix, "/")+1]))
+
+	var keys []string
+	err := filepath.WalkDir(start, func(p string, d fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || strings.HasPrefix(d.Name(), tempPrefix) {
+			return nil
+		}
+
+		rel, err := filepath.Rel(l.objectsDir(), p)
+		if err != nil {
+			return err
+		}
+		if key := filepath.ToSlash(rel); strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list objects: %w", err)
+	}
+
+	sort.Strings(keys)
+	for _, key := range keys {
+		info, err := l.Stat(ctx, key)
+		if IsNotFound(err) {
+			continue // deleted meanwhile
+		}
+		if err != nil {
+			return err
+		}
+		if err := fn(*info); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Health checks that the root directory is accessible
+func (l *Local) Health(ctx context.Context) error {
+	fi, err := os.Stat(l.objectsDir())
+	if err != nil {
+		return err
+	}
+	if !fi.IsDir() {
+		return fmt.Errorf("%s is not a directory", l.objectsDir())
+	}
+	return nil
+}
+
+// uploadDir returns the directory holding the parts of a multipart upload
+func (l *Local) uploadDir(uploadID string) (string, error) {
+	if _, err := uuid.Parse(uploadID); err != nil {
+		return "", fmt.Errorf("invalid upload ID: %w", ErrUploadNotFound)
+	}
+	return filepath.Join(l.multipartDir(), uploadID), nil
+}
+
+// loadUpload returns the directory of an existing multipart upload for key
+func (l *Local) loadUpload(key, uploadID string) (string, localMeta, error) {
+	dir, err := l.uploadDir(uploadID)
+	if err != nil {
+		return "", localMeta{}, err
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "upload.json"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", localMeta{}, ErrUploadNotFound
+	}
+	if err != nil {
+		return "", localMeta{}, err
+	}
+
+	var meta localMeta
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return "", localMeta{}, fmt.Errorf("invalid multipart upload metadata: %w", err)
+	}
+	if meta.Key != key {
+		return "", localMeta{}, ErrUploadNotFound
+	}
+	return dir, meta, nil
+}
+
+// NewMultipartUpload starts a multipart upload and returns its upload ID
+func (l *Local) NewMultipartUpload(ctx context.Context, key, contentType string) (string, error) {
+	if _, _, err := l.paths(key); err != nil {
+		return "", err
+	}
+
+	uploadID := uuid.NewString()
+	dir, _ := l.uploadDir(uploadID)
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return "", fmt.Errorf("failed to start multipart upload: %w", err)
+	}
+	if err := l.writeMeta(filepath.Join(dir, "upload.json"), localMeta{Key: key, ContentType: contentType}); err != nil {
+		return "", fmt.Errorf("failed to start multipart upload: %w", err)
+	}
+	return uploadID, nil
+}
+
+// PutPart uploads one part of a multipart upload
+func (l *Local) PutPart(ctx context.Context, key, uploadID string, number int, reader io.Reader, size int64) error {
+	dir, _, err := l.loadUpload(key, uploadID)
+	if err != nil {
+		return fmt.Errorf("failed to upload part %d: %w", number, err)
+	}
+
+	written, err := writeFileAtomic(filepath.Join(dir, strconv.Itoa(number)), reader)
+	if err != nil {
+		return fmt.Errorf("failed to upload part %d: %w", number, err)
+	}
+	if size >= 0 && written != size {
+		return fmt.Errorf("failed to upload part %d: got %d bytes, want %d", number, written, size)
+	}
+	return nil
+}
+
+// ListParts returns the uploaded parts of a multipart upload in order
+func (l *Local) ListParts(ctx context.Context, key, uploadID string) ([]Part, error) {
+	dir, _, err := l.loadUpload(key, uploadID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list parts: %w", err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list parts: %w", err)
+	}
+
+	var parts []Part
+	for _, entry := range entries {
+		number, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue // upload.json and partial writes
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read part %d: %w", number, err)
+		}
+		parts = append(parts, Part{Number: number, ETag: etag(data), Size: int64(len(data))})
+	}
+
+	sort.Slice(parts, func(i, j int) bool { return parts[i].Number < parts[j].Number })
+	return parts, nil
+}
+
+// CompleteMultipartUpload assembles the parts into the final object
+func (l *Local) CompleteMultipartUpload(ctx context.Context, key, uploadID string, parts []Part) error {
+	dir, meta, err := l.loadUpload(key, uploadID)
+	if err != nil {
+		return fmt.Errorf("failed to complete multipart upload: %w", err)
+	}
+
+	readers := make([]io.Reader, 0, len(parts))
+	var size int64
+	for _, p := range parts {
+		data, err := os.ReadFile(filepath.Join(dir, strconv.Itoa(p.Number)))
+		if err != nil || etag(data) != p.ETag {
+			return fmt.Errorf("failed to complete multipart upload: invalid part %d", p.Number)
+		}
+		readers = append(readers, strings.NewReader(string(data)))
+		size += int64(len(data))
+	}
+
+	if err := l.Upload(ctx, key, io.MultiReader(readers...), size, meta.ContentType); err != nil {
+		return fmt.Errorf("failed to complete multipart upload: %w", err)
+	}
+	return os.RemoveAll(dir)
+}
+
+// AbortMultipartUpload discards a multipart upload and its parts
+func (l *Local) AbortMultipartUpload(ctx context.Context, key, uploadID string) error {
+	dir, _, err := l.loadUpload(key, uploadID)
+	if errors.Is(err, ErrUploadNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to abort multipart upload: %w", err)
+	}
+	return os.RemoveAll(dir)
+}
+
+func (l *Local) writeMeta(metaPath string, meta localMeta) error {
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	if _, err := writeFileAtomic(metaPath, strings.NewReader(string(data))); err != nil {
+		return fmt.Errorf("failed to write object metadata: %w", err)
+	}
+	return nil
+}
+
+// readMeta returns an object's metadata, or defaults if it is missing
+func (l *Local) readMeta(metaPath string) localMeta {
+	meta := localMeta{ContentType: "application/octet-stream"}
+	if data, err := os.ReadFile(metaPath); err == nil {
+		_ = json.Unmarshal(data, &meta)
+	}
+	return meta
+}
+
+// writeFileAtomic writes reader to name via a temporary file, so readers never see partial content
+func writeFileAtomic(name string, reader io.Reader) (int64, error) {
+	if err := os.MkdirAll(filepath.Dir(name), 0o750); err != nil {
+		return 0, err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(name), tempPrefix+"*")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmp.Name())
+
+	written, err := io.Copy(tmp, reader)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return written, err
+	}
+
+	return written, os.Rename(tmp.Name(), name)
+}
+
+// removeEmptyParents removes dir and its ancestors up to (excluding) root while they are empty
+func removeEmptyParents(dir, root string) {
+	for dir != root && strings.HasPrefix(dir, root) {
+		if err := os.Remove(dir); err != nil {
+			return
+		}
+		dir = filepath.Dir(dir)
+	}
+}
+
+var _ MultipartBackend = (*Local)(nil)
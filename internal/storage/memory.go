@@ -0,0 +1,275 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5" //nolint:gosec // ETags are S3-style content checksums, not security
+	"encoding/hex"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Memory is an in-memory backend for tests. Its contents are lost on exit.
+type Memory struct {
+	objects map[string]*memoryObject
+	uploads map[string]*memoryUpload
+	mu      sync.RWMutex
+}
+
+type memoryObject struct {
+	modified    time.Time
+	contentType string
+	etag        string
+	data        []byte
+}
+
+type memoryUpload struct {
+	parts       map[int][]byte
+	key         string
+	contentType string
+}
+
+// NewMemory creates an empty in-memory backend
+func NewMemory() *Memory {
+	return &Memory{
+		objects: make(map[string]*memoryObject),
+		uploads: make(map[string]*memoryUpload),
+	}
+}
+
+// Upload stores size bytes from reader under key
+func (m *Memory) Upload(ctx context.Context, key string, reader io.Reader, size int64, contentType string) error {
+	data, err := readObject(reader, size)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.objects[key] = newMemoryObject(data, contentType)
+	return nil
+}
+
+// Download opens an object for reading
+func (m *Memory) Download(ctx context.Context, key string) (io.ReadSeekCloser, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	obj, ok := m.objects[key]
+	if !ok {
+		return nil, fmt.Errorf("failed to get object %s: %w", key, ErrNotFound)
+	}
+	return nopSeekCloser{bytes.NewReader(obj.data)}, nil
+}
+
+// Delete removes an object
+func (m *Memory) Delete(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.objects, key)
+	return nil
+}
+
+// DeleteMany removes objects
+func (m *Memory) DeleteMany(ctx context.Context, keys []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, key := range keys {
+		delete(m.objects, key)
+	}
+	return nil
+}
+
+// Stat returns object metadata
+func (m *Memory) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	obj, ok := m.objects[key]
+	if !ok {
+		return nil, fmt.Errorf("failed to stat object %s: %w", key, ErrNotFound)
+	}
+	info := obj.info(key)
+	return &info, nil
+}
+
+// Exists reports whether an object exists
+func (m *Memory) Exists(ctx context.Context, key string) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	_, ok := m.objects[key]
+	return ok, nil
+}
+
+// Copy copies an object
+func (m *Memory) Copy(ctx context.Context, srcKey, dstKey string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	obj, ok := m.objects[srcKey]
+	if !ok {
+		return fmt.Errorf("failed to copy object %s: %w", srcKey, ErrNotFound)
+	}
+	m.objects[dstKey] = newMemoryObject(obj.data, obj.contentType)
+	return nil
+}
+
+// Presign is not supported: memory objects are not reachable by clients
+func (m *Memory) Presign(ctx context.Context, method, key string, expiry time.Duration, opts PresignOptions) (string, error) {
+	return "", fmt.Errorf("cannot presign %s: %w", method, ErrNotSupported)
+}
+
+// List calls fn for every object whose key starts with prefix, in key order
+func (m *Memory) List(ctx context.Context, prefix string, fn func(ObjectInfo) error) error {
+	m.mu.RLock()
+	var infos []ObjectInfo
+	for key, obj := range m.objects {
+		if strings.HasPrefix(key, prefix) {
+			infos = append(infos, obj.info(key))
+		}
+	}
+	m.mu.RUnlock()
+
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Key < infos[j].Key })
+	for _, info := range infos {
+		if err := fn(info); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Health always succeeds
+func (m *Memory) Health(ctx context.Context) error {
+	return nil
+}
+
+// NewMultipartUpload starts a multipart upload and returns its upload ID
+func (m *Memory) NewMultipartUpload(ctx context.Context, key, contentType string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	uploadID := uuid.NewString()
+	m.uploads[uploadID] = &memoryUpload{key: key, contentType: contentType, parts: make(map[int][]byte)}
+	return uploadID, nil
+}
+
+// PutPart uploads one part of a multipart upload
+func (m *Memory) PutPart(ctx context.Context, key, uploadID string, number int, reader io.Reader, size int64) error {
+	data, err := readObject(reader, size)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	upload, ok := m.uploads[uploadID]
+	if !ok || upload.key != key {
+		return fmt.Errorf("failed to upload part %d: %w", number, ErrUploadNotFound)
+	}
+	upload.parts[number] = data
+	return nil
+}
+
+// ListParts returns the uploaded parts of a multipart upload in order
+func (m *Memory) ListParts(ctx context.Context, key, uploadID string) ([]Part, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	upload, ok := m.uploads[uploadID]
+	if !ok || upload.key != key {
+		return nil, fmt.Errorf("failed to list parts: %w", ErrUploadNotFound)
+	}
+
+	parts := make([]Part, 0, len(upload.parts))
+	for number, data := range upload.parts {
+		parts = append(parts, Part{Number: number, ETag: etag(data), Size: int64(len(data))})
+	}
+	sort.Slice(parts, func(i, j int) bool { return parts[i].Number < parts[j].Number })
+	return parts, nil
+}
+
+// CompleteMultipartUpload assembles the parts into the final object
+func (m *Memory) CompleteMultipartUpload(ctx context.Context, key, uploadID string, parts []Part) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	upload, ok := m.uploads[uploadID]
+	if !ok || upload.key != key {
+		return fmt.Errorf("failed to complete multipart upload: %w", ErrUploadNotFound)
+	}
+
+	var buf bytes.Buffer
+	for _, p := range parts {
+		data, ok := upload.parts[p.Number]
+		if !ok || etag(data) != p.ETag {
+			return fmt.Errorf("failed to complete multipart upload: invalid part %d", p.Number)
+		}
+		buf.Write(data)
+	}
+
+	m.objects[key] = newMemoryObject(buf.Bytes(), upload.contentType)
+	delete(m.uploads, uploadID)
+	return nil
+}
+
+// AbortMultipartUpload discards a multipart upload and its parts
+func (m *Memory) AbortMultipartUpload(ctx context.Context, key, uploadID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.uploads, uploadID)
+	return nil
+}
+
+func newMemoryObject(data []byte, contentType string) *memoryObject {
+	return &memoryObject{
+		data:        data,
+		contentType: contentType,
+		etag:        etag(data),
+		modified:    time.Now().UTC().Truncate(time.Second),
+	}
+}
+
+func (o *memoryObject) info(key string) ObjectInfo {
+	return ObjectInfo{
+		Key:          key,
+		Size:         int64(len(o.data)),
+		ContentType:  o.contentType,
+		ETag:         o.etag,
+		LastModified: o.modified,
+	}
+}
+
+// readObject reads an object body, checking its size when it is known (size >= 0)
+func readObject(reader io.Reader, size int64) ([]byte, error) {
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read object: %w", err)
+	}
+	if size >= 0 && int64(len(data)) != size {
+		return nil, fmt.Errorf("failed to upload object: got %d bytes, want %d", len(data), size)
+	}
+	return data, nil
+}
+
+// etag returns the S3-style ETag (hex MD5) of data
+func etag(data []byte) string {
+	sum := md5.Sum(data) //nolint:gosec // see import
+	return hex.EncodeToString(sum[:])
+}
+
+// nopSeekCloser adds a no-op Close to an io.ReadSeeker
+type nopSeekCloser struct {
+	io.ReadSeeker
+}
+
+func (nopSeekCloser) Close() error { return nil }
+
+var _ MultipartBackend = (*Memory)(nil)
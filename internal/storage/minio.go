@@ -2,21 +2,30 @@ package storage
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/minio/minio-go/v7/pkg/encrypt"
+	"github.com/minio/minio-go/v7/pkg/sse"
 
 	"github.com/timkrebs/image-processor/internal/metrics"
 )
 
-// Storage provides object storage operations
+// Storage is the MinIO (S3-compatible) backend
 type Storage struct {
-	client     *minio.Client
-	metrics    *metrics.StorageMetrics
-	bucketName string
+	client    *minio.Client
+	metrics   *metrics.StorageMetrics
+	keys      *keyring
+	originals location
+	processed location
 }
 
 // Config holds MinIO configuration
@@ -26,10 +35,32 @@ type Config struct {
 	SecretKey string
 	Bucket    string
 	UseSSL    bool
+	// Encryption is the server-side encryption mode (EncryptionNone, EncryptionSSES3 or EncryptionSSEC)
+	Encryption string
+	// EncryptionKeys are the base64-encoded 32-byte SSE-C master keys, newest first
+	EncryptionKeys []string
+	// ProcessedBucket stores processed outputs; empty means Bucket
+	ProcessedBucket string
+	// OriginalsPrefix and ProcessedPrefix are prepended to the keys of each class of objects
+	OriginalsPrefix string
+	ProcessedPrefix string
+	// OriginalsExpiryDays and ProcessedExpiryDays configure lifecycle rules
+	// that expire forgotten objects; 0 disables them
+	OriginalsExpiryDays int
+	ProcessedExpiryDays int
 }
 
 // New creates a new storage client
 func New(cfg Config) (*Storage, error) {
+	keys, err := newKeyring(cfg.Encryption, cfg.EncryptionKeys)
+	if err != nil {
+		return nil, err
+	}
+	originals, processed, err := newLayout(cfg)
+	if err != nil {
+		return nil, err
+	}
+
 	client, err := minio.New(cfg.Endpoint, &minio.Options{
 		Creds:  credentials.NewStaticV4(cfg.AccessKey, cfg.SecretKey, ""),
 		Secure: cfg.UseSSL,
@@ -39,8 +70,10 @@ func New(cfg Config) (*Storage, error) {
 	}
 
 	return &Storage{
-		client:     client,
-		bucketName: cfg.Bucket,
+		client:    client,
+		keys:      keys,
+		originals: originals,
+		processed: processed,
 	}, nil
 }
 
@@ -49,17 +82,33 @@ func (s *Storage) SetMetrics(m *metrics.StorageMetrics) {
 	s.metrics = m
 }
 
-// EnsureBucket creates the bucket if it doesn't exist
+// EnsureBucket creates the buckets if they don't exist and configures their
+// encryption and lifecycle rules
 func (s *Storage) EnsureBucket(ctx context.Context) error {
-	exists, err := s.client.BucketExists(ctx, s.bucketName)
-	if err != nil {
-		return fmt.Errorf("failed to check bucket existence: %w", err)
+	for _, bucket := range s.buckets() {
+		exists, err := s.client.BucketExists(ctx, bucket)
+		if err != nil {
+			return fmt.Errorf("failed to check bucket existence: %w", err)
+		}
+
+		if !exists {
+			err = s.client.MakeBucket(ctx, bucket, minio.MakeBucketOptions{})
+			if err != nil {
+				return fmt.Errorf("failed to create bucket: %w", err)
+			}
+		}
+
+		// Default encryption also covers objects clients PUT through presigned URLs
+		if s.keys.mode == EncryptionSSES3 {
+			if err := s.client.SetBucketEncryption(ctx, bucket, sse.NewConfigurationSSES3()); err != nil {
+				return fmt.Errorf("failed to enable bucket encryption: %w", err)
+			}
+		}
 	}
 
-	if !exists {
-		err = s.client.MakeBucket(ctx, s.bucketName, minio.MakeBucketOptions{})
-		if err != nil {
-			return fmt.Errorf("failed to create bucket: %w", err)
+	for bucket, rules := range s.lifecycleRules() {
+		if err := s.configureLifecycle(ctx, bucket, rules); err != nil {
+			return err
 		}
 	}
 
@@ -71,8 +120,10 @@ func (s *Storage) Upload(ctx context.Context, key string, reader io.Reader, size
 	start := time.Now()
 	status := "success"
 
-	_, err := s.client.PutObject(ctx, s.bucketName, key, reader, size, minio.PutObjectOptions{
-		ContentType: contentType,
+	bucket, object := s.locate(key)
+	_, err := s.client.PutObject(ctx, bucket, object, reader, size, minio.PutObjectOptions{
+		ContentType:          contentType,
+		ServerSideEncryption: s.keys.write(key),
 	})
 
 	if err != nil {
@@ -94,12 +145,24 @@ func (s *Storage) Upload(ctx context.Context, key string, reader io.Reader, size
 	return nil
 }
 
-// Download downloads a file from storage
-func (s *Storage) Download(ctx context.Context, key string) (io.ReadCloser, error) {
+// Download downloads a file from storage.
+// The object is fetched lazily, so seeking to a range only transfers what is read.
+func (s *Storage) Download(ctx context.Context, key string) (io.ReadSeekCloser, error) {
 	start := time.Now()
 	status := "success"
 
-	obj, err := s.client.GetObject(ctx, s.bucketName, key, minio.GetObjectOptions{})
+	var opts minio.GetObjectOptions
+	var err error
+	if s.keys.mode == EncryptionSSEC {
+		// Find the object's key up front; a wrong one would only fail on the first read
+		opts.ServerSideEncryption, _, _, err = s.resolve(ctx, key)
+	}
+
+	var obj *minio.Object
+	if err == nil {
+		bucket, object := s.locate(key)
+		obj, err = s.client.GetObject(ctx, bucket, object, opts)
+	}
 
 	if err != nil {
 		status = "error"
@@ -119,7 +182,7 @@ func (s *Storage) Download(ctx context.Context, key string) (io.ReadCloser, erro
 
 // Exists checks if a file exists in storage
 func (s *Storage) Exists(ctx context.Context, key string) (bool, error) {
-	_, err := s.client.StatObject(ctx, s.bucketName, key, minio.StatObjectOptions{})
+	_, _, _, err := s.resolve(ctx, key)
 	if err != nil {
 		// Check if error is "object not found"
 		errResponse := minio.ToErrorResponse(err)
@@ -139,7 +202,8 @@ func (s *Storage) Delete(ctx context.Context, key string) error {
 	opts := minio.RemoveObjectOptions{
 		GovernanceBypass: true,
 	}
-	err := s.client.RemoveObject(ctx, s.bucketName, key, opts)
+	bucket, object := s.locate(key)
+	err := s.client.RemoveObject(ctx, bucket, object, opts)
 
 	if err != nil {
 		status = "error"
@@ -154,26 +218,263 @@ func (s *Storage) Delete(ctx context.Context, key string) error {
 	return err
 }
 
-// GetPresignedURL generates a presigned URL for downloading
-func (s *Storage) GetPresignedURL(ctx context.Context, key string, expiry time.Duration) (string, error) {
-	url, err := s.client.PresignedGetObject(ctx, s.bucketName, key, expiry, nil)
+// Copy copies an object within the bucket without transferring it through the client
+func (s *Storage) Copy(ctx context.Context, srcKey, dstKey string) error {
+	srcEncryption, _, _, err := s.resolve(ctx, srcKey)
+	if err != nil {
+		return fmt.Errorf("failed to copy object: %w", err)
+	}
+
+	srcBucket, srcObject := s.locate(srcKey)
+	dstBucket, dstObject := s.locate(dstKey)
+	_, err = s.client.CopyObject(ctx,
+		minio.CopyDestOptions{Bucket: dstBucket, Object: dstObject, Encryption: s.keys.write(dstKey)},
+		minio.CopySrcOptions{Bucket: srcBucket, Object: srcObject, Encryption: srcEncryption},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to copy object: %w", err)
+	}
+	return nil
+}
+
+// List calls fn for every object whose key starts with prefix, in key order
+func (s *Storage) List(ctx context.Context, prefix string, fn func(ObjectInfo) error) error {
+	locations := s.locations()
+	if len(locations) == 1 {
+		return s.listLocation(ctx, locations[0], prefix, fn)
+	}
+
+	// Merge the locations; each lists in key order, but not the two together
+	var infos []ObjectInfo
+	for _, loc := range locations {
+		err := s.listLocation(ctx, loc, prefix, func(info ObjectInfo) error {
+			infos = append(infos, info)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Key < infos[j].Key })
+	for _, info := range infos {
+		if err := fn(info); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// listLocation lists the objects of one location whose key starts with prefix
+func (s *Storage) listLocation(ctx context.Context, loc location, prefix string, fn func(ObjectInfo) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	objects := s.client.ListObjects(ctx, loc.bucket, minio.ListObjectsOptions{Prefix: loc.prefix + prefix, Recursive: true})
+	for object := range objects {
+		if object.Err != nil {
+			return fmt.Errorf("failed to list objects: %w", object.Err)
+		}
+
+		info := objectInfo(object)
+		info.Key = strings.TrimPrefix(info.Key, loc.prefix)
+		// Skip objects of the other class when both share the location's prefix
+		if s.location(info.Key) != loc {
+			continue
+		}
+		if err := fn(info); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteMany removes objects with as few requests as possible
+func (s *Storage) DeleteMany(ctx context.Context, keys []string) error {
+	start := time.Now()
+	status := "success"
+
+	objects := make(map[string][]string)
+	for _, key := range keys {
+		bucket, object := s.locate(key)
+		objects[bucket] = append(objects[bucket], object)
+	}
+
+	var errs []error
+	for bucket, names := range objects {
+		objectsCh := make(chan minio.ObjectInfo)
+		go func() {
+			defer close(objectsCh)
+			for _, name := range names {
+				select {
+				case objectsCh <- minio.ObjectInfo{Key: name}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+
+		// The client sends up to 1000 keys per request
+		for removeErr := range s.client.RemoveObjects(ctx, bucket, objectsCh, minio.RemoveObjectsOptions{GovernanceBypass: true}) {
+			errs = append(errs, fmt.Errorf("failed to delete object %s: %w", removeErr.ObjectName, removeErr.Err))
+		}
+	}
+	err := errors.Join(errs...)
+	if err == nil {
+		// Cancellation stops sending keys without reporting the unsent ones
+		err = ctx.Err()
+	}
 	if err != nil {
-		return "", fmt.Errorf("failed to generate presigned URL: %w", err)
+		status = "error"
+	}
+
+	if s.metrics != nil {
+		duration := time.Since(start).Seconds()
+		s.metrics.OperationDuration.WithLabelValues("delete_many", status).Observe(duration)
+		s.metrics.OperationsTotal.WithLabelValues("delete_many", status).Inc()
+	}
+
+	return err
+}
+
+// Presign generates a presigned URL for downloading (GET) or uploading (PUT) an object.
+// A presigned PUT must send opts.ContentType, which is part of the signature.
+// SSE-C objects cannot be presigned, as clients would need the user's key.
+func (s *Storage) Presign(ctx context.Context, method, key string, expiry time.Duration, opts PresignOptions) (string, error) {
+	if !s.keys.presignable() {
+		return "", fmt.Errorf("cannot presign %s of encrypted object: %w", method, ErrNotSupported)
+	}
+
+	switch method {
+	case http.MethodGet:
+		params := url.Values{}
+		if opts.CacheControl != "" {
+			params.Set("response-cache-control", opts.CacheControl)
+		}
+		if opts.ContentDisposition != "" {
+			params.Set("response-content-disposition", opts.ContentDisposition)
+		}
+
+		bucket, object := s.locate(key)
+		presigned, err := s.client.PresignedGetObject(ctx, bucket, object, expiry, params)
+		if err != nil {
+			return "", fmt.Errorf("failed to generate presigned URL: %w", err)
+		}
+		return presigned.String(), nil
+	case http.MethodPut:
+		headers := http.Header{}
+		headers.Set("Content-Type", opts.ContentType)
+
+		bucket, object := s.locate(key)
+		presigned, err := s.client.PresignHeader(ctx, http.MethodPut, bucket, object, expiry, nil, headers)
+		if err != nil {
+			return "", fmt.Errorf("failed to generate presigned upload URL: %w", err)
+		}
+		return presigned.String(), nil
+	default:
+		return "", fmt.Errorf("cannot presign %s: %w", method, ErrNotSupported)
+	}
+}
+
+// IsNotFound reports whether err means the object does not exist
+func IsNotFound(err error) bool {
+	if errors.Is(err, ErrNotFound) {
+		return true
+	}
+	var resp minio.ErrorResponse
+	if !errors.As(err, &resp) {
+		return false
 	}
-	return url.String(), nil
+	return resp.Code == "NoSuchKey" || resp.StatusCode == http.StatusNotFound
 }
 
 // Stat retrieves object metadata
-func (s *Storage) Stat(ctx context.Context, key string) (*minio.ObjectInfo, error) {
-	info, err := s.client.StatObject(ctx, s.bucketName, key, minio.StatObjectOptions{})
+func (s *Storage) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	_, info, _, err := s.resolve(ctx, key)
 	if err != nil {
 		return nil, fmt.Errorf("failed to stat object: %w", err)
 	}
-	return &info, nil
+	result := objectInfo(info)
+	return &result, nil
+}
+
+// resolve stats an object, trying each encryption it may have been stored
+// with. It returns the encryption that works and whether it is the one new
+// objects are written with.
+func (s *Storage) resolve(ctx context.Context, key string) (encrypt.ServerSide, minio.ObjectInfo, bool, error) {
+	bucket, object := s.locate(key)
+
+	var firstErr error
+	for i, candidate := range s.keys.candidates(key) {
+		info, err := s.client.StatObject(ctx, bucket, object, minio.StatObjectOptions{ServerSideEncryption: candidate})
+		if err == nil {
+			info.Key = key
+			return candidate, info, s.keys.current(i, info), nil
+		}
+		if !isKeyMismatch(err) {
+			return nil, minio.ObjectInfo{}, false, err
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return nil, minio.ObjectInfo{}, false, firstErr
+}
+
+// Rekey rewrites every object under prefix that is not stored with the
+// current encryption, so that retired encryption keys can be removed.
+// It returns the number of objects rewritten.
+func (s *Storage) Rekey(ctx context.Context, prefix string) (int, error) {
+	var keys []string
+	err := s.List(ctx, prefix, func(info ObjectInfo) error {
+		keys = append(keys, info.Key)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	rekeyed := 0
+	for _, key := range keys {
+		encryption, _, current, err := s.resolve(ctx, key)
+		if IsNotFound(err) || current {
+			continue
+		}
+		if err != nil {
+			return rekeyed, fmt.Errorf("failed to read object %s: %w", key, err)
+		}
+
+		// Copying an object onto itself with a different encryption re-encrypts it in place
+		bucket, object := s.locate(key)
+		_, err = s.client.CopyObject(ctx,
+			minio.CopyDestOptions{Bucket: bucket, Object: object, Encryption: s.keys.write(key)},
+			minio.CopySrcOptions{Bucket: bucket, Object: object, Encryption: encryption},
+		)
+		if err != nil {
+			return rekeyed, fmt.Errorf("failed to re-encrypt object %s: %w", key, err)
+		}
+		rekeyed++
+	}
+	return rekeyed, nil
+}
+
+// objectInfo converts MinIO object metadata
+func objectInfo(info minio.ObjectInfo) ObjectInfo {
+	return ObjectInfo{
+		Key:          info.Key,
+		Size:         info.Size,
+		ContentType:  info.ContentType,
+		ETag:         info.ETag,
+		LastModified: info.LastModified,
+	}
 }
 
 // Health checks if storage is accessible
 func (s *Storage) Health(ctx context.Context) error {
-	_, err := s.client.BucketExists(ctx, s.bucketName)
-	return err
+	for _, bucket := range s.buckets() {
+		if _, err := s.client.BucketExists(ctx, bucket); err != nil {
+			return err
+		}
+	}
+	return nil
 }
@@ -0,0 +1,105 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// core exposes the low-level multipart API of the storage client
+func (s *Storage) core() *minio.Core {
+	return &minio.Core{Client: s.client}
+}
+
+// NewMultipartUpload starts a multipart upload and returns its upload ID
+func (s *Storage) NewMultipartUpload(ctx context.Context, key, contentType string) (string, error) {
+	bucket, object := s.locate(key)
+	uploadID, err := s.core().NewMultipartUpload(ctx, bucket, object, minio.PutObjectOptions{
+		ContentType:          contentType,
+		ServerSideEncryption: s.keys.write(key),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to start multipart upload: %w", err)
+	}
+	return uploadID, nil
+}
+
+// PutPart uploads one part of a multipart upload
+func (s *Storage) PutPart(ctx context.Context, key, uploadID string, number int, reader io.Reader, size int64) error {
+	bucket, object := s.locate(key)
+	_, err := s.core().PutObjectPart(ctx, bucket, object, uploadID, number, reader, size, minio.PutObjectPartOptions{
+		SSE: s.keys.write(key),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to upload part %d: %w", number, err)
+	}
+
+	if s.metrics != nil {
+		s.metrics.BytesTransferred.WithLabelValues("upload").Add(float64(size))
+	}
+	return nil
+}
+
+// ListParts returns the uploaded parts of a multipart upload in order
+func (s *Storage) ListParts(ctx context.Context, key, uploadID string) ([]Part, error) {
+	bucket, object := s.locate(key)
+
+	var parts []Part
+	marker := 0
+	for {
+		result, err := s.core().ListObjectParts(ctx, bucket, object, uploadID, marker, 1000)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list parts: %w", err)
+		}
+		for _, p := range result.ObjectParts {
+			parts = append(parts, Part{Number: p.PartNumber, ETag: p.ETag, Size: p.Size})
+		}
+		if !result.IsTruncated {
+			break
+		}
+		marker = result.NextPartNumberMarker
+	}
+
+	sort.Slice(parts, func(i, j int) bool { return parts[i].Number < parts[j].Number })
+	return parts, nil
+}
+
+// CompleteMultipartUpload assembles the parts into the final object
+func (s *Storage) CompleteMultipartUpload(ctx context.Context, key, uploadID string, parts []Part) error {
+	complete := make([]minio.CompletePart, 0, len(parts))
+	for _, p := range parts {
+		complete = append(complete, minio.CompletePart{PartNumber: p.Number, ETag: p.ETag})
+	}
+
+	bucket, object := s.locate(key)
+	if _, err := s.core().CompleteMultipartUpload(ctx, bucket, object, uploadID, complete, minio.PutObjectOptions{
+		ServerSideEncryption: s.keys.write(key),
+	}); err != nil {
+		return fmt.Errorf("failed to complete multipart upload: %w", err)
+	}
+	return nil
+}
+
+// AbortMultipartUpload discards a multipart upload and its parts
+func (s *Storage) AbortMultipartUpload(ctx context.Context, key, uploadID string) error {
+	bucket, object := s.locate(key)
+	err := s.core().AbortMultipartUpload(ctx, bucket, object, uploadID)
+	if err != nil && !IsNoSuchUpload(err) {
+		return fmt.Errorf("failed to abort multipart upload: %w", err)
+	}
+	return nil
+}
+
+// IsNoSuchUpload reports whether err means the multipart upload no longer exists,
+// because it was completed or aborted
+func IsNoSuchUpload(err error) bool {
+	if errors.Is(err, ErrUploadNotFound) {
+		return true
+	}
+	var resp minio.ErrorResponse
+	return errors.As(err, &resp) && resp.Code == "NoSuchUpload"
+}
@@ -0,0 +1,5 @@
+-- Remove priority index
+DROP INDEX IF EXISTS idx_jobs_priority_status;
+
+-- Remove priority column
+ALTER TABLE jobs DROP COLUMN priority;
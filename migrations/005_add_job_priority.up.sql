@@ -0,0 +1,8 @@
+-- Add scheduling priority to jobs
+ALTER TABLE jobs ADD COLUMN priority VARCHAR(20) NOT NULL DEFAULT 'default';
+
+-- Create index for per-priority dashboard queries
+CREATE INDEX idx_jobs_priority_status ON jobs(priority, status);
+
+-- Add comment explaining the field
+COMMENT ON COLUMN jobs.priority IS 'Queue tier the job was submitted under: interactive, default or bulk.';
@@ -0,0 +1,5 @@
+-- Remove scheduled index
+DROP INDEX IF EXISTS idx_jobs_scheduled_run_at;
+
+-- Remove run_at column
+ALTER TABLE jobs DROP COLUMN run_at;
@@ -0,0 +1,8 @@
+-- Add run_at timestamp for scheduled and delayed jobs
+ALTER TABLE jobs ADD COLUMN run_at TIMESTAMP WITH TIME ZONE;
+
+-- Create index for finding scheduled jobs by due time
+CREATE INDEX idx_jobs_scheduled_run_at ON jobs(run_at) WHERE status = 'scheduled';
+
+-- Add comment explaining the field
+COMMENT ON COLUMN jobs.run_at IS 'Earliest time a scheduled job is dispatched to the queue. NULL for jobs queued immediately.';
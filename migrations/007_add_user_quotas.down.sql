@@ -0,0 +1,8 @@
+-- Drop trigger
+DROP TRIGGER IF EXISTS update_user_quotas_updated_at ON user_quotas;
+
+-- Drop usage index
+DROP INDEX IF EXISTS idx_jobs_user_id_created_at;
+
+-- Drop user_quotas table
+DROP TABLE IF EXISTS user_quotas;
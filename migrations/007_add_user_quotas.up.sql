@@ -0,0 +1,18 @@
+-- Create per-user quota overrides. NULL limits fall back to the configured defaults.
+CREATE TABLE IF NOT EXISTS user_quotas (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    max_jobs_per_day INTEGER,
+    max_bytes_stored BIGINT,
+    max_active_jobs INTEGER,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+-- Create index for per-user usage queries
+CREATE INDEX IF NOT EXISTS idx_jobs_user_id_created_at ON jobs(user_id, created_at DESC);
+
+-- Create updated_at trigger for user_quotas
+CREATE TRIGGER update_user_quotas_updated_at
+    BEFORE UPDATE ON user_quotas
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
@@ -0,0 +1,5 @@
+-- Remove anonymous owner index
+DROP INDEX IF EXISTS idx_jobs_anonymous_id_created_at;
+
+-- Remove anonymous_id column
+ALTER TABLE jobs DROP COLUMN IF EXISTS anonymous_id;
@@ -0,0 +1,8 @@
+-- Track the ephemeral identity of anonymous clients so their jobs are not shared
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS anonymous_id UUID;
+
+-- Create index for listing an anonymous client's jobs
+CREATE INDEX IF NOT EXISTS idx_jobs_anonymous_id_created_at ON jobs(anonymous_id, created_at DESC)
+    WHERE anonymous_id IS NOT NULL;
+
+COMMENT ON COLUMN jobs.anonymous_id IS 'Signed-cookie identity of the anonymous client that submitted the job';
@@ -0,0 +1,10 @@
+-- Drop trigger
+DROP TRIGGER IF EXISTS update_batches_updated_at ON batches;
+
+-- Drop indexes
+DROP INDEX IF EXISTS idx_batches_user_id_created_at;
+DROP INDEX IF EXISTS idx_jobs_batch_id;
+
+-- Unlink jobs and drop batches table
+ALTER TABLE jobs DROP COLUMN IF EXISTS batch_id;
+DROP TABLE IF EXISTS batches;
@@ -0,0 +1,23 @@
+-- Create batches table grouping jobs submitted together
+CREATE TABLE IF NOT EXISTS batches (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    anonymous_id UUID,
+    priority VARCHAR(20) NOT NULL DEFAULT 'default',
+    operations JSONB NOT NULL DEFAULT '[]',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+-- Link jobs to their batch
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS batch_id UUID REFERENCES batches(id) ON DELETE SET NULL;
+
+-- Create indexes
+CREATE INDEX IF NOT EXISTS idx_jobs_batch_id ON jobs(batch_id) WHERE batch_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_batches_user_id_created_at ON batches(user_id, created_at DESC);
+
+-- Create updated_at trigger for batches
+CREATE TRIGGER update_batches_updated_at
+    BEFORE UPDATE ON batches
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
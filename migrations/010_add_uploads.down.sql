@@ -0,0 +1,8 @@
+-- Drop trigger
+DROP TRIGGER IF EXISTS update_uploads_updated_at ON uploads;
+
+-- Drop index
+DROP INDEX IF EXISTS idx_uploads_expires_at;
+
+-- Drop uploads table
+DROP TABLE IF EXISTS uploads;
@@ -0,0 +1,25 @@
+-- Create uploads table tracking direct-to-storage uploads until a job claims them
+CREATE TABLE IF NOT EXISTS uploads (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    anonymous_id UUID,
+    object_key VARCHAR(512) NOT NULL,
+    filename VARCHAR(255) NOT NULL,
+    content_type VARCHAR(100) NOT NULL,
+    size BIGINT NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+-- Create index for cleanup of expired uploads
+CREATE INDEX IF NOT EXISTS idx_uploads_expires_at ON uploads(expires_at);
+
+-- Create updated_at trigger for uploads
+CREATE TRIGGER update_uploads_updated_at
+    BEFORE UPDATE ON uploads
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
+
+COMMENT ON COLUMN uploads.expires_at IS 'Deadline for creating a job from this upload. Unclaimed uploads are deleted by the cleanup worker after it.';
@@ -0,0 +1,4 @@
+-- Remove resumable upload columns
+ALTER TABLE uploads DROP COLUMN IF EXISTS locked_until;
+ALTER TABLE uploads DROP COLUMN IF EXISTS completed_at;
+ALTER TABLE uploads DROP COLUMN IF EXISTS multipart_id;
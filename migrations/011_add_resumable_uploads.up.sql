@@ -0,0 +1,7 @@
+-- Track resumable uploads backed by storage multipart uploads
+ALTER TABLE uploads ADD COLUMN IF NOT EXISTS multipart_id VARCHAR(1024);
+ALTER TABLE uploads ADD COLUMN IF NOT EXISTS completed_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE uploads ADD COLUMN IF NOT EXISTS locked_until TIMESTAMP WITH TIME ZONE;
+
+COMMENT ON COLUMN uploads.multipart_id IS 'Storage multipart upload ID of a resumable upload';
+COMMENT ON COLUMN uploads.locked_until IS 'Lease held while a chunk is written, so chunks of one upload never interleave';
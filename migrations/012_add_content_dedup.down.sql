@@ -0,0 +1,9 @@
+-- Drop index
+DROP INDEX IF EXISTS idx_jobs_dedup;
+
+-- Remove content hash columns
+ALTER TABLE jobs DROP COLUMN IF EXISTS operations_sha256;
+ALTER TABLE jobs DROP COLUMN IF EXISTS original_sha256;
+
+-- Drop object_refs table
+DROP TABLE IF EXISTS object_refs;
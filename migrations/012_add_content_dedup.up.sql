@@ -0,0 +1,20 @@
+-- Record which jobs reference each shared storage object (content-addressed originals
+-- and reused results). An object is deleted when its last reference is released.
+CREATE TABLE IF NOT EXISTS object_refs (
+    object_key VARCHAR(512) NOT NULL,
+    job_id UUID NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (object_key, job_id)
+);
+
+-- Content hashes used to find identical work
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS original_sha256 CHAR(64);
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS operations_sha256 CHAR(64);
+
+-- Create index for finding completed jobs with the same original and operations
+CREATE INDEX IF NOT EXISTS idx_jobs_dedup ON jobs(user_id, original_sha256, operations_sha256)
+    WHERE status = 'completed' AND original_sha256 IS NOT NULL;
+
+COMMENT ON TABLE object_refs IS 'Jobs referencing a shared storage object. Objects without rows belong to a single job.';
+COMMENT ON COLUMN jobs.original_sha256 IS 'SHA-256 of the original image, NULL for direct uploads';
+COMMENT ON COLUMN jobs.operations_sha256 IS 'SHA-256 of the canonical JSON encoding of the operations';
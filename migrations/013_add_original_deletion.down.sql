@@ -0,0 +1,3 @@
+-- Remove original deletion columns
+ALTER TABLE jobs DROP COLUMN IF EXISTS original_deleted_at;
+ALTER TABLE jobs DROP COLUMN IF EXISTS delete_original;
@@ -0,0 +1,6 @@
+-- Jobs whose original is deleted as soon as processing succeeds
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS delete_original BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS original_deleted_at TIMESTAMP WITH TIME ZONE;
+
+COMMENT ON COLUMN jobs.delete_original IS 'Delete the original after successful processing instead of at cleanup';
+COMMENT ON COLUMN jobs.original_deleted_at IS 'When the original was deleted, NULL while it is kept';
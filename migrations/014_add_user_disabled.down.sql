@@ -0,0 +1,2 @@
+-- Remove account disabling
+ALTER TABLE users DROP COLUMN IF EXISTS disabled_at;
@@ -0,0 +1,4 @@
+-- Accounts disabled by an administrator can no longer log in
+ALTER TABLE users ADD COLUMN IF NOT EXISTS disabled_at TIMESTAMP WITH TIME ZONE;
+
+COMMENT ON COLUMN users.disabled_at IS 'When the account was disabled, NULL while it is active';
@@ -0,0 +1,9 @@
+-- Rollback job search indexes
+-- The pg_trgm extension is kept, as other objects may depend on it
+
+DROP INDEX IF EXISTS idx_jobs_user_id_file_size;
+DROP INDEX IF EXISTS idx_jobs_user_id_content_type;
+DROP INDEX IF EXISTS idx_jobs_user_id_status_created_at;
+DROP INDEX IF EXISTS idx_jobs_operations;
+DROP INDEX IF EXISTS idx_jobs_original_name_prefix;
+DROP INDEX IF EXISTS idx_jobs_original_name_trgm;
@@ -0,0 +1,17 @@
+-- Indexes for searching and filtering the job list
+
+-- Trigram matching for name searches (original_name ILIKE '%...%')
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE INDEX IF NOT EXISTS idx_jobs_original_name_trgm ON jobs USING GIN (original_name gin_trgm_ops);
+
+-- Case-insensitive name prefix searches (lower(original_name) LIKE '...%')
+CREATE INDEX IF NOT EXISTS idx_jobs_original_name_prefix ON jobs(lower(original_name) text_pattern_ops);
+
+-- Jobs containing an operation (operations @> '[{"operation": "..."}]')
+CREATE INDEX IF NOT EXISTS idx_jobs_operations ON jobs USING GIN (operations jsonb_path_ops);
+
+-- Owner scoped status, content type and size filters
+CREATE INDEX IF NOT EXISTS idx_jobs_user_id_status_created_at ON jobs(user_id, status, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_jobs_user_id_content_type ON jobs(user_id, content_type);
+CREATE INDEX IF NOT EXISTS idx_jobs_user_id_file_size ON jobs(user_id, file_size);
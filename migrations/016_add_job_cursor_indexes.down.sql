@@ -0,0 +1,9 @@
+-- Restore the owner indexes on created_at alone
+
+CREATE INDEX IF NOT EXISTS idx_jobs_user_id_created_at ON jobs(user_id, created_at DESC);
+
+CREATE INDEX IF NOT EXISTS idx_jobs_anonymous_id_created_at ON jobs(anonymous_id, created_at DESC)
+    WHERE anonymous_id IS NOT NULL;
+
+DROP INDEX IF EXISTS idx_jobs_anonymous_id_created_at_id;
+DROP INDEX IF EXISTS idx_jobs_user_id_created_at_id;
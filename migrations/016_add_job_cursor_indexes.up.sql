@@ -0,0 +1,10 @@
+-- Keyset pagination seeks on (created_at, id) within an owner's jobs. These
+-- indexes supersede the owner indexes on created_at alone.
+
+CREATE INDEX IF NOT EXISTS idx_jobs_user_id_created_at_id ON jobs(user_id, created_at DESC, id DESC);
+
+CREATE INDEX IF NOT EXISTS idx_jobs_anonymous_id_created_at_id ON jobs(anonymous_id, created_at DESC, id DESC)
+    WHERE anonymous_id IS NOT NULL;
+
+DROP INDEX IF EXISTS idx_jobs_user_id_created_at;
+DROP INDEX IF EXISTS idx_jobs_anonymous_id_created_at;
@@ -0,0 +1,3 @@
+-- Remove job lineage
+DROP INDEX IF EXISTS idx_jobs_parent_job_id;
+ALTER TABLE jobs DROP COLUMN IF EXISTS parent_job_id;
@@ -0,0 +1,6 @@
+-- Link retried and cloned jobs to the job they were created from
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS parent_job_id UUID REFERENCES jobs(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_jobs_parent_job_id ON jobs(parent_job_id) WHERE parent_job_id IS NOT NULL;
+
+COMMENT ON COLUMN jobs.parent_job_id IS 'Job this one retries or clones, NULL for uploads';
@@ -0,0 +1,12 @@
+-- Drop trigger
+DROP TRIGGER IF EXISTS update_presets_updated_at ON presets;
+
+-- Drop indexes
+DROP INDEX IF EXISTS idx_jobs_preset_id;
+DROP INDEX IF EXISTS idx_presets_shared_name;
+
+-- Unlink jobs and drop presets tables
+ALTER TABLE jobs DROP COLUMN IF EXISTS preset_version;
+ALTER TABLE jobs DROP COLUMN IF EXISTS preset_id;
+DROP TABLE IF EXISTS preset_versions;
+DROP TABLE IF EXISTS presets;
@@ -0,0 +1,38 @@
+-- Create presets table for saved operation pipelines
+CREATE TABLE IF NOT EXISTS presets (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    shared BOOLEAN NOT NULL DEFAULT FALSE,
+    version INTEGER NOT NULL DEFAULT 1,
+    operations JSONB NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    UNIQUE (user_id, name)
+);
+
+-- Every version of each preset's operations, so jobs can pin one
+CREATE TABLE IF NOT EXISTS preset_versions (
+    preset_id UUID NOT NULL REFERENCES presets(id) ON DELETE CASCADE,
+    version INTEGER NOT NULL,
+    operations JSONB NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (preset_id, version)
+);
+
+-- Record which preset version a job was created from
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS preset_id UUID REFERENCES presets(id) ON DELETE SET NULL;
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS preset_version INTEGER;
+
+-- Create indexes
+CREATE INDEX IF NOT EXISTS idx_presets_shared_name ON presets(name, created_at) WHERE shared;
+CREATE INDEX IF NOT EXISTS idx_jobs_preset_id ON jobs(preset_id) WHERE preset_id IS NOT NULL;
+
+-- Create updated_at trigger for presets
+CREATE TRIGGER update_presets_updated_at
+    BEFORE UPDATE ON presets
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
+
+COMMENT ON COLUMN presets.version IS 'Current version, incremented whenever the operations change';
@@ -0,0 +1,4 @@
+-- Remove output size and quality columns
+ALTER TABLE jobs DROP COLUMN IF EXISTS psnr;
+ALTER TABLE jobs DROP COLUMN IF EXISTS ssim;
+ALTER TABLE jobs DROP COLUMN IF EXISTS output_size;
@@ -0,0 +1,9 @@
+-- Record the size of each job's output and, when measured, how closely it
+-- matches the original
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS output_size BIGINT;
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS ssim DOUBLE PRECISION;
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS psnr DOUBLE PRECISION;
+
+COMMENT ON COLUMN jobs.output_size IS 'Size of the processed image in bytes';
+COMMENT ON COLUMN jobs.ssim IS 'Structural similarity of the output to the resized original, NULL unless measured';
+COMMENT ON COLUMN jobs.psnr IS 'Peak signal-to-noise ratio of the output in dB, NULL unless measured';
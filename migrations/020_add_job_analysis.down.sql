@@ -0,0 +1,8 @@
+-- Drop indexes
+DROP INDEX IF EXISTS idx_jobs_user_id_dhash;
+DROP INDEX IF EXISTS idx_jobs_user_id_phash;
+
+-- Remove analysis columns
+ALTER TABLE jobs DROP COLUMN IF EXISTS dhash;
+ALTER TABLE jobs DROP COLUMN IF EXISTS phash;
+ALTER TABLE jobs DROP COLUMN IF EXISTS analysis;
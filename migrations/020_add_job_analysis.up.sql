@@ -0,0 +1,14 @@
+-- Results of analysis operations (hashes, palettes, blurhashes, histograms)
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS analysis JSONB;
+
+-- Perceptual and difference hashes as integers, so near-duplicates can be
+-- found by Hamming distance (bit_count(phash # $1))
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS phash BIGINT;
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS dhash BIGINT;
+
+CREATE INDEX IF NOT EXISTS idx_jobs_user_id_phash ON jobs(user_id, phash) WHERE phash IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_jobs_user_id_dhash ON jobs(user_id, dhash) WHERE dhash IS NOT NULL;
+
+COMMENT ON COLUMN jobs.analysis IS 'Results of the analysis operations, NULL without any';
+COMMENT ON COLUMN jobs.phash IS 'Perceptual hash of the image, as a signed 64-bit integer';
+COMMENT ON COLUMN jobs.dhash IS 'Difference hash of the image, as a signed 64-bit integer';
@@ -0,0 +1,2 @@
+-- Drop fonts table
+DROP TABLE IF EXISTS fonts;
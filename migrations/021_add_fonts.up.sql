@@ -0,0 +1,12 @@
+-- Create fonts table for user-uploaded fonts used by text operations
+CREATE TABLE IF NOT EXISTS fonts (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    storage_key VARCHAR(512) NOT NULL,
+    size BIGINT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    UNIQUE (user_id, name)
+);
+
+COMMENT ON COLUMN fonts.storage_key IS 'TrueType or OpenType file in object storage, deleted along with the row';
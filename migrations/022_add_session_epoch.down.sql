@@ -0,0 +1,2 @@
+-- Remove session epoch
+ALTER TABLE users DROP COLUMN IF EXISTS session_epoch;
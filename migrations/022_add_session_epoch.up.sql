@@ -0,0 +1,4 @@
+-- Track a per-user session epoch so sessions can be revoked
+ALTER TABLE users ADD COLUMN IF NOT EXISTS session_epoch INTEGER NOT NULL DEFAULT 0;
+
+COMMENT ON COLUMN users.session_epoch IS 'Incremented to revoke sessions, on password resets and when the user is disabled';
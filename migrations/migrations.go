@@ -0,0 +1,10 @@
+// Package migrations embeds the SQL schema migrations so they ship inside
+// the binaries that apply them.
+package migrations
+
+import "embed"
+
+// FS holds the NNN_name.up.sql and NNN_name.down.sql migration files
+//
+//go:embed *.sql
+var FS embed.FS
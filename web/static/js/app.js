@@ -83,6 +83,9 @@ function formatBytes(bytes) {
 // Operations Management
 let operations = [];
 
+// The preset the operations were loaded from, until they are edited
+let presetId = '';
+
 function initOperations() {
     updateOperationsInput();
 }
@@ -99,6 +102,7 @@ function addOperation() {
         parameters: params
     });
 
+    clearPreset();
     renderOperations();
     updateOperationsInput();
 }
@@ -112,6 +116,8 @@ function getOperationParams(operation) {
             const height = document.getElementById('param-height');
             if (width && width.value) params.width = parseInt(width.value);
             if (height && height.value) params.height = parseInt(height.value);
+            const linear = document.getElementById('param-linear');
+            if (linear && linear.checked) params.linear = true;
             break;
         case 'thumbnail':
             const size = document.getElementById('param-size');
@@ -136,6 +142,52 @@ function getOperationParams(operation) {
             const amount = document.getElementById('param-amount');
             if (amount && amount.value) params.amount = parseFloat(amount.value);
             break;
+        case 'color_profile':
+            const profileMode = document.getElementById('param-profile-mode');
+            if (profileMode && profileMode.value) params.mode = profileMode.value;
+            break;
+        case 'text':
+            const text = document.getElementById('param-text');
+            const textFont = document.getElementById('param-font');
+            const textSize = document.getElementById('param-text-size');
+            const textColor = document.getElementById('param-text-color');
+            const position = document.getElementById('param-position');
+            if (text && text.value) params.text = text.value;
+            if (textFont && textFont.value) params.font = textFont.value;
+            if (textSize && textSize.value) params.size = parseFloat(textSize.value);
+            if (textColor && textColor.value) params.color = textColor.value;
+            if (position && position.value) params.position = position.value;
+            break;
+        case 'pad':
+            const padding = document.getElementById('param-pad-all');
+            const padColor = document.getElementById('param-pad-color');
+            if (padding && padding.value) params.all = parseInt(padding.value);
+            if (padColor && padColor.value) params.color = padColor.value;
+            break;
+        case 'border':
+            const borderSize = document.getElementById('param-border-size');
+            const borderColor = document.getElementById('param-border-color');
+            if (borderSize && borderSize.value) params.size = parseInt(borderSize.value);
+            if (borderColor && borderColor.value) params.color = borderColor.value;
+            break;
+        case 'round_corners':
+            const radius = document.getElementById('param-radius');
+            const circle = document.getElementById('param-circle');
+            if (radius && radius.value) params.radius = parseInt(radius.value);
+            if (circle && circle.checked) params.circle = true;
+            break;
+        case 'background':
+            const background = document.getElementById('param-background-color');
+            if (background && background.value) params.color = background.value;
+            break;
+        case 'trim':
+            const tolerance = document.getElementById('param-tolerance');
+            if (tolerance && tolerance.value) params.tolerance = parseInt(tolerance.value);
+            break;
+        case 'palette':
+            const colors = document.getElementById('param-colors');
+            if (colors && colors.value) params.colors = parseInt(colors.value);
+            break;
     }
 
     return params;
@@ -143,6 +195,7 @@ function getOperationParams(operation) {
 
 function removeOperation(index) {
     operations.splice(index, 1);
+    clearPreset();
     renderOperations();
     updateOperationsInput();
 }
@@ -175,6 +228,80 @@ function updateOperationsInput() {
     if (input) {
         input.value = JSON.stringify(operations);
     }
+    const presetInput = document.getElementById('preset-input');
+    if (presetInput) {
+        presetInput.value = presetId;
+    }
+}
+
+// Presets
+function loadPreset(id) {
+    if (!id) {
+        clearPreset();
+        updateOperationsInput();
+        return;
+    }
+
+    fetch(`/api/v1/presets/${id}`)
+    .then(response => response.json())
+    .then(data => {
+        if (data.error) {
+            alert('Error: ' + data.error);
+            return;
+        }
+        operations = data.operations.map(op => ({
+            operation: op.operation,
+            parameters: Object.assign({}, op.parameters)
+        }));
+        presetId = data.id;
+        renderOperations();
+        updateOperationsInput();
+    })
+    .catch(error => {
+        alert('Error: ' + error.message);
+    });
+}
+
+// Editing the operations detaches them from the preset they came from
+function clearPreset() {
+    presetId = '';
+    const select = document.getElementById('preset-select');
+    if (select) {
+        select.value = '';
+    }
+}
+
+function savePreset() {
+    if (operations.length === 0) {
+        alert('Add operations before saving them as a preset');
+        return;
+    }
+
+    const name = prompt('Preset name');
+    if (!name) return;
+
+    fetch('/api/v1/presets', {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify({ name: name, operations: operations })
+    })
+    .then(response => response.json())
+    .then(data => {
+        if (data.error) {
+            alert('Error: ' + data.error);
+            return;
+        }
+        const select = document.getElementById('preset-select');
+        if (select) {
+            select.add(new Option(`${data.name} (v${data.version})`, data.id));
+            select.value = data.id;
+        }
+        presetId = data.id;
+        updateOperationsInput();
+    })
+    .catch(error => {
+        alert('Error: ' + error.message);
+    });
 }
 
 function showParams(operation) {
@@ -195,8 +322,14 @@ function submitJob(event) {
     const form = event.target;
     const formData = new FormData(form);
 
-    // Add operations
-    formData.set('operations', JSON.stringify(operations));
+    // Add operations, or the preset they were loaded from unchanged
+    if (presetId) {
+        formData.set('preset_id', presetId);
+        formData.delete('operations');
+    } else {
+        formData.set('operations', JSON.stringify(operations));
+        formData.delete('preset_id');
+    }
 
     const submitBtn = document.getElementById('submit-btn');
     submitBtn.disabled = true;
@@ -223,6 +356,26 @@ function submitJob(event) {
     });
 }
 
+// Retry a failed or canceled job; the retry is a new job with the same original
+function retryJob(jobId, button) {
+    button.disabled = true;
+
+    fetch(`/api/v1/jobs/${jobId}/retry`, { method: 'POST' })
+    .then(response => response.json())
+    .then(data => {
+        if (data.error) {
+            alert('Error: ' + data.error);
+            button.disabled = false;
+        } else {
+            window.location.href = '/jobs/' + data.id;
+        }
+    })
+    .catch(error => {
+        alert('Error: ' + error.message);
+        button.disabled = false;
+    });
+}
+
 // Streaming job status with Server-Sent Events
 function streamJobStatus(jobId) {
     const statusEl = document.getElementById('job-status');